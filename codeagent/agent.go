@@ -4,13 +4,16 @@
 package codeagent
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -28,9 +31,16 @@ const (
 	StatusKilled   AgentStatus = "killed"
 )
 
+// outputWaitDelay bounds how long Start waits for output pipes held open by
+// children of the agent process after it exits
+const outputWaitDelay = 5 * time.Second
+
 // CompletionCallback is called when an agent completes (successfully or with error)
 type CompletionCallback func(agent *Agent)
 
+// CompletionInfoCallback is called with an immutable snapshot of the agent when it completes
+type CompletionInfoCallback func(info AgentInfo)
+
 // Agent represents a code agent instance
 type Agent struct {
 	ID                 string
@@ -43,11 +53,44 @@ type Agent struct {
 	EndTime            time.Time
 	cmd                *exec.Cmd
 	mu                 sync.RWMutex
-	PlanFilename       string             // Custom plan filename (defaults to CURRENT_PLAN.md)
-	completionCallback CompletionCallback // Called when agent completes
-	PlanContent        string             // Content of CURRENT_PLAN.md (preserved on error)
-	cmdString          string             // The actual command string executed
-	hasMCPConfig       bool               // Whether MCP config was used
+	PlanFilename       string                 // Custom plan filename (defaults to CURRENT_PLAN.md)
+	callbacks          []CompletionCallback   // Called in registration order when agent completes
+	callbacksFired     bool                   // Set once callbacks have been taken for completion; later ones run immediately
+	PlanContent        string                 // Content of CURRENT_PLAN.md (preserved on error)
+	cmdString          string                 // The actual command string executed
+	hasMCPConfig       bool                   // Whether MCP config was used
+	Model              string                 // Claude model passed via --model (empty uses the CLI default)
+	lastActivity       time.Time              // Last time the process produced output
+	stale              bool                   // Set by the manager watchdog when no output was seen for too long
+	watchdogKilled     bool                   // Set when the watchdog or token budget terminated the agent
+	completionOnce     sync.Once              // Guards against firing the completion callback twice
+	structured         bool                   // Run claude with --output-format stream-json
+	parser             *ClaudeParser          // Parses stream-json events when structured output is enabled
+	infoCallback       CompletionInfoCallback // Called with an AgentInfo snapshot when agent completes
+	exitCode           int                    // Process exit code (-1 until the process has exited)
+	tokenLimit         int                    // Kill the agent once its session uses more tokens than this (0 disables)
+	liveOutput         cappedOutput           // Output captured so far while the process runs
+	liveOutputMu       sync.Mutex             // Guards liveOutput
+	done               chan struct{}          // Closed once the agent has completed
+	doneOnce           sync.Once              // Guards closing done
+	labels             map[string]string      // Labels attached at launch, e.g. kind=review
+	env                map[string]string      // Extra environment variables for the claude process
+	eventHook          agentEventHook         // Reports started and output events to the manager
+	resources          ResourceStats          // Latest CPU and memory sample while the agent runs
+}
+
+// AgentOptions holds optional settings for launching an agent
+type AgentOptions struct {
+	Model               string            // Claude model name, passed as --model when set
+	UseStructuredOutput bool              // Run claude in stream-json mode and parse events into the message history
+	Labels              map[string]string // Labels for filtering agents, e.g. {"kind": "review"}
+	Env                 map[string]string // Extra environment variables, set on top of the bot's environment; values are never logged
+	PlanFilename        string            // Plan file written in the folder, defaults to CURRENT_PLAN.md
+
+	// OnCreate is called with the agent right before it starts. For a queued
+	// launch that is when the task leaves the queue, so it is the place to
+	// register completion callbacks. RetryAgent does not carry it over.
+	OnCreate func(*Agent)
 }
 
 // NewAgent creates a new agent instance
@@ -58,6 +101,9 @@ func NewAgent(id, folder, prompt string) *Agent {
 		Prompt:       prompt,
 		Status:       StatusPending,
 		PlanFilename: "CURRENT_PLAN.md", // Default plan filename
+		exitCode:     -1,
+		done:         make(chan struct{}),
+		liveOutput:   cappedOutput{limit: GetMaxOutputSize()},
 	}
 }
 
@@ -69,14 +115,61 @@ func NewAgentWithPlanFile(id, folder, prompt, planFilename string) *Agent {
 		Prompt:       prompt,
 		Status:       StatusPending,
 		PlanFilename: planFilename,
+		exitCode:     -1,
+		done:         make(chan struct{}),
+		liveOutput:   cappedOutput{limit: GetMaxOutputSize()},
 	}
 }
 
-// SetCompletionCallback sets the callback to be called when the agent completes
+// SetCompletionCallback replaces all completion callbacks with callback.
+// Prefer AddCompletionCallback, which keeps callbacks registered by others.
 func (a *Agent) SetCompletionCallback(callback CompletionCallback) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.completionCallback = callback
+	a.callbacks = nil
+	if callback != nil {
+		a.callbacks = append(a.callbacks, callback)
+	}
+}
+
+// AddCompletionCallback registers a callback to be called when the agent completes.
+// Callbacks run in registration order; a panic in one does not stop the others.
+// If the agent has already completed, the callback runs right away.
+func (a *Agent) AddCompletionCallback(callback CompletionCallback) {
+	if callback == nil {
+		return
+	}
+	a.mu.Lock()
+	if !a.callbacksFired {
+		a.callbacks = append(a.callbacks, callback)
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+	log.Printf("[Agent] Agent %s already completed, calling completion callback now", a.ID)
+	a.runCallback(func() { callback(a) })
+}
+
+// SetCompletionCallbackInfo sets a callback that receives an AgentInfo snapshot when the agent completes.
+// It fires after the callbacks registered with AddCompletionCallback, on the same goroutine, and the
+// snapshot is taken before any callback runs so all observe the same final state.
+func (a *Agent) SetCompletionCallbackInfo(callback CompletionInfoCallback) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.infoCallback = callback
+}
+
+// failStart marks an agent that could not start as failed and fires its
+// completion, so waiters and the folder it holds are released
+func (a *Agent) failStart(err error) {
+	a.mu.Lock()
+	a.Status = StatusFailed
+	a.Error = fmt.Sprintf("Failed to start agent: %v\nWorking Directory: %s\nFailure Time: %s",
+		err, a.Folder, time.Now().Format("2006-01-02 15:04:05"))
+	a.EndTime = time.Now()
+	a.mu.Unlock()
+	log.Printf("[Agent] Agent %s failed to start: %v", a.ID, err)
+	a.fireCompletion()
 }
 
 // Start launches the agent
@@ -86,8 +179,15 @@ func (a *Agent) Start(ctx context.Context) error {
 	a.mu.Lock()
 	a.Status = StatusRunning
 	a.StartTime = time.Now()
+	a.lastActivity = a.StartTime
 	a.mu.Unlock()
 
+	// Make sure the configured claude binary is available before doing any work
+	if _, err := lookupClaudeBinary(); err != nil {
+		a.failStart(err)
+		return err
+	}
+
 	// Create plan file
 	planFile := fmt.Sprintf("%s/%s", a.Folder, a.PlanFilename)
 	planContent := `# Current Task Plan
@@ -102,7 +202,9 @@ func (a *Agent) Start(ctx context.Context) error {
 (The AI will update progress here as it works)
 `
 	if err := os.WriteFile(planFile, []byte(planContent), 0644); err != nil {
-		return fmt.Errorf("failed to create %s: %v", a.PlanFilename, err)
+		err = fmt.Errorf("failed to create %s: %v", a.PlanFilename, err)
+		a.failStart(err)
+		return err
 	}
 
 	// Defer cleanup of the plan file
@@ -118,19 +220,6 @@ func (a *Agent) Start(ctx context.Context) error {
 		os.Remove(planFile)
 	}()
 
-	// Modified prompt to instruct the AI to use the plan file
-	enhancedPrompt := fmt.Sprintf(`IMPORTANT: Before starting any work, you MUST:
-1. Read the file %s in the current directory
-2. Write your detailed plan for completing the task in the "## Plan" section
-3. As you work, update the "## Progress" section with what you've completed
-4. Keep the plan updated as you discover new requirements or change approach
-
-`, a.PlanFilename) + a.Prompt
-
-	// Use a shell to properly execute the claude script
-	// Escape single quotes in the prompt to prevent shell injection
-	escapedPrompt := strings.ReplaceAll(enhancedPrompt, "'", "'\"'\"'")
-	
 	// Check if .mcp.json exists in the working directory
 	mcpConfigPath := filepath.Join(a.Folder, ".mcp.json")
 	hasMCPConfig := false
@@ -159,37 +248,55 @@ func (a *Agent) Start(ctx context.Context) error {
 	// Store MCP config state
 	a.hasMCPConfig = hasMCPConfig
 	
-	// Add MCP hint to prompt if MCP config exists
-	if hasMCPConfig {
-		escapedPrompt = "IMPORTANT: MCP servers have been configured for this session. The available tools from MCP servers should be accessible to you.\n\n" + escapedPrompt
-	}
+	// Use a shell to properly execute the claude script
+	// Escape single quotes in the prompt to prevent shell injection
+	escapedPrompt := strings.ReplaceAll(enhancePrompt(a.Prompt, a.PlanFilename, hasMCPConfig), "'", "'\"'\"'")
 	
 	// Build the claude command with MCP config if present
-	cmdString := ""
+	cmdArgs := []string{shellQuote(GetClaudeBinary())}
+	for _, flag := range GetDefaultFlags() {
+		cmdArgs = append(cmdArgs, shellQuote(flag))
+	}
 	if hasMCPConfig {
-		cmdString = fmt.Sprintf("cd '%s' && claude --dangerously-skip-permissions --mcp-config .mcp.json -p '%s'", a.Folder, escapedPrompt)
-	} else {
-		cmdString = fmt.Sprintf("cd '%s' && claude --dangerously-skip-permissions -p '%s'", a.Folder, escapedPrompt)
+		cmdArgs = append(cmdArgs, "--mcp-config", ".mcp.json")
+	}
+	if a.Model != "" {
+		cmdArgs = append(cmdArgs, "--model", shellQuote(a.Model))
 	}
+	if a.structured {
+		// stream-json requires --verbose when combined with -p
+		cmdArgs = append(cmdArgs, "--output-format", "stream-json", "--verbose")
+	}
+	cmdString := fmt.Sprintf("cd '%s' && %s -p '%s'", a.Folder, strings.Join(cmdArgs, " "), escapedPrompt)
 	log.Printf("[Agent] Executing command: %s", cmdString)
+	a.mu.Lock()
 	a.cmdString = cmdString
 	a.cmd = exec.CommandContext(ctx, "/bin/sh", "-c", cmdString)
-	
-	// Ensure the command inherits the current environment
-	a.cmd.Env = os.Environ()
+	a.mu.Unlock()
 
-	// Set up pipes for streaming output
-	stdout, err := a.cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-	stderr, err := a.cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
+	// Run in a process group so killing the agent also kills claude, not just the shell
+	cmd := a.cmd
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process) }
+	
+	// Ensure the command inherits the current environment, with the agent's
+	// own variables taking precedence
+	a.cmd.Env = append(os.Environ(), a.envList()...)
+
+	// Set up pipes for streaming output. Wait copies everything the process
+	// wrote into these before returning, so no output is lost at exit;
+	// WaitDelay stops it waiting on children that outlive a killed shell.
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	a.cmd.Stdout = stdoutWriter
+	a.cmd.Stderr = stderrWriter
+	a.cmd.WaitDelay = outputWaitDelay
 
 	// Start the command
-	if err := a.cmd.Start(); err != nil {
+	a.mu.Lock()
+	err := a.cmd.Start()
+	a.mu.Unlock()
+	if err != nil {
 		a.mu.Lock()
 		a.Status = StatusFailed
 		
@@ -216,12 +323,15 @@ func (a *Agent) Start(ctx context.Context) error {
 		a.Error = errorBuilder.String()
 		a.EndTime = time.Now()
 		a.mu.Unlock()
+		a.fireCompletion()
 		return err
 	}
+	a.emitEvent(EventStarted, "")
+	go a.sampleResources(cmd.Process.Pid)
 
-	// Capture output in a thread-safe way
-	var outputBuilder strings.Builder
-	var outputMu sync.Mutex
+	// Capture output in a thread-safe way; kept on the agent so it can be read while running
+	outputBuilder := &a.liveOutput
+	outputMu := &a.liveOutputMu
 
 	// Create a wait group for the output readers
 	var wg sync.WaitGroup
@@ -230,6 +340,10 @@ func (a *Agent) Start(ctx context.Context) error {
 	// Read stdout
 	go func() {
 		defer wg.Done()
+		if a.structured {
+			a.readStructuredOutput(stdout, outputBuilder, outputMu)
+			return
+		}
 		buf := make([]byte, 4096)
 		for {
 			n, err := stdout.Read(buf)
@@ -237,6 +351,7 @@ func (a *Agent) Start(ctx context.Context) error {
 				outputMu.Lock()
 				outputBuilder.Write(buf[:n])
 				outputMu.Unlock()
+				a.touchActivity(string(buf[:n]))
 			}
 			if err != nil {
 				break
@@ -254,6 +369,7 @@ func (a *Agent) Start(ctx context.Context) error {
 				outputMu.Lock()
 				outputBuilder.Write(buf[:n])
 				outputMu.Unlock()
+				a.touchActivity(string(buf[:n]))
 			}
 			if err != nil {
 				break
@@ -263,6 +379,8 @@ func (a *Agent) Start(ctx context.Context) error {
 
 	// Wait for the command to complete
 	cmdErr := a.cmd.Wait()
+	stdoutWriter.Close()
+	stderrWriter.Close()
 
 	// Wait for all output to be read
 	wg.Wait()
@@ -274,10 +392,27 @@ func (a *Agent) Start(ctx context.Context) error {
 
 	a.mu.Lock()
 	a.Output = output
+	if a.structured {
+		// Prefer the final result reported by the CLI over the raw event stream
+		if result := a.parser.GetFinalResult(); result != "" {
+			a.Output = result
+		}
+	}
+	if a.watchdogKilled {
+		// The watchdog already finalised this agent
+		a.mu.Unlock()
+		return nil
+	}
 	a.EndTime = time.Now()
+	if a.cmd.ProcessState != nil {
+		a.exitCode = a.cmd.ProcessState.ExitCode()
+	}
 
 	if cmdErr != nil {
-		a.Status = StatusFailed
+		// Keep the killed status set by Kill
+		if a.Status != StatusKilled {
+			a.Status = StatusFailed
+		}
 		// Create a detailed error message including command information and output
 		var errorBuilder strings.Builder
 		errorBuilder.WriteString(fmt.Sprintf("Command failed: %v", cmdErr))
@@ -383,15 +518,331 @@ func (a *Agent) Start(ctx context.Context) error {
 
 	log.Printf("[Agent] Agent %s completed with status %s, waiting for monitor to detect", a.ID, a.Status)
 
-	// Call completion callback if set
-	if a.completionCallback != nil {
-		log.Printf("[Agent] Calling completion callback for agent %s", a.ID)
-		a.completionCallback(a)
-	}
+	a.fireCompletion()
 
 	return nil
 }
 
+// readStructuredOutput reads stream-json events line by line, keeping the raw text in the output builder.
+// Lines longer than the output cap are kept in the output but not parsed, so
+// a runaway line cannot be buffered whole.
+func (a *Agent) readStructuredOutput(r io.Reader, outputBuilder *cappedOutput, outputMu *sync.Mutex) {
+	reader := bufio.NewReader(r)
+	maxLine := outputBuilder.limit
+	var line []byte
+	oversized := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			outputMu.Lock()
+			outputBuilder.Write(chunk)
+			outputMu.Unlock()
+			a.touchActivity(string(chunk))
+			if maxLine > 0 && len(line)+len(chunk) > maxLine {
+				oversized = true
+				line = nil
+			} else if !oversized {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue // The rest of the line follows
+		}
+		if oversized {
+			log.Printf("[Agent] Agent %s: skipped a stream-json line over %d bytes", a.ID, maxLine)
+		} else if len(line) > 0 {
+			if parseErr := a.parser.ParseStreamJSONLine(string(line)); parseErr != nil {
+				log.Printf("[Agent] Agent %s: %v", a.ID, parseErr)
+			}
+			a.checkTokenLimit()
+		}
+		line, oversized = line[:0], false
+		if err != nil {
+			break
+		}
+	}
+}
+
+// SetStructuredOutput enables stream-json mode; must be called before Start
+func (a *Agent) SetStructuredOutput(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.structured = enabled
+	if enabled && a.parser == nil {
+		a.parser = NewClaudeParser()
+		// The final result and token usage are kept apart from the history,
+		// so only the most recent messages need to stay in memory
+		a.parser.SetHistoryLimit(a.liveOutput.limit)
+	}
+}
+
+// outputText returns the agent's final output, or the output captured so far
+// while it is still running, with environment variable values redacted
+func (a *Agent) outputText() string {
+	a.mu.RLock()
+	output := a.Output
+	a.mu.RUnlock()
+
+	if output == "" {
+		a.liveOutputMu.Lock()
+		output = a.liveOutput.String()
+		a.liveOutputMu.Unlock()
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return redactEnv(output, a.env)
+}
+
+// OutputTruncated reports whether the agent printed more than its output cap,
+// so only the head and tail of its output were kept
+func (a *Agent) OutputTruncated() bool {
+	a.liveOutputMu.Lock()
+	defer a.liveOutputMu.Unlock()
+	return a.liveOutput.Truncated()
+}
+
+// GetOutputTail returns the last n lines of output. While the agent is running
+// this is the output captured so far.
+func (a *Agent) GetOutputTail(n int) []string {
+	output := strings.TrimRight(a.outputText(), "\n")
+	if output == "" || n <= 0 {
+		return nil
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// SetTokenLimit kills the agent once its session token usage exceeds limit; 0 disables
+func (a *Agent) SetTokenLimit(limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokenLimit = limit
+}
+
+// checkTokenLimit kills the agent if it has used more tokens than allowed
+func (a *Agent) checkTokenLimit() {
+	a.mu.RLock()
+	limit := a.tokenLimit
+	a.mu.RUnlock()
+	if limit <= 0 {
+		return
+	}
+
+	used := a.GetTokenUsage().Total
+	if used <= limit {
+		return
+	}
+	reason := fmt.Sprintf("Agent killed: token budget exceeded (%d tokens used, limit %d)", used, limit)
+	if a.killWithError(reason) {
+		log.Printf("[Agent] Agent %s killed after using %d tokens (limit %d)", a.ID, used, limit)
+	}
+}
+
+// GetMessageHistory returns the parsed conversation when structured output is enabled
+func (a *Agent) GetMessageHistory() []Message {
+	a.mu.RLock()
+	parser := a.parser
+	a.mu.RUnlock()
+	if parser == nil {
+		return nil
+	}
+	return parser.GetHistory()
+}
+
+// GetToolInvocations returns the tool calls reported in structured output mode
+func (a *Agent) GetToolInvocations() []ToolInvocation {
+	a.mu.RLock()
+	parser := a.parser
+	a.mu.RUnlock()
+	if parser == nil {
+		return nil
+	}
+	return parser.GetToolInvocations()
+}
+
+// GetLastTokenStatus returns the latest token usage reported in structured output mode
+func (a *Agent) GetLastTokenStatus() string {
+	a.mu.RLock()
+	parser := a.parser
+	a.mu.RUnlock()
+	if parser == nil {
+		return ""
+	}
+	return parser.GetLastTokenStatus()
+}
+
+// HasTokenData reports whether the agent reports token usage, which requires structured output mode
+func (a *Agent) HasTokenData() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.parser != nil
+}
+
+// GetTokenUsage returns the session token usage reported in structured output mode
+func (a *Agent) GetTokenUsage() TokenUsage {
+	a.mu.RLock()
+	parser := a.parser
+	a.mu.RUnlock()
+	if parser == nil {
+		return TokenUsage{}
+	}
+	return parser.GetTokenUsage()
+}
+
+// GetTokenStats returns the latest token counters and peaks reported in structured output mode
+func (a *Agent) GetTokenStats() TokenStats {
+	a.mu.RLock()
+	parser := a.parser
+	a.mu.RUnlock()
+	if parser == nil {
+		return TokenStats{}
+	}
+	return parser.GetTokenStats()
+}
+
+// fireCompletion calls the completion callbacks at most once
+func (a *Agent) fireCompletion() {
+	a.completionOnce.Do(func() {
+		info := a.ToInfo()
+		a.mu.Lock()
+		callbacks := append([]CompletionCallback(nil), a.callbacks...)
+		infoCallback := a.infoCallback
+		a.callbacksFired = true
+		a.mu.Unlock()
+		for i, callback := range callbacks {
+			log.Printf("[Agent] Calling completion callback %d/%d for agent %s", i+1, len(callbacks), a.ID)
+			a.runCallback(func() { callback(a) })
+		}
+		if infoCallback != nil {
+			a.runCallback(func() { infoCallback(info) })
+		}
+		a.markDone()
+	})
+}
+
+// runCallback runs a completion callback, recovering from panics so the
+// remaining callbacks still run
+func (a *Agent) runCallback(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Agent] [ERROR] Completion callback for agent %s panicked: %v", a.ID, r)
+		}
+	}()
+	fn()
+}
+
+// Done returns a channel that is closed once the agent has completed and its
+// completion callbacks have run, or once it has been marked as failed
+func (a *Agent) Done() <-chan struct{} {
+	return a.done
+}
+
+// markDone closes the done channel at most once
+func (a *Agent) markDone() {
+	a.doneOnce.Do(func() {
+		close(a.done)
+	})
+}
+
+// GetExitCode returns the process exit code, or -1 if the process has not exited normally
+func (a *Agent) GetExitCode() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.exitCode
+}
+
+// touchActivity records that the agent produced output
+func (a *Agent) touchActivity(output string) {
+	a.mu.Lock()
+	a.lastActivity = time.Now()
+	a.mu.Unlock()
+	a.emitEvent(EventOutput, output)
+}
+
+// setEventHook sets the function the agent reports its events to
+func (a *Agent) setEventHook(hook agentEventHook) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.eventHook = hook
+}
+
+// emitEvent reports an event to the event hook, if any
+func (a *Agent) emitEvent(eventType AgentEventType, output string) {
+	a.mu.RLock()
+	hook := a.eventHook
+	a.mu.RUnlock()
+	if hook != nil {
+		hook(eventType, output)
+	}
+}
+
+// GetLastActivity returns the last time the agent produced output
+func (a *Agent) GetLastActivity() time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastActivity
+}
+
+// IsStale reports whether the watchdog flagged the agent as stuck
+func (a *Agent) IsStale() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.stale
+}
+
+// markStale flags the agent as stale; returns false if it was already flagged or is no longer running
+func (a *Agent) markStale() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stale || a.Status != StatusRunning {
+		return false
+	}
+	a.stale = true
+	return true
+}
+
+// killStale terminates a stale agent and fires the completion callback so queued work can continue
+func (a *Agent) killStale(timeout time.Duration) {
+	a.mu.RLock()
+	reason := fmt.Sprintf("Agent killed by watchdog: no output for %s\nLast Activity: %s\nWorking Directory: %s",
+		timeout, a.lastActivity.Format("2006-01-02 15:04:05"), a.Folder)
+	a.mu.RUnlock()
+
+	if a.killWithError(reason) {
+		a.mu.Lock()
+		a.stale = true
+		a.mu.Unlock()
+		log.Printf("[Agent] Agent %s killed by watchdog after %s without output", a.ID, timeout)
+	}
+}
+
+// killWithError terminates a running agent on behalf of a monitor and records
+// the reason. It returns false if the agent was not running.
+func (a *Agent) killWithError(reason string) bool {
+	a.mu.Lock()
+	if a.Status != StatusRunning {
+		a.mu.Unlock()
+		return false
+	}
+	if a.cmd != nil && a.cmd.Process != nil {
+		if err := killProcessGroup(a.cmd.Process); err != nil {
+			log.Printf("[Agent] Failed to kill agent %s: %v", a.ID, err)
+		}
+	}
+	a.Status = StatusKilled
+	a.watchdogKilled = true
+	a.EndTime = time.Now()
+	a.Error = reason
+	a.mu.Unlock()
+
+	a.fireCompletion()
+	return true
+}
+
 // StartAsync launches the agent asynchronously
 func (a *Agent) StartAsync(ctx context.Context) {
 	go func() {
@@ -409,7 +860,7 @@ func (a *Agent) Kill() error {
 	}
 
 	if a.cmd != nil && a.cmd.Process != nil {
-		if err := a.cmd.Process.Kill(); err != nil {
+		if err := killProcessGroup(a.cmd.Process); err != nil {
 			return err
 		}
 		a.Status = StatusKilled
@@ -419,6 +870,14 @@ func (a *Agent) Kill() error {
 	return nil
 }
 
+// killProcessGroup kills the process and everything it spawned
+func killProcessGroup(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
 // GetStatus returns the current status of the agent
 func (a *Agent) GetStatus() AgentStatus {
 	a.mu.RLock()
@@ -458,6 +917,8 @@ func (a *Agent) GetDuration() time.Duration {
 
 // ToInfo returns a snapshot of the agent's current state
 func (a *Agent) ToInfo() AgentInfo {
+	truncated := a.OutputTruncated()
+
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
@@ -472,17 +933,101 @@ func (a *Agent) ToInfo() AgentInfo {
 	}
 
 	return AgentInfo{
-		ID:          a.ID,
-		Folder:      a.Folder,
-		Prompt:      a.Prompt,
-		Status:      a.Status,
-		Output:      a.Output,
-		Error:       a.Error,
-		StartTime:   a.StartTime,
-		EndTime:     a.EndTime,
-		Duration:    duration,
-		PlanContent: a.PlanContent,
+		ID:              a.ID,
+		Folder:          a.Folder,
+		Prompt:          a.Prompt,
+		Status:          a.Status,
+		Output:          redactEnv(a.Output, a.env),
+		Error:           redactEnv(a.Error, a.env),
+		StartTime:       a.StartTime,
+		EndTime:         a.EndTime,
+		Duration:        duration,
+		PlanContent:     a.PlanContent,
+		Model:           a.Model,
+		IsStale:         a.stale,
+		LastActivity:    a.lastActivity,
+		ExitCode:        a.exitCode,
+		Labels:          copyStringMap(a.labels),
+		EnvKeys:         sortedMapKeys(a.env),
+		OutputTruncated: truncated,
+	}
+}
+
+// SetLabels replaces the agent's labels with a copy of labels
+func (a *Agent) SetLabels(labels map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.labels = copyStringMap(labels)
+}
+
+// launchOptions returns the options the agent was launched with
+func (a *Agent) launchOptions() AgentOptions {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return AgentOptions{
+		Model:               a.Model,
+		UseStructuredOutput: a.structured,
+		Labels:              copyStringMap(a.labels),
+		Env:                 copyStringMap(a.env),
+		PlanFilename:        a.PlanFilename,
+	}
+}
+
+// SetEnv replaces the agent's extra environment variables with a copy of env.
+// It must be called before Start.
+func (a *Agent) SetEnv(env map[string]string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.env = copyStringMap(env)
+	if len(env) > 0 {
+		log.Printf("[Agent] Agent %s has extra environment variables: %s", a.ID, strings.Join(sortedMapKeys(env), ", "))
+	}
+}
+
+// envList returns the agent's extra environment variables as KEY=VALUE pairs
+func (a *Agent) envList() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	list := make([]string, 0, len(a.env))
+	for _, key := range sortedMapKeys(a.env) {
+		list = append(list, key+"="+a.env[key])
+	}
+	return list
+}
+
+// redactEnv replaces the values of env in s so they can be shown to users
+func redactEnv(s string, env map[string]string) string {
+	for _, value := range env {
+		if value != "" {
+			s = strings.ReplaceAll(s, value, "***")
+		}
+	}
+	return s
+}
+
+// sortedMapKeys returns the keys of m in sorted order, or nil if m is empty
+func sortedMapKeys(m map[string]string) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyStringMap returns a copy of m, or nil if it is empty
+func copyStringMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
 	}
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
 }
 
 // IsProcessAlive checks if the agent's process is still running
@@ -509,6 +1054,7 @@ func (a *Agent) MarkAsFailed(errorMsg string) {
 	if a.EndTime.IsZero() {
 		a.EndTime = time.Now()
 	}
+	a.markDone()
 }
 
 // MarkAsFailedWithDetails marks the agent as failed and includes available process output and error details
@@ -583,20 +1129,56 @@ func (a *Agent) MarkAsFailedWithDetails(errorMsg string) {
 	detailedError.WriteString(fmt.Sprintf("\nFailure Time: %s", time.Now().Format("2006-01-02 15:04:05")))
 
 	a.Error = detailedError.String()
+	a.markDone()
 }
 
 // AgentInfo is a snapshot of an agent's state
 type AgentInfo struct {
-	ID          string
-	Folder      string
-	Prompt      string
-	Status      AgentStatus
-	Output      string
-	Error       string
-	StartTime   time.Time
-	EndTime     time.Time
-	Duration    time.Duration
-	PlanContent string // Content of CURRENT_PLAN.md (preserved on error)
+	ID              string
+	Folder          string
+	Prompt          string
+	Status          AgentStatus
+	Output          string
+	Error           string
+	StartTime       time.Time
+	EndTime         time.Time
+	Duration        time.Duration
+	PlanContent     string            // Content of CURRENT_PLAN.md (preserved on error)
+	Model           string            // Claude model requested for this agent
+	IsStale         bool              // Set when the watchdog saw no output for longer than the stale timeout
+	LastActivity    time.Time         // Last time the agent produced output
+	ExitCode        int               // Process exit code (-1 if still running or killed by a signal)
+	Labels          map[string]string // Labels attached at launch
+	EnvKeys         []string          // Names of extra environment variables; values are never exposed
+	OutputTruncated bool              // Set when output exceeded the size cap and its middle was dropped
+}
+
+// enhancePrompt wraps the task prompt with the plan file instructions and,
+// when MCP servers are configured, a hint that their tools are available
+func enhancePrompt(prompt, planFilename string, hasMCPConfig bool) string {
+	enhancedPrompt := fmt.Sprintf(`IMPORTANT: Before starting any work, you MUST:
+1. Read the file %s in the current directory
+2. Write your detailed plan for completing the task in the "## Plan" section
+3. As you work, update the "## Progress" section with what you've completed
+4. Keep the plan updated as you discover new requirements or change approach
+
+`, planFilename) + prompt
+
+	if hasMCPConfig {
+		enhancedPrompt = "IMPORTANT: MCP servers have been configured for this session. The available tools from MCP servers should be accessible to you.\n\n" + enhancedPrompt
+	}
+	return enhancedPrompt
+}
+
+// PreviewPrompt returns the full prompt an agent launched in folder with the
+// given task prompt would send to claude, without launching anything. An
+// empty planFilename uses the default plan file.
+func PreviewPrompt(folder, prompt, planFilename string) string {
+	if planFilename == "" {
+		planFilename = "CURRENT_PLAN.md"
+	}
+	_, err := os.Stat(filepath.Join(folder, ".mcp.json"))
+	return enhancePrompt(prompt, planFilename, err == nil)
 }
 
 // GetCommandString returns the command string that was executed
@@ -2,6 +2,9 @@ package codeagent
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -148,3 +151,215 @@ func TestManagerAutoID(t *testing.T) {
 		t.Errorf("Failed to get agent 2: %v", err)
 	}
 }
+
+func TestCompletionCallbackInfo(t *testing.T) {
+	SetClaudeBinary("mavis-nonexistent-claude-binary")
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("info-callback", t.TempDir(), "test")
+
+	var order []string
+	var snapshot AgentInfo
+	agent.SetCompletionCallback(func(a *Agent) {
+		order = append(order, "agent")
+	})
+	agent.SetCompletionCallbackInfo(func(info AgentInfo) {
+		order = append(order, "info")
+		snapshot = info
+	})
+
+	_ = agent.Start(context.Background())
+
+	if len(order) != 2 || order[0] != "agent" || order[1] != "info" {
+		t.Fatalf("Expected callbacks to fire in order [agent info], got %v", order)
+	}
+	if snapshot.ID != "info-callback" {
+		t.Errorf("Expected snapshot ID info-callback, got %s", snapshot.ID)
+	}
+	if snapshot.Status != StatusFailed {
+		t.Errorf("Expected snapshot status failed, got %s", snapshot.Status)
+	}
+	if snapshot.ExitCode != -1 {
+		t.Errorf("Expected exit code -1 for a process that never started, got %d", snapshot.ExitCode)
+	}
+	if snapshot.EndTime.IsZero() {
+		t.Error("Expected snapshot EndTime to be set")
+	}
+}
+
+func TestAddCompletionCallback(t *testing.T) {
+	SetClaudeBinary("mavis-nonexistent-claude-binary")
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("multi-callback", t.TempDir(), "test")
+
+	var order []string
+	agent.SetCompletionCallback(func(a *Agent) {
+		order = append(order, "replaced")
+	})
+	agent.SetCompletionCallback(func(a *Agent) {
+		order = append(order, "first")
+	})
+	agent.AddCompletionCallback(func(a *Agent) {
+		panic("callback failure")
+	})
+	agent.AddCompletionCallback(func(a *Agent) {
+		order = append(order, "third")
+	})
+	agent.SetCompletionCallbackInfo(func(info AgentInfo) {
+		order = append(order, "info")
+	})
+
+	_ = agent.Start(context.Background())
+
+	if len(order) != 3 || order[0] != "first" || order[1] != "third" || order[2] != "info" {
+		t.Fatalf("Expected callbacks [first third info], got %v", order)
+	}
+	select {
+	case <-agent.Done():
+	default:
+		t.Error("Expected agent to be done after a callback panicked")
+	}
+}
+
+func TestCompletionCallbackInfoExitCode(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-claude")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho fake output\nexit 3\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("exit-code", dir, "test")
+	infos := make(chan AgentInfo, 1)
+	agent.SetCompletionCallbackInfo(func(info AgentInfo) {
+		infos <- info
+	})
+
+	_ = agent.Start(context.Background())
+
+	select {
+	case info := <-infos:
+		if info.ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", info.ExitCode)
+		}
+		if info.Status != StatusFailed {
+			t.Errorf("Expected status failed, got %s", info.Status)
+		}
+		if info.Duration <= 0 {
+			t.Errorf("Expected positive duration, got %s", info.Duration)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Completion info callback was not called")
+	}
+}
+
+func TestGetOutputTail(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-claude")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nfor i in 1 2 3 4 5; do echo line $i; done\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("tail", dir, "test")
+	if tail := agent.GetOutputTail(3); len(tail) != 0 {
+		t.Errorf("Expected no output before start, got %v", tail)
+	}
+
+	_ = agent.Start(context.Background())
+
+	tail := agent.GetOutputTail(2)
+	if len(tail) != 2 || tail[0] != "line 4" || tail[1] != "line 5" {
+		t.Errorf("Expected last 2 lines, got %v", tail)
+	}
+	if tail := agent.GetOutputTail(10); len(tail) != 5 {
+		t.Errorf("Expected all 5 lines, got %d", len(tail))
+	}
+}
+
+func TestAgentEnv(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-claude")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"key=$MAVIS_TEST_KEY mode=$MAVIS_TEST_MODE\"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+	t.Setenv("MAVIS_TEST_MODE", "bot")
+
+	agent := NewAgent("env", dir, "test")
+	agent.SetEnv(map[string]string{"MAVIS_TEST_KEY": "s3cret", "MAVIS_TEST_MODE": "agent"})
+	_ = agent.Start(context.Background())
+
+	info := agent.ToInfo()
+	if strings.Contains(info.Output, "s3cret") {
+		t.Errorf("Expected env value to be redacted, got %q", info.Output)
+	}
+	if !strings.Contains(info.Output, "key=*** mode=***") {
+		t.Errorf("Expected agent env to override the bot's environment, got %q", info.Output)
+	}
+	if strings.Join(info.EnvKeys, ",") != "MAVIS_TEST_KEY,MAVIS_TEST_MODE" {
+		t.Errorf("Expected sorted env keys, got %v", info.EnvKeys)
+	}
+	if tail := agent.GetOutputTail(1); len(tail) != 1 || strings.Contains(tail[0], "s3cret") {
+		t.Errorf("Expected redacted output tail, got %v", tail)
+	}
+}
+
+func TestPreviewPrompt(t *testing.T) {
+	dir := t.TempDir()
+
+	prompt := PreviewPrompt(dir, "fix the bug", "")
+	if !strings.Contains(prompt, "Read the file CURRENT_PLAN.md") || !strings.HasSuffix(prompt, "fix the bug") {
+		t.Errorf("Expected plan instructions followed by the task, got %q", prompt)
+	}
+	if strings.Contains(prompt, "MCP servers") {
+		t.Errorf("Expected no MCP hint without .mcp.json, got %q", prompt)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".mcp.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write .mcp.json: %v", err)
+	}
+	prompt = PreviewPrompt(dir, "fix the bug", "REVIEW_PLAN_1.md")
+	if !strings.HasPrefix(prompt, "IMPORTANT: MCP servers") || !strings.Contains(prompt, "Read the file REVIEW_PLAN_1.md") {
+		t.Errorf("Expected MCP hint and custom plan file, got %q", prompt)
+	}
+}
+
+func TestAddCompletionCallbackAfterCompletion(t *testing.T) {
+	agent := NewAgent("late", t.TempDir(), "test")
+	agent.fireCompletion()
+
+	called := false
+	agent.AddCompletionCallback(func(a *Agent) { called = true })
+	if !called {
+		t.Error("Expected a callback added after completion to run immediately")
+	}
+}
+
+func TestStartFailsWhenPlanFileCannotBeWritten(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "fake-claude")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho done\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("noplan", filepath.Join(t.TempDir(), "missing"), "test")
+	if err := agent.Start(context.Background()); err == nil {
+		t.Fatal("Expected Start to fail when the plan file cannot be written")
+	}
+
+	select {
+	case <-agent.Done():
+	default:
+		t.Fatal("Expected Done to be closed after a failed start")
+	}
+	info := agent.ToInfo()
+	if info.Status != StatusFailed || info.EndTime.IsZero() || !strings.Contains(info.Error, "CURRENT_PLAN.md") {
+		t.Errorf("Expected a failed agent with an end time and error, got %+v", info)
+	}
+}
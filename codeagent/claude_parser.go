@@ -1,6 +1,8 @@
 package codeagent
 
 import (
+	"encoding/csv"
+	"log"
 	"regexp"
 	"strings"
 	"sync"
@@ -18,10 +20,39 @@ type Message struct {
 	Metadata  map[string]string
 }
 
-// ConversationHistory maintains the full history of messages
+// ConversationHistory maintains the history of messages
 type ConversationHistory struct {
 	Messages []Message
 	mu       sync.RWMutex
+	limit    int // Bytes of message text kept, oldest messages are dropped beyond it (0 keeps everything)
+	size     int // Bytes of message text currently kept
+}
+
+// appendLocked adds a message and drops the oldest ones once over the limit.
+// The newest message is always kept. Callers hold mu.
+func (h *ConversationHistory) appendLocked(message Message) {
+	h.Messages = append(h.Messages, message)
+	h.size += messageSize(message)
+	h.trimLocked()
+}
+
+// trimLocked drops the oldest messages until the history fits its limit.
+// Callers hold mu.
+func (h *ConversationHistory) trimLocked() {
+	for h.limit > 0 && h.size > h.limit && len(h.Messages) > 1 {
+		h.size -= messageSize(h.Messages[0])
+		h.Messages[0] = Message{} // Release the dropped text
+		h.Messages = h.Messages[1:]
+	}
+}
+
+// messageSize is the number of bytes of text a message holds
+func messageSize(message Message) int {
+	size := len(message.Content)
+	for _, value := range message.Metadata {
+		size += len(value)
+	}
+	return size
 }
 
 // ClaudeParser handles parsing of Claude Code CLI output
@@ -34,13 +65,62 @@ type ClaudeParser struct {
 	toolPattern     *regexp.Regexp
 	ansiPattern     *regexp.Regexp
 	uiPatterns      []*regexp.Regexp
+	matchers        []LineMatcher // Custom matchers, tried before rules
+	rules           []lineRule    // Built-in parsing steps
 	inToolOutput    bool
 	toolDepth       int
+	finalResult     string     // Result text reported by a stream-json session
+	mu              sync.Mutex // Guards state updated by the stream-json parser
+
+	// Tool call pairing state
+	pendingTool     *ToolInvocation // Tool call waiting for its result line
+	pendingToolLine string          // Start line of the pending tool call
+	lastToolLine    string          // Start line of the last recorded tool call
+	lastToolID      string          // Message ID of the last recorded tool call
+	replayingTool   bool            // Skipping a tool block the CLI redrew
+
+	// Token usage, guarded by mu
+	sessionUsage TokenUsage // Usage from completed turns or API calls
+	turnUsage    TokenUsage // Latest counter shown for the current turn
+	tokenStats   TokenStats // Latest counters and session peaks
+}
+
+var (
+	// toolStartPattern matches tool calls such as "⏺ Read(main.go)"; the closing
+	// parenthesis may still be missing while the line is streaming
+	toolStartPattern = regexp.MustCompile(`^⏺\s+([A-Za-z][\w.:-]*)\((.*)$`)
+	// runningPattern matches "⏺ Running <command>" lines
+	runningPattern = regexp.MustCompile(`^⏺\s+Running\s+(.+?)[.…]*$`)
+)
+
+// Metadata keys recorded on tool messages
+const (
+	MetaToolName   = "tool_name"
+	MetaToolInput  = "tool_input"
+	MetaToolResult = "tool_result"
+	MetaToolStatus = "tool_status"
+)
+
+// Tool invocation statuses
+const (
+	ToolStatusPending = "pending"
+	ToolStatusSuccess = "success"
+	ToolStatusError   = "error"
+)
+
+// ToolInvocation is a tool call paired with its result
+type ToolInvocation struct {
+	MessageID string
+	Name      string // e.g. "Read", "Edit", "Bash"
+	Input     string // e.g. the file path passed to Read
+	Result    string // Text of the result line, empty while pending
+	Status    string // ToolStatusPending, ToolStatusSuccess or ToolStatusError
+	Timestamp time.Time
 }
 
 // NewClaudeParser creates a new parser instance
 func NewClaudeParser() *ClaudeParser {
-	return &ClaudeParser{
+	p := &ClaudeParser{
 		history: &ConversationHistory{
 			Messages: make([]Message, 0),
 		},
@@ -57,6 +137,27 @@ func NewClaudeParser() *ClaudeParser {
 			regexp.MustCompile(`Auto-update failed`),
 		},
 	}
+	p.rules = p.defaultRules()
+	return p
+}
+
+// LineMatcher recognizes a line of CLI output. When it returns true the line
+// is consumed: the message being built is flushed and the returned message is
+// added to the history. Returning true with an empty Type and Content drops
+// the line without recording anything.
+type LineMatcher func(line string) (Message, bool)
+
+// lineRule is a built-in parsing step; it reports whether it consumed the line
+type lineRule func(trimmed, cleanLine string) bool
+
+// AddMatcher registers a custom matcher. Custom matchers receive each
+// non-empty line with ANSI sequences and surrounding whitespace removed, and
+// run in registration order before the built-in rules. Register matchers
+// before parsing starts.
+func (p *ClaudeParser) AddMatcher(matcher LineMatcher) {
+	if matcher != nil {
+		p.matchers = append(p.matchers, matcher)
+	}
 }
 
 // ParseLine processes a single line of output
@@ -74,38 +175,75 @@ func (p *ClaudeParser) ParseLine(line string) {
 		return
 	}
 	
-	// Check for token status update FIRST
-	if p.tokenPattern.MatchString(trimmed) {
-		p.lastTokenStatus = trimmed
-		// If we have a pending assistant message, flush it before updating token status
-		if p.currentType == "assistant" && p.currentMessage.Len() > 0 {
-			p.flushCurrentMessage()
+	// Custom matchers take precedence over the built-in rules
+	for _, matcher := range p.matchers {
+		if message, ok := matcher(trimmed); ok {
+			p.addMatchedMessage(message)
+			return
 		}
-		return // Don't add to message history
 	}
 	
+	for _, rule := range p.rules {
+		if rule(trimmed, cleanLine) {
+			return
+		}
+	}
+}
+
+// defaultRules returns the built-in parsing steps in the order they are tried
+func (p *ClaudeParser) defaultRules() []lineRule {
+	return []lineRule{
+		p.matchTokenStatus,
+		p.skipUILine,
+		func(trimmed, _ string) bool { return p.handleToolLine(trimmed) },
+		p.matchMessageBoundary,
+		p.appendContent,
+	}
+}
+
+// matchTokenStatus records token counter lines instead of adding them to a message
+func (p *ClaudeParser) matchTokenStatus(trimmed, _ string) bool {
+	if !p.tokenPattern.MatchString(trimmed) && !tokenSummaryPattern.MatchString(trimmed) {
+		return false
+	}
+	p.mu.Lock()
+	p.lastTokenStatus = trimmed
+	p.mu.Unlock()
+	p.recordTokenStatus(trimmed)
+	// If we have a pending assistant message, flush it before updating token status
+	if p.currentType == "assistant" && p.currentMessage.Len() > 0 {
+		p.flushCurrentMessage()
+	}
+	return true // Don't add to message history
+}
+
+// skipUILine consumes lines that are only CLI decoration
+func (p *ClaudeParser) skipUILine(trimmed, _ string) bool {
 	// Skip lines that are clearly just UI elements
 	if p.isDefinitelyUI(trimmed) {
-		return
+		return true
 	}
-	
 	// Skip obvious UI elements
-	if p.isUIElement(trimmed) && p.currentMessage.Len() == 0 {
-		return
-	}
-	
-	// Detect message boundaries and types
-	if p.detectMessageBoundary(trimmed) {
-		p.flushCurrentMessage()
-		p.startNewMessage(trimmed)
-		return
+	return p.isUIElement(trimmed) && p.currentMessage.Len() == 0
+}
+
+// matchMessageBoundary starts a new message when a line begins one
+func (p *ClaudeParser) matchMessageBoundary(trimmed, _ string) bool {
+	if !p.detectMessageBoundary(trimmed) {
+		return false
 	}
-	
+	p.flushCurrentMessage()
+	p.startNewMessage(trimmed)
+	return true
+}
+
+// appendContent adds the line to the message being built
+func (p *ClaudeParser) appendContent(trimmed, cleanLine string) bool {
 	// If we have no current type but this looks like content, assume it's assistant
 	if p.currentType == "" && !p.isUIElement(trimmed) && len(trimmed) > 0 {
 		p.currentType = "assistant"
 		p.currentMessage.WriteString(cleanLine)
-		return
+		return true
 	}
 	
 	// Add line to current message if we have a type
@@ -115,6 +253,28 @@ func (p *ClaudeParser) ParseLine(line string) {
 		}
 		p.currentMessage.WriteString(cleanLine)
 	}
+	return true
+}
+
+// addMatchedMessage records a message returned by a custom matcher
+func (p *ClaudeParser) addMatchedMessage(message Message) {
+	p.flushCurrentMessage()
+	if message.Type == "" && message.Content == "" {
+		return
+	}
+	if message.ID == "" {
+		message.ID = generateMessageID()
+	}
+	if message.Timestamp.IsZero() {
+		message.Timestamp = time.Now()
+	}
+	if message.Metadata == nil {
+		message.Metadata = make(map[string]string)
+	}
+	
+	p.history.mu.Lock()
+	p.history.appendLocked(message)
+	p.history.mu.Unlock()
 }
 
 // detectMessageBoundary checks if a line indicates a new message
@@ -237,8 +397,20 @@ func (p *ClaudeParser) flushCurrentMessage() {
 		message.Metadata["tokens"] = p.lastTokenStatus
 	}
 	
+	// Attach the paired tool call details
+	if p.currentType == "tool" && p.pendingTool != nil {
+		message.Metadata[MetaToolName] = p.pendingTool.Name
+		message.Metadata[MetaToolInput] = p.pendingTool.Input
+		message.Metadata[MetaToolResult] = p.pendingTool.Result
+		message.Metadata[MetaToolStatus] = p.pendingTool.Status
+		p.lastToolLine = p.pendingToolLine
+		p.lastToolID = message.ID
+	}
+	p.pendingTool = nil
+	p.pendingToolLine = ""
+	
 	p.history.mu.Lock()
-	p.history.Messages = append(p.history.Messages, message)
+	p.history.appendLocked(message)
 	p.history.mu.Unlock()
 	
 	// Reset for next message
@@ -246,6 +418,110 @@ func (p *ClaudeParser) flushCurrentMessage() {
 	p.currentType = ""
 }
 
+// handleToolLine pairs tool start lines with their result lines. It returns
+// true when the line was consumed.
+func (p *ClaudeParser) handleToolLine(line string) bool {
+	if name, input, ok := p.parseToolStart(line); ok {
+		p.replayingTool = false
+		
+		// Streaming output redraws the start line as it grows; update in place
+		if p.pendingTool != nil && p.currentType == "tool" && strings.HasPrefix(line, p.pendingToolLine) {
+			p.pendingTool.Name = name
+			p.pendingTool.Input = input
+			content := strings.TrimPrefix(p.currentMessage.String(), p.pendingToolLine)
+			p.currentMessage.Reset()
+			p.currentMessage.WriteString(line + content)
+			p.pendingToolLine = line
+			return true
+		}
+		
+		p.flushCurrentMessage()
+		
+		// The CLI redrew a tool call that is already recorded
+		if line == p.lastToolLine && p.isLastMessage(p.lastToolID) {
+			p.replayingTool = true
+			return true
+		}
+		
+		p.inToolOutput = true
+		p.toolDepth++
+		p.currentType = "tool"
+		p.currentMessage.WriteString(line)
+		p.pendingToolLine = line
+		p.pendingTool = &ToolInvocation{
+			Name:      name,
+			Input:     input,
+			Status:    ToolStatusPending,
+			Timestamp: time.Now(),
+		}
+		return true
+	}
+	
+	if result, status, ok := parseToolResult(line); ok {
+		if p.replayingTool {
+			p.replayingTool = false
+			return true
+		}
+		if p.pendingTool == nil || p.currentType != "tool" {
+			return false
+		}
+		p.pendingTool.Result = result
+		p.pendingTool.Status = status
+		p.currentMessage.WriteString("\n" + line)
+		p.flushCurrentMessage()
+		p.inToolOutput = false
+		return true
+	}
+	
+	if p.replayingTool {
+		// Anything that starts a new block ends the redrawn tool output
+		if strings.HasPrefix(line, "⏺") || strings.HasPrefix(line, "> ") {
+			p.replayingTool = false
+			return false
+		}
+		return true
+	}
+	
+	return false
+}
+
+// parseToolStart extracts the tool name and input from a tool start line such
+// as "⏺ Read(main.go)" or "⏺ Running tests"
+func (p *ClaudeParser) parseToolStart(line string) (name, input string, ok bool) {
+	if matches := toolStartPattern.FindStringSubmatch(line); matches != nil {
+		return matches[1], strings.TrimSuffix(matches[2], ")"), true
+	}
+	if matches := runningPattern.FindStringSubmatch(line); matches != nil {
+		return matches[1], "", true
+	}
+	return "", "", false
+}
+
+// parseToolResult recognizes the ✓/✗/⎿ lines that report a tool's outcome
+func parseToolResult(line string) (result, status string, ok bool) {
+	switch {
+	case strings.HasPrefix(line, "✓"):
+		return strings.TrimSpace(strings.TrimPrefix(line, "✓")), ToolStatusSuccess, true
+	case strings.HasPrefix(line, "✗"):
+		return strings.TrimSpace(strings.TrimPrefix(line, "✗")), ToolStatusError, true
+	case strings.HasPrefix(line, "⎿"):
+		result = strings.TrimSpace(strings.TrimPrefix(line, "⎿"))
+		if strings.HasPrefix(result, "Error") {
+			return result, ToolStatusError, true
+		}
+		return result, ToolStatusSuccess, true
+	}
+	return "", "", false
+}
+
+// isLastMessage reports whether the most recent history entry has the given ID
+func (p *ClaudeParser) isLastMessage(id string) bool {
+	p.history.mu.RLock()
+	defer p.history.mu.RUnlock()
+	n := len(p.history.Messages)
+	return id != "" && n > 0 && p.history.Messages[n-1].ID == id
+}
+
 // isUIElement checks if a line is a UI element to be filtered
 func (p *ClaudeParser) isUIElement(line string) bool {
 	for _, pattern := range p.uiPatterns {
@@ -316,6 +592,18 @@ func (p *ClaudeParser) stripANSI(text string) string {
 	return p.ansiPattern.ReplaceAllString(text, "")
 }
 
+// SetHistoryLimit caps the message text the history keeps at about limit
+// bytes by dropping the oldest messages. Zero or less keeps everything.
+func (p *ClaudeParser) SetHistoryLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+	p.history.limit = limit
+	p.history.trimLocked()
+}
+
 // GetHistory returns the conversation history
 func (p *ClaudeParser) GetHistory() []Message {
 	p.history.mu.RLock()
@@ -329,6 +617,8 @@ func (p *ClaudeParser) GetHistory() []Message {
 
 // GetLastTokenStatus returns the most recent token status
 func (p *ClaudeParser) GetLastTokenStatus() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.lastTokenStatus
 }
 
@@ -378,9 +668,18 @@ func (p *ClaudeParser) ClearHistory() {
 	p.history.mu.Lock()
 	defer p.history.mu.Unlock()
 	p.history.Messages = make([]Message, 0)
+	p.history.size = 0
 	p.currentMessage.Reset()
 	p.currentType = ""
 	p.lastTokenStatus = ""
+	p.sessionUsage = TokenUsage{}
+	p.turnUsage = TokenUsage{}
+	p.tokenStats = TokenStats{}
+	p.pendingTool = nil
+	p.pendingToolLine = ""
+	p.lastToolLine = ""
+	p.lastToolID = ""
+	p.replayingTool = false
 }
 
 // FlushPending flushes any pending message
@@ -393,23 +692,77 @@ func generateMessageID() string {
 	return core.NewID(8)
 }
 
-// MessageFilter allows filtering messages by criteria
+// GetToolInvocations returns the recorded tool calls with their inputs and results
+func (p *ClaudeParser) GetToolInvocations() []ToolInvocation {
+	p.history.mu.RLock()
+	defer p.history.mu.RUnlock()
+	
+	var invocations []ToolInvocation
+	for _, msg := range p.history.Messages {
+		name := msg.Metadata[MetaToolName]
+		if msg.Type != "tool" || name == "" {
+			continue
+		}
+		status := msg.Metadata[MetaToolStatus]
+		if status == "" {
+			status = ToolStatusPending
+		}
+		invocations = append(invocations, ToolInvocation{
+			MessageID: msg.ID,
+			Name:      name,
+			Input:     msg.Metadata[MetaToolInput],
+			Result:    msg.Metadata[MetaToolResult],
+			Status:    status,
+			Timestamp: msg.Timestamp,
+		})
+	}
+	
+	return invocations
+}
+
+// MessageFilter allows filtering messages by criteria. Type and Types are
+// combined: a message matches if its type is Type or any of Types. Contains
+// and ExcludeContains are case-insensitive.
 type MessageFilter struct {
-	Type      string
-	StartTime time.Time
-	EndTime   time.Time
-	Contains  string
+	Type            string
+	Types           []string
+	StartTime       time.Time
+	EndTime         time.Time
+	Contains        string
+	ExcludeContains string // Drop messages containing this text
+	Regex           string // Keep only messages whose content matches this regular expression
 }
 
-// GetFilteredHistory returns messages matching the filter criteria
+// GetFilteredHistory returns messages matching the filter criteria. An invalid
+// Regex matches no messages.
 func (p *ClaudeParser) GetFilteredHistory(filter MessageFilter) []Message {
+	var pattern *regexp.Regexp
+	if filter.Regex != "" {
+		var err error
+		pattern, err = regexp.Compile(filter.Regex)
+		if err != nil {
+			log.Printf("[ClaudeParser] Invalid message filter regex %q: %v", filter.Regex, err)
+			return nil
+		}
+	}
+	
+	types := make(map[string]bool)
+	if filter.Type != "" {
+		types[filter.Type] = true
+	}
+	for _, t := range filter.Types {
+		types[t] = true
+	}
+	contains := strings.ToLower(filter.Contains)
+	excludes := strings.ToLower(filter.ExcludeContains)
+	
 	p.history.mu.RLock()
 	defer p.history.mu.RUnlock()
 	
 	var filtered []Message
 	for _, msg := range p.history.Messages {
 		// Check type filter
-		if filter.Type != "" && msg.Type != filter.Type {
+		if len(types) > 0 && !types[msg.Type] {
 			continue
 		}
 		
@@ -421,8 +774,15 @@ func (p *ClaudeParser) GetFilteredHistory(filter MessageFilter) []Message {
 			continue
 		}
 		
-		// Check content filter
-		if filter.Contains != "" && !strings.Contains(strings.ToLower(msg.Content), strings.ToLower(filter.Contains)) {
+		// Check content filters
+		content := strings.ToLower(msg.Content)
+		if contains != "" && !strings.Contains(content, contains) {
+			continue
+		}
+		if excludes != "" && strings.Contains(content, excludes) {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(msg.Content) {
 			continue
 		}
 		
@@ -441,6 +801,10 @@ func (p *ClaudeParser) ExportHistory(format string) string {
 		return p.exportMarkdown(messages)
 	case "json":
 		return p.exportJSON(messages)
+	case "csv":
+		return p.exportCSV(messages)
+	case "html":
+		return p.exportHTML(messages)
 	default:
 		return p.exportPlainText(messages)
 	}
@@ -496,6 +860,94 @@ func (p *ClaudeParser) exportJSON(messages []Message) string {
 	return sb.String()
 }
 
+// csvTokenColumns are the token metadata keys exported as CSV columns
+var csvTokenColumns = []string{"tokens", "input_tokens", "output_tokens", "cache_read_input_tokens", "cache_creation_input_tokens"}
+
+// exportCSV writes one RFC 4180 row per message; an empty history yields only the header
+func (p *ClaudeParser) exportCSV(messages []Message) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.UseCRLF = true
+	
+	header := append([]string{"timestamp", "type", "content"}, csvTokenColumns...)
+	w.Write(header)
+	
+	for _, msg := range messages {
+		record := []string{msg.Timestamp.Format(time.RFC3339), msg.Type, msg.Content}
+		for _, key := range csvTokenColumns {
+			record = append(record, msg.Metadata[key])
+		}
+		w.Write(record)
+	}
+	
+	w.Flush()
+	return sb.String()
+}
+
+// exportHTML renders each message as a block using the web dashboard's
+// conversation classes (message, message-<type>, message-content...). Content
+// is escaped, and any ANSI colors left in it become inline styles.
+func (p *ClaudeParser) exportHTML(messages []Message) string {
+	var sb strings.Builder
+	sb.WriteString(`<div class="conversation-view">` + "\n")
+	
+	for _, msg := range messages {
+		label := msg.Type
+		switch msg.Type {
+		case "user":
+			label = "You"
+		case "assistant":
+			label = "Claude"
+		case "tool":
+			label = "Tool"
+		case "system":
+			label = "System"
+		}
+		
+		classes := "message message-" + cssClassName(msg.Type)
+		if status := msg.Metadata[MetaToolStatus]; status != "" {
+			classes += " tool-" + cssClassName(status)
+		}
+		
+		sb.WriteString(`<div class="` + classes + `" data-id="` + escapeHTML(msg.ID) + `">` + "\n")
+		sb.WriteString(`<div class="message-header"><span class="message-type">` + escapeHTML(label) + `</span>`)
+		if !msg.Timestamp.IsZero() {
+			sb.WriteString(`<time class="message-time" datetime="` + msg.Timestamp.Format(time.RFC3339) + `">` + msg.Timestamp.Format("3:04 PM") + `</time>`)
+		}
+		sb.WriteString("</div>\n")
+		sb.WriteString(`<div class="message-content"><pre>` + ansiToHTML(msg.Content) + "</pre></div>\n")
+		
+		var tokens []string
+		for _, key := range csvTokenColumns {
+			if value := msg.Metadata[key]; value != "" {
+				tokens = append(tokens, escapeHTML(strings.ReplaceAll(key, "_", " ")+": "+value))
+			}
+		}
+		if len(tokens) > 0 {
+			sb.WriteString(`<div class="message-tokens">` + strings.Join(tokens, " · ") + "</div>\n")
+		}
+		
+		sb.WriteString("</div>\n")
+	}
+	
+	sb.WriteString("</div>\n")
+	return sb.String()
+}
+
+// cssClassName reduces s to lowercase letters, digits and dashes
+func cssClassName(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			sb.WriteRune(r)
+		case r == '_' || r == ' ':
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
+
 func (p *ClaudeParser) exportPlainText(messages []Message) string {
 	var sb strings.Builder
 	
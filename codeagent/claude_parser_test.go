@@ -0,0 +1,222 @@
+package codeagent
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolInvocationPairing(t *testing.T) {
+	parser := NewClaudeParser()
+
+	lines := []string{
+		"⏺ Read(main.go)",
+		"✓ Read 42 lines",
+		"⏺ Running Python script",
+		"Hello, World!",
+		"✗ Script exited with code 1",
+		"⏺ All done.",
+	}
+	for _, line := range lines {
+		parser.ParseLine(line)
+	}
+	parser.FlushPending()
+
+	invocations := parser.GetToolInvocations()
+	if len(invocations) != 2 {
+		t.Fatalf("Expected 2 tool invocations, got %d", len(invocations))
+	}
+
+	read := invocations[0]
+	if read.Name != "Read" || read.Input != "main.go" {
+		t.Errorf("Expected Read(main.go), got %s(%s)", read.Name, read.Input)
+	}
+	if read.Result != "Read 42 lines" || read.Status != ToolStatusSuccess {
+		t.Errorf("Expected successful result, got %q (%s)", read.Result, read.Status)
+	}
+
+	script := invocations[1]
+	if script.Name != "Python script" {
+		t.Errorf("Expected name Python script, got %q", script.Name)
+	}
+	if script.Status != ToolStatusError {
+		t.Errorf("Expected status %s, got %s", ToolStatusError, script.Status)
+	}
+
+	history := parser.GetHistory()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(history))
+	}
+	if history[1].Content != "⏺ Running Python script\nHello, World!\n✗ Script exited with code 1" {
+		t.Errorf("Unexpected tool message content: %q", history[1].Content)
+	}
+	if history[2].Type != "assistant" {
+		t.Errorf("Expected assistant message, got %s", history[2].Type)
+	}
+}
+
+func TestToolInvocationNoDuplicates(t *testing.T) {
+	parser := NewClaudeParser()
+
+	// A line streamed in pieces, then the whole block redrawn by the CLI
+	lines := []string{
+		"⏺ Edit(src/ma",
+		"⏺ Edit(src/main.go)",
+		"✓ Updated src/main.go",
+		"⏺ Edit(src/main.go)",
+		"✓ Updated src/main.go",
+	}
+	for _, line := range lines {
+		parser.ParseLine(line)
+	}
+	parser.FlushPending()
+
+	invocations := parser.GetToolInvocations()
+	if len(invocations) != 1 {
+		t.Fatalf("Expected 1 tool invocation, got %d: %+v", len(invocations), invocations)
+	}
+	if invocations[0].Input != "src/main.go" {
+		t.Errorf("Expected input src/main.go, got %q", invocations[0].Input)
+	}
+	if len(parser.GetHistory()) != 1 {
+		t.Errorf("Expected 1 message, got %d", len(parser.GetHistory()))
+	}
+}
+
+func TestExportHistoryCSV(t *testing.T) {
+	parser := NewClaudeParser()
+
+	empty := parser.ExportHistory("csv")
+	expectedHeader := "timestamp,type,content,tokens,input_tokens,output_tokens,cache_read_input_tokens,cache_creation_input_tokens\r\n"
+	if empty != expectedHeader {
+		t.Errorf("Expected only the header row, got %q", empty)
+	}
+
+	parser.ParseLine("You: Fix the bug, please")
+	parser.ParseLine(`Claude: Sure, here is "the fix"`)
+	parser.ParseLine("second line")
+	parser.FlushPending()
+
+	reader := csv.NewReader(strings.NewReader(parser.ExportHistory("csv")))
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(records))
+	}
+	if records[1][1] != "user" || records[1][2] != "Fix the bug, please" {
+		t.Errorf("Unexpected user row: %v", records[1])
+	}
+	if records[2][2] != "Sure, here is \"the fix\"\nsecond line" {
+		t.Errorf("Unexpected assistant content: %q", records[2][2])
+	}
+}
+
+func TestAddMatcher(t *testing.T) {
+	parser := NewClaudeParser()
+	parser.AddMatcher(func(line string) (Message, bool) {
+		if strings.HasPrefix(line, "☞ ") {
+			return Message{Type: "system", Content: strings.TrimPrefix(line, "☞ ")}, true
+		}
+		return Message{}, false
+	})
+	// Drops lines without recording them
+	parser.AddMatcher(func(line string) (Message, bool) {
+		return Message{}, strings.HasPrefix(line, "Tip:")
+	})
+
+	lines := []string{
+		"You: fix the tests",
+		"\x1b[33m☞ Compacting conversation\x1b[0m",
+		"Tip: press ctrl+r",
+		"⏺ Done.",
+	}
+	for _, line := range lines {
+		parser.ParseLine(line)
+	}
+	parser.FlushPending()
+
+	history := parser.GetHistory()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %+v", len(history), history)
+	}
+	if history[0].Type != "user" || history[0].Content != "fix the tests" {
+		t.Errorf("Expected user message to be flushed first, got %+v", history[0])
+	}
+	if history[1].Type != "system" || history[1].Content != "Compacting conversation" {
+		t.Errorf("Expected custom system message, got %+v", history[1])
+	}
+	if history[1].ID == "" || history[1].Timestamp.IsZero() || history[1].Metadata == nil {
+		t.Errorf("Expected custom message to get an ID, timestamp and metadata, got %+v", history[1])
+	}
+	if history[2].Type != "assistant" || history[2].Content != "Done." {
+		t.Errorf("Expected built-in rules to still parse the response, got %+v", history[2])
+	}
+}
+
+func TestExportHistoryHTML(t *testing.T) {
+	parser := NewClaudeParser()
+	parser.history.Messages = []Message{
+		{ID: "1", Type: "user", Content: "<script>alert('x')</script>", Timestamp: time.Date(2024, 6, 10, 14, 5, 0, 0, time.UTC)},
+		{ID: "2", Type: "assistant", Content: "\x1b[31mred\x1b[0m", Metadata: map[string]string{"tokens": "1,234 tokens"}},
+		{ID: "3", Type: "tool", Content: "⏺ Read(main.go)", Metadata: map[string]string{MetaToolStatus: ToolStatusError}},
+	}
+
+	html := parser.ExportHistory("html")
+	for _, expected := range []string{
+		`<div class="conversation-view">`,
+		`<div class="message message-user" data-id="1">`,
+		`&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;`,
+		`datetime="2024-06-10T14:05:00Z">2:05 PM</time>`,
+		`<span style="color: #cc0000">red</span>`,
+		`<div class="message-tokens">tokens: 1,234 tokens</div>`,
+		`<div class="message message-tool tool-error" data-id="3">`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected HTML export to contain %q, got:\n%s", expected, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("Expected message content to be escaped")
+	}
+}
+
+func TestGetFilteredHistory(t *testing.T) {
+	parser := NewClaudeParser()
+	parser.history.Messages = []Message{
+		{ID: "1", Type: "user", Content: "Fix main.go"},
+		{ID: "2", Type: "assistant", Content: "Reading MAIN.GO now"},
+		{ID: "3", Type: "tool", Content: "⏺ Read(main_test.go)"},
+		{ID: "4", Type: "system", Content: "Auto-compacting"},
+		{ID: "5", Type: "tool", Content: "⏺ Bash(go test ./...)"},
+	}
+
+	ids := func(messages []Message) string {
+		result := make([]string, len(messages))
+		for i, msg := range messages {
+			result[i] = msg.ID
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		name     string
+		filter   MessageFilter
+		expected string
+	}{
+		{"single type", MessageFilter{Type: "tool"}, "3,5"},
+		{"type and types are combined", MessageFilter{Type: "user", Types: []string{"system"}}, "1,4"},
+		{"contains is case-insensitive", MessageFilter{Contains: "main.go"}, "1,2"},
+		{"exclude", MessageFilter{Types: []string{"tool"}, ExcludeContains: "_TEST"}, "5"},
+		{"regex", MessageFilter{Regex: `^⏺ \w+\(`}, "3,5"},
+		{"regex with contains", MessageFilter{Regex: `\.go\b`, Contains: "fix"}, "1"},
+		{"invalid regex", MessageFilter{Regex: `(`}, ""},
+	}
+	for _, tt := range tests {
+		if got := ids(parser.GetFilteredHistory(tt.filter)); got != tt.expected {
+			t.Errorf("%s: expected messages %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// globalQueuePrefix starts the placeholder ID of a launch waiting for the
+// global limit, as opposed to "queued-<agent>-" for one waiting for its folder
+const globalQueuePrefix = "queued-global-"
+
+// ConcurrencyStatus is the global agent limit and how much of it is in use
+type ConcurrencyStatus struct {
+	Limit   int `json:"limit"`   // MaxConcurrentAgents, 0 when unlimited
+	Running int `json:"running"` // Agents holding a folder, which count against the limit
+	Waiting int `json:"waiting"` // Tasks waiting for a free slot
+}
+
+// SetMaxConcurrentAgents caps how many agents run at once across all folders.
+// Launches beyond the cap wait in a global queue, separate from the folder
+// queues, and start in order as running agents are removed. Zero or less
+// removes the cap.
+func (m *Manager) SetMaxConcurrentAgents(n int) {
+	if n < 0 {
+		n = 0
+	}
+	m.queueMu.Lock()
+	m.maxConcurrent = n
+	m.queueMu.Unlock()
+
+	// A higher cap may free slots right away
+	m.startWaitingTasks()
+}
+
+// MaxConcurrentAgents returns the global agent limit, 0 when unlimited
+func (m *Manager) MaxConcurrentAgents() int {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	return m.maxConcurrent
+}
+
+// GetConcurrencyStatus returns the global limit and its current utilization
+func (m *Manager) GetConcurrencyStatus() ConcurrencyStatus {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	return ConcurrencyStatus{
+		Limit:   m.maxConcurrent,
+		Running: len(m.runningPerFolder),
+		Waiting: len(m.globalQueue),
+	}
+}
+
+// IsWaitingForSlot reports whether a placeholder ID returned by a launch
+// means the task waits for the global limit rather than for its folder
+func IsWaitingForSlot(placeholder string) bool {
+	return strings.HasPrefix(placeholder, globalQueuePrefix)
+}
+
+// atCapacityLocked reports whether MaxConcurrentAgents agents hold a folder.
+// Callers hold queueMu.
+func (m *Manager) atCapacityLocked() bool {
+	return m.maxConcurrent > 0 && len(m.runningPerFolder) >= m.maxConcurrent
+}
+
+// queueTaskLocked queues a launch that cannot start now: behind the agent
+// holding its folder, or behind the global limit when the folder is free. It
+// returns the placeholder ID of the queued task, or false when the task can
+// start right away. Callers hold queueMu.
+func (m *Manager) queueTaskLocked(task QueuedTask) (string, bool) {
+	if runningID, exists := m.runningPerFolder[task.Folder]; exists {
+		m.folderQueues[task.Folder] = append(m.folderQueues[task.Folder], task)
+		return fmt.Sprintf("queued-%s-pos-%d-qid-%s", runningID, len(m.folderQueues[task.Folder]), task.QueueID), true
+	}
+	if m.atCapacityLocked() {
+		m.globalQueue = append(m.globalQueue, task)
+		log.Printf("[Manager] Concurrency limit of %d reached, task %s waits for a free slot", m.maxConcurrent, task.QueueID)
+		return fmt.Sprintf("%spos-%d-qid-%s", globalQueuePrefix, len(m.globalQueue), task.QueueID), true
+	}
+	return "", false
+}
+
+// startWaitingTasks starts tasks from the global queue while slots are free
+func (m *Manager) startWaitingTasks() {
+	for {
+		m.queueMu.Lock()
+		task, ok := m.nextWaitingTaskLocked()
+		if ok {
+			// Hold the folder, and with it a slot, while the agent starts
+			m.runningPerFolder[task.Folder] = task.QueueID
+		}
+		m.queueMu.Unlock()
+
+		if !ok {
+			return
+		}
+		m.startQueuedTask(task)
+	}
+}
+
+// nextWaitingTaskLocked takes the first task of the global queue that can
+// start. A task whose folder was taken while it waited moves to the end of
+// that folder's queue instead. Callers hold queueMu.
+func (m *Manager) nextWaitingTaskLocked() (QueuedTask, bool) {
+	for len(m.globalQueue) > 0 && !m.atCapacityLocked() {
+		task := m.globalQueue[0]
+		m.globalQueue = m.globalQueue[1:]
+		if _, busy := m.runningPerFolder[task.Folder]; busy {
+			m.folderQueues[task.Folder] = append(m.folderQueues[task.Folder], task)
+			continue
+		}
+		return task, true
+	}
+	return QueuedTask{}, false
+}
@@ -0,0 +1,164 @@
+package codeagent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMaxConcurrentAgentsQueuesGlobally(t *testing.T) {
+	manager := NewManager()
+	manager.SetMaxConcurrentAgents(1)
+	ctx := context.Background()
+
+	started := make(chan string, 4)
+	manager.SetAgentStartCallback(func(agentID, folder, prompt, queueID string) {
+		started <- folder
+	})
+
+	first, err := manager.LaunchAgent(ctx, "/test/folder1", "first")
+	if err != nil || strings.HasPrefix(first, "queued-") {
+		t.Fatalf("Expected the first agent to start, got %q (%v)", first, err)
+	}
+
+	second, err := manager.LaunchAgent(ctx, "/test/folder2", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsWaitingForSlot(second) {
+		t.Fatalf("Expected the second agent to wait for a slot, got %q", second)
+	}
+
+	third, _ := manager.LaunchAgent(ctx, "/test/folder1", "third")
+	if !strings.HasPrefix(third, "queued-") || IsWaitingForSlot(third) {
+		t.Errorf("Expected the third agent to wait for its folder, got %q", third)
+	}
+
+	status := manager.GetConcurrencyStatus()
+	if status.Limit != 1 || status.Running != 1 || status.Waiting != 1 {
+		t.Errorf("Expected limit 1, 1 running and 1 waiting, got %+v", status)
+	}
+
+	detailed := manager.GetFolderQueues()
+	if len(detailed["/test/folder1"].Tasks) != 1 || len(detailed["/test/folder1"].WaitingForSlot) != 0 {
+		t.Errorf("Expected folder1 to have one task behind its agent, got %+v", detailed["/test/folder1"])
+	}
+	if len(detailed["/test/folder2"].Tasks) != 0 || len(detailed["/test/folder2"].WaitingForSlot) != 1 {
+		t.Errorf("Expected folder2 to have one task behind the limit, got %+v", detailed["/test/folder2"])
+	}
+	if manager.GetQueueStatus()["/test/folder2"] != 1 {
+		t.Errorf("Expected GetQueueStatus to count the waiting task, got %v", manager.GetQueueStatus())
+	}
+
+	// Folder1's own queue takes over the slot its agent frees
+	if err := manager.RemoveAgent(first); err != nil {
+		t.Fatal(err)
+	}
+	if folder := <-started; folder != "/test/folder1" {
+		t.Errorf("Expected folder1's queued task to start, got %s", folder)
+	}
+	if status := manager.GetConcurrencyStatus(); status.Running != 1 || status.Waiting != 1 {
+		t.Errorf("Expected the limit to still hold, got %+v", status)
+	}
+}
+
+func TestRaisingMaxConcurrentAgentsStartsWaitingTasks(t *testing.T) {
+	manager := NewManager()
+	manager.SetMaxConcurrentAgents(1)
+	ctx := context.Background()
+
+	started := make(chan string, 4)
+	manager.SetAgentStartCallback(func(agentID, folder, prompt, queueID string) {
+		started <- folder
+	})
+
+	manager.LaunchAgent(ctx, "/test/folder1", "first")
+	if id, _ := manager.LaunchAgent(ctx, "/test/folder2", "second"); !IsWaitingForSlot(id) {
+		t.Fatalf("Expected the second agent to wait for a slot, got %q", id)
+	}
+
+	manager.SetMaxConcurrentAgents(0)
+	if folder := <-started; folder != "/test/folder2" {
+		t.Errorf("Expected folder2's task to start, got %s", folder)
+	}
+	if status := manager.GetConcurrencyStatus(); status.Limit != 0 || status.Running != 2 || status.Waiting != 0 {
+		t.Errorf("Expected 2 running and none waiting, got %+v", status)
+	}
+}
+
+func TestWaitingTasksOfOneFolderStartInTurn(t *testing.T) {
+	manager := NewManager()
+	manager.SetMaxConcurrentAgents(1)
+	ctx := context.Background()
+
+	started := make(chan string, 4)
+	manager.SetAgentStartCallback(func(agentID, folder, prompt, queueID string) {
+		started <- agentID
+	})
+
+	first, _ := manager.LaunchAgent(ctx, "/test/folder1", "first")
+	manager.LaunchAgent(ctx, "/test/folder2", "second")
+	manager.LaunchAgent(ctx, "/test/folder2", "third")
+
+	if err := manager.RemoveAgent(first); err != nil {
+		t.Fatal(err)
+	}
+	second := <-started
+	if status := manager.GetConcurrencyStatus(); status.Running != 1 || status.Waiting != 1 {
+		t.Errorf("Expected 1 running and 1 waiting, got %+v", status)
+	}
+
+	if err := manager.RemoveAgent(second); err != nil {
+		t.Fatal(err)
+	}
+	third := <-started
+	info, err := manager.GetAgentInfo(third)
+	if err != nil || info.Prompt != "third" {
+		t.Errorf("Expected the third task to start, got %+v (%v)", info, err)
+	}
+}
+
+func TestClearQueuesDropsWaitingTasks(t *testing.T) {
+	manager := NewManager()
+	manager.SetMaxConcurrentAgents(1)
+	ctx := context.Background()
+
+	manager.LaunchAgent(ctx, "/test/folder1", "first")
+	manager.LaunchAgent(ctx, "/test/folder2", "second")
+
+	if dropped := manager.ClearQueues(); len(dropped) != 1 {
+		t.Errorf("Expected 1 dropped task, got %d", len(dropped))
+	}
+	if status := manager.GetConcurrencyStatus(); status.Waiting != 0 {
+		t.Errorf("Expected no waiting tasks, got %+v", status)
+	}
+}
+
+func TestOnCreateRunsWhenWaitingTaskStarts(t *testing.T) {
+	manager := NewManager()
+	manager.SetMaxConcurrentAgents(1)
+	ctx := context.Background()
+
+	created := make(chan string, 4)
+	opts := AgentOptions{OnCreate: func(a *Agent) { created <- a.Prompt }}
+
+	first, _ := manager.LaunchAgentWithOptions(ctx, "/test/folder1", "first", opts)
+	if prompt := <-created; prompt != "first" {
+		t.Errorf("Expected OnCreate for the first agent, got %s", prompt)
+	}
+	if id, _ := manager.LaunchAgentWithOptions(ctx, "/test/folder2", "second", opts); !IsWaitingForSlot(id) {
+		t.Fatalf("Expected the second agent to wait for a slot, got %q", id)
+	}
+	select {
+	case prompt := <-created:
+		t.Fatalf("Expected OnCreate to wait until the task starts, got %s", prompt)
+	default:
+	}
+
+	if err := manager.RemoveAgent(first); err != nil {
+		t.Fatal(err)
+	}
+	if prompt := <-created; prompt != "second" {
+		t.Errorf("Expected OnCreate for the waiting task once it started, got %s", prompt)
+	}
+}
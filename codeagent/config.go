@@ -0,0 +1,73 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultClaudeBinary is the Claude CLI binary used when none is configured
+	DefaultClaudeBinary = "claude"
+)
+
+var (
+	claudeBinary = DefaultClaudeBinary
+	defaultFlags = []string{"--dangerously-skip-permissions"}
+	configMu     sync.RWMutex
+)
+
+// SetClaudeBinary sets the name or path of the Claude CLI binary used to launch agents.
+// An empty path restores the default.
+func SetClaudeBinary(path string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if path == "" {
+		path = DefaultClaudeBinary
+	}
+	claudeBinary = path
+}
+
+// SetDefaultFlags sets the base flags passed to every Claude CLI invocation.
+// A nil slice restores the default flags.
+func SetDefaultFlags(flags []string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if flags == nil {
+		flags = []string{"--dangerously-skip-permissions"}
+	}
+	defaultFlags = append([]string(nil), flags...)
+}
+
+// GetClaudeBinary returns the configured Claude CLI binary
+func GetClaudeBinary() string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return claudeBinary
+}
+
+// GetDefaultFlags returns a copy of the configured base flags
+func GetDefaultFlags() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return append([]string(nil), defaultFlags...)
+}
+
+// lookupClaudeBinary resolves the configured binary, returning a descriptive error if it is missing
+func lookupClaudeBinary() (string, error) {
+	binary := GetClaudeBinary()
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", fmt.Errorf("claude binary %q not found on PATH (configure it with MAVIS_CLAUDE_BINARY): %w", binary, err)
+	}
+	return path, nil
+}
+
+// shellQuote wraps a value in single quotes for use in a /bin/sh command string
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
+}
@@ -0,0 +1,63 @@
+package codeagent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestClaudeBinaryConfig(t *testing.T) {
+	defer SetClaudeBinary("")
+	defer SetDefaultFlags(nil)
+
+	if got := GetClaudeBinary(); got != DefaultClaudeBinary {
+		t.Errorf("Expected default binary %s, got %s", DefaultClaudeBinary, got)
+	}
+
+	SetClaudeBinary("/opt/claude/bin/claude-cli")
+	if got := GetClaudeBinary(); got != "/opt/claude/bin/claude-cli" {
+		t.Errorf("Expected configured binary, got %s", got)
+	}
+
+	SetDefaultFlags([]string{"--verbose"})
+	flags := GetDefaultFlags()
+	if len(flags) != 1 || flags[0] != "--verbose" {
+		t.Errorf("Expected [--verbose], got %v", flags)
+	}
+
+	// Mutating the returned slice must not affect the configuration
+	flags[0] = "--changed"
+	if got := GetDefaultFlags(); got[0] != "--verbose" {
+		t.Errorf("Expected flags to be copied, got %v", got)
+	}
+
+	SetDefaultFlags(nil)
+	if got := GetDefaultFlags(); len(got) != 1 || got[0] != "--dangerously-skip-permissions" {
+		t.Errorf("Expected default flags to be restored, got %v", got)
+	}
+}
+
+func TestAgentStartMissingBinary(t *testing.T) {
+	SetClaudeBinary("mavis-nonexistent-claude-binary")
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("missing-binary", t.TempDir(), "test")
+	called := false
+	agent.SetCompletionCallback(func(a *Agent) {
+		called = true
+	})
+
+	err := agent.Start(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when binary is missing")
+	}
+	if !strings.Contains(err.Error(), "mavis-nonexistent-claude-binary") {
+		t.Errorf("Expected error to mention the binary, got %v", err)
+	}
+	if agent.GetStatus() != StatusFailed {
+		t.Errorf("Expected status failed, got %s", agent.GetStatus())
+	}
+	if !called {
+		t.Error("Expected completion callback to be called")
+	}
+}
@@ -8,6 +8,7 @@
 //   - Launch code agents with specific prompts and working directories
 //   - Track the status of running agents (pending, running, finished, failed, killed)
 //   - Retrieve agent output and error messages
+//   - Search tracked agents by prompt and output text
 //   - Manage multiple agents concurrently
 //   - Wait for agent completion
 //   - Kill running agents
@@ -32,6 +33,49 @@
 //	fmt.Printf("Status: %s\n", info.Status)
 //	fmt.Printf("Output: %s\n", info.Output)
 //
+// Completion callbacks:
+//
+// An agent can notify callers when it completes through two kinds of
+// callbacks. Callbacks registered with AddCompletionCallback receive the live
+// *Agent and run in registration order; SetCompletionCallback replaces them
+// all with a single callback. The one set with SetCompletionCallbackInfo
+// receives an immutable AgentInfo snapshot (including Duration and ExitCode)
+// and runs last. All fire exactly once, on the same goroutine, and a panic in
+// one callback is logged without stopping the others. The snapshot is taken
+// before any callback runs, so all observe the same final state.
+//
+// The manager registers its own callback on every agent it launches, so code
+// that launches agents through the manager should use AddCompletionCallback.
+//
+// Events:
+//
+// Instead of polling ListAgents, observers can subscribe to the manager's
+// event stream. Each call to Events returns a new channel that receives an
+// AgentEvent when an agent is launched, starts, produces output, finishes,
+// fails or is killed, and when a task is queued:
+//
+//	events := manager.Events()
+//	defer manager.StopEvents(events)
+//	for event := range events {
+//		if event.Type.IsTerminal() {
+//			fmt.Printf("Agent %s %s\n", event.AgentID, event.Type)
+//		}
+//	}
+//
+// Output events are dropped for subscribers that fall behind; other events
+// wait briefly before being dropped.
+//
+// SetWebhook uses the same stream to POST a JSON WebhookPayload to an
+// external URL whenever an agent reaches a terminal state, optionally signed
+// with an HMAC-SHA256 of the body.
+//
+// Waiting for several agents:
+//
+// WaitForAnyAgent returns as soon as one of a set of agents finishes, and
+// WaitForAllAgents returns once all of them have. Both block on the agents'
+// Done channels, which close after the completion callbacks run, and return
+// early with ctx.Err() if the context is cancelled.
+//
 // The package is designed to be used by AI agents and tools, providing
 // a clean API for programmatic control of Claude code agents.
 package codeagent
@@ -0,0 +1,184 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AgentEventType identifies what happened to an agent
+type AgentEventType string
+
+const (
+	EventLaunched AgentEventType = "launched" // Agent was created and is about to start
+	EventStarted  AgentEventType = "started"  // Claude process is running
+	EventOutput   AgentEventType = "output"   // Agent produced output
+	EventFinished AgentEventType = "finished" // Agent completed successfully
+	EventFailed   AgentEventType = "failed"   // Agent completed with an error
+	EventKilled   AgentEventType = "killed"   // Agent was killed
+	EventQueued   AgentEventType = "queued"   // Task was queued behind another agent in the same folder
+)
+
+// eventBufferSize is the number of events buffered per subscriber
+const eventBufferSize = 256
+
+// eventSendTimeout is how long a lifecycle event waits for a full subscriber
+// before it is dropped. Output events are dropped immediately.
+const eventSendTimeout = 500 * time.Millisecond
+
+// AgentEvent is a change in an agent's state published by the Manager
+type AgentEvent struct {
+	Type    AgentEventType
+	AgentID string    // Agent ID, or the queue ID for EventQueued
+	Info    AgentInfo // Snapshot when the event fired; only Folder, Prompt and Labels for EventQueued
+	Output  string    // New output for EventOutput
+	Time    time.Time
+}
+
+// IsTerminal reports whether the event ends the agent's run
+func (t AgentEventType) IsTerminal() bool {
+	return t == EventFinished || t == EventFailed || t == EventKilled
+}
+
+// agentEventHook is how an agent reports its own events to the manager
+type agentEventHook func(eventType AgentEventType, output string)
+
+// completionEventType maps a final agent status to its event type
+func completionEventType(status AgentStatus) AgentEventType {
+	switch status {
+	case StatusFinished:
+		return EventFinished
+	case StatusKilled:
+		return EventKilled
+	default:
+		return EventFailed
+	}
+}
+
+// eventSubscriber is a channel registered with Events or LifecycleEvents
+type eventSubscriber struct {
+	ch         chan AgentEvent
+	skipOutput bool          // Set for LifecycleEvents subscribers, which never receive EventOutput
+	done       chan struct{} // Closed by StopEvents to abort sends waiting on a full channel
+	mu         sync.RWMutex  // Held for reading while sending, so ch is not closed mid-send
+	closed     bool
+}
+
+// Events subscribes to agent events. Each call returns a new channel that
+// receives every event published after the call; release it with StopEvents.
+// Slow subscribers miss events rather than blocking agents.
+func (m *Manager) Events() <-chan AgentEvent {
+	return m.subscribe(false)
+}
+
+// LifecycleEvents is like Events but never delivers EventOutput, so a
+// subscriber that only reacts to state changes is not flooded by output
+func (m *Manager) LifecycleEvents() <-chan AgentEvent {
+	return m.subscribe(true)
+}
+
+// subscribe registers a new subscriber channel
+func (m *Manager) subscribe(skipOutput bool) <-chan AgentEvent {
+	sub := &eventSubscriber{
+		ch:         make(chan AgentEvent, eventBufferSize),
+		skipOutput: skipOutput,
+		done:       make(chan struct{}),
+	}
+	m.eventsMu.Lock()
+	m.eventSubs = append(m.eventSubs, sub)
+	m.eventsMu.Unlock()
+	return sub.ch
+}
+
+// StopEvents unsubscribes and closes a channel returned by Events
+func (m *Manager) StopEvents(events <-chan AgentEvent) {
+	m.eventsMu.Lock()
+	var sub *eventSubscriber
+	for i, s := range m.eventSubs {
+		if s.ch == events {
+			sub = s
+			m.eventSubs = append(m.eventSubs[:i], m.eventSubs[i+1:]...)
+			break
+		}
+	}
+	m.eventsMu.Unlock()
+	if sub == nil {
+		return
+	}
+
+	close(sub.done)
+	sub.mu.Lock()
+	sub.closed = true
+	close(sub.ch)
+	sub.mu.Unlock()
+}
+
+// publishEvent sends event to all subscribers. A full subscriber can hold it
+// for up to eventSendTimeout, so callers must not hold queueMu or mu.
+func (m *Manager) publishEvent(event AgentEvent) {
+	event.Time = time.Now()
+
+	m.eventsMu.Lock()
+	subs := make([]*eventSubscriber, len(m.eventSubs))
+	copy(subs, m.eventSubs)
+	m.eventsMu.Unlock()
+
+	for _, sub := range subs {
+		if event.Type == EventOutput && sub.skipOutput {
+			continue
+		}
+		sub.send(event)
+	}
+}
+
+// send delivers event unless the subscriber has been stopped. Output events
+// are dropped if the channel is full; others wait up to eventSendTimeout.
+func (sub *eventSubscriber) send(event AgentEvent) {
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	if sub.closed {
+		return
+	}
+	select {
+	case sub.ch <- event:
+		return
+	default:
+	}
+	if event.Type == EventOutput {
+		return
+	}
+	select {
+	case sub.ch <- event:
+	case <-sub.done:
+	case <-time.After(eventSendTimeout):
+		log.Printf("[Manager] [WARNING] Dropped %s event for agent %s: subscriber is not reading", event.Type, event.AgentID)
+	}
+}
+
+// observeAgent publishes the agent's lifecycle and output as manager events
+func (m *Manager) observeAgent(agent *Agent) {
+	agent.setEventHook(func(eventType AgentEventType, output string) {
+		m.publishEvent(AgentEvent{Type: eventType, AgentID: agent.ID, Info: agent.ToInfo(), Output: output})
+	})
+	agent.AddCompletionCallback(func(a *Agent) {
+		info := a.ToInfo()
+		m.publishEvent(AgentEvent{Type: completionEventType(info.Status), AgentID: a.ID, Info: info})
+	})
+}
+
+// publishQueued publishes an EventQueued event for a task
+func (m *Manager) publishQueued(task QueuedTask) {
+	m.publishEvent(AgentEvent{
+		Type:    EventQueued,
+		AgentID: task.QueueID,
+		Info: AgentInfo{
+			Folder: task.Folder,
+			Prompt: task.Prompt,
+			Status: StatusPending,
+			Labels: copyStringMap(task.Options.Labels),
+		},
+	})
+}
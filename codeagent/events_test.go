@@ -0,0 +1,173 @@
+package codeagent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectEvents reads events until one of type until arrives
+func collectEvents(t *testing.T, events <-chan AgentEvent, until AgentEventType) []AgentEvent {
+	t.Helper()
+	var collected []AgentEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			collected = append(collected, event)
+			if event.Type == until {
+				return collected
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s event, got %v", until, collected)
+		}
+	}
+}
+
+func TestManagerEvents(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	events := manager.Events()
+	defer manager.StopEvents(events)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "delay"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Failed to write delay file: %v", err)
+	}
+	id, err := manager.LaunchAgent(context.Background(), dir, "first")
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+	queuedID, err := manager.LaunchAgent(context.Background(), dir, "second")
+	if err != nil {
+		t.Fatalf("Failed to queue agent: %v", err)
+	}
+
+	collected := collectEvents(t, events, EventFinished)
+	var types []AgentEventType
+	sawOutput, sawQueued := false, false
+	for _, event := range collected {
+		switch event.Type {
+		case EventOutput:
+			sawOutput = true
+		case EventQueued:
+			sawQueued = true
+			if event.AgentID == id || event.Info.Prompt != "second" {
+				t.Errorf("Expected queued event for the second task (%s), got %s %q", queuedID, event.AgentID, event.Info.Prompt)
+			}
+		default:
+			types = append(types, event.Type)
+			if event.AgentID != id {
+				t.Errorf("Expected event for agent %s, got %s", id, event.AgentID)
+			}
+		}
+	}
+	expected := []AgentEventType{EventLaunched, EventStarted, EventFinished}
+	if len(types) != len(expected) || types[0] != expected[0] || types[1] != expected[1] || types[2] != expected[2] {
+		t.Errorf("Expected events %v, got %v", expected, types)
+	}
+	if !sawQueued {
+		t.Error("Expected a queued event")
+	}
+	if !sawOutput {
+		t.Error("Expected an output event")
+	}
+	if info := collected[len(collected)-1].Info; info.Status != StatusFinished {
+		t.Errorf("Expected finished event to carry status finished, got %s", info.Status)
+	}
+}
+
+func TestManagerEventsKilled(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	events := manager.Events()
+	defer manager.StopEvents(events)
+
+	id := launchWithDelay(t, manager, "30")
+	collectEvents(t, events, EventStarted)
+	if err := manager.KillAgent(id); err != nil {
+		t.Fatalf("Failed to kill agent: %v", err)
+	}
+	collected := collectEvents(t, events, EventKilled)
+	if info := collected[len(collected)-1].Info; info.Status != StatusKilled {
+		t.Errorf("Expected status killed, got %s", info.Status)
+	}
+
+	manager.StopEvents(events)
+	if _, ok := <-events; ok {
+		t.Error("Expected events channel to be closed after StopEvents")
+	}
+}
+
+func TestManagerLifecycleEvents(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	events := manager.LifecycleEvents()
+	defer manager.StopEvents(events)
+
+	launchWithDelay(t, manager, "0")
+	for _, event := range collectEvents(t, events, EventFinished) {
+		if event.Type == EventOutput {
+			t.Errorf("Expected no output events on a lifecycle subscription, got %q", event.Output)
+		}
+	}
+}
+
+func TestLaunchDoesNotBlockOnFullSubscriber(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	// A subscriber that never reads fills its buffer and then delays every
+	// lifecycle event by eventSendTimeout
+	events := manager.Events()
+	defer manager.StopEvents(events)
+	for i := 0; i < eventBufferSize; i++ {
+		manager.publishEvent(AgentEvent{Type: EventQueued, AgentID: "filler"})
+	}
+
+	launched := make(chan struct{})
+	go func() {
+		launchWithDelay(t, manager, "0")
+		close(launched)
+	}()
+	// Queue operations must not wait behind the blocked publish
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	manager.GetQueueStatus()
+	if elapsed := time.Since(start); elapsed > eventSendTimeout/2 {
+		t.Errorf("Expected queue status while a launch publishes, waited %v", elapsed)
+	}
+	<-launched
+}
+
+func TestStopEventsDuringBlockedPublish(t *testing.T) {
+	manager := NewManager()
+	events := manager.Events()
+	for i := 0; i < eventBufferSize; i++ {
+		manager.publishEvent(AgentEvent{Type: EventQueued, AgentID: "filler"})
+	}
+
+	published := make(chan struct{})
+	go func() {
+		manager.publishEvent(AgentEvent{Type: EventFinished, AgentID: "blocked"})
+		close(published)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Subscribing must not wait for the blocked send
+	start := time.Now()
+	other := manager.LifecycleEvents()
+	manager.StopEvents(other)
+	if elapsed := time.Since(start); elapsed > eventSendTimeout/2 {
+		t.Errorf("Expected subscribe while a publish is blocked, waited %v", elapsed)
+	}
+
+	// Stopping the full subscriber aborts the pending send instead of panicking
+	manager.StopEvents(events)
+	select {
+	case <-published:
+	case <-time.After(eventSendTimeout / 2):
+		t.Error("Expected StopEvents to abort the blocked publish")
+	}
+}
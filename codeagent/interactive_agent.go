@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -178,19 +179,37 @@ func ansiCodesToCSS(codes string) string {
 	return strings.Join(styles, "; ")
 }
 
-// ansi256ToHex converts 256 color index to hex
+// ansi16Colors are the standard and bright system colors (indices 0-15)
+var ansi16Colors = [16]string{
+	"#000000", "#800000", "#008000", "#808000",
+	"#000080", "#800080", "#008080", "#c0c0c0",
+	"#808080", "#ff0000", "#00ff00", "#ffff00",
+	"#0000ff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// ansiCubeLevels are the channel intensities of the 6x6x6 color cube
+var ansiCubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// ansi256ToHex converts 256 color index to hex, returning "" for invalid indices
 func ansi256ToHex(index string) string {
-	// This is a simplified version - you could expand with full 256 color palette
-	colorMap := map[string]string{
-		"0": "#000000", "1": "#800000", "2": "#008000", "3": "#808000",
-		"4": "#000080", "5": "#800080", "6": "#008080", "7": "#c0c0c0",
-		"8": "#808080", "9": "#ff0000", "10": "#00ff00", "11": "#ffff00",
-		"12": "#0000ff", "13": "#ff00ff", "14": "#00ffff", "15": "#ffffff",
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 || n > 255 {
+		return ""
 	}
-	if color, ok := colorMap[index]; ok {
-		return color
+	
+	switch {
+	case n < 16:
+		return ansi16Colors[n]
+	case n < 232:
+		// 6x6x6 color cube
+		n -= 16
+		r, g, b := ansiCubeLevels[n/36], ansiCubeLevels[(n/6)%6], ansiCubeLevels[n%6]
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	default:
+		// Grayscale ramp from #080808 to #eeeeee
+		level := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", level, level, level)
 	}
-	return ""
 }
 
 // TerminalBuffer simulates a simple terminal buffer for handling cursor movements
@@ -202,6 +221,52 @@ type TerminalBuffer struct {
 	height      int
 	savedRow    int // For save/restore cursor
 	savedCol    int
+	scrollback  scrollbackRing // Lines scrolled off the top of the screen
+}
+
+// DefaultScrollbackLines is how many lines scrolled off the screen are kept by default
+const DefaultScrollbackLines = 1000
+
+// scrollbackRing keeps the most recent lines scrolled off the top of the
+// screen in a fixed-size ring
+type scrollbackRing struct {
+	lines []string // Storage, len is the line limit
+	start int      // Index of the oldest line
+	count int      // Number of lines stored
+}
+
+// push appends line, dropping the oldest line when the ring is full
+func (r *scrollbackRing) push(line string) {
+	if len(r.lines) == 0 {
+		return
+	}
+	if r.count < len(r.lines) {
+		r.lines[(r.start+r.count)%len(r.lines)] = line
+		r.count++
+		return
+	}
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % len(r.lines)
+}
+
+// snapshot returns the stored lines, oldest first
+func (r *scrollbackRing) snapshot() []string {
+	result := make([]string, r.count)
+	for i := range result {
+		result[i] = r.lines[(r.start+i)%len(r.lines)]
+	}
+	return result
+}
+
+// setLimit changes the line limit, keeping the most recent lines
+func (r *scrollbackRing) setLimit(limit int) {
+	lines := r.snapshot()
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	r.lines = make([]string, max(0, limit))
+	r.start = 0
+	r.count = copy(r.lines, lines)
 }
 
 // NewTerminalBuffer creates a new terminal buffer
@@ -217,6 +282,7 @@ func NewTerminalBuffer(width, height int) *TerminalBuffer {
 		currentCol: 0,
 		width:      width,
 		height:     height,
+		scrollback: scrollbackRing{lines: make([]string, DefaultScrollbackLines)},
 	}
 }
 
@@ -495,11 +561,60 @@ func (tb *TerminalBuffer) clearLineTo(row, col int) {
 }
 
 func (tb *TerminalBuffer) scrollUp() {
+	tb.scrollback.push(strings.TrimRight(tb.screen[0], " "))
 	// Move all lines up by one
 	copy(tb.screen[0:], tb.screen[1:])
 	tb.screen[tb.height-1] = ""
 }
 
+// Resize changes the buffer dimensions, keeping the most recent lines and
+// truncating lines wider than the new width
+func (tb *TerminalBuffer) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	
+	// Keep the lines up to the cursor so the latest output stays visible
+	used := tb.currentRow + 1
+	for i := tb.height - 1; i >= used; i-- {
+		if strings.TrimRight(tb.screen[i], " ") != "" {
+			used = i + 1
+			break
+		}
+	}
+	start := max(0, used-height)
+	for i := 0; i < start; i++ {
+		tb.scrollback.push(strings.TrimRight(tb.screen[i], " "))
+	}
+	
+	screen := make([]string, height)
+	for i := 0; i < height && start+i < len(tb.screen); i++ {
+		line := []rune(tb.screen[start+i])
+		if len(line) > width {
+			line = line[:width]
+		}
+		screen[i] = string(line)
+	}
+	
+	tb.screen = screen
+	tb.width = width
+	tb.height = height
+	tb.currentRow = max(0, min(height-1, tb.currentRow-start))
+	tb.currentCol = min(width-1, tb.currentCol)
+	tb.savedRow = max(0, min(height-1, tb.savedRow-start))
+	tb.savedCol = min(width-1, tb.savedCol)
+}
+
+// SetScrollbackLimit sets how many scrolled-off lines are kept. Zero disables scrollback.
+func (tb *TerminalBuffer) SetScrollbackLimit(lines int) {
+	tb.scrollback.setLimit(lines)
+}
+
+// GetScrollback returns the lines scrolled off the top of the screen, oldest first
+func (tb *TerminalBuffer) GetScrollback() []string {
+	return tb.scrollback.snapshot()
+}
+
 // GetScreenLines returns the current screen content
 func (tb *TerminalBuffer) GetScreenLines() []string {
 	result := make([]string, tb.height)
@@ -541,6 +656,9 @@ type InteractiveAgent struct {
 	Error        string
 	CreatedBy    string
 	
+	// Guards Status, LastActive and Error once the session has started
+	stateMutex   sync.RWMutex
+	
 	// Process management
 	cmd          *exec.Cmd
 	ptmx         *os.File      // PTY master
@@ -552,16 +670,55 @@ type InteractiveAgent struct {
 	subscribers  map[string]chan string
 	subMutex     sync.RWMutex
 	
+	// Raw screen subscribers, guarded by subMutex
+	rawSubscribers map[string]chan string
+	// Set once the process exits and subscriber channels are closed, guarded by subMutex
+	subsClosed     bool
+	
 	// Terminal emulation
 	termBuffer   *TerminalBuffer
 	termMutex    sync.RWMutex
 	
 	// Claude parser for message history
 	parser       *ClaudeParser
+	
+	// Terminal size shared by the PTY and the terminal buffer
+	cols         int
+	rows         int
+	
+	// Closed when the session is stopped or the process exits
+	done         chan struct{}
+	doneOnce     sync.Once
 }
 
+// subscriberSendTimeout is how long delivery waits on a subscriber whose
+// channel is full before the subscriber is dropped
+const subscriberSendTimeout = 500 * time.Millisecond
+
+// subscriberBufferSize is the channel capacity for new subscribers, on top
+// of the buffered output sent when subscribing
+const subscriberBufferSize = 100
+
+// Default terminal dimensions for interactive sessions
+const (
+	DefaultTerminalCols = 120
+	DefaultTerminalRows = 40
+)
+
 // NewInteractiveAgent creates a new interactive agent
 func NewInteractiveAgent(folder string, mcpConfig string) *InteractiveAgent {
+	return NewInteractiveAgentWithSize(folder, mcpConfig, DefaultTerminalCols, DefaultTerminalRows)
+}
+
+// NewInteractiveAgentWithSize creates a new interactive agent with the given
+// terminal size. Non-positive dimensions fall back to the defaults.
+func NewInteractiveAgentWithSize(folder string, mcpConfig string, cols, rows int) *InteractiveAgent {
+	if cols <= 0 {
+		cols = DefaultTerminalCols
+	}
+	if rows <= 0 {
+		rows = DefaultTerminalRows
+	}
 	return &InteractiveAgent{
 		ID:          core.NewID(8),
 		Folder:      folder,
@@ -569,23 +726,35 @@ func NewInteractiveAgent(folder string, mcpConfig string) *InteractiveAgent {
 		StartTime:   time.Now(),
 		LastActive:  time.Now(),
 		subscribers: make(map[string]chan string),
-		termBuffer:  NewTerminalBuffer(120, 40), // Match PTY size
+		termBuffer:  NewTerminalBuffer(cols, rows), // Match PTY size
 		parser:      NewClaudeParser(),
+		cols:        cols,
+		rows:        rows,
+		done:        make(chan struct{}),
 	}
 }
 
 // Start launches the interactive Claude session
 func (ia *InteractiveAgent) Start(ctx context.Context, mcpConfig string) error {
 	log.Printf("[InteractiveAgent %s] Starting interactive session in folder: %s", ia.ID, ia.Folder)
+	ia.stateMutex.Lock()
 	ia.Status = "running"
 	ia.LastActive = time.Now()
+	ia.stateMutex.Unlock()
 	
 	// Create command context
 	cmdCtx, cancel := context.WithCancel(ctx)
 	ia.cancel = cancel
 	
 	// Build command
-	cmdParts := []string{"claude", "--dangerously-skip-permissions"}
+	binary, err := lookupClaudeBinary()
+	if err != nil {
+		cancel()
+		ia.setState("failed", err.Error())
+		log.Printf("[InteractiveAgent %s] %v", ia.ID, err)
+		return err
+	}
+	cmdParts := append([]string{binary}, GetDefaultFlags()...)
 	
 	// Add MCP config if provided
 	if mcpConfig != "" {
@@ -602,17 +771,15 @@ func (ia *InteractiveAgent) Start(ctx context.Context, mcpConfig string) error {
 	log.Printf("[InteractiveAgent %s] Working directory: %s", ia.ID, ia.Folder)
 	
 	// Start the process with PTY
-	var err error
 	ia.ptmx, err = pty.Start(ia.cmd)
 	if err != nil {
-		ia.Status = "failed"
 		// Provide more helpful error messages
 		if strings.Contains(err.Error(), "executable file not found") {
-			ia.Error = "Claude CLI not found. Please ensure 'claude' is installed and in your PATH."
+			ia.setState("failed", "Claude CLI not found. Please ensure 'claude' is installed and in your PATH.")
 		} else if strings.Contains(err.Error(), "permission denied") {
-			ia.Error = "Permission denied. Please check that you have execute permissions for the Claude CLI."
+			ia.setState("failed", "Permission denied. Please check that you have execute permissions for the Claude CLI.")
 		} else {
-			ia.Error = err.Error()
+			ia.setState("failed", err.Error())
 		}
 		log.Printf("[InteractiveAgent %s] Failed to start process with PTY: %v", ia.ID, err)
 		return fmt.Errorf("failed to start claude: %w", err)
@@ -620,11 +787,13 @@ func (ia *InteractiveAgent) Start(ctx context.Context, mcpConfig string) error {
 	
 	log.Printf("[InteractiveAgent %s] Process started successfully with PID: %d using PTY", ia.ID, ia.cmd.Process.Pid)
 	
-	// Set PTY size to reasonable defaults
+	// Set PTY size to match the terminal buffer
+	ia.termMutex.RLock()
 	winSize := &pty.Winsize{
-		Rows: 40,
-		Cols: 120,
+		Rows: uint16(ia.rows),
+		Cols: uint16(ia.cols),
 	}
+	ia.termMutex.RUnlock()
 	if err := pty.Setsize(ia.ptmx, winSize); err != nil {
 		log.Printf("[InteractiveAgent %s] Failed to set PTY size: %v", ia.ID, err)
 	} else {
@@ -697,9 +866,10 @@ func (ia *InteractiveAgent) readOutput(reader io.Reader, source string) {
 			if hasNewMessages {
 				ia.broadcastScreenUpdate()
 			}
+			ia.broadcastRawScreen(screenLines)
 			
 			// Update last active time
-			ia.LastActive = time.Now()
+			ia.touch()
 		}
 		
 		if err != nil {
@@ -886,87 +1056,148 @@ func isRepetitiveUI(line string) bool {
 // broadcastScreenUpdate notifies subscribers of a screen update
 func (ia *InteractiveAgent) broadcastScreenUpdate() {
 	// Send a special marker to indicate screen refresh
-	ia.subMutex.RLock()
-	for _, ch := range ia.subscribers {
-		select {
-		case ch <- "[[SCREEN_UPDATE]]":
-		default:
-			// Skip if channel is full
-		}
-	}
-	ia.subMutex.RUnlock()
+	ia.sendToSubscribers("[[SCREEN_UPDATE]]")
 }
 
 // broadcastCompleteBuffer broadcasts a complete buffer update to subscribers
 func (ia *InteractiveAgent) broadcastCompleteBuffer() {
 	// Create a special message indicating full buffer update
 	ia.outputMutex.RLock()
-	bufferCopy := make([]string, len(ia.outputBuffer))
-	copy(bufferCopy, ia.outputBuffer)
+	msgs := make([]string, 0, len(ia.outputBuffer)+2)
+	msgs = append(msgs, "[[BUFFER_UPDATE]]")
+	msgs = append(msgs, ia.outputBuffer...)
+	msgs = append(msgs, "[[BUFFER_UPDATE_END]]")
 	ia.outputMutex.RUnlock()
 	
-	// Send special marker followed by all lines
+	ia.sendToSubscribers(msgs...)
+}
+
+// sendToSubscribers delivers msgs in order to every subscriber. A subscriber
+// whose channel stays full for subscriberSendTimeout is unsubscribed, which
+// closes its channel, so slow consumers see the end of the stream rather than
+// silently missing updates.
+func (ia *InteractiveAgent) sendToSubscribers(msgs ...string) {
+	var slow []string
 	ia.subMutex.RLock()
-	for _, ch := range ia.subscribers {
-		// Send a marker to indicate full buffer update
-		select {
-		case ch <- "[[BUFFER_UPDATE]]":
-		default:
-		}
-		
-		// Send all lines
-		for _, line := range bufferCopy {
-			select {
-			case ch <- line:
-			default:
-				// Skip if channel is full
-			}
+	for subID, ch := range ia.subscribers {
+		if !sendWithTimeout(ch, msgs) {
+			slow = append(slow, subID)
 		}
-		
-		// Send end marker
+	}
+	ia.subMutex.RUnlock()
+	
+	for _, subID := range slow {
+		log.Printf("[InteractiveAgent %s] WARNING: Subscriber %s is not keeping up, unsubscribing", ia.ID, subID)
+		ia.Unsubscribe(subID)
+	}
+}
+
+// sendWithTimeout sends msgs to ch, giving up after subscriberSendTimeout
+func sendWithTimeout(ch chan string, msgs []string) bool {
+	timer := time.NewTimer(subscriberSendTimeout)
+	defer timer.Stop()
+	for _, msg := range msgs {
 		select {
-		case ch <- "[[BUFFER_UPDATE_END]]":
-		default:
+		case ch <- msg:
+		case <-timer.C:
+			return false
 		}
 	}
-	ia.subMutex.RUnlock()
+	return true
 }
 
-// Subscribe creates a new subscription channel for output
+// Subscribe creates a new subscription channel for output. The channel starts
+// with the scrollback, if any, between [[SCROLLBACK]] and [[SCROLLBACK_END]]
+// markers, followed by the current output buffer. It is closed when the
+// session ends, including when subscribing after the session has already finished.
 func (ia *InteractiveAgent) Subscribe() (string, chan string) {
 	subID := core.NewID(8)
-	ch := make(chan string, 100)
+	
+	// Earlier output that scrolled off the screen comes first, between markers
+	var backlog []string
+	if scrollback := ia.GetScrollback(); len(scrollback) > 0 {
+		backlog = append(backlog, "[[SCROLLBACK]]")
+		backlog = append(backlog, scrollback...)
+		backlog = append(backlog, "[[SCROLLBACK_END]]")
+	}
+	
+	// Size the channel so the whole backlog fits without blocking
+	ia.outputMutex.RLock()
+	backlog = append(backlog, ia.outputBuffer...)
+	ia.outputMutex.RUnlock()
+	ch := make(chan string, len(backlog)+subscriberBufferSize)
+	bufferLen := len(backlog)
+	for _, line := range backlog {
+		ch <- line
+	}
 	
 	ia.subMutex.Lock()
-	ia.subscribers[subID] = ch
+	if ia.subsClosed {
+		close(ch)
+	} else {
+		ia.subscribers[subID] = ch
+	}
+	total := len(ia.subscribers)
 	ia.subMutex.Unlock()
 	
-	log.Printf("[InteractiveAgent %s] New subscriber added: %s (total subscribers: %d)", ia.ID, subID, len(ia.subscribers))
+	log.Printf("[InteractiveAgent %s] New subscriber added: %s (total subscribers: %d)", ia.ID, subID, total)
+	log.Printf("[InteractiveAgent %s] Sent %d buffered lines to subscriber %s", ia.ID, bufferLen, subID)
 	
-	// Send current buffer state to new subscriber
-	ia.outputMutex.RLock()
-	bufferLen := len(ia.outputBuffer)
-	for _, line := range ia.outputBuffer {
-		select {
-		case ch <- line:
-		default:
-			break
+	return subID, ch
+}
+
+// SubscribeRaw creates a subscription that receives the unfiltered terminal
+// screen. Each value is a complete screen snapshot with lines separated by
+// newlines, starting with the current screen.
+func (ia *InteractiveAgent) SubscribeRaw() (string, chan string) {
+	subID := core.NewID(8)
+	ch := make(chan string, 16)
+	ch <- strings.Join(ia.GetRawOutput(), "\n")
+	
+	ia.subMutex.Lock()
+	if ia.subsClosed {
+		close(ch)
+	} else {
+		if ia.rawSubscribers == nil {
+			ia.rawSubscribers = make(map[string]chan string)
 		}
+		ia.rawSubscribers[subID] = ch
 	}
-	ia.outputMutex.RUnlock()
-	
-	log.Printf("[InteractiveAgent %s] Sent %d buffered lines to subscriber %s", ia.ID, bufferLen, subID)
+	ia.subMutex.Unlock()
 	
+	log.Printf("[InteractiveAgent %s] New raw subscriber added: %s", ia.ID, subID)
 	return subID, ch
 }
 
-// Unsubscribe removes a subscription
+// broadcastRawScreen sends a raw screen snapshot to raw subscribers
+func (ia *InteractiveAgent) broadcastRawScreen(screenLines []string) {
+	ia.subMutex.RLock()
+	defer ia.subMutex.RUnlock()
+	if len(ia.rawSubscribers) == 0 {
+		return
+	}
+	
+	snapshot := strings.Join(screenLines, "\n")
+	for _, ch := range ia.rawSubscribers {
+		select {
+		case ch <- snapshot:
+		default:
+			// Skip if channel is full; the next snapshot replaces this one
+		}
+	}
+}
+
+// Unsubscribe removes a subscription created by Subscribe or SubscribeRaw
 func (ia *InteractiveAgent) Unsubscribe(subID string) {
 	ia.subMutex.Lock()
 	if ch, ok := ia.subscribers[subID]; ok {
 		close(ch)
 		delete(ia.subscribers, subID)
 	}
+	if ch, ok := ia.rawSubscribers[subID]; ok {
+		close(ch)
+		delete(ia.rawSubscribers, subID)
+	}
 	ia.subMutex.Unlock()
 }
 
@@ -974,8 +1205,8 @@ func (ia *InteractiveAgent) Unsubscribe(subID string) {
 func (ia *InteractiveAgent) SendInput(input string) error {
 	log.Printf("[InteractiveAgent %s] Attempting to send input: %q (length: %d)", ia.ID, input, len(input))
 	
-	if ia.Status != "running" {
-		log.Printf("[InteractiveAgent %s] Cannot send input - agent status is: %s", ia.ID, ia.Status)
+	if status := ia.GetStatus(); status != "running" {
+		log.Printf("[InteractiveAgent %s] Cannot send input - agent status is: %s", ia.ID, status)
 		return fmt.Errorf("agent is not running")
 	}
 	
@@ -1009,20 +1240,158 @@ func (ia *InteractiveAgent) SendInput(input string) error {
 	}
 	
 	log.Printf("[InteractiveAgent %s] Sent Enter key: %d bytes", ia.ID, n2)
-	ia.LastActive = time.Now()
+	ia.touch()
+	return nil
+}
+
+// terminalKeys maps the key names accepted by SendKey to the bytes a terminal sends for them
+var terminalKeys = map[string][]byte{
+	"enter":     {'\r'},
+	"tab":       {'\t'},
+	"esc":       {0x1b},
+	"backspace": {0x7f},
+	"ctrl-c":    {0x03},
+	"ctrl-d":    {0x04},
+	"up":        []byte("\x1b[A"),
+	"down":      []byte("\x1b[B"),
+	"right":     []byte("\x1b[C"),
+	"left":      []byte("\x1b[D"),
+}
+
+// TerminalKeys returns the key names accepted by SendKey, sorted
+func TerminalKeys() []string {
+	keys := make([]string, 0, len(terminalKeys))
+	for key := range terminalKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SendKey writes a named key such as "ctrl-c", "esc" or "up" to the PTY.
+// Unlike SendInput, no Enter is sent afterwards.
+func (ia *InteractiveAgent) SendKey(key string) error {
+	seq, ok := terminalKeys[strings.ToLower(strings.TrimSpace(key))]
+	if !ok {
+		return fmt.Errorf("unknown key %q (supported: %s)", key, strings.Join(TerminalKeys(), ", "))
+	}
+	
+	if status := ia.GetStatus(); status != "running" {
+		log.Printf("[InteractiveAgent %s] Cannot send key - agent status is: %s", ia.ID, status)
+		return fmt.Errorf("agent is not running")
+	}
+	
+	if ia.ptmx == nil {
+		log.Printf("[InteractiveAgent %s] Cannot send key - PTY is nil", ia.ID)
+		return fmt.Errorf("PTY is not available")
+	}
+	
+	if _, err := ia.ptmx.Write(seq); err != nil {
+		log.Printf("[InteractiveAgent %s] Failed to write key %s: %v", ia.ID, key, err)
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	
+	log.Printf("[InteractiveAgent %s] Sent key %s", ia.ID, key)
+	ia.touch()
 	return nil
 }
 
+// Resize changes the PTY and terminal buffer dimensions, keeping the existing
+// screen content where possible
+func (ia *InteractiveAgent) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", cols, rows)
+	}
+	if cols > 1000 || rows > 1000 {
+		return fmt.Errorf("terminal size %dx%d is too large", cols, rows)
+	}
+	
+	ia.termMutex.Lock()
+	ia.cols = cols
+	ia.rows = rows
+	ia.termBuffer.Resize(cols, rows)
+	ptmx := ia.ptmx
+	ia.termMutex.Unlock()
+	
+	if ptmx != nil {
+		if err := pty.Setsize(ptmx, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}); err != nil {
+			return fmt.Errorf("failed to resize PTY: %w", err)
+		}
+	}
+	
+	log.Printf("[InteractiveAgent %s] Resized terminal to %dx%d", ia.ID, cols, rows)
+	ia.broadcastScreenUpdate()
+	return nil
+}
+
+// GetSize returns the terminal dimensions as columns and rows
+func (ia *InteractiveAgent) GetSize() (int, int) {
+	ia.termMutex.RLock()
+	defer ia.termMutex.RUnlock()
+	return ia.cols, ia.rows
+}
+
 // Stop terminates the interactive session
 func (ia *InteractiveAgent) Stop() error {
 	if ia.cancel != nil {
 		ia.cancel()
 	}
 	
-	ia.Status = "killed"
+	ia.setState("killed", "")
+	ia.markDone()
 	return nil
 }
 
+// setState sets the session status and, when errMsg is not empty, its error
+func (ia *InteractiveAgent) setState(status, errMsg string) {
+	ia.stateMutex.Lock()
+	defer ia.stateMutex.Unlock()
+	ia.Status = status
+	if errMsg != "" {
+		ia.Error = errMsg
+	}
+}
+
+// touch records activity on the session
+func (ia *InteractiveAgent) touch() {
+	ia.stateMutex.Lock()
+	defer ia.stateMutex.Unlock()
+	ia.LastActive = time.Now()
+}
+
+// GetStatus returns the session status
+func (ia *InteractiveAgent) GetStatus() string {
+	ia.stateMutex.RLock()
+	defer ia.stateMutex.RUnlock()
+	return ia.Status
+}
+
+// GetLastActive returns when the session last produced output or received input
+func (ia *InteractiveAgent) GetLastActive() time.Time {
+	ia.stateMutex.RLock()
+	defer ia.stateMutex.RUnlock()
+	return ia.LastActive
+}
+
+// GetError returns the error the session ended with, if any
+func (ia *InteractiveAgent) GetError() string {
+	ia.stateMutex.RLock()
+	defer ia.stateMutex.RUnlock()
+	return ia.Error
+}
+
+// markDone closes the done channel once
+func (ia *InteractiveAgent) markDone() {
+	ia.doneOnce.Do(func() { close(ia.done) })
+}
+
+// hasSubscribers reports whether any client is watching the session
+func (ia *InteractiveAgent) hasSubscribers() bool {
+	ia.subMutex.RLock()
+	defer ia.subMutex.RUnlock()
+	return len(ia.subscribers)+len(ia.rawSubscribers) > 0
+}
+
 // monitorProcess monitors the claude process
 func (ia *InteractiveAgent) monitorProcess() {
 	if ia.cmd == nil {
@@ -1034,6 +1403,7 @@ func (ia *InteractiveAgent) monitorProcess() {
 	err := ia.cmd.Wait()
 	
 	// Update status based on exit
+	ia.stateMutex.Lock()
 	if err != nil {
 		if ia.Status == "killed" {
 			// Already marked as killed
@@ -1047,19 +1417,32 @@ func (ia *InteractiveAgent) monitorProcess() {
 		ia.Status = "finished"
 		log.Printf("[InteractiveAgent %s] Process finished successfully", ia.ID)
 	}
+	ia.stateMutex.Unlock()
 	
 	// Close PTY
 	if ia.ptmx != nil {
 		ia.ptmx.Close()
 	}
 	
+	// Let subscribers pick up the final output before their channels close
+	ia.termMutex.Lock()
+	ia.parser.FlushPending()
+	ia.termMutex.Unlock()
+	ia.broadcastScreenUpdate()
+	
 	// Close all subscriber channels
 	ia.subMutex.Lock()
+	ia.subsClosed = true
 	for _, ch := range ia.subscribers {
 		close(ch)
 	}
 	ia.subscribers = make(map[string]chan string)
+	for _, ch := range ia.rawSubscribers {
+		close(ch)
+	}
+	ia.rawSubscribers = nil
 	ia.subMutex.Unlock()
+	ia.markDone()
 }
 
 // GetOutput returns the current output buffer
@@ -1073,21 +1456,117 @@ func (ia *InteractiveAgent) GetOutput() []string {
 	return output
 }
 
+// GetRawOutput returns the full terminal screen without the UI filtering
+// applied by GetOutput, for debugging output the filter drops
+func (ia *InteractiveAgent) GetRawOutput() []string {
+	ia.termMutex.RLock()
+	defer ia.termMutex.RUnlock()
+	return ia.termBuffer.GetScreenLines()
+}
+
+// GetScrollback returns the terminal lines that scrolled off the screen, oldest first
+func (ia *InteractiveAgent) GetScrollback() []string {
+	ia.termMutex.RLock()
+	defer ia.termMutex.RUnlock()
+	return ia.termBuffer.GetScrollback()
+}
+
+// SetScrollbackLimit sets how many lines scrolled off the screen are kept
+// (DefaultScrollbackLines unless changed). Zero disables scrollback.
+func (ia *InteractiveAgent) SetScrollbackLimit(lines int) {
+	ia.termMutex.Lock()
+	defer ia.termMutex.Unlock()
+	ia.termBuffer.SetScrollbackLimit(lines)
+}
+
 // InteractiveAgentManager manages multiple interactive agents
 type InteractiveAgentManager struct {
-	agents map[string]*InteractiveAgent
-	mutex  sync.RWMutex
+	agents          map[string]*InteractiveAgent
+	mutex           sync.RWMutex
+	scrollbackLines int           // Scrollback limit applied to new agents
+	idleTimeout     time.Duration // Stop agents idle for this long (0 disables)
 }
 
 // NewInteractiveAgentManager creates a new manager
 func NewInteractiveAgentManager() *InteractiveAgentManager {
 	return &InteractiveAgentManager{
-		agents: make(map[string]*InteractiveAgent),
+		agents:          make(map[string]*InteractiveAgent),
+		scrollbackLines: DefaultScrollbackLines,
 	}
 }
 
+// SetScrollbackLimit sets how many scrolled-off lines agents created afterwards keep
+func (iam *InteractiveAgentManager) SetScrollbackLimit(lines int) {
+	iam.mutex.Lock()
+	defer iam.mutex.Unlock()
+	iam.scrollbackLines = lines
+}
+
+// SetIdleTimeout stops sessions that have no subscribers and no input or
+// output for longer than d. A zero duration disables the timeout.
+func (iam *InteractiveAgentManager) SetIdleTimeout(d time.Duration) {
+	iam.mutex.Lock()
+	defer iam.mutex.Unlock()
+	iam.idleTimeout = d
+}
+
+// watchIdle stops agent once it has been idle for longer than the idle
+// timeout. It returns when the agent stops, exits or is removed.
+func (iam *InteractiveAgentManager) watchIdle(agent *InteractiveAgent) {
+	for {
+		iam.mutex.RLock()
+		timeout := iam.idleTimeout
+		iam.mutex.RUnlock()
+		
+		// Check a few times per timeout window, but at least every 30 seconds
+		interval := timeout / 4
+		if interval <= 0 || interval > 30*time.Second {
+			interval = 30 * time.Second
+		}
+		if interval < time.Second {
+			interval = time.Second
+		}
+		
+		select {
+		case <-agent.done:
+			return
+		case <-time.After(interval):
+		}
+		
+		if iam.checkIdle(agent) {
+			return
+		}
+	}
+}
+
+// checkIdle stops agent if it is idle past the timeout and reports whether
+// it no longer needs watching
+func (iam *InteractiveAgentManager) checkIdle(agent *InteractiveAgent) bool {
+	iam.mutex.RLock()
+	timeout := iam.idleTimeout
+	_, tracked := iam.agents[agent.ID]
+	iam.mutex.RUnlock()
+	
+	if !tracked || agent.GetStatus() != "running" {
+		return true
+	}
+	if timeout <= 0 || agent.hasSubscribers() || time.Since(agent.GetLastActive()) < timeout {
+		return false
+	}
+	
+	log.Printf("[InteractiveAgentManager] Agent %s idle for more than %s with no subscribers, stopping", agent.ID, timeout)
+	agent.setState("killed", "idle timeout")
+	agent.Stop()
+	return true
+}
+
 // CreateAgent creates and starts a new interactive agent
 func (iam *InteractiveAgentManager) CreateAgent(ctx context.Context, folder string, mcpConfig string) (*InteractiveAgent, error) {
+	return iam.CreateAgentWithSize(ctx, folder, mcpConfig, DefaultTerminalCols, DefaultTerminalRows)
+}
+
+// CreateAgentWithSize creates and starts a new interactive agent with the given terminal size
+func (iam *InteractiveAgentManager) CreateAgentWithSize(ctx context.Context, folder string, mcpConfig string, cols, rows int) (*InteractiveAgent, error) {
 	log.Printf("[InteractiveAgentManager] Creating new agent for folder: %s", folder)
 	
 	// Check if folder exists
@@ -1097,7 +1576,10 @@ func (iam *InteractiveAgentManager) CreateAgent(ctx context.Context, folder stri
 	}
 	
 	// Create agent
-	agent := NewInteractiveAgent(folder, mcpConfig)
+	agent := NewInteractiveAgentWithSize(folder, mcpConfig, cols, rows)
+	iam.mutex.RLock()
+	agent.SetScrollbackLimit(iam.scrollbackLines)
+	iam.mutex.RUnlock()
 	log.Printf("[InteractiveAgentManager] Created agent with ID: %s", agent.ID)
 	
 	// Start agent
@@ -1110,6 +1592,7 @@ func (iam *InteractiveAgentManager) CreateAgent(ctx context.Context, folder stri
 	iam.mutex.Lock()
 	iam.agents[agent.ID] = agent
 	iam.mutex.Unlock()
+	go iam.watchIdle(agent)
 	
 	log.Printf("[InteractiveAgentManager] Successfully created and started agent %s", agent.ID)
 	return agent, nil
@@ -1170,6 +1653,14 @@ func (ia *InteractiveAgent) GetFilteredHistory(filter MessageFilter) []Message {
 	return ia.parser.GetFilteredHistory(filter)
 }
 
+// GetToolInvocations returns the tool calls seen so far with their results
+func (ia *InteractiveAgent) GetToolInvocations() []ToolInvocation {
+	if ia.parser == nil {
+		return []ToolInvocation{}
+	}
+	return ia.parser.GetToolInvocations()
+}
+
 // GetLastTokenStatus returns the most recent token count status
 func (ia *InteractiveAgent) GetLastTokenStatus() string {
 	if ia.parser == nil {
@@ -1178,6 +1669,22 @@ func (ia *InteractiveAgent) GetLastTokenStatus() string {
 	return ia.parser.GetLastTokenStatus()
 }
 
+// GetTokenUsage returns the token usage aggregated across the session
+func (ia *InteractiveAgent) GetTokenUsage() TokenUsage {
+	if ia.parser == nil {
+		return TokenUsage{}
+	}
+	return ia.parser.GetTokenUsage()
+}
+
+// GetTokenStats returns the latest parsed token counters and session peaks
+func (ia *InteractiveAgent) GetTokenStats() TokenStats {
+	if ia.parser == nil {
+		return TokenStats{}
+	}
+	return ia.parser.GetTokenStats()
+}
+
 // ExportHistory exports the conversation history in the specified format
 func (ia *InteractiveAgent) ExportHistory(format string) string {
 	if ia.parser == nil {
@@ -0,0 +1,325 @@
+package codeagent
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalBufferResize(t *testing.T) {
+	tb := NewTerminalBuffer(10, 4)
+	tb.ProcessOutput("line1\r\nline2\r\nline3\r\nlong line")
+
+	// Shrinking keeps the most recent lines and truncates to the new width
+	tb.Resize(5, 2)
+	lines := tb.GetScreenLines()
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0] != "line3" {
+		t.Errorf("Expected first line 'line3', got %q", lines[0])
+	}
+	if lines[1] != "long" {
+		t.Errorf("Expected second line 'long', got %q", lines[1])
+	}
+	if tb.currentRow != 1 || tb.currentCol != 4 {
+		t.Errorf("Expected cursor at 1,4, got %d,%d", tb.currentRow, tb.currentCol)
+	}
+
+	// Growing keeps the existing content at the top
+	tb.Resize(20, 6)
+	lines = tb.GetScreenLines()
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 lines, got %d", len(lines))
+	}
+	if lines[0] != "line3" {
+		t.Errorf("Expected first line 'line3', got %q", lines[0])
+	}
+
+	// Writing after a resize uses the new width
+	tb.ProcessOutput("\r\n" + strings.Repeat("x", 20))
+	lines = tb.GetScreenLines()
+	if lines[2] != strings.Repeat("x", 20) {
+		t.Errorf("Expected full-width line, got %q", lines[2])
+	}
+}
+
+func TestInteractiveAgentSize(t *testing.T) {
+	agent := NewInteractiveAgentWithSize("/tmp", "", 0, -5)
+	cols, rows := agent.GetSize()
+	if cols != DefaultTerminalCols || rows != DefaultTerminalRows {
+		t.Errorf("Expected default size %dx%d, got %dx%d", DefaultTerminalCols, DefaultTerminalRows, cols, rows)
+	}
+
+	if err := agent.Resize(0, 10); err == nil {
+		t.Error("Expected error for zero columns")
+	}
+	if err := agent.Resize(80, -1); err == nil {
+		t.Error("Expected error for negative rows")
+	}
+
+	// Resizing before the session starts only updates the buffer
+	if err := agent.Resize(80, 24); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cols, rows = agent.GetSize()
+	if cols != 80 || rows != 24 {
+		t.Errorf("Expected size 80x24, got %dx%d", cols, rows)
+	}
+	if lines := agent.termBuffer.GetScreenLines(); len(lines) != 24 {
+		t.Errorf("Expected 24 screen lines, got %d", len(lines))
+	}
+}
+
+func TestRawOutput(t *testing.T) {
+	agent := NewInteractiveAgentWithSize("/tmp", "", 40, 3)
+	agent.termBuffer.ProcessOutput("> hello\r\n? for shortcuts")
+
+	raw := agent.GetRawOutput()
+	if len(raw) != 3 {
+		t.Fatalf("Expected 3 raw lines, got %d", len(raw))
+	}
+	if raw[1] != "? for shortcuts" {
+		t.Errorf("Expected UI line to be kept in raw output, got %q", raw[1])
+	}
+
+	subID, ch := agent.SubscribeRaw()
+	if initial := <-ch; initial != "> hello\n? for shortcuts\n" {
+		t.Errorf("Expected initial screen snapshot, got %q", initial)
+	}
+
+	agent.broadcastRawScreen([]string{"a", "b"})
+	if snapshot := <-ch; snapshot != "a\nb" {
+		t.Errorf("Expected broadcast snapshot, got %q", snapshot)
+	}
+
+	agent.Unsubscribe(subID)
+	if _, ok := <-ch; ok {
+		t.Error("Expected raw channel to be closed after unsubscribe")
+	}
+}
+
+func TestSubscribeDeliversFullBacklog(t *testing.T) {
+	agent := NewInteractiveAgent("/tmp", "")
+	for i := 0; i < 3*subscriberBufferSize; i++ {
+		agent.outputBuffer = append(agent.outputBuffer, "line")
+	}
+
+	subID, ch := agent.Subscribe()
+	defer agent.Unsubscribe(subID)
+	if len(ch) != len(agent.outputBuffer) {
+		t.Errorf("Expected %d buffered lines, got %d", len(agent.outputBuffer), len(ch))
+	}
+}
+
+func TestSlowSubscriberIsUnsubscribed(t *testing.T) {
+	agent := NewInteractiveAgent("/tmp", "")
+	subID, ch := agent.Subscribe()
+
+	// Fill the channel without reading so the next update times out
+	for i := 0; i < subscriberBufferSize; i++ {
+		agent.broadcastScreenUpdate()
+	}
+	agent.broadcastScreenUpdate()
+
+	agent.subMutex.RLock()
+	_, stillSubscribed := agent.subscribers[subID]
+	agent.subMutex.RUnlock()
+	if stillSubscribed {
+		t.Error("Expected slow subscriber to be removed")
+	}
+
+	// All delivered updates are still readable before the channel reports closed
+	count := 0
+	for range ch {
+		count++
+	}
+	if count != subscriberBufferSize {
+		t.Errorf("Expected %d delivered updates, got %d", subscriberBufferSize, count)
+	}
+}
+
+func TestSubscribeAfterSessionEnded(t *testing.T) {
+	agent := NewInteractiveAgent("/tmp", "")
+	agent.outputBuffer = []string{"final line"}
+	agent.subsClosed = true
+
+	_, ch := agent.Subscribe()
+	if line := <-ch; line != "final line" {
+		t.Errorf("Expected final output line, got %q", line)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed for a finished session")
+	}
+}
+
+func TestAnsi256ToHex(t *testing.T) {
+	tests := map[string]string{
+		"1":   "#800000",
+		"15":  "#ffffff",
+		"16":  "#000000",
+		"21":  "#0000ff",
+		"196": "#ff0000",
+		"46":  "#00ff00",
+		"231": "#ffffff",
+		"232": "#080808",
+		"244": "#808080",
+		"255": "#eeeeee",
+		"256": "",
+		"-1":  "",
+		"x":   "",
+	}
+
+	for index, expected := range tests {
+		if got := ansi256ToHex(index); got != expected {
+			t.Errorf("ansi256ToHex(%s): expected %q, got %q", index, expected, got)
+		}
+	}
+
+	if html := ansiToHTML("\x1b[38;5;196mremoved\x1b[0m"); !strings.Contains(html, "color: #ff0000") {
+		t.Errorf("Expected 256-color foreground in HTML, got %q", html)
+	}
+}
+
+func TestSendKey(t *testing.T) {
+	agent := NewInteractiveAgent("/tmp", "")
+	if err := agent.SendKey("ctrl-c"); err == nil {
+		t.Error("Expected error when the session is not running")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	agent.Status = "running"
+	agent.ptmx = w
+
+	if err := agent.SendKey("f13"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+	for _, key := range []string{"ctrl-c", "ESC", "up"} {
+		if err := agent.SendKey(key); err != nil {
+			t.Fatalf("Failed to send %s: %v", key, err)
+		}
+	}
+
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read keys: %v", err)
+	}
+	if got := string(buf[:n]); got != "\x03\x1b\x1b[A" {
+		t.Errorf("Expected ctrl-c, esc and up sequences without Enter, got %q", got)
+	}
+}
+
+func TestTerminalBufferScrollback(t *testing.T) {
+	tb := NewTerminalBuffer(20, 2)
+	tb.SetScrollbackLimit(3)
+	tb.ProcessOutput("one\r\ntwo\r\nthree\r\nfour\r\nfive\r\nsix")
+
+	scrollback := tb.GetScrollback()
+	if strings.Join(scrollback, ",") != "two,three,four" {
+		t.Errorf("Expected the 3 most recent scrolled lines, got %v", scrollback)
+	}
+	if lines := tb.GetScreenLines(); lines[0] != "five" || lines[1] != "six" {
+		t.Errorf("Expected screen to keep the last lines, got %v", lines)
+	}
+
+	// Shrinking the screen moves lines into the scrollback
+	tb.Resize(20, 1)
+	if scrollback := tb.GetScrollback(); scrollback[len(scrollback)-1] != "five" {
+		t.Errorf("Expected resize to push five into the scrollback, got %v", scrollback)
+	}
+
+	tb.SetScrollbackLimit(0)
+	tb.ProcessOutput("\r\nseven")
+	if scrollback := tb.GetScrollback(); len(scrollback) != 0 {
+		t.Errorf("Expected scrollback to be disabled, got %v", scrollback)
+	}
+}
+
+func TestSubscribeReplaysScrollback(t *testing.T) {
+	agent := NewInteractiveAgentWithSize("/tmp", "", 20, 1)
+	agent.termBuffer.ProcessOutput("old\r\nnew")
+	agent.outputBuffer = []string{"new"}
+
+	subID, ch := agent.Subscribe()
+	defer agent.Unsubscribe(subID)
+
+	var got []string
+	for len(ch) > 0 {
+		got = append(got, <-ch)
+	}
+	if strings.Join(got, ",") != "[[SCROLLBACK]],old,[[SCROLLBACK_END]],new" {
+		t.Errorf("Expected scrollback before the screen, got %v", got)
+	}
+}
+
+func TestInteractiveIdleTimeout(t *testing.T) {
+	manager := NewInteractiveAgentManager()
+	agent := NewInteractiveAgent("/tmp", "")
+	agent.Status = "running"
+	agent.LastActive = time.Now().Add(-time.Hour)
+	manager.agents[agent.ID] = agent
+
+	if done := manager.checkIdle(agent); done {
+		t.Error("Expected agent to keep running with the timeout disabled")
+	}
+
+	manager.SetIdleTimeout(30 * time.Minute)
+	subID, _ := agent.Subscribe()
+	if done := manager.checkIdle(agent); done || agent.Status != "running" {
+		t.Error("Expected agent with a subscriber to keep running")
+	}
+	agent.Unsubscribe(subID)
+
+	if done := manager.checkIdle(agent); !done {
+		t.Error("Expected idle agent to be stopped")
+	}
+	if agent.Status != "killed" || agent.Error != "idle timeout" {
+		t.Errorf("Expected killed with idle timeout, got %s %q", agent.Status, agent.Error)
+	}
+	select {
+	case <-agent.done:
+	default:
+		t.Error("Expected done channel to be closed so the watcher exits")
+	}
+}
+
+func TestInteractiveIdleCheckConcurrentWithActivity(t *testing.T) {
+	manager := NewInteractiveAgentManager()
+	manager.SetIdleTimeout(time.Hour)
+	agent := NewInteractiveAgent("/tmp", "")
+	agent.setState("running", "")
+	manager.agents[agent.ID] = agent
+
+	// The PTY reader and SendInput record activity while the watcher checks
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				agent.touch()
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if manager.checkIdle(agent) {
+			t.Fatal("Expected an active agent to keep running")
+		}
+	}
+	close(stop)
+	<-finished
+
+	if agent.GetStatus() != "running" {
+		t.Errorf("Expected agent to keep running, got %s", agent.GetStatus())
+	}
+}
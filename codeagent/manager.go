@@ -7,16 +7,31 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// killAllTimeout is how long KillAllAgents waits for killed agents to exit
+const killAllTimeout = 5 * time.Second
+
 // QueuedTask represents a task waiting to be executed
 type QueuedTask struct {
 	Folder  string
 	Prompt  string
 	Ctx     context.Context
-	QueueID string // Unique ID for this queued task
+	QueueID string       // Unique ID for this queued task
+	Options AgentOptions // Launch options to apply when the task starts
+}
+
+// FolderQueue is the queue of a folder as reported by GetDetailedQueueStatus
+type FolderQueue struct {
+	RunningAgentID string       // Agent currently holding the folder, empty if none
+	Tasks          []QueuedTask // Tasks waiting for the folder, in order
+	WaitingForSlot []QueuedTask // Tasks for the folder waiting behind MaxConcurrentAgents, in order
 }
 
 // AgentStartCallback is called when a queued agent starts
@@ -32,6 +47,21 @@ type Manager struct {
 	runningPerFolder map[string]string       // Maps folder to currently running agent ID
 	queueMu          sync.Mutex              // Separate mutex for queue operations
 	startCallback    AgentStartCallback      // Callback when queued agent starts
+	staleTimeout     time.Duration           // Agents without output for this long are considered stale (0 disables)
+	autoKillStale    bool                    // Whether stale agents are killed by the watchdog
+	watchdogOnce     sync.Once               // Ensures only one watchdog goroutine runs
+	tokenBudgetAgent int                     // Max tokens per agent (0 disables)
+	tokenBudgetDay   int                     // Max tokens per local day (0 disables)
+	tokenUsageFile   string                  // Where the daily token counter is persisted
+	dailyTokens      dailyTokenCounter       // Tokens used by finished agents today
+	budgetMu         sync.Mutex              // Guards the token budget fields
+	eventSubs        []*eventSubscriber      // Subscribers registered with Events or LifecycleEvents
+	eventsMu         sync.Mutex              // Guards eventSubs
+	launchedTotal    int                     // Agents launched since the manager was created
+	webhookEvents    <-chan AgentEvent       // Subscription used by the completion webhook, nil when disabled
+	webhookMu        sync.Mutex              // Guards webhookEvents
+	maxConcurrent    int                     // Max agents holding a folder at once (0 is unlimited)
+	globalQueue      []QueuedTask            // Tasks waiting for a slot under maxConcurrent, in order
 }
 
 // NewManager creates a new agent manager
@@ -50,43 +80,126 @@ func (m *Manager) SetAgentStartCallback(callback AgentStartCallback) {
 	m.startCallback = callback
 }
 
+// SetStaleTimeout enables the stale agent watchdog. Running agents that produce no output
+// for longer than d are flagged as stale. A zero duration disables detection.
+func (m *Manager) SetStaleTimeout(d time.Duration) {
+	m.mu.Lock()
+	m.staleTimeout = d
+	m.mu.Unlock()
+
+	if d > 0 {
+		m.watchdogOnce.Do(func() {
+			go m.runStaleWatchdog()
+		})
+	}
+}
+
+// SetAutoKillStale controls whether the watchdog kills agents once they are flagged as stale
+func (m *Manager) SetAutoKillStale(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autoKillStale = enabled
+}
+
+// runStaleWatchdog periodically checks running agents for missing output
+func (m *Manager) runStaleWatchdog() {
+	log.Printf("[Watchdog] Stale agent watchdog started")
+	for {
+		m.mu.RLock()
+		timeout := m.staleTimeout
+		m.mu.RUnlock()
+
+		// Check a few times per timeout window, but at least every 30 seconds
+		interval := timeout / 4
+		if interval <= 0 || interval > 30*time.Second {
+			interval = 30 * time.Second
+		}
+		if interval < time.Second {
+			interval = time.Second
+		}
+		time.Sleep(interval)
+
+		m.checkStaleAgents()
+	}
+}
+
+// checkStaleAgents flags (and optionally kills) running agents that stopped producing output
+func (m *Manager) checkStaleAgents() {
+	m.mu.RLock()
+	timeout := m.staleTimeout
+	autoKill := m.autoKillStale
+	agents := make([]*Agent, 0, len(m.agents))
+	for _, agent := range m.agents {
+		agents = append(agents, agent)
+	}
+	m.mu.RUnlock()
+
+	if timeout <= 0 {
+		return
+	}
+
+	for _, agent := range agents {
+		if agent.GetStatus() != StatusRunning {
+			continue
+		}
+		idle := time.Since(agent.GetLastActivity())
+		if idle < timeout {
+			continue
+		}
+
+		if agent.markStale() {
+			log.Printf("[Watchdog] Agent %s has produced no output for %s, marking as stale", agent.ID, idle.Round(time.Second))
+		}
+		if autoKill {
+			agent.killStale(timeout)
+		}
+	}
+}
+
 // LaunchAgent creates and starts a new agent or queues it if one is already running in the folder
 func (m *Manager) LaunchAgent(ctx context.Context, folder, prompt string) (string, error) {
-	// Check if an agent is already running in this folder
+	return m.LaunchAgentWithOptions(ctx, folder, prompt, AgentOptions{})
+}
+
+// LaunchAgentWithOptions is like LaunchAgent but applies the given options to the agent
+func (m *Manager) LaunchAgentWithOptions(ctx context.Context, folder, prompt string, opts AgentOptions) (string, error) {
+	if err := m.CheckTokenBudget(); err != nil {
+		return "", err
+	}
+
+	opts.Labels = copyStringMap(opts.Labels)
+	opts.Env = copyStringMap(opts.Env)
+	task := QueuedTask{
+		Folder:  folder,
+		Prompt:  prompt,
+		Ctx:     ctx,
+		QueueID: fmt.Sprintf("queue-%d-%s", time.Now().Unix(), folder),
+		Options: opts,
+	}
+
+	// Queue behind an agent already running in this folder or behind the global limit
 	m.queueMu.Lock()
-	if runningID, exists := m.runningPerFolder[folder]; exists {
-		// Agent is already running in this folder, add to queue
-		queueID := fmt.Sprintf("queue-%d-%s", time.Now().Unix(), folder)
-		task := QueuedTask{
-			Folder:  folder,
-			Prompt:  prompt,
-			Ctx:     ctx,
-			QueueID: queueID,
-		}
-
-		m.folderQueues[folder] = append(m.folderQueues[folder], task)
-		queuePos := len(m.folderQueues[folder])
+	if placeholder, queued := m.queueTaskLocked(task); queued {
 		m.queueMu.Unlock()
-
-		// Return a placeholder ID indicating the task is queued
-		return fmt.Sprintf("queued-%s-pos-%d-qid-%s", runningID, queuePos, queueID), nil
+		m.publishQueued(task)
+		return placeholder, nil
 	}
 
-	// No agent running in this folder, start immediately
-	id := m.createAndStartAgent(ctx, folder, prompt)
-	m.runningPerFolder[folder] = id
+	// No agent running in this folder, start immediately. Hold the folder,
+	// and with it a slot, while the agent is created outside queueMu.
+	m.runningPerFolder[folder] = task.QueueID
 	m.queueMu.Unlock()
 
-	return id, nil
-}
+	id := m.createAndStartAgentWithQueueID(ctx, folder, prompt, "", opts)
+	m.queueMu.Lock()
+	m.claimFolderLocked(folder, task.QueueID, id)
+	m.queueMu.Unlock()
 
-// createAndStartAgent is a helper that creates and starts an agent
-func (m *Manager) createAndStartAgent(ctx context.Context, folder, prompt string) string {
-	return m.createAndStartAgentWithQueueID(ctx, folder, prompt, "")
+	return id, nil
 }
 
 // createAndStartAgentWithQueueID is a helper that creates and starts an agent with optional queue ID
-func (m *Manager) createAndStartAgentWithQueueID(ctx context.Context, folder, prompt, queueID string) string {
+func (m *Manager) createAndStartAgentWithQueueID(ctx context.Context, folder, prompt, queueID string, opts AgentOptions) string {
 	m.mu.Lock()
 	var agentNum int
 	if len(m.availableIDs) > 0 {
@@ -102,17 +215,33 @@ func (m *Manager) createAndStartAgentWithQueueID(ctx context.Context, folder, pr
 	m.mu.Unlock()
 
 	agent := NewAgent(id, folder, prompt)
+	if opts.PlanFilename != "" {
+		agent.PlanFilename = opts.PlanFilename
+	}
+	agent.Model = opts.Model
+	agent.SetStructuredOutput(opts.UseStructuredOutput)
+	agent.SetLabels(opts.Labels)
+	agent.SetEnv(opts.Env)
+
+	m.applyTokenBudget(agent)
 
 	// Set completion callback to ensure notifications are sent before queue processing
-	agent.SetCompletionCallback(func(a *Agent) {
+	agent.AddCompletionCallback(func(a *Agent) {
 		log.Printf("[Manager] Agent %s completed with status %s", a.ID, a.GetStatus())
+		m.recordAgentTokens(a)
 		// The monitor will detect this completion and send notifications
 		// then call RemoveAgent which will trigger ProcessQueueForFolder
 	})
+	m.observeAgent(agent)
+	if opts.OnCreate != nil {
+		opts.OnCreate(agent)
+	}
 
 	m.mu.Lock()
 	m.agents[id] = agent
+	m.launchedTotal++
 	m.mu.Unlock()
+	m.publishEvent(AgentEvent{Type: EventLaunched, AgentID: id, Info: agent.ToInfo()})
 
 	// Start agent with completion callback
 	go func() {
@@ -124,6 +253,15 @@ func (m *Manager) createAndStartAgentWithQueueID(ctx context.Context, folder, pr
 	return id
 }
 
+// claimFolderLocked hands the folder reserved under queueID to the agent
+// created for it. If the agent already finished and the folder was released,
+// the folder stays free. Callers hold queueMu.
+func (m *Manager) claimFolderLocked(folder, queueID, agentID string) {
+	if m.runningPerFolder[folder] == queueID {
+		m.runningPerFolder[folder] = agentID
+	}
+}
+
 // ProcessQueueForFolder checks if there are queued tasks for a folder and starts the next one
 // This is now public to allow recovery mechanisms to trigger queue processing
 func (m *Manager) ProcessQueueForFolder(folder string) {
@@ -138,13 +276,23 @@ func (m *Manager) ProcessQueueForFolder(folder string) {
 	var taskToProcess *QueuedTask
 	if queue, exists := m.folderQueues[folder]; exists && len(queue) > 0 {
 		log.Printf("[QueueProcessor] Found %d queued tasks for folder %s", len(queue), folder)
-		// Get the next task
-		taskToProcess = &queue[0]
-		m.folderQueues[folder] = queue[1:]
-
-		// If queue is now empty, remove it
-		if len(m.folderQueues[folder]) == 0 {
+		if m.atCapacityLocked() {
+			// The limit was lowered while the folder was busy, so its tasks
+			// now wait for a free slot like any other launch
+			log.Printf("[QueueProcessor] Concurrency limit reached, moving tasks of folder %s to the global queue", folder)
+			m.globalQueue = append(m.globalQueue, queue...)
 			delete(m.folderQueues, folder)
+		} else {
+			// Get the next task
+			taskToProcess = &queue[0]
+			m.folderQueues[folder] = queue[1:]
+
+			// If queue is now empty, remove it
+			if len(m.folderQueues[folder]) == 0 {
+				delete(m.folderQueues, folder)
+			}
+			// Hold the folder, and with it a slot, while the agent starts
+			m.runningPerFolder[folder] = taskToProcess.QueueID
 		}
 	} else {
 		log.Printf("[QueueProcessor] No queued tasks for folder %s", folder)
@@ -154,26 +302,63 @@ func (m *Manager) ProcessQueueForFolder(folder string) {
 
 	// Process the task outside of the queue lock to avoid deadlock
 	if taskToProcess != nil {
-		log.Printf("[QueueProcessor] Starting queued task for folder %s, QueueID: %s", folder, taskToProcess.QueueID)
-		// Start the queued task with its queue ID
-		id := m.createAndStartAgentWithQueueID(taskToProcess.Ctx, taskToProcess.Folder, taskToProcess.Prompt, taskToProcess.QueueID)
+		m.startQueuedTask(*taskToProcess)
+	}
 
-		// Update the running agent for this folder
-		m.queueMu.Lock()
-		m.runningPerFolder[folder] = id
-		m.queueMu.Unlock()
-		log.Printf("[QueueProcessor] Started agent %s for queued task in folder %s", id, folder)
+	// Hand a slot this folder did not reuse to tasks waiting for the limit
+	m.startWaitingTasks()
+}
 
-		// Call the callback if set
-		if m.startCallback != nil {
-			log.Printf("[QueueProcessor] Calling start callback for agent %s", id)
-			m.startCallback(id, taskToProcess.Folder, taskToProcess.Prompt, taskToProcess.QueueID)
-		}
+// startQueuedTask starts a task taken from a queue. The caller has already
+// reserved the task's folder in runningPerFolder.
+func (m *Manager) startQueuedTask(task QueuedTask) {
+	log.Printf("[QueueProcessor] Starting queued task for folder %s, QueueID: %s", task.Folder, task.QueueID)
+	// Start the queued task with its queue ID
+	id := m.createAndStartAgentWithQueueID(task.Ctx, task.Folder, task.Prompt, task.QueueID, task.Options)
+
+	// Update the running agent for this folder
+	m.queueMu.Lock()
+	m.claimFolderLocked(task.Folder, task.QueueID, id)
+	m.queueMu.Unlock()
+	log.Printf("[QueueProcessor] Started agent %s for queued task in folder %s", id, task.Folder)
+
+	// Call the callback if set
+	if m.startCallback != nil {
+		log.Printf("[QueueProcessor] Calling start callback for agent %s", id)
+		m.startCallback(id, task.Folder, task.Prompt, task.QueueID)
 	}
 }
 
-// LaunchAgentWithID creates and starts a new agent with a custom ID
+// RetryAgent launches a new agent with the same folder, prompt, plan file and
+// options as the agent with the given ID, which must no longer be running.
+// Like LaunchAgentWithOptions, the returned ID may be a queued placeholder.
+func (m *Manager) RetryAgent(ctx context.Context, id string) (string, error) {
+	m.mu.RLock()
+	agent, exists := m.agents[id]
+	m.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("agent %s not found", id)
+	}
+
+	status := agent.GetStatus()
+	if status == StatusPending || status == StatusRunning {
+		return "", fmt.Errorf("agent %s is still %s", id, status)
+	}
+	if _, err := os.Stat(agent.Folder); err != nil {
+		return "", fmt.Errorf("working directory %s is no longer available: %w", agent.Folder, err)
+	}
+
+	log.Printf("[Manager] Retrying agent %s in folder %s", id, agent.Folder)
+	return m.LaunchAgentWithOptions(ctx, agent.Folder, agent.Prompt, agent.launchOptions())
+}
+
+// LaunchAgentWithID creates and starts a new agent with a custom ID. It starts
+// right away even when MaxConcurrentAgents agents are running.
 func (m *Manager) LaunchAgentWithID(ctx context.Context, id, folder, prompt string) error {
+	if err := m.CheckTokenBudget(); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	if _, exists := m.agents[id]; exists {
 		m.mu.Unlock()
@@ -183,16 +368,22 @@ func (m *Manager) LaunchAgentWithID(ctx context.Context, id, folder, prompt stri
 
 	agent := NewAgent(id, folder, prompt)
 
+	m.applyTokenBudget(agent)
+
 	// Set completion callback to ensure notifications are sent before queue processing
-	agent.SetCompletionCallback(func(a *Agent) {
+	agent.AddCompletionCallback(func(a *Agent) {
 		log.Printf("[Manager] Agent %s completed with status %s", a.ID, a.GetStatus())
+		m.recordAgentTokens(a)
 		// The monitor will detect this completion and send notifications
 		// then call RemoveAgent which will trigger ProcessQueueForFolder
 	})
+	m.observeAgent(agent)
 
 	m.mu.Lock()
 	m.agents[id] = agent
+	m.launchedTotal++
 	m.mu.Unlock()
+	m.publishEvent(AgentEvent{Type: EventLaunched, AgentID: id, Info: agent.ToInfo()})
 
 	// Track this agent as running in its folder
 	m.queueMu.Lock()
@@ -209,69 +400,10 @@ func (m *Manager) LaunchAgentWithID(ctx context.Context, id, folder, prompt stri
 	return nil
 }
 
-// LaunchAgentWithPlanFile creates and starts a new agent with a custom plan filename
+// LaunchAgentWithPlanFile creates and starts a new agent with a custom plan
+// filename, or queues it like LaunchAgent
 func (m *Manager) LaunchAgentWithPlanFile(ctx context.Context, folder, prompt, planFilename string) (string, error) {
-	// Check if an agent is already running in this folder
-	m.queueMu.Lock()
-	if runningID, exists := m.runningPerFolder[folder]; exists {
-		// Agent is already running in this folder, add to queue
-		queueID := fmt.Sprintf("queue-%d-%s", time.Now().Unix(), folder)
-		task := QueuedTask{
-			Folder:  folder,
-			Prompt:  prompt,
-			Ctx:     ctx,
-			QueueID: queueID,
-		}
-
-		m.folderQueues[folder] = append(m.folderQueues[folder], task)
-		queuePos := len(m.folderQueues[folder])
-		m.queueMu.Unlock()
-
-		// Return a placeholder ID indicating the task is queued
-		return fmt.Sprintf("queued-%s-pos-%d-qid-%s", runningID, queuePos, queueID), nil
-	}
-	m.queueMu.Unlock()
-
-	m.mu.Lock()
-	var agentNum int
-	if len(m.availableIDs) > 0 {
-		// Reuse an available ID
-		agentNum = m.availableIDs[0]
-		m.availableIDs = m.availableIDs[1:]
-	} else {
-		// Use next sequential ID
-		agentNum = m.nextID
-		m.nextID++
-	}
-	id := fmt.Sprintf("%d", agentNum)
-	m.mu.Unlock()
-
-	agent := NewAgentWithPlanFile(id, folder, prompt, planFilename)
-
-	// Set completion callback to ensure notifications are sent before queue processing
-	agent.SetCompletionCallback(func(a *Agent) {
-		log.Printf("[Manager] Agent %s completed with status %s", a.ID, a.GetStatus())
-		// The monitor will detect this completion and send notifications
-		// then call RemoveAgent which will trigger ProcessQueueForFolder
-	})
-
-	m.mu.Lock()
-	m.agents[id] = agent
-	m.mu.Unlock()
-
-	// Track this agent as running in its folder
-	m.queueMu.Lock()
-	m.runningPerFolder[folder] = id
-	m.queueMu.Unlock()
-
-	// Start agent with completion callback for queue processing
-	go func() {
-		agent.Start(ctx)
-		// The completion callback will be called when the agent finishes
-		// The monitor will handle notification and removal
-	}()
-
-	return id, nil
+	return m.LaunchAgentWithOptions(ctx, folder, prompt, AgentOptions{PlanFilename: planFilename})
 }
 
 // GetAgent returns an agent by ID
@@ -325,6 +457,154 @@ func (m *Manager) ListAgentsByStatus(status AgentStatus) []AgentInfo {
 	return infos
 }
 
+// ListAgentsByLabel returns agents whose label key has the given value
+func (m *Manager) ListAgentsByLabel(key, value string) []AgentInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var infos []AgentInfo
+	for _, agent := range m.agents {
+		info := agent.ToInfo()
+		if labelValue, ok := info.Labels[key]; ok && labelValue == value {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// AgentFilter narrows the agents returned by FilterAgents
+type AgentFilter struct {
+	Status AgentStatus // Only agents with this status (empty matches all)
+	Folder string      // Only agents working in this folder or below it (empty matches all)
+}
+
+// FilterAgents returns the agents matching filter, most recently started first
+func (m *Manager) FilterAgents(filter AgentFilter) []AgentInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var infos []AgentInfo
+	for _, agent := range m.agents {
+		info := agent.ToInfo()
+		if filter.Status != "" && info.Status != filter.Status {
+			continue
+		}
+		if filter.Folder != "" && !InFolder(info.Folder, filter.Folder) {
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	sortAgentsNewestFirst(infos)
+	return infos
+}
+
+// SearchOptions narrows the agents returned by SearchAgents
+type SearchOptions struct {
+	Status    AgentStatus // Only agents with this status (empty matches all)
+	StartTime time.Time   // Only agents started at or after this time
+	EndTime   time.Time   // Only agents started at or before this time
+	Folder    string      // Only agents working in this folder or below it
+}
+
+// InFolder reports whether folder is dir or inside it
+func InFolder(folder, dir string) bool {
+	folder, dir = filepath.Clean(folder), filepath.Clean(dir)
+	return folder == dir || strings.HasPrefix(folder, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// SearchAgents returns the agents whose prompt or output contains query
+// (case-insensitive) and that match opts, most recently started first.
+// Running agents are matched against the output produced so far.
+func (m *Manager) SearchAgents(query string, opts SearchOptions) []AgentInfo {
+	m.mu.RLock()
+	agents := make([]*Agent, 0, len(m.agents))
+	for _, agent := range m.agents {
+		agents = append(agents, agent)
+	}
+	m.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	var infos []AgentInfo
+	for _, agent := range agents {
+		info := agent.ToInfo()
+
+		// Check status filter
+		if opts.Status != "" && info.Status != opts.Status {
+			continue
+		}
+
+		// Check time filters
+		if !opts.StartTime.IsZero() && info.StartTime.Before(opts.StartTime) {
+			continue
+		}
+		if !opts.EndTime.IsZero() && info.StartTime.After(opts.EndTime) {
+			continue
+		}
+
+		if opts.Folder != "" && !InFolder(info.Folder, opts.Folder) {
+			continue
+		}
+
+		// Check content filter
+		if query != "" && !strings.Contains(strings.ToLower(info.Prompt), query) &&
+			!strings.Contains(strings.ToLower(agent.outputText()), query) {
+			continue
+		}
+
+		infos = append(infos, info)
+	}
+
+	sortAgentsNewestFirst(infos)
+	return infos
+}
+
+// ListAgentsPaged returns one page of agents matching statusFilter (empty
+// matches all) and the total number of matching agents. Running and pending
+// agents are always returned first, on every page; offset and limit page
+// through the remaining agents, most recently started first. A limit <= 0
+// returns all of them.
+func (m *Manager) ListAgentsPaged(offset, limit int, statusFilter string) ([]AgentInfo, int) {
+	var active, inactive []AgentInfo
+	for _, info := range m.ListAgents() {
+		if statusFilter != "" && string(info.Status) != statusFilter {
+			continue
+		}
+		if info.Status == StatusRunning || info.Status == StatusPending {
+			active = append(active, info)
+		} else {
+			inactive = append(inactive, info)
+		}
+	}
+	sortAgentsNewestFirst(active)
+	sortAgentsNewestFirst(inactive)
+	total := len(active) + len(inactive)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(inactive) {
+		offset = len(inactive)
+	}
+	end := len(inactive)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return append(active, inactive[offset:end]...), total
+}
+
+// sortAgentsNewestFirst sorts agents by start time, newest first, then by ID
+func sortAgentsNewestFirst(infos []AgentInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].StartTime.Equal(infos[j].StartTime) {
+			return infos[i].StartTime.After(infos[j].StartTime)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+}
+
 // KillAgent terminates a running agent
 func (m *Manager) KillAgent(id string) error {
 	agent, err := m.GetAgent(id)
@@ -335,6 +615,41 @@ func (m *Manager) KillAgent(id string) error {
 	return agent.Kill()
 }
 
+// KillAllAgents kills every running agent and waits up to killAllTimeout for
+// them to exit. It returns one error per agent that could not be stopped.
+func (m *Manager) KillAllAgents() []error {
+	m.mu.RLock()
+	var running []*Agent
+	for _, agent := range m.agents {
+		if agent.GetStatus() == StatusRunning {
+			running = append(running, agent)
+		}
+	}
+	m.mu.RUnlock()
+
+	var errs []error
+	var killed []*Agent
+	for _, agent := range running {
+		if err := agent.Kill(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to kill agent %s: %w", agent.ID, err))
+			continue
+		}
+		killed = append(killed, agent)
+	}
+
+	timeout := time.After(killAllTimeout)
+	for _, agent := range killed {
+		select {
+		case <-agent.Done():
+		case <-timeout:
+			errs = append(errs, fmt.Errorf("agent %s did not exit within %s", agent.ID, killAllTimeout))
+		}
+	}
+
+	log.Printf("[Manager] Killed %d of %d running agents", len(running)-len(errs), len(running))
+	return errs
+}
+
 // RemoveAgent removes an agent from the manager
 func (m *Manager) RemoveAgent(id string) error {
 	log.Printf("[RemoveAgent] Attempting to remove agent %s", id)
@@ -405,21 +720,85 @@ func (m *Manager) WaitForAgent(ctx context.Context, id string) (AgentInfo, error
 		return AgentInfo{}, err
 	}
 
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+	select {
+	case <-ctx.Done():
+		return AgentInfo{}, ctx.Err()
+	case <-agent.Done():
+		return agent.ToInfo(), nil
+	}
+}
 
-	for {
+// WaitForAnyAgent waits until one of the given agents finishes and returns its
+// ID and final state. If several have already finished, the first in agentIDs wins.
+func (m *Manager) WaitForAnyAgent(ctx context.Context, agentIDs []string) (string, AgentInfo, error) {
+	agents, err := m.lookupAgents(agentIDs)
+	if err != nil {
+		return "", AgentInfo{}, err
+	}
+	for _, agent := range agents {
 		select {
-		case <-ctx.Done():
-			return AgentInfo{}, ctx.Err()
-		case <-ticker.C:
-			status := agent.GetStatus()
-			if status == StatusFinished || status == StatusFailed || status == StatusKilled {
-				// ToInfo() is safe to call here as it no longer has nested lock issues
-				return agent.ToInfo(), nil
+		case <-agent.Done():
+			return agent.ID, agent.ToInfo(), nil
+		default:
+		}
+	}
+
+	finished := make(chan *Agent, len(agents))
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, agent := range agents {
+		go func(agent *Agent) {
+			select {
+			case <-agent.Done():
+				finished <- agent
+			case <-stop:
 			}
+		}(agent)
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", AgentInfo{}, ctx.Err()
+	case agent := <-finished:
+		return agent.ID, agent.ToInfo(), nil
+	}
+}
+
+// WaitForAllAgents waits until all of the given agents finish and returns
+// their final state keyed by agent ID
+func (m *Manager) WaitForAllAgents(ctx context.Context, agentIDs []string) (map[string]AgentInfo, error) {
+	agents, err := m.lookupAgents(agentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]AgentInfo, len(agents))
+	for _, agent := range agents {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-agent.Done():
+			results[agent.ID] = agent.ToInfo()
+		}
+	}
+	return results, nil
+}
+
+// lookupAgents resolves agent IDs, failing if the list is empty or any ID is unknown
+func (m *Manager) lookupAgents(agentIDs []string) ([]*Agent, error) {
+	if len(agentIDs) == 0 {
+		return nil, fmt.Errorf("no agent IDs given")
+	}
+
+	agents := make([]*Agent, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		agent, err := m.GetAgent(id)
+		if err != nil {
+			return nil, err
 		}
+		agents = append(agents, agent)
 	}
+	return agents, nil
 }
 
 // CleanupFinishedAgents removes all finished, failed, or killed agents
@@ -455,6 +834,11 @@ func (m *Manager) CleanupFinishedAgents() int {
 
 // GetRunningCount returns the number of currently running agents
 func (m *Manager) GetRunningCount() int {
+	return m.RunningCount()
+}
+
+// RunningCount returns the number of currently running agents
+func (m *Manager) RunningCount() int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -469,16 +853,28 @@ func (m *Manager) GetRunningCount() int {
 	return count
 }
 
-// GetRunningAgentForFolder returns the agent ID running in the specified folder, or empty string if none
-func (m *Manager) GetRunningAgentForFolder(folder string) string {
+// GetRunningAgentForFolder returns the agent holding the specified folder,
+// reporting false if no agent is running there
+func (m *Manager) GetRunningAgentForFolder(folder string) (AgentInfo, bool) {
 	m.queueMu.Lock()
-	defer m.queueMu.Unlock()
-
 	agentID, exists := m.runningPerFolder[folder]
+	m.queueMu.Unlock()
 	if !exists {
-		return ""
+		return AgentInfo{}, false
 	}
-	return agentID
+
+	agent, err := m.GetAgent(agentID)
+	if err != nil {
+		return AgentInfo{}, false
+	}
+	return agent.ToInfo(), true
+}
+
+// LaunchedCount returns the number of agents launched since the manager was created
+func (m *Manager) LaunchedCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.launchedTotal
 }
 
 // GetTotalCount returns the total number of agents
@@ -489,7 +885,8 @@ func (m *Manager) GetTotalCount() int {
 	return len(m.agents)
 }
 
-// GetQueueStatus returns information about queued tasks for each folder
+// GetQueueStatus returns the number of queued tasks for each folder, including
+// tasks waiting behind MaxConcurrentAgents
 func (m *Manager) GetQueueStatus() map[string]int {
 	m.queueMu.Lock()
 	defer m.queueMu.Unlock()
@@ -498,37 +895,82 @@ func (m *Manager) GetQueueStatus() map[string]int {
 	for folder, queue := range m.folderQueues {
 		status[folder] = len(queue)
 	}
+	for _, task := range m.globalQueue {
+		status[task.Folder]++
+	}
 
 	return status
 }
 
-// GetDetailedQueueStatus returns detailed information about all queued tasks
+// GetDetailedQueueStatus returns the queued tasks of every folder with a
+// queue: first those waiting for the folder, then those waiting for the
+// global limit. GetFolderQueues tells the two apart.
 func (m *Manager) GetDetailedQueueStatus() map[string][]QueuedTask {
+	detailedStatus := make(map[string][]QueuedTask)
+	for folder, queue := range m.GetFolderQueues() {
+		detailedStatus[folder] = append(queue.Tasks, queue.WaitingForSlot...)
+	}
+	return detailedStatus
+}
+
+// GetFolderQueues returns the queued tasks of every folder with a queue,
+// together with the agent currently holding the folder. Tasks waiting for the
+// folder are in Tasks, those waiting for the global limit in WaitingForSlot.
+func (m *Manager) GetFolderQueues() map[string]FolderQueue {
 	m.queueMu.Lock()
 	defer m.queueMu.Unlock()
 
 	// Create a copy of the queue data to avoid holding the lock too long
-	detailedStatus := make(map[string][]QueuedTask)
+	detailedStatus := make(map[string]FolderQueue)
 	for folder, queue := range m.folderQueues {
 		// Create a copy of the tasks
 		tasksCopy := make([]QueuedTask, len(queue))
 		copy(tasksCopy, queue)
-		detailedStatus[folder] = tasksCopy
+		detailedStatus[folder] = FolderQueue{
+			RunningAgentID: m.runningPerFolder[folder],
+			Tasks:          tasksCopy,
+		}
+	}
+	for _, task := range m.globalQueue {
+		queue := detailedStatus[task.Folder]
+		queue.RunningAgentID = m.runningPerFolder[task.Folder]
+		queue.WaitingForSlot = append(queue.WaitingForSlot, task)
+		detailedStatus[task.Folder] = queue
 	}
 
 	return detailedStatus
 }
 
-// GetQueuedTasksForFolder returns the number of queued tasks for a specific folder
-func (m *Manager) GetQueuedTasksForFolder(folder string) int {
+// ClearQueues drops every queued task and returns the dropped tasks
+func (m *Manager) ClearQueues() []QueuedTask {
 	m.queueMu.Lock()
 	defer m.queueMu.Unlock()
 
-	if queue, exists := m.folderQueues[folder]; exists {
-		return len(queue)
+	var dropped []QueuedTask
+	for folder, queue := range m.folderQueues {
+		dropped = append(dropped, queue...)
+		delete(m.folderQueues, folder)
 	}
+	dropped = append(dropped, m.globalQueue...)
+	m.globalQueue = nil
+
+	log.Printf("[Manager] Cleared %d queued tasks", len(dropped))
+	return dropped
+}
 
-	return 0
+// GetQueuedTasksForFolder returns the number of queued tasks for a specific
+// folder, including tasks waiting behind MaxConcurrentAgents
+func (m *Manager) GetQueuedTasksForFolder(folder string) int {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+
+	count := len(m.folderQueues[folder])
+	for _, task := range m.globalQueue {
+		if task.Folder == folder {
+			count++
+		}
+	}
+	return count
 }
 
 // IsAgentRunningInFolder checks if an agent is currently running in the specified folder
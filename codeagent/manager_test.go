@@ -7,6 +7,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -380,3 +382,443 @@ func TestIDReuse(t *testing.T) {
 	// Clean up
 	manager.KillAgent(id2)
 }
+
+// TestLaunchAgentWithOptions verifies that launch options reach the agent, including queued ones
+func TestLaunchAgentWithOptions(t *testing.T) {
+	manager := NewManager()
+	ctx := context.Background()
+	testFolder := "/tmp/test-launch-options"
+
+	agentID, err := manager.LaunchAgentWithOptions(ctx, testFolder, "First task", AgentOptions{Model: "opus"})
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+
+	info, err := manager.GetAgentInfo(agentID)
+	if err != nil {
+		t.Fatalf("Failed to get agent info: %v", err)
+	}
+	if info.Model != "opus" {
+		t.Errorf("Expected model opus, got %q", info.Model)
+	}
+
+	// Queue a second task and make sure its options are preserved
+	if _, err := manager.LaunchAgentWithOptions(ctx, testFolder, "Second task", AgentOptions{Model: "sonnet"}); err != nil {
+		t.Fatalf("Failed to queue agent: %v", err)
+	}
+	queued := manager.GetFolderQueues()[testFolder].Tasks
+	if len(queued) != 1 || queued[0].Options.Model != "sonnet" {
+		t.Errorf("Expected queued task with model sonnet, got %+v", queued)
+	}
+
+	// Plain LaunchAgent must not set a model
+	otherID, err := manager.LaunchAgent(ctx, "/tmp/test-launch-options-default", "Task")
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+	if info, _ := manager.GetAgentInfo(otherID); info.Model != "" {
+		t.Errorf("Expected empty model, got %q", info.Model)
+	}
+}
+
+// TestStaleWatchdog verifies that agents without output are flagged and optionally killed
+func TestStaleWatchdog(t *testing.T) {
+	manager := NewManager()
+	manager.mu.Lock()
+	manager.staleTimeout = 50 * time.Millisecond
+	manager.mu.Unlock()
+
+	agent := NewAgent("stale-1", "/tmp", "test")
+	agent.Status = StatusRunning
+	agent.StartTime = time.Now().Add(-time.Second)
+	agent.lastActivity = agent.StartTime
+
+	callbackCount := 0
+	agent.SetCompletionCallback(func(a *Agent) {
+		callbackCount++
+	})
+
+	manager.mu.Lock()
+	manager.agents[agent.ID] = agent
+	manager.mu.Unlock()
+
+	// Without auto-kill the agent is only flagged
+	manager.checkStaleAgents()
+	info := agent.ToInfo()
+	if !info.IsStale {
+		t.Error("Expected agent to be marked as stale")
+	}
+	if info.Status != StatusRunning {
+		t.Errorf("Expected status running, got %s", info.Status)
+	}
+
+	// With auto-kill the agent is terminated and the callback fires once
+	manager.SetAutoKillStale(true)
+	manager.checkStaleAgents()
+	manager.checkStaleAgents()
+	if status := agent.GetStatus(); status != StatusKilled {
+		t.Errorf("Expected status killed, got %s", status)
+	}
+	if callbackCount != 1 {
+		t.Errorf("Expected completion callback to fire once, got %d", callbackCount)
+	}
+}
+
+func TestKillAllAgents(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "fake-claude")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho started\nsleep 30\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+
+	manager := NewManager()
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id, err := manager.LaunchAgent(context.Background(), t.TempDir(), "test")
+		if err != nil {
+			t.Fatalf("Failed to launch agent: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Wait until both processes are running
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range ids {
+		agent, _ := manager.GetAgent(id)
+		for len(agent.GetOutputTail(1)) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Agent %s did not start", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if count := manager.RunningCount(); count != 2 {
+		t.Fatalf("Expected 2 running agents, got %d", count)
+	}
+
+	// The agents' children (sleep) must die too, or the wait would time out
+	if errs := manager.KillAllAgents(); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+	if count := manager.RunningCount(); count != 0 {
+		t.Errorf("Expected no running agents, got %d", count)
+	}
+	if errs := manager.KillAllAgents(); len(errs) != 0 {
+		t.Errorf("Expected no errors with nothing running, got %v", errs)
+	}
+}
+
+func TestListAgentsByLabel(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "delay"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Failed to write delay file: %v", err)
+	}
+
+	reviewOpts := AgentOptions{Labels: map[string]string{"kind": "review"}}
+	reviewID, err := manager.LaunchAgentWithOptions(context.Background(), dir, "review", reviewOpts)
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+	// Changing the caller's map must not affect the agent
+	reviewOpts.Labels["kind"] = "changed"
+
+	commitOpts := AgentOptions{Labels: map[string]string{"kind": "commit"}}
+	queuedID, err := manager.LaunchAgentWithOptions(context.Background(), dir, "commit", commitOpts)
+	if err != nil {
+		t.Fatalf("Failed to queue agent: %v", err)
+	}
+	if !strings.HasPrefix(queuedID, "queued-") {
+		t.Fatalf("Expected second agent to be queued, got %s", queuedID)
+	}
+	commitOpts.Labels["kind"] = "changed"
+
+	reviews := manager.ListAgentsByLabel("kind", "review")
+	if len(reviews) != 1 || reviews[0].ID != reviewID {
+		t.Errorf("Expected only agent %s labelled kind=review, got %v", reviewID, reviews)
+	}
+	if agents := manager.ListAgentsByLabel("kind", "commit"); len(agents) != 0 {
+		t.Errorf("Expected no running commit agents, got %d", len(agents))
+	}
+
+	// Labels are preserved through the queue
+	tasks := manager.GetFolderQueues()[dir].Tasks
+	if len(tasks) != 1 || tasks[0].Options.Labels["kind"] != "commit" {
+		t.Fatalf("Expected queued task labelled kind=commit, got %v", tasks)
+	}
+	if _, err := manager.WaitForAgent(context.Background(), reviewID); err != nil {
+		t.Fatalf("Failed to wait for agent: %v", err)
+	}
+	if err := manager.RemoveAgent(reviewID); err != nil {
+		t.Fatalf("Failed to remove agent: %v", err)
+	}
+	commits := manager.ListAgentsByLabel("kind", "commit")
+	if len(commits) != 1 || commits[0].Labels["kind"] != "commit" {
+		t.Errorf("Expected dequeued agent labelled kind=commit, got %v", commits)
+	}
+}
+
+func TestListAgentsPaged(t *testing.T) {
+	manager := NewManager()
+	base := time.Now().Add(-time.Hour)
+
+	add := func(id string, status AgentStatus, age time.Duration) {
+		agent := NewAgent(id, "/repo", "test")
+		agent.Status = status
+		agent.StartTime = base.Add(-age)
+		manager.agents[id] = agent
+	}
+	add("running-old", StatusRunning, 10*time.Minute)
+	add("pending", StatusPending, 0)
+	add("done-1", StatusFinished, 1*time.Minute)
+	add("done-2", StatusFinished, 2*time.Minute)
+	add("failed-3", StatusFailed, 3*time.Minute)
+	add("done-4", StatusFinished, 4*time.Minute)
+
+	ids := func(infos []AgentInfo) []string {
+		result := make([]string, len(infos))
+		for i, info := range infos {
+			result[i] = info.ID
+		}
+		return result
+	}
+
+	page, total := manager.ListAgentsPaged(0, 2, "")
+	if total != 6 {
+		t.Errorf("Expected total 6, got %d", total)
+	}
+	expected := []string{"pending", "running-old", "done-1", "done-2"}
+	if got := ids(page); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Active agents appear on every page
+	page, _ = manager.ListAgentsPaged(2, 2, "")
+	expected = []string{"pending", "running-old", "failed-3", "done-4"}
+	if got := ids(page); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	page, _ = manager.ListAgentsPaged(10, 2, "")
+	if len(page) != 2 {
+		t.Errorf("Expected only active agents past the last page, got %v", ids(page))
+	}
+
+	page, total = manager.ListAgentsPaged(1, 0, string(StatusFinished))
+	if total != 3 {
+		t.Errorf("Expected 3 finished agents, got %d", total)
+	}
+	expected = []string{"done-2", "done-4"}
+	if got := ids(page); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestSearchAgents(t *testing.T) {
+	manager := NewManager()
+	now := time.Now()
+
+	add := func(id, prompt, output string, status AgentStatus, age time.Duration) {
+		agent := NewAgent(id, "/repo", prompt)
+		agent.Status = status
+		agent.Output = output
+		agent.StartTime = now.Add(-age)
+		manager.agents[id] = agent
+	}
+	add("1", "Fix login in auth.go", "", StatusFinished, 3*time.Hour)
+	add("2", "Add tests", "edited Auth.go and main.go", StatusFailed, 2*time.Hour)
+	add("3", "Update README", "done", StatusFinished, time.Hour)
+
+	// A running agent is matched against its live output
+	running := NewAgent("4", "/repo", "Refactor")
+	running.Status = StatusRunning
+	running.StartTime = now
+	running.liveOutput.WriteString("reading auth.go\n")
+	manager.agents["4"] = running
+
+	ids := func(infos []AgentInfo) string {
+		result := make([]string, len(infos))
+		for i, info := range infos {
+			result[i] = info.ID
+		}
+		return strings.Join(result, ",")
+	}
+
+	if got := ids(manager.SearchAgents("AUTH.GO", SearchOptions{})); got != "4,2,1" {
+		t.Errorf("Expected agents 4,2,1 newest first, got %s", got)
+	}
+	if got := ids(manager.SearchAgents("auth.go", SearchOptions{Status: StatusFinished})); got != "1" {
+		t.Errorf("Expected only finished agent 1, got %s", got)
+	}
+	opts := SearchOptions{StartTime: now.Add(-150 * time.Minute), EndTime: now.Add(-time.Minute)}
+	if got := ids(manager.SearchAgents("", opts)); got != "3,2" {
+		t.Errorf("Expected agents 3,2 in the time range, got %s", got)
+	}
+	manager.agents["5"] = NewAgent("5", "/other/app", "Fix auth.go")
+	if got := ids(manager.SearchAgents("auth.go", SearchOptions{Folder: "/other"})); got != "5" {
+		t.Errorf("Expected only agent 5 under /other, got %s", got)
+	}
+	if got := manager.SearchAgents("", SearchOptions{Folder: "/oth"}); len(got) != 0 {
+		t.Errorf("Expected a folder prefix not to match, got %d", len(got))
+	}
+	if got := manager.SearchAgents("nothing matches", SearchOptions{}); len(got) != 0 {
+		t.Errorf("Expected no matches, got %d", len(got))
+	}
+}
+
+func TestRetryAgent(t *testing.T) {
+	SetClaudeBinary("mavis-nonexistent-claude-binary")
+	defer SetClaudeBinary("")
+
+	manager := NewManager()
+	dir := t.TempDir()
+
+	running := NewAgent("1", dir, "still going")
+	running.Status = StatusRunning
+	manager.agents["1"] = running
+	if _, err := manager.RetryAgent(context.Background(), "1"); err == nil {
+		t.Error("Expected error when retrying a running agent")
+	}
+
+	failed := NewAgent("2", dir, "fix the bug")
+	failed.Status = StatusFailed
+	failed.Model = "opus"
+	failed.SetLabels(map[string]string{"kind": "review"})
+	manager.agents["2"] = failed
+	running.Status = StatusFinished
+
+	id, err := manager.RetryAgent(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("Failed to retry agent: %v", err)
+	}
+	retried, err := manager.GetAgent(id)
+	if err != nil {
+		t.Fatalf("Failed to get retried agent: %v", err)
+	}
+	if retried.Folder != dir || retried.Prompt != "fix the bug" || retried.Model != "opus" {
+		t.Errorf("Expected same folder, prompt and model, got %s %q %s", retried.Folder, retried.Prompt, retried.Model)
+	}
+	if labels := retried.ToInfo().Labels; labels["kind"] != "review" {
+		t.Errorf("Expected labels to be kept, got %v", labels)
+	}
+
+	// Agents with a custom plan file keep it along with their options
+	review := NewAgentWithPlanFile("4", t.TempDir(), "review the diff", "REVIEW_PLAN_1.md")
+	review.Status = StatusFinished
+	review.Model = "sonnet"
+	review.SetLabels(map[string]string{"kind": "review"})
+	manager.agents["4"] = review
+	id, err = manager.RetryAgent(context.Background(), "4")
+	if err != nil {
+		t.Fatalf("Failed to retry plan file agent: %v", err)
+	}
+	retried, err = manager.GetAgent(id)
+	if err != nil {
+		t.Fatalf("Failed to get retried plan file agent: %v", err)
+	}
+	if retried.PlanFilename != "REVIEW_PLAN_1.md" || retried.Model != "sonnet" || retried.ToInfo().Labels["kind"] != "review" {
+		t.Errorf("Expected plan file, model and labels to be kept, got %s %s %v", retried.PlanFilename, retried.Model, retried.ToInfo().Labels)
+	}
+
+	gone := NewAgentWithPlanFile("3", filepath.Join(dir, "removed-workspace"), "review", "REVIEW_PLAN_1.md")
+	gone.Status = StatusFinished
+	manager.agents["3"] = gone
+	if _, err := manager.RetryAgent(context.Background(), "3"); err == nil {
+		t.Error("Expected error when the working directory no longer exists")
+	}
+
+	if _, err := manager.RetryAgent(context.Background(), "missing"); err == nil {
+		t.Error("Expected error for unknown agent")
+	}
+}
+
+func TestClearQueues(t *testing.T) {
+	manager := NewManager()
+	manager.folderQueues["/a"] = []QueuedTask{{QueueID: "q1"}, {QueueID: "q2"}}
+	manager.folderQueues["/b"] = []QueuedTask{{QueueID: "q3"}}
+
+	dropped := manager.ClearQueues()
+	if len(dropped) != 3 {
+		t.Errorf("Expected 3 dropped tasks, got %d", len(dropped))
+	}
+	if status := manager.GetQueueStatus(); len(status) != 0 {
+		t.Errorf("Expected empty queues, got %v", status)
+	}
+
+	// With the queues cleared, finishing an agent starts nothing
+	manager.ProcessQueueForFolder("/a")
+	if count := manager.GetTotalCount(); count != 0 {
+		t.Errorf("Expected no agents to start, got %d", count)
+	}
+}
+
+func TestFilterAgents(t *testing.T) {
+	manager := NewManager()
+	now := time.Now()
+
+	add := func(id, folder string, status AgentStatus, age time.Duration) {
+		agent := NewAgent(id, folder, "task")
+		agent.Status = status
+		agent.StartTime = now.Add(-age)
+		manager.agents[id] = agent
+	}
+	add("1", "/repo", StatusFinished, 3*time.Hour)
+	add("2", "/repo/sub", StatusRunning, 2*time.Hour)
+	add("3", "/repository", StatusRunning, time.Hour)
+	add("4", "/other", StatusFailed, 0)
+
+	ids := func(infos []AgentInfo) string {
+		result := make([]string, len(infos))
+		for i, info := range infos {
+			result[i] = info.ID
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		filter   AgentFilter
+		expected string
+	}{
+		{AgentFilter{}, "4,3,2,1"},
+		{AgentFilter{Status: StatusRunning}, "3,2"},
+		{AgentFilter{Folder: "/repo/"}, "2,1"},
+		{AgentFilter{Status: StatusRunning, Folder: "/repo"}, "2"},
+		{AgentFilter{Status: StatusKilled}, ""},
+		{AgentFilter{Folder: "/"}, "4,3,2,1"},
+	}
+	for _, tt := range tests {
+		if got := ids(manager.FilterAgents(tt.filter)); got != tt.expected {
+			t.Errorf("%+v: expected %q, got %q", tt.filter, tt.expected, got)
+		}
+	}
+}
+
+func TestFolderHolder(t *testing.T) {
+	manager := NewManager()
+	agent := NewAgent("7", "/repo", "task")
+	agent.Status = StatusRunning
+	agent.StartTime = time.Now().Add(-4 * time.Minute)
+	manager.agents["7"] = agent
+	manager.runningPerFolder["/repo"] = "7"
+	manager.folderQueues["/repo"] = []QueuedTask{{QueueID: "q1"}}
+	manager.folderQueues["/idle"] = []QueuedTask{{QueueID: "q2"}}
+
+	holder, ok := manager.GetRunningAgentForFolder("/repo")
+	if !ok || holder.ID != "7" || holder.Duration < 4*time.Minute {
+		t.Errorf("Expected agent 7 running for 4m, got %+v (ok %v)", holder, ok)
+	}
+	if _, ok := manager.GetRunningAgentForFolder("/idle"); ok {
+		t.Error("Expected no agent holding /idle")
+	}
+
+	status := manager.GetFolderQueues()
+	if status["/repo"].RunningAgentID != "7" || len(status["/repo"].Tasks) != 1 {
+		t.Errorf("Expected /repo held by 7 with 1 task, got %+v", status["/repo"])
+	}
+	if status["/idle"].RunningAgentID != "" {
+		t.Errorf("Expected /idle without holder, got %q", status["/idle"].RunningAgentID)
+	}
+}
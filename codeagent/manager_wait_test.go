@@ -0,0 +1,108 @@
+package codeagent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupWaitTest installs a fake claude binary that sleeps for the number of
+// seconds in the agent folder's "delay" file
+func setupWaitTest(t *testing.T) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "fake-claude")
+	content := "#!/bin/sh\nsleep $(cat delay 2>/dev/null || echo 0)\necho done\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	t.Cleanup(func() { SetClaudeBinary("") })
+}
+
+func launchWithDelay(t *testing.T, manager *Manager, delay string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "delay"), []byte(delay), 0644); err != nil {
+		t.Fatalf("Failed to write delay file: %v", err)
+	}
+	id, err := manager.LaunchAgent(context.Background(), dir, "test")
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+	return id
+}
+
+func TestWaitForAnyAgent(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	slow := launchWithDelay(t, manager, "2")
+	fast := launchWithDelay(t, manager, "0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	id, info, err := manager.WaitForAnyAgent(ctx, []string{slow, fast})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if id != fast || info.ID != fast {
+		t.Errorf("Expected fast agent %s to finish first, got %s", fast, id)
+	}
+	if info.Status != StatusFinished {
+		t.Errorf("Expected status finished, got %s", info.Status)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected to return before the slow agent finished, took %s", elapsed)
+	}
+
+}
+
+func TestWaitForAllAgents(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	first := launchWithDelay(t, manager, "1")
+	second := launchWithDelay(t, manager, "0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := manager.WaitForAllAgents(ctx, []string{first, second})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, id := range []string{first, second} {
+		if results[id].Status != StatusFinished {
+			t.Errorf("Expected agent %s to be finished, got %s", id, results[id].Status)
+		}
+	}
+}
+
+func TestWaitForAgentsCancellation(t *testing.T) {
+	setupWaitTest(t)
+	manager := NewManager()
+	slow := launchWithDelay(t, manager, "2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := manager.WaitForAnyAgent(ctx, []string{slow}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded from WaitForAnyAgent, got %v", err)
+	}
+	if _, err := manager.WaitForAllAgents(ctx, []string{slow}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded from WaitForAllAgents, got %v", err)
+	}
+
+	if _, _, err := manager.WaitForAnyAgent(context.Background(), []string{"missing"}); err == nil {
+		t.Error("Expected error for unknown agent ID")
+	}
+	if _, err := manager.WaitForAllAgents(context.Background(), nil); err == nil {
+		t.Error("Expected error for empty agent list")
+	}
+}
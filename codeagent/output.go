@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DefaultMaxOutputSize is how much output an agent keeps unless configured otherwise
+const DefaultMaxOutputSize = 10 << 20 // 10 MB
+
+var maxOutputSize = DefaultMaxOutputSize
+
+// SetMaxOutputSize sets how many bytes of output each agent launched afterwards
+// keeps. Beyond it only the head and the most recent tail are kept. Zero or
+// less keeps everything.
+func SetMaxOutputSize(bytes int) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if bytes < 0 {
+		bytes = 0
+	}
+	maxOutputSize = bytes
+}
+
+// GetMaxOutputSize returns the configured output cap, 0 when unlimited
+func GetMaxOutputSize() int {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return maxOutputSize
+}
+
+// cappedOutput collects process output in at most about limit bytes: the
+// first half is kept as written and the second half holds the most recent
+// output. A zero limit keeps everything. It is not safe for concurrent use;
+// the agent guards it with liveOutputMu.
+type cappedOutput struct {
+	limit   int
+	head    []byte
+	tail    []byte // Grows to twice the tail size before old bytes are dropped
+	written int64  // Total bytes written
+}
+
+// Write appends p, dropping the oldest bytes after the head once over the limit
+func (c *cappedOutput) Write(p []byte) (int, error) {
+	c.written += int64(len(p))
+	data := p
+	if c.limit <= 0 {
+		c.head = append(c.head, data...)
+		return len(p), nil
+	}
+
+	if room := c.headSize() - len(c.head); room > 0 {
+		if room > len(data) {
+			room = len(data)
+		}
+		c.head = append(c.head, data[:room]...)
+		data = data[room:]
+	}
+	c.tail = append(c.tail, data...)
+	if tailSize := c.limit - c.headSize(); len(c.tail) > 2*tailSize {
+		c.tail = append(c.tail[:0], c.tail[len(c.tail)-tailSize:]...)
+	}
+	return len(p), nil
+}
+
+// WriteString appends s
+func (c *cappedOutput) WriteString(s string) (int, error) {
+	return c.Write([]byte(s))
+}
+
+// headSize is how many bytes from the start of the output are kept
+func (c *cappedOutput) headSize() int {
+	return c.limit / 2
+}
+
+// Truncated reports whether output was dropped
+func (c *cappedOutput) Truncated() bool {
+	return c.limit > 0 && c.written > int64(c.limit)
+}
+
+// String returns the output, with a "[...N bytes truncated...]" marker where
+// bytes were dropped
+func (c *cappedOutput) String() string {
+	if !c.Truncated() {
+		return string(c.head) + string(c.tail)
+	}
+
+	// Cut on rune boundaries so multi-byte characters are not split
+	head := c.head
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				head = head[:i]
+			}
+			break
+		}
+	}
+	tail := c.tail
+	if tailSize := c.limit - c.headSize(); len(tail) > tailSize {
+		tail = tail[len(tail)-tailSize:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+
+	dropped := c.written - int64(len(head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n[...%d bytes truncated...]\n%s", head, dropped, tail)
+}
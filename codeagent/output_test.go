@@ -0,0 +1,107 @@
+package codeagent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCappedOutputUnderLimit(t *testing.T) {
+	out := cappedOutput{limit: 100}
+	out.WriteString("hello ")
+	out.WriteString("world")
+	if out.Truncated() || out.String() != "hello world" {
+		t.Errorf("Expected untouched output, got %q (truncated %v)", out.String(), out.Truncated())
+	}
+}
+
+func TestCappedOutputKeepsHeadAndTail(t *testing.T) {
+	out := cappedOutput{limit: 20}
+	for i := 0; i < 100; i++ {
+		out.WriteString("0123456789")
+	}
+
+	if !out.Truncated() {
+		t.Fatal("Expected output to be truncated")
+	}
+	expected := "0123456789\n[...980 bytes truncated...]\n0123456789"
+	if got := out.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if len(out.tail) > 20 {
+		t.Errorf("Expected the tail buffer to stay bounded, got %d bytes", len(out.tail))
+	}
+}
+
+func TestCappedOutputRuneBoundaries(t *testing.T) {
+	out := cappedOutput{limit: 8}
+	out.WriteString("aaaé" + strings.Repeat("x", 20) + "ébbb")
+
+	got := out.String()
+	if !strings.HasPrefix(got, "aaa\n[...") || !strings.HasSuffix(got, "...]\nbbb") {
+		t.Errorf("Expected split characters to be dropped, got %q", got)
+	}
+}
+
+func TestCappedOutputUnlimited(t *testing.T) {
+	out := cappedOutput{}
+	big := strings.Repeat("x", 1<<16)
+	out.WriteString(big)
+	if out.Truncated() || out.String() != big {
+		t.Error("Expected a zero limit to keep everything")
+	}
+}
+
+func TestAgentOutputCap(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-claude")
+	// Write to stdout and stderr at once so both readers append concurrently
+	body := "#!/bin/sh\n(for i in $(seq 1 2000); do echo err $i >&2; done) &\nfor i in $(seq 1 2000); do echo out $i; done\nwait\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+	SetMaxOutputSize(1024)
+	defer SetMaxOutputSize(DefaultMaxOutputSize)
+
+	agent := NewAgent("capped", dir, "test")
+	_ = agent.Start(context.Background())
+
+	info := agent.ToInfo()
+	if !info.OutputTruncated {
+		t.Error("Expected OutputTruncated to be set")
+	}
+	if !strings.Contains(info.Output, "bytes truncated...]") {
+		t.Errorf("Expected a truncation marker, got %q", info.Output)
+	}
+	if len(info.Output) > 1100 {
+		t.Errorf("Expected output near the 1024 byte cap, got %d bytes", len(info.Output))
+	}
+}
+
+func TestReadStructuredOutputSkipsOversizedLines(t *testing.T) {
+	agent := NewAgent("lines", t.TempDir(), "test")
+	agent.SetStructuredOutput(true)
+	out := &cappedOutput{limit: 64 << 10}
+	var mu sync.Mutex
+
+	huge := `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"` + strings.Repeat("x", 1<<20) + `"}]}}`
+	input := huge + "\n" + `{"type":"result","subtype":"success","result":"Done!"}` + "\n"
+	agent.readStructuredOutput(strings.NewReader(input), out, &mu)
+
+	for _, message := range agent.parser.GetHistory() {
+		if len(message.Content) > out.limit {
+			t.Errorf("Expected the oversized line to be skipped, got a %d byte message", len(message.Content))
+		}
+	}
+	if result := agent.parser.GetFinalResult(); result != "Done!" {
+		t.Errorf("Expected the line after the oversized one to be parsed, got %q", result)
+	}
+	if !out.Truncated() {
+		t.Error("Expected the oversized line to count against the output cap")
+	}
+}
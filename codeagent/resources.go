@@ -0,0 +1,205 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrResourcesUnsupported is returned by GetAgentResources on systems without /proc
+var ErrResourcesUnsupported = errors.New("resource usage is only sampled on Linux")
+
+// resourceSampleInterval is how often a running agent's CPU and memory are sampled
+var resourceSampleInterval = 10 * time.Second
+
+// procDir is where process information is read from
+var procDir = "/proc"
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc/<pid>/stat.
+// It is 100 on every Linux architecture Go supports.
+const clockTicks = 100
+
+// ResourceStats is a sample of the CPU and memory used by an agent's process
+// group: the shell, claude and anything they spawned
+type ResourceStats struct {
+	CPUPercent float64   // CPU used since the previous sample; 100 is one full core
+	RSSBytes   int64     // Resident memory of all processes in the group
+	Processes  int       // Number of live processes in the group
+	SampledAt  time.Time // When the sample was taken
+}
+
+// String formats the sample, e.g. "CPU 12% · RSS 340.5 MB · 3 processes"
+func (s ResourceStats) String() string {
+	return fmt.Sprintf("CPU %.0f%% · RSS %s · %d processes", s.CPUPercent, formatMemory(s.RSSBytes), s.Processes)
+}
+
+// formatMemory formats a byte count in binary units
+func formatMemory(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGT"[exp])
+}
+
+// GetAgentResources returns the latest CPU and memory sample of a running agent
+func (m *Manager) GetAgentResources(id string) (ResourceStats, error) {
+	agent, err := m.GetAgent(id)
+	if err != nil {
+		return ResourceStats{}, err
+	}
+	return agent.GetResources()
+}
+
+// GetResources returns the latest CPU and memory sample of the agent
+func (a *Agent) GetResources() (ResourceStats, error) {
+	if runtime.GOOS != "linux" {
+		return ResourceStats{}, ErrResourcesUnsupported
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.Status != StatusRunning {
+		return ResourceStats{}, fmt.Errorf("agent %s is not running", a.ID)
+	}
+	if a.resources.SampledAt.IsZero() {
+		return ResourceStats{}, fmt.Errorf("agent %s has not been sampled yet", a.ID)
+	}
+	return a.resources, nil
+}
+
+// sampleResources samples the process group pgid right away and then every
+// resourceSampleInterval until the agent completes. It does nothing on
+// systems without /proc.
+func (a *Agent) sampleResources(pgid int) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	var lastTicks uint64
+	var lastTime time.Time
+	sample := func() {
+		ticks, rssPages, processes, err := readProcessGroupUsage(procDir, pgid)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		stats := ResourceStats{
+			RSSBytes:  rssPages * int64(os.Getpagesize()),
+			Processes: processes,
+			SampledAt: now,
+		}
+		// The first sample has nothing to measure CPU use against, so it reports 0
+		if !lastTime.IsZero() {
+			stats.CPUPercent = cpuPercent(ticks, lastTicks, now.Sub(lastTime))
+		}
+		lastTicks, lastTime = ticks, now
+
+		a.mu.Lock()
+		a.resources = stats
+		a.mu.Unlock()
+	}
+
+	sample()
+	ticker := time.NewTicker(resourceSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			sample()
+		}
+	}
+}
+
+// cpuPercent converts the CPU ticks used over elapsed into a percentage of one
+// core. Ticks go down when a busy process exits, which counts as idle.
+func cpuPercent(ticks, lastTicks uint64, elapsed time.Duration) float64 {
+	if ticks <= lastTicks || elapsed <= 0 {
+		return 0
+	}
+	return float64(ticks-lastTicks) / clockTicks / elapsed.Seconds() * 100
+}
+
+// readProcessGroupUsage sums the user and system CPU ticks and resident pages
+// of every process in group pgid, read from /proc/<pid>/stat and statm
+func readProcessGroupUsage(proc string, pgid int) (ticks uint64, rssPages int64, processes int, err error) {
+	entries, err := os.ReadDir(proc)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join(proc, entry.Name(), "stat"))
+		if err != nil {
+			continue // The process exited while we were looking
+		}
+		group, cpu, ok := parseProcStat(string(stat))
+		if !ok || group != pgid {
+			continue
+		}
+		statm, err := os.ReadFile(filepath.Join(proc, entry.Name(), "statm"))
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(string(statm))
+		if len(fields) < 2 {
+			continue
+		}
+		resident, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ticks += cpu
+		rssPages += resident
+		processes++
+	}
+	return ticks, rssPages, processes, nil
+}
+
+// parseProcStat returns the process group and the user plus system CPU ticks
+// from the contents of /proc/<pid>/stat
+func parseProcStat(stat string) (pgid int, ticks uint64, ok bool) {
+	// The command name is in parentheses and may itself contain spaces or
+	// parentheses, so fields are counted from the last ')'
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	// fields[0] is field 3 (state): pgrp is field 5, utime 14 and stime 15
+	if len(fields) < 13 {
+		return 0, 0, false
+	}
+	pgid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return pgid, utime + stime, true
+}
@@ -0,0 +1,104 @@
+package codeagent
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat := "4242 (node (worker) x) S 4200 4200 4200 0 -1 4194560 1000 0 0 0 150 50 0 0 20 0 11 0 123456 1000000 2500"
+	pgid, ticks, ok := parseProcStat(stat)
+	if !ok {
+		t.Fatal("Expected stat to parse")
+	}
+	if pgid != 4200 {
+		t.Errorf("Expected pgid 4200, got %d", pgid)
+	}
+	if ticks != 200 {
+		t.Errorf("Expected 200 ticks, got %d", ticks)
+	}
+
+	if _, _, ok := parseProcStat("4242 (truncated"); ok {
+		t.Error("Expected truncated stat to be rejected")
+	}
+}
+
+func TestReadProcessGroupUsage(t *testing.T) {
+	proc := t.TempDir()
+	writeProc := func(pid, stat, statm string) {
+		dir := filepath.Join(proc, pid)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644)
+		os.WriteFile(filepath.Join(dir, "statm"), []byte(statm), 0644)
+	}
+	writeProc("100", "100 (sh) S 1 100 100 0 -1 0 0 0 0 0 10 5 0 0 20 0 1 0 1 0 0", "500 100 50 1 0 80 0")
+	writeProc("101", "101 (claude) S 100 100 100 0 -1 0 0 0 0 0 300 100 0 0 20 0 1 0 1 0 0", "9000 4000 200 1 0 800 0")
+	writeProc("200", "200 (other) S 1 200 200 0 -1 0 0 0 0 0 999 999 0 0 20 0 1 0 1 0 0", "9000 9999 200 1 0 800 0")
+	os.MkdirAll(filepath.Join(proc, "self"), 0755)
+
+	ticks, rssPages, processes, err := readProcessGroupUsage(proc, 100)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ticks != 415 || rssPages != 4100 || processes != 2 {
+		t.Errorf("Expected 415 ticks, 4100 pages and 2 processes, got %d, %d and %d", ticks, rssPages, processes)
+	}
+}
+
+func TestCPUPercent(t *testing.T) {
+	if got := cpuPercent(250, 50, 2*time.Second); got != 100 {
+		t.Errorf("Expected 100%%, got %v", got)
+	}
+	if got := cpuPercent(50, 250, 2*time.Second); got != 0 {
+		t.Errorf("Expected 0%% when ticks go down, got %v", got)
+	}
+}
+
+func TestResourceStatsString(t *testing.T) {
+	stats := ResourceStats{CPUPercent: 12.4, RSSBytes: 340 << 20, Processes: 3}
+	if got := stats.String(); got != "CPU 12% · RSS 340.0 MB · 3 processes" {
+		t.Errorf("Expected formatted stats, got %q", got)
+	}
+}
+
+func TestGetAgentResources(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Resources are only sampled on Linux")
+	}
+
+	manager := NewManager()
+	agent := NewAgent("res-1", t.TempDir(), "task")
+	agent.Status = StatusRunning
+	manager.mu.Lock()
+	manager.agents[agent.ID] = agent
+	manager.mu.Unlock()
+
+	if _, err := manager.GetAgentResources(agent.ID); err == nil {
+		t.Error("Expected an error before the first sample")
+	}
+
+	// Sample the test's own process group
+	go agent.sampleResources(syscall.Getpgrp())
+	defer agent.markDone()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats, err := manager.GetAgentResources(agent.ID); err == nil {
+			if stats.RSSBytes <= 0 {
+				t.Errorf("Expected resident memory, got %+v", stats)
+			}
+			if stats.CPUPercent != 0 {
+				t.Errorf("Expected no CPU use in the first sample, got %+v", stats)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected a resource sample")
+}
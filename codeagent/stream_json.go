@@ -0,0 +1,271 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// streamEvent is a single line emitted by `claude --output-format stream-json`
+type streamEvent struct {
+	Type      string         `json:"type"`
+	Subtype   string         `json:"subtype"`
+	SessionID string         `json:"session_id"`
+	Model     string         `json:"model"`
+	Message   *streamMessage `json:"message"`
+	Result    string         `json:"result"`
+	IsError   bool           `json:"is_error"`
+	Usage     *streamUsage   `json:"usage"`
+	CostUSD   float64        `json:"total_cost_usd"`
+	NumTurns  int            `json:"num_turns"`
+}
+
+// streamMessage is the API message embedded in assistant and user events
+type streamMessage struct {
+	Role    string          `json:"role"`
+	Content []streamContent `json:"content"`
+	Usage   *streamUsage    `json:"usage"`
+}
+
+// streamContent is one content block of a stream message
+type streamContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// streamUsage holds the token counters reported by the API
+type streamUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// total returns the sum of all token counters
+func (u *streamUsage) total() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// tokenUsage converts API counters to a TokenUsage; cache reads count as cached
+func (u *streamUsage) tokenUsage() TokenUsage {
+	return TokenUsage{
+		Total:        u.total(),
+		Conversation: u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens,
+		Cached:       u.CacheReadInputTokens,
+	}
+}
+
+// ParseStreamJSONLine processes a single line of stream-json output and records
+// the resulting messages in the conversation history
+func (p *ClaudeParser) ParseStreamJSONLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	var event streamEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return fmt.Errorf("invalid stream-json line: %w", err)
+	}
+
+	switch event.Type {
+	case "system":
+		if event.Subtype == "init" {
+			content := "Session started"
+			if event.Model != "" {
+				content += fmt.Sprintf(" (model: %s)", event.Model)
+			}
+			p.addMessage("system", content, map[string]string{"session_id": event.SessionID})
+		}
+	case "assistant":
+		if event.Message == nil {
+			return nil
+		}
+		// Usage is reported per message, not per content block
+		if event.Message.Usage != nil {
+			p.addTokenUsage(event.Message.Usage.tokenUsage())
+		}
+		for _, block := range event.Message.Content {
+			switch block.Type {
+			case "text":
+				if strings.TrimSpace(block.Text) == "" {
+					continue
+				}
+				metadata := make(map[string]string)
+				if event.Message.Usage != nil {
+					p.setUsageMetadata(metadata, event.Message.Usage)
+				}
+				p.addMessage("assistant", strings.TrimSpace(block.Text), metadata)
+			case "tool_use":
+				input := compactJSON(block.Input)
+				p.addMessage("tool", fmt.Sprintf("%s(%s)", block.Name, input), map[string]string{
+					MetaToolName:   block.Name,
+					MetaToolInput:  input,
+					MetaToolStatus: ToolStatusPending,
+					"tool_use_id":  block.ID,
+				})
+			}
+		}
+	case "user":
+		if event.Message == nil {
+			return nil
+		}
+		for _, block := range event.Message.Content {
+			switch block.Type {
+			case "tool_result":
+				result := toolResultText(block.Content)
+				status := ToolStatusSuccess
+				metadata := map[string]string{
+					"tool_use_id":  block.ToolUseID,
+					MetaToolResult: result,
+				}
+				if block.IsError {
+					status = ToolStatusError
+					metadata["is_error"] = "true"
+				}
+				metadata[MetaToolStatus] = status
+				p.completeToolUse(block.ToolUseID, result, status)
+				p.addMessage("tool", result, metadata)
+			case "text":
+				p.addMessage("user", strings.TrimSpace(block.Text), nil)
+			}
+		}
+	case "result":
+		metadata := map[string]string{"subtype": event.Subtype}
+		if event.Usage != nil {
+			p.setUsageMetadata(metadata, event.Usage)
+			// The result event reports the totals for the whole session
+			p.setTokenUsage(event.Usage.tokenUsage())
+		}
+		if event.CostUSD > 0 {
+			metadata["cost_usd"] = fmt.Sprintf("%.4f", event.CostUSD)
+		}
+		if event.NumTurns > 0 {
+			metadata["num_turns"] = fmt.Sprintf("%d", event.NumTurns)
+		}
+		if event.IsError {
+			metadata["is_error"] = "true"
+		}
+		p.mu.Lock()
+		p.finalResult = event.Result
+		p.mu.Unlock()
+		p.addMessage("system", "Session finished: "+event.Subtype, metadata)
+	}
+
+	return nil
+}
+
+// GetFinalResult returns the result text reported by a stream-json session, if any
+func (p *ClaudeParser) GetFinalResult() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.finalResult
+}
+
+// setUsageMetadata records token counters on a message and updates the last token status
+func (p *ClaudeParser) setUsageMetadata(metadata map[string]string, usage *streamUsage) {
+	metadata["input_tokens"] = fmt.Sprintf("%d", usage.InputTokens)
+	metadata["output_tokens"] = fmt.Sprintf("%d", usage.OutputTokens)
+	metadata["cache_read_input_tokens"] = fmt.Sprintf("%d", usage.CacheReadInputTokens)
+	metadata["cache_creation_input_tokens"] = fmt.Sprintf("%d", usage.CacheCreationInputTokens)
+
+	status := fmt.Sprintf("%d tokens (%d input, %d output, %d cached)",
+		usage.total(), usage.InputTokens, usage.OutputTokens, usage.CacheReadInputTokens)
+	metadata["tokens"] = status
+
+	p.mu.Lock()
+	p.lastTokenStatus = status
+	p.mu.Unlock()
+}
+
+// addMessage appends a complete message to the history
+func (p *ClaudeParser) addMessage(msgType, content string, metadata map[string]string) {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["source"] = "stream-json"
+
+	p.history.mu.Lock()
+	p.history.appendLocked(Message{
+		ID:        generateMessageID(),
+		Type:      msgType,
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata:  metadata,
+	})
+	p.history.mu.Unlock()
+}
+
+// completeToolUse records a tool_result on the tool_use message it answers
+func (p *ClaudeParser) completeToolUse(toolUseID, result, status string) {
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+
+	for i := len(p.history.Messages) - 1; i >= 0; i-- {
+		msg := &p.history.Messages[i]
+		if msg.Metadata["tool_use_id"] != toolUseID || msg.Metadata[MetaToolName] == "" {
+			continue
+		}
+		// Copy the map so snapshots returned by GetHistory stay unchanged
+		metadata := make(map[string]string, len(msg.Metadata)+2)
+		for k, v := range msg.Metadata {
+			metadata[k] = v
+		}
+		metadata[MetaToolResult] = result
+		metadata[MetaToolStatus] = status
+		previous := messageSize(*msg)
+		msg.Metadata = metadata
+		p.history.size += messageSize(*msg) - previous
+		p.history.trimLocked()
+		return
+	}
+}
+
+// toolResultText extracts readable text from a tool_result content field,
+// which may be a plain string or a list of content blocks
+func toolResultText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var blocks []streamContent
+	if err := json.Unmarshal(raw, &blocks); err == nil {
+		parts := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Text != "" {
+				parts = append(parts, block.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+
+	return string(raw)
+}
+
+// compactJSON returns a single-line representation of a JSON value
+func compactJSON(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
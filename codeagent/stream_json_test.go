@@ -0,0 +1,128 @@
+package codeagent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStreamJSONLine(t *testing.T) {
+	parser := NewClaudeParser()
+
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"abc","model":"claude-sonnet"}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Let me look at the file."}],"usage":{"input_tokens":100,"output_tokens":20,"cache_read_input_tokens":50}}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"tool_1","name":"Read","input":{"file_path": "main.go"}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"tool_1","content":[{"type":"text","text":"package main"}]}]}}`,
+		`{"type":"result","subtype":"success","result":"Done!","num_turns":2,"total_cost_usd":0.0123,"usage":{"input_tokens":200,"output_tokens":40}}`,
+	}
+	for _, line := range lines {
+		if err := parser.ParseStreamJSONLine(line); err != nil {
+			t.Fatalf("Unexpected error parsing %s: %v", line, err)
+		}
+	}
+
+	history := parser.GetHistory()
+	if len(history) != 5 {
+		t.Fatalf("Expected 5 messages, got %d", len(history))
+	}
+
+	expectedTypes := []string{"system", "assistant", "tool", "tool", "system"}
+	for i, msgType := range expectedTypes {
+		if history[i].Type != msgType {
+			t.Errorf("Message %d: expected type %s, got %s", i, msgType, history[i].Type)
+		}
+	}
+
+	if history[1].Metadata["input_tokens"] != "100" || history[1].Metadata["output_tokens"] != "20" {
+		t.Errorf("Expected usage metadata on assistant message, got %v", history[1].Metadata)
+	}
+	if history[2].Content != `Read({"file_path":"main.go"})` {
+		t.Errorf("Expected tool_use content, got %q", history[2].Content)
+	}
+	if history[2].Metadata["tool_name"] != "Read" {
+		t.Errorf("Expected tool_name Read, got %q", history[2].Metadata["tool_name"])
+	}
+	if history[3].Content != "package main" || history[3].Metadata["tool_use_id"] != "tool_1" {
+		t.Errorf("Expected tool_result for tool_1, got %q %v", history[3].Content, history[3].Metadata)
+	}
+	invocations := parser.GetToolInvocations()
+	if len(invocations) != 1 {
+		t.Fatalf("Expected 1 tool invocation, got %d", len(invocations))
+	}
+	if invocations[0].Result != "package main" || invocations[0].Status != ToolStatusSuccess {
+		t.Errorf("Expected paired tool result, got %+v", invocations[0])
+	}
+	if usage := parser.GetTokenUsage(); usage.Total != 240 {
+		t.Errorf("Expected session total of 240 tokens, got %+v", usage)
+	}
+	if parser.GetFinalResult() != "Done!" {
+		t.Errorf("Expected final result Done!, got %q", parser.GetFinalResult())
+	}
+	if status := parser.GetLastTokenStatus(); status != "240 tokens (200 input, 40 output, 0 cached)" {
+		t.Errorf("Unexpected token status: %q", status)
+	}
+}
+
+func TestParseStreamJSONLineInvalid(t *testing.T) {
+	parser := NewClaudeParser()
+	if err := parser.ParseStreamJSONLine("not json"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+	if err := parser.ParseStreamJSONLine("   "); err != nil {
+		t.Errorf("Expected blank lines to be ignored, got %v", err)
+	}
+	if len(parser.GetHistory()) != 0 {
+		t.Error("Expected no messages to be recorded")
+	}
+}
+
+func TestParseStreamJSONHistoryLimit(t *testing.T) {
+	parser := NewClaudeParser()
+	parser.SetHistoryLimit(200)
+
+	text := strings.Repeat("x", 50)
+	for i := 0; i < 100; i++ {
+		line := `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"` + text + `"}],"usage":{"input_tokens":10,"output_tokens":1}}}`
+		if err := parser.ParseStreamJSONLine(line); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := parser.ParseStreamJSONLine(`{"type":"result","subtype":"success","result":"Done!","usage":{"input_tokens":10,"output_tokens":1}}`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	history := parser.GetHistory()
+	if len(history) == 0 || len(history) > 5 {
+		t.Errorf("Expected only the most recent messages to be kept, got %d", len(history))
+	}
+	if last := history[len(history)-1]; last.Content != "Session finished: success" {
+		t.Errorf("Expected the newest message to be kept, got %q", last.Content)
+	}
+	if result := parser.GetFinalResult(); result != "Done!" {
+		t.Errorf("Expected the final result to survive trimming, got %q", result)
+	}
+	if usage := parser.GetTokenUsage(); usage.Total == 0 {
+		t.Errorf("Expected token usage to survive trimming, got %+v", usage)
+	}
+}
+
+func TestParseStreamJSONCountsUsageOncePerMessage(t *testing.T) {
+	parser := NewClaudeParser()
+
+	lines := []string{
+		// A turn that only calls tools still consumes tokens
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"tool_1","name":"Read","input":{}}],"usage":{"input_tokens":100,"output_tokens":10}}}`,
+		// Several text blocks share the usage of their message
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"First."},{"type":"text","text":"Second."}],"usage":{"input_tokens":200,"output_tokens":20}}}`,
+	}
+	for _, line := range lines {
+		if err := parser.ParseStreamJSONLine(line); err != nil {
+			t.Fatalf("Unexpected error parsing %s: %v", line, err)
+		}
+	}
+
+	usage := parser.GetTokenUsage()
+	if usage.Total != 330 || usage.Conversation != 330 {
+		t.Errorf("Expected 330 tokens, got %+v", usage)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTokenBudgetExceeded is returned when the daily token budget has been used up
+var ErrTokenBudgetExceeded = errors.New("daily token budget exceeded")
+
+// dailyTokenCounter is the persisted token usage for a single local day
+type dailyTokenCounter struct {
+	Date   string `json:"date"` // Local date in YYYY-MM-DD format
+	Tokens int    `json:"tokens"`
+}
+
+// SetTokenBudget configures token limits. Agents whose session usage exceeds
+// perAgent are killed, and new agents are refused once perDay tokens have been
+// used today. Zero disables a limit. Agents launched while a budget is set run
+// in structured output mode so their token usage is reported.
+func (m *Manager) SetTokenBudget(perAgent, perDay int) {
+	m.budgetMu.Lock()
+	defer m.budgetMu.Unlock()
+	m.tokenBudgetAgent = perAgent
+	m.tokenBudgetDay = perDay
+}
+
+// SetTokenUsageFile sets where the daily token counter is persisted and loads it,
+// so restarting does not reset the daily budget
+func (m *Manager) SetTokenUsageFile(path string) error {
+	m.budgetMu.Lock()
+	defer m.budgetMu.Unlock()
+	m.tokenUsageFile = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read token usage file: %w", err)
+	}
+
+	var counter dailyTokenCounter
+	if err := json.Unmarshal(data, &counter); err != nil {
+		return fmt.Errorf("failed to parse token usage file: %w", err)
+	}
+	m.dailyTokens = counter
+	return nil
+}
+
+// GetDailyTokenUsage returns the tokens used today, including running agents
+func (m *Manager) GetDailyTokenUsage() int {
+	m.budgetMu.Lock()
+	used := m.todayTokens()
+	m.budgetMu.Unlock()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, agent := range m.agents {
+		if agent.GetStatus() == StatusRunning {
+			used += agent.GetTokenUsage().Total
+		}
+	}
+	return used
+}
+
+// TotalTokensUsed returns the session tokens used by tracked agents, by folder.
+// Agents without token data (not run in structured output mode) are left out;
+// see AgentsWithoutTokenData.
+func (m *Manager) TotalTokensUsed() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	totals := make(map[string]int)
+	for _, agent := range m.agents {
+		if agent.HasTokenData() {
+			totals[agent.Folder] += agent.GetTokenUsage().Total
+		}
+	}
+	return totals
+}
+
+// AgentsWithoutTokenData returns how many tracked agents have unknown token usage, by folder
+func (m *Manager) AgentsWithoutTokenData() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, agent := range m.agents {
+		if !agent.HasTokenData() {
+			counts[agent.Folder]++
+		}
+	}
+	return counts
+}
+
+// CheckTokenBudget returns ErrTokenBudgetExceeded if today's budget is used up
+func (m *Manager) CheckTokenBudget() error {
+	m.budgetMu.Lock()
+	perDay := m.tokenBudgetDay
+	m.budgetMu.Unlock()
+
+	if perDay <= 0 {
+		return nil
+	}
+	if used := m.GetDailyTokenUsage(); used >= perDay {
+		return fmt.Errorf("%w: %d of %d tokens used today", ErrTokenBudgetExceeded, used, perDay)
+	}
+	return nil
+}
+
+// applyTokenBudget configures a new agent according to the token budget
+func (m *Manager) applyTokenBudget(agent *Agent) {
+	m.budgetMu.Lock()
+	perAgent := m.tokenBudgetAgent
+	enabled := perAgent > 0 || m.tokenBudgetDay > 0
+	m.budgetMu.Unlock()
+
+	if !enabled {
+		return
+	}
+	agent.SetStructuredOutput(true)
+	agent.SetTokenLimit(perAgent)
+}
+
+// recordAgentTokens adds a finished agent's token usage to today's counter
+func (m *Manager) recordAgentTokens(agent *Agent) {
+	tokens := agent.GetTokenUsage().Total
+	if tokens == 0 {
+		return
+	}
+
+	m.budgetMu.Lock()
+	defer m.budgetMu.Unlock()
+	m.dailyTokens.Tokens = m.todayTokens() + tokens
+	m.dailyTokens.Date = time.Now().Format("2006-01-02")
+
+	if err := m.saveTokenUsage(); err != nil {
+		log.Printf("[Manager] Failed to save token usage: %v", err)
+	}
+}
+
+// todayTokens returns the persisted counter, resetting it after local midnight.
+// The caller must hold budgetMu.
+func (m *Manager) todayTokens() int {
+	today := time.Now().Format("2006-01-02")
+	if m.dailyTokens.Date != today {
+		m.dailyTokens = dailyTokenCounter{Date: today}
+	}
+	return m.dailyTokens.Tokens
+}
+
+// saveTokenUsage writes the daily counter to disk. The caller must hold budgetMu.
+func (m *Manager) saveTokenUsage() error {
+	if m.tokenUsageFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.tokenUsageFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m.dailyTokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.tokenUsageFile, data, 0644)
+}
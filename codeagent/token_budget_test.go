@@ -0,0 +1,111 @@
+package codeagent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyTokenBudget(t *testing.T) {
+	usageFile := filepath.Join(t.TempDir(), "token_usage.json")
+
+	manager := NewManager()
+	if err := manager.SetTokenUsageFile(usageFile); err != nil {
+		t.Fatalf("Unexpected error loading missing usage file: %v", err)
+	}
+	manager.SetTokenBudget(0, 1000)
+
+	agent := NewAgent("budget", t.TempDir(), "test")
+	agent.SetStructuredOutput(true)
+	agent.parser.ParseStreamJSONLine(`{"type":"result","subtype":"success","usage":{"input_tokens":900,"output_tokens":200}}`)
+	manager.recordAgentTokens(agent)
+
+	_, err := manager.LaunchAgent(context.Background(), t.TempDir(), "test")
+	if !errors.Is(err, ErrTokenBudgetExceeded) {
+		t.Fatalf("Expected ErrTokenBudgetExceeded, got %v", err)
+	}
+
+	// A restarted manager picks up the persisted counter
+	restarted := NewManager()
+	if err := restarted.SetTokenUsageFile(usageFile); err != nil {
+		t.Fatalf("Unexpected error loading usage file: %v", err)
+	}
+	if used := restarted.GetDailyTokenUsage(); used != 1100 {
+		t.Errorf("Expected 1100 tokens used today, got %d", used)
+	}
+
+	// Counters from a previous day are ignored
+	restarted.dailyTokens.Date = "2000-01-01"
+	if used := restarted.GetDailyTokenUsage(); used != 0 {
+		t.Errorf("Expected counter to reset on a new day, got %d", used)
+	}
+}
+
+func TestAgentTokenLimit(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-claude")
+	content := "#!/bin/sh\n" +
+		`echo '{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":400,"output_tokens":100}}}'` +
+		"\nsleep 10\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	SetClaudeBinary(script)
+	defer SetClaudeBinary("")
+
+	agent := NewAgent("token-limit", dir, "test")
+	agent.SetStructuredOutput(true)
+	agent.SetTokenLimit(100)
+
+	done := make(chan AgentInfo, 1)
+	agent.SetCompletionCallbackInfo(func(info AgentInfo) {
+		done <- info
+	})
+	go agent.Start(context.Background())
+
+	select {
+	case info := <-done:
+		if info.Status != StatusKilled {
+			t.Errorf("Expected status killed, got %s", info.Status)
+		}
+		if !strings.Contains(info.Error, "token budget exceeded") {
+			t.Errorf("Expected token budget error, got %q", info.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Agent was not killed after exceeding its token limit")
+	}
+}
+
+func TestTotalTokensUsed(t *testing.T) {
+	manager := NewManager()
+
+	withTokens := func(id, folder, usage string) {
+		agent := NewAgent(id, folder, "test")
+		agent.SetStructuredOutput(true)
+		if usage != "" {
+			agent.parser.ParseStreamJSONLine(usage)
+		}
+		manager.agents[id] = agent
+	}
+	withTokens("1", "/repo/a", `{"type":"result","subtype":"success","usage":{"input_tokens":900,"output_tokens":100}}`)
+	withTokens("2", "/repo/a", `{"type":"result","subtype":"success","usage":{"input_tokens":40,"output_tokens":10}}`)
+	withTokens("3", "/repo/b", "")
+	manager.agents["4"] = NewAgent("4", "/repo/b", "test")
+
+	totals := manager.TotalTokensUsed()
+	if totals["/repo/a"] != 1050 {
+		t.Errorf("Expected 1050 tokens for /repo/a, got %d", totals["/repo/a"])
+	}
+	if tokens, ok := totals["/repo/b"]; !ok || tokens != 0 {
+		t.Errorf("Expected /repo/b to report 0 known tokens, got %d (present: %v)", tokens, ok)
+	}
+
+	unknown := manager.AgentsWithoutTokenData()
+	if len(unknown) != 1 || unknown["/repo/b"] != 1 {
+		t.Errorf("Expected one agent without token data in /repo/b, got %v", unknown)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TokenUsage holds parsed token counters
+type TokenUsage struct {
+	Total        int
+	Conversation int
+	Cached       int
+}
+
+// Add returns the sum of two usages
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		Total:        u.Total + other.Total,
+		Conversation: u.Conversation + other.Conversation,
+		Cached:       u.Cached + other.Cached,
+	}
+}
+
+// TokenStats holds the latest parsed token counters and the peak values seen
+// during the session
+type TokenStats struct {
+	Total        int
+	Conversation int
+	Cached       int
+
+	PeakTotal        int
+	PeakConversation int
+	PeakCached       int
+}
+
+var (
+	// tokenTotalPattern matches the leading "1,234 tokens" or "1.2k tokens" of a status line
+	tokenTotalPattern = regexp.MustCompile(`(?i)(\d[\d,]*(?:\.\d+)?[km]?)\s+tokens?\b`)
+	// tokenPartPattern matches the "1,000 conversation" and "234 cached" segments
+	tokenPartPattern = regexp.MustCompile(`(?i)(\d[\d,]*(?:\.\d+)?[km]?)\s+(conversation|cached)`)
+	// tokenSummaryPattern matches standalone usage lines like "1,234 tokens (...)"
+	tokenSummaryPattern = regexp.MustCompile(`^(\d[\d,]*)\s+tokens\s*\(`)
+)
+
+// ParseTokenUsage parses a status line such as
+// "1,234 tokens (1,000 conversation, 234 cached)". Missing segments are
+// derived from the total where possible.
+func ParseTokenUsage(status string) (TokenUsage, bool) {
+	matches := tokenTotalPattern.FindStringSubmatch(status)
+	if matches == nil {
+		return TokenUsage{}, false
+	}
+
+	usage := TokenUsage{Total: parseTokenCount(matches[1])}
+	hasConversation := false
+	for _, part := range tokenPartPattern.FindAllStringSubmatch(status, -1) {
+		switch strings.ToLower(part[2]) {
+		case "conversation":
+			usage.Conversation = parseTokenCount(part[1])
+			hasConversation = true
+		case "cached":
+			usage.Cached = parseTokenCount(part[1])
+		}
+	}
+	if !hasConversation {
+		usage.Conversation = usage.Total - usage.Cached
+	}
+
+	return usage, true
+}
+
+// parseTokenCount converts a number such as "1,234" or "1.2k" to an int
+func parseTokenCount(s string) int {
+	s = strings.ToLower(strings.ReplaceAll(s, ",", ""))
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1000
+		s = strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1000000
+		s = strings.TrimSuffix(s, "m")
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(n*multiplier + 0.5)
+}
+
+// recordTokenStatus updates the session usage from a terminal status line.
+// The CLI counter restarts with every turn, so when it drops the previous
+// value is folded into the session total.
+func (p *ClaudeParser) recordTokenStatus(status string) {
+	usage, ok := ParseTokenUsage(status)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if usage.Total < p.turnUsage.Total {
+		p.sessionUsage = p.sessionUsage.Add(p.turnUsage)
+	}
+	p.turnUsage = usage
+	p.observeTokenStats(usage)
+}
+
+// observeTokenStats records the latest counters and updates the peaks.
+// The caller must hold p.mu.
+func (p *ClaudeParser) observeTokenStats(usage TokenUsage) {
+	stats := &p.tokenStats
+	stats.Total = usage.Total
+	stats.Conversation = usage.Conversation
+	stats.Cached = usage.Cached
+	stats.PeakTotal = max(stats.PeakTotal, usage.Total)
+	stats.PeakConversation = max(stats.PeakConversation, usage.Conversation)
+	stats.PeakCached = max(stats.PeakCached, usage.Cached)
+}
+
+// GetTokenStats returns the latest parsed token counters and session peaks
+func (p *ClaudeParser) GetTokenStats() TokenStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokenStats
+}
+
+// addTokenUsage adds usage reported for a single API call
+func (p *ClaudeParser) addTokenUsage(usage TokenUsage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessionUsage = p.sessionUsage.Add(usage)
+	p.observeTokenStats(usage)
+}
+
+// setTokenUsage replaces the session usage with an authoritative total
+func (p *ClaudeParser) setTokenUsage(usage TokenUsage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessionUsage = usage
+	p.turnUsage = TokenUsage{}
+}
+
+// GetTokenUsage returns the token usage aggregated over the whole session
+func (p *ClaudeParser) GetTokenUsage() TokenUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sessionUsage.Add(p.turnUsage)
+}
@@ -0,0 +1,71 @@
+package codeagent
+
+import (
+	"testing"
+)
+
+func TestParseTokenUsage(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected TokenUsage
+		ok       bool
+	}{
+		{"1,234 tokens (1,000 conversation, 234 cached)", TokenUsage{1234, 1000, 234}, true},
+		{"12,345,678 tokens (12,345,678 conversation, 0 cached)", TokenUsage{12345678, 12345678, 0}, true},
+		{"500 tokens (500 conversation)", TokenUsage{500, 500, 0}, true},
+		{"✻ Thinking… (3s · 2,048 tokens · esc to interrupt)", TokenUsage{2048, 2048, 0}, true},
+		{"↓ 1.2k tokens", TokenUsage{1200, 1200, 0}, true},
+		{"1,500 Tokens (1,000 Conversation, 500 Cached)", TokenUsage{1500, 1000, 500}, true},
+		{"no usage here", TokenUsage{}, false},
+	}
+
+	for _, tt := range tests {
+		usage, ok := ParseTokenUsage(tt.status)
+		if ok != tt.ok {
+			t.Errorf("%q: expected ok=%v, got %v", tt.status, tt.ok, ok)
+			continue
+		}
+		if usage != tt.expected {
+			t.Errorf("%q: expected %+v, got %+v", tt.status, tt.expected, usage)
+		}
+	}
+}
+
+func TestGetTokenUsageAggregation(t *testing.T) {
+	parser := NewClaudeParser()
+
+	// Two turns: the counter grows, then restarts for the second turn
+	lines := []string{
+		"100 tokens (100 conversation, 0 cached)",
+		"300 tokens (250 conversation, 50 cached)",
+		"⏺ First answer",
+		"80 tokens (80 conversation, 0 cached)",
+	}
+	for _, line := range lines {
+		parser.ParseLine(line)
+	}
+
+	usage := parser.GetTokenUsage()
+	expected := TokenUsage{Total: 380, Conversation: 330, Cached: 50}
+	if usage != expected {
+		t.Errorf("Expected %+v, got %+v", expected, usage)
+	}
+	if status := parser.GetLastTokenStatus(); status != "80 tokens (80 conversation, 0 cached)" {
+		t.Errorf("Expected latest status line, got %q", status)
+	}
+}
+
+func TestGetTokenStatsPeak(t *testing.T) {
+	parser := NewClaudeParser()
+
+	parser.ParseLine("2,000 tokens (1,500 conversation, 500 cached)")
+	parser.ParseLine("300 tokens (200 conversation, 100 cached)")
+
+	stats := parser.GetTokenStats()
+	if stats.Total != 300 || stats.Conversation != 200 || stats.Cached != 100 {
+		t.Errorf("Expected latest counters 300/200/100, got %+v", stats)
+	}
+	if stats.PeakTotal != 2000 || stats.PeakConversation != 1500 || stats.PeakCached != 500 {
+		t.Errorf("Expected peaks 2000/1500/500, got %+v", stats)
+	}
+}
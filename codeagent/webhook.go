@@ -0,0 +1,164 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package codeagent
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// prefixed with "sha256=", when a webhook secret is configured
+const WebhookSignatureHeader = "X-Mavis-Signature"
+
+const (
+	webhookTimeout     = 10 * time.Second // Timeout for a single delivery attempt
+	webhookMaxAttempts = 4                // Attempts before a delivery is given up
+	webhookMaxOutput   = 4000             // Output characters included in the payload
+)
+
+// webhookRetryBackoff is the delay before the first retry; it doubles after each attempt
+var webhookRetryBackoff = time.Second
+
+// WebhookPayload is the JSON body POSTed when an agent reaches a terminal state
+type WebhookPayload struct {
+	Event           AgentEventType    `json:"event"`
+	AgentID         string            `json:"agent_id"`
+	Folder          string            `json:"folder"`
+	Prompt          string            `json:"prompt"`
+	Status          AgentStatus       `json:"status"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Output          string            `json:"output"`
+	Error           string            `json:"error,omitempty"`
+	ExitCode        int               `json:"exit_code"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+}
+
+// webhookSender delivers payloads to a single URL
+type webhookSender struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// SetWebhook POSTs a WebhookPayload to url whenever an agent finishes, fails
+// or is killed. If secret is set, the body is signed in WebhookSignatureHeader.
+// Failed deliveries are retried with backoff. An empty url disables the webhook.
+func (m *Manager) SetWebhook(url, secret string) {
+	m.webhookMu.Lock()
+	defer m.webhookMu.Unlock()
+
+	if m.webhookEvents != nil {
+		m.StopEvents(m.webhookEvents)
+		m.webhookEvents = nil
+	}
+	if url == "" {
+		return
+	}
+
+	sender := &webhookSender{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+	m.webhookEvents = m.LifecycleEvents()
+	go sender.run(m.webhookEvents)
+}
+
+// run delivers a payload for every terminal event until events is closed
+func (s *webhookSender) run(events <-chan AgentEvent) {
+	for event := range events {
+		if !event.Type.IsTerminal() {
+			continue
+		}
+		go s.deliver(newWebhookPayload(event))
+	}
+}
+
+// newWebhookPayload builds the payload for a terminal event
+func newWebhookPayload(event AgentEvent) WebhookPayload {
+	info := event.Info
+	output := info.Output
+	if len(output) > webhookMaxOutput {
+		output = output[len(output)-webhookMaxOutput:]
+	}
+	return WebhookPayload{
+		Event:           event.Type,
+		AgentID:         event.AgentID,
+		Folder:          info.Folder,
+		Prompt:          info.Prompt,
+		Status:          info.Status,
+		DurationSeconds: info.Duration.Seconds(),
+		Output:          output,
+		Error:           info.Error,
+		ExitCode:        info.ExitCode,
+		Labels:          info.Labels,
+		Timestamp:       event.Time,
+	}
+}
+
+// deliver POSTs the payload, retrying with exponential backoff on errors and non-2xx responses
+func (s *webhookSender) deliver(payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[Webhook] Failed to encode payload for agent %s: %v", payload.AgentID, err)
+		return
+	}
+
+	backoff := webhookRetryBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		err = s.post(body)
+		if err == nil {
+			log.Printf("[Webhook] Delivered %s event for agent %s", payload.Event, payload.AgentID)
+			return
+		}
+		log.Printf("[Webhook] Attempt %d/%d for agent %s failed: %v", attempt, webhookMaxAttempts, payload.AgentID, err)
+		if attempt < webhookMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	log.Printf("[Webhook] Giving up on %s event for agent %s", payload.Event, payload.AgentID)
+}
+
+// post sends a single delivery attempt
+func (s *webhookSender) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mavis-webhook")
+	if s.secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhookBody(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhookBody returns the hex HMAC-SHA256 of body keyed with secret
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,94 @@
+package codeagent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	oldBackoff := webhookRetryBackoff
+	webhookRetryBackoff = 10 * time.Millisecond
+	defer func() { webhookRetryBackoff = oldBackoff }()
+
+	var mu sync.Mutex
+	attempts := 0
+	payloads := make(chan WebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		attempts++
+		attempt := attempts
+		mu.Unlock()
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if sig := r.Header.Get(WebhookSignatureHeader); sig != "sha256="+signWebhookBody("s3cret", body) {
+			t.Errorf("Expected valid signature, got %q", sig)
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	SetClaudeBinary("mavis-nonexistent-claude-binary")
+	defer SetClaudeBinary("")
+
+	manager := NewManager()
+	manager.SetWebhook(server.URL, "s3cret")
+	defer manager.SetWebhook("", "")
+
+	id, err := manager.LaunchAgent(context.Background(), t.TempDir(), "test webhook")
+	if err != nil {
+		t.Fatalf("Failed to launch agent: %v", err)
+	}
+
+	select {
+	case payload := <-payloads:
+		if payload.AgentID != id {
+			t.Errorf("Expected agent ID %s, got %s", id, payload.AgentID)
+		}
+		if payload.Event != EventFailed || payload.Status != StatusFailed {
+			t.Errorf("Expected failed event, got %s/%s", payload.Event, payload.Status)
+		}
+		if payload.Prompt != "test webhook" {
+			t.Errorf("Expected prompt 'test webhook', got %q", payload.Prompt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook was not delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestWebhookPayloadTruncatesOutput(t *testing.T) {
+	output := strings.Repeat("a", webhookMaxOutput) + "tail"
+	payload := newWebhookPayload(AgentEvent{
+		Type:    EventFinished,
+		AgentID: "1",
+		Info:    AgentInfo{Output: output, Duration: 90 * time.Second},
+	})
+
+	if len(payload.Output) != webhookMaxOutput || !strings.HasSuffix(payload.Output, "tail") {
+		t.Errorf("Expected the last %d characters of output, got %d", webhookMaxOutput, len(payload.Output))
+	}
+	if payload.DurationSeconds != 90 {
+		t.Errorf("Expected duration 90, got %v", payload.DurationSeconds)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathNotAllowed is returned by ResolvePath for paths outside the allowed directories
+var ErrPathNotAllowed = errors.New("path is outside the allowed directories")
+
+// allowedDirs are the directories paths must be in; empty allows every path
+var allowedDirs []string
+
+// SetAllowedDirs restricts the paths ResolvePath accepts to dirs and their
+// subdirectories. Entries are resolved like any other path, so ~ and paths
+// relative to home work. An empty list lifts the restriction.
+func SetAllowedDirs(dirs []string) error {
+	var resolved []string
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		absDir, err := expandPath(dir)
+		if err != nil {
+			return fmt.Errorf("invalid allowed directory %s: %w", dir, err)
+		}
+		resolved = append(resolved, realPath(filepath.Clean(absDir)))
+	}
+	allowedDirs = resolved
+	return nil
+}
+
+// AllowedDirs returns the allowed directories, empty when every path is allowed
+func AllowedDirs() []string {
+	return append([]string{}, allowedDirs...)
+}
+
+// CheckAllowedPath returns an error wrapping ErrPathNotAllowed unless the
+// absolute path is inside one of the allowed directories. Symlinks in the
+// existing part of the path are followed so a link cannot escape them.
+// Output saved by TruncateOutput is always allowed so it can be downloaded.
+func CheckAllowedPath(path string) error {
+	if len(allowedDirs) == 0 || isOutputFile(path) {
+		return nil
+	}
+
+	// Symlinks are resolved before any .. that follows them, as the OS does
+	real := realPath(path)
+	for _, dir := range allowedDirs {
+		if real == dir || isWithin(dir, real) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (allowed: %s)", ErrPathNotAllowed, path, strings.Join(allowedDirs, ", "))
+}
+
+// realPath resolves symlinks in the longest existing prefix of path, keeping
+// the components that do not exist yet
+func realPath(path string) string {
+	var missing []string
+	for {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{real}, missing...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, missing...)...)
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePathAllowlist(t *testing.T) {
+	base := t.TempDir()
+	allowed := filepath.Join(base, "projects")
+	outside := filepath.Join(base, "secrets")
+	os.MkdirAll(filepath.Join(allowed, "app"), 0755)
+	os.MkdirAll(outside, 0755)
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	if err := SetAllowedDirs([]string{allowed}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { SetAllowedDirs(nil) })
+
+	for _, path := range []string{allowed, filepath.Join(allowed, "app"), filepath.Join(allowed, "new", "dir")} {
+		if _, err := ResolvePath(path); err != nil {
+			t.Errorf("%s: expected allowed, got %v", path, err)
+		}
+	}
+	for _, path := range []string{
+		outside,
+		allowed + "-other",
+		filepath.Join(allowed, "..", "secrets"),
+		filepath.Join(allowed, "escape"),
+		filepath.Join(allowed, "escape", "key"),
+		"~",
+	} {
+		if _, err := ResolvePath(path); !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("%s: expected ErrPathNotAllowed, got %v", path, err)
+		}
+	}
+
+	// An empty allowlist allows everything again
+	SetAllowedDirs(nil)
+	if _, err := ResolvePath(outside); err != nil {
+		t.Errorf("Expected no restriction, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ClonesDir is the directory where repositories cloned by /clone are kept
+var ClonesDir = filepath.Join("data", "clones")
+
+// scpLikeURLPattern matches ssh URLs in the short form git@host:owner/repo.git
+var scpLikeURLPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/].*$`)
+
+// ClonedRepo describes a repository cloned into the managed workspace
+type ClonedRepo struct {
+	Dir           string // Absolute path of the clone
+	DefaultBranch string // Default branch of the remote
+	Reused        bool   // True if an existing clone was updated instead of cloned
+}
+
+// IsGitURL reports whether s looks like a remote git URL (https, ssh or git protocol)
+func IsGitURL(s string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "file://"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return scpLikeURLPattern.MatchString(s)
+}
+
+// IsRemoteGitURL reports whether s is an http(s) or ssh git remote
+func IsRemoteGitURL(s string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return scpLikeURLPattern.MatchString(s)
+}
+
+// CloneRepository clones url into ClonesDir. If the same URL was cloned
+// before, the existing clone is fetched and reset to the remote default
+// branch instead, discarding any local changes.
+func CloneRepository(url string) (*ClonedRepo, error) {
+	if !IsGitURL(url) {
+		return nil, fmt.Errorf("not a git URL: %s", url)
+	}
+
+	dir, err := CloneDir(url)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &ClonedRepo{Dir: dir}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		repo.Reused = true
+		log.Printf("[Clone] Updating existing clone of %s in %s", url, dir)
+		if output, err := runGit(dir, "fetch", "--prune", "origin"); err != nil {
+			return nil, fmt.Errorf("failed to fetch: %v\nOutput: %s", err, output)
+		}
+		// Pick up default branch changes on the remote
+		runGit(dir, "remote", "set-head", "origin", "--auto")
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create clones directory: %w", err)
+		}
+		// Remove a partial clone left behind by a failed attempt
+		os.RemoveAll(dir)
+		log.Printf("[Clone] Cloning %s into %s", url, dir)
+		if output, err := runGit(filepath.Dir(dir), "clone", url, dir); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to clone: %v\nOutput: %s", err, output)
+		}
+	}
+
+	repo.DefaultBranch = defaultBranch(dir)
+	if repo.Reused && repo.DefaultBranch != "" {
+		remoteRef := "origin/" + repo.DefaultBranch
+		for _, args := range [][]string{
+			{"checkout", "-f", "-B", repo.DefaultBranch, remoteRef},
+			{"reset", "--hard", remoteRef},
+			{"clean", "-fd"},
+		} {
+			if output, err := runGit(dir, args...); err != nil {
+				return nil, fmt.Errorf("failed to reset clone: git %s: %v\nOutput: %s", args[0], err, output)
+			}
+		}
+	}
+
+	return repo, nil
+}
+
+// defaultBranch returns the remote's default branch, falling back to the
+// currently checked out branch
+func defaultBranch(dir string) string {
+	if output, err := runGit(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(output), "origin/")
+	}
+	if output, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		if branch := strings.TrimSpace(output); branch != "HEAD" {
+			return branch
+		}
+	}
+	return ""
+}
+
+// CloneDir returns the absolute path CloneRepository uses for url. Equivalent
+// URLs with or without a trailing ".git" or slash share a clone.
+func CloneDir(url string) (string, error) {
+	normalized := normalizeGitURL(url)
+	sum := sha1.Sum([]byte(normalized))
+	dir, err := filepath.Abs(filepath.Join(ClonesDir, repoName(url)+"-"+hex.EncodeToString(sum[:])[:8]))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve clone directory: %w", err)
+	}
+	return dir, nil
+}
+
+// normalizeGitURL strips a trailing slash and ".git" from url
+func normalizeGitURL(url string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+}
+
+// repoName returns the repository name at the end of url, e.g. "repo" for
+// git@github.com:owner/repo.git
+func repoName(url string) string {
+	normalized := normalizeGitURL(url)
+	name := normalized[strings.LastIndexAny(normalized, "/:")+1:]
+	if name == "" || name == "." || name == ".." {
+		return "repo"
+	}
+	return name
+}
+
+// ResolveCloneTarget returns the directory a clone of url into target should
+// use. target is resolved relative to the home directory and defaults to the
+// repository name; it must stay inside the home directory.
+func ResolveCloneTarget(url, target string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if target == "" {
+		target = repoName(url)
+	}
+
+	dir, err := ResolvePath(target)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Clean(dir)
+	if !isWithin(homeDir, dir) {
+		return "", fmt.Errorf("clone target must be inside the home directory: %s", dir)
+	}
+
+	// Follow symlinks in the existing part of the path so a link cannot escape the home directory
+	if realHome, err := filepath.EvalSymlinks(homeDir); err == nil {
+		if realParent, err := filepath.EvalSymlinks(filepath.Dir(dir)); err == nil && !isWithin(realHome, filepath.Join(realParent, filepath.Base(dir))) {
+			return "", fmt.Errorf("clone target must be inside the home directory: %s", dir)
+		}
+	}
+	return dir, nil
+}
+
+// isWithin reports whether path is strictly inside dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CloneInto clones url into dir, which must not exist or be empty. Each
+// progress line git prints is passed to progress, which may be nil.
+func CloneInto(url, dir string, progress func(line string)) error {
+	if !IsGitURL(url) {
+		return fmt.Errorf("not a git URL: %s", url)
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("target directory already exists and is not empty: %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	log.Printf("[Clone] Cloning %s into %s", url, dir)
+	cmd := exec.Command("git", "clone", "--progress", url, dir)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git clone: %w", err)
+	}
+
+	// git rewrites progress lines in place with carriage returns
+	var output []string
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		output = append(output, line)
+		if progress != nil {
+			progress(line)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		// Leave nothing behind that would block a retry
+		os.RemoveAll(dir)
+		tail := output
+		if len(tail) > 5 {
+			tail = tail[len(tail)-5:]
+		}
+		return fmt.Errorf("failed to clone: %v\nOutput: %s", err, strings.Join(tail, "\n"))
+	}
+	return nil
+}
+
+// scanProgressLines is a bufio.SplitFunc that splits on both \n and \r
+func scanProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsGitURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://github.com/owner/repo.git", true},
+		{"https://github.com/owner/repo", true},
+		{"ssh://git@github.com/owner/repo.git", true},
+		{"git@github.com:owner/repo.git", true},
+		{"~/projects/repo", false},
+		{"/home/user/repo", false},
+		{"repo", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsGitURL(tt.url); got != tt.expected {
+			t.Errorf("IsGitURL(%q): expected %v, got %v", tt.url, tt.expected, got)
+		}
+	}
+}
+
+func TestCloneDir(t *testing.T) {
+	a, err := CloneDir("https://github.com/owner/repo.git")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	b, _ := CloneDir("https://github.com/owner/repo/")
+	if a != b {
+		t.Errorf("Expected equivalent URLs to share a clone, got %s and %s", a, b)
+	}
+	if !strings.HasPrefix(filepath.Base(a), "repo-") {
+		t.Errorf("Expected clone directory named after the repository, got %s", a)
+	}
+
+	c, _ := CloneDir("git@gitlab.com:other/repo.git")
+	if a == c {
+		t.Errorf("Expected different URLs to use different clones, got %s", c)
+	}
+}
+
+func TestCloneRepositoryReuse(t *testing.T) {
+	origin := initTestRepo(t)
+	oldDir := ClonesDir
+	ClonesDir = t.TempDir()
+	t.Cleanup(func() { ClonesDir = oldDir })
+
+	url := "file://" + origin
+	repo, err := CloneRepository(url)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if repo.Reused {
+		t.Error("Expected a fresh clone")
+	}
+	if repo.DefaultBranch != "main" {
+		t.Errorf("Expected default branch 'main', got %q", repo.DefaultBranch)
+	}
+
+	// Local changes are discarded and new remote commits picked up on reuse
+	if err := os.WriteFile(filepath.Join(repo.Dir, "README.md"), []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(origin, "NEW.md"), []byte("new\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(origin, "add", "NEW.md")
+	if output, err := runGit(origin, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "second"); err != nil {
+		t.Fatalf("Failed to commit: %v\n%s", err, output)
+	}
+
+	reused, err := CloneRepository(url)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reused.Reused || reused.Dir != repo.Dir {
+		t.Errorf("Expected existing clone %s to be reused, got %+v", repo.Dir, reused)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo.Dir, "README.md")); string(data) != "hello\n" {
+		t.Errorf("Expected local changes to be reset, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(repo.Dir, "NEW.md")); err != nil {
+		t.Errorf("Expected new remote commit to be checked out, got %v", err)
+	}
+}
+
+func TestResolveCloneTarget(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"", filepath.Join(home, "repo")},
+		{"projects/app", filepath.Join(home, "projects", "app")},
+		{"~/app", filepath.Join(home, "app")},
+		{filepath.Join(home, "abs"), filepath.Join(home, "abs")},
+	}
+	for _, tt := range tests {
+		dir, err := ResolveCloneTarget("https://github.com/owner/repo.git", tt.target)
+		if err != nil || dir != tt.expected {
+			t.Errorf("%q: expected %s, got %s (err %v)", tt.target, tt.expected, dir, err)
+		}
+	}
+
+	for _, target := range []string{"../escape", "~/a/../../escape", "/etc/repo", "~"} {
+		if _, err := ResolveCloneTarget("https://github.com/owner/repo.git", target); err == nil {
+			t.Errorf("%q: expected error for a target outside the home directory", target)
+		}
+	}
+
+	// A symlink inside the home directory cannot be used to escape it
+	outside := t.TempDir()
+	os.Symlink(outside, filepath.Join(home, "link"))
+	if _, err := ResolveCloneTarget("https://github.com/owner/repo.git", "link/repo"); err == nil {
+		t.Error("Expected error for a target behind a symlink leaving the home directory")
+	}
+}
+
+func TestCloneInto(t *testing.T) {
+	origin := initTestRepo(t)
+	target := filepath.Join(t.TempDir(), "clone")
+
+	var lines []string
+	if err := CloneInto("file://"+origin, target, func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "README.md")); err != nil {
+		t.Errorf("Expected README.md in clone, got %v", err)
+	}
+	if len(lines) == 0 {
+		t.Error("Expected progress output")
+	}
+
+	if err := CloneInto("file://"+origin, target, nil); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("Expected error for a non-empty target, got %v", err)
+	}
+}
+
+func TestIsRemoteGitURL(t *testing.T) {
+	for url, expected := range map[string]bool{
+		"https://github.com/owner/repo.git": true,
+		"git@github.com:owner/repo.git":     true,
+		"ssh://git@host/repo.git":           true,
+		"file:///tmp/repo":                  false,
+		"git://host/repo.git":               false,
+		"~/repo":                            false,
+	} {
+		if got := IsRemoteGitURL(url); got != expected {
+			t.Errorf("IsRemoteGitURL(%q): expected %v, got %v", url, expected, got)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GitAgentWorkspace is a workspace prepared for a git agent together with
+// the workflow instructions the agent is launched with
+type GitAgentWorkspace struct {
+	*Workspace
+	Prompt         string // Git workflow instructions followed by the task
+	ExistingBranch bool   // True if the branch already exists locally or on origin
+}
+
+// ValidateGitRepo resolves dir relative to the home directory and checks that
+// it is a git repository, returning the absolute path
+func ValidateGitRepo(dir string) (string, error) {
+	absDir, err := ResolvePath(dir)
+	if err != nil {
+		return "", fmt.Errorf("error resolving directory path: %w", err)
+	}
+
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return "", fmt.Errorf("directory not found: %s", absDir)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", absDir)
+	}
+
+	if _, err := os.Stat(filepath.Join(absDir, ".git")); os.IsNotExist(err) {
+		return "", fmt.Errorf("directory is not a git repository: %s", absDir)
+	}
+	return absDir, nil
+}
+
+// BranchExists reports whether branch exists locally or on origin
+func BranchExists(repoDir, branch string) (bool, error) {
+	output, err := runGit(repoDir, "branch", "--list", branch)
+	if err != nil {
+		return false, fmt.Errorf("failed to check local branches: %v", err)
+	}
+	if strings.TrimSpace(output) != "" {
+		return true, nil
+	}
+
+	output, err = runGit(repoDir, "branch", "-r", "--list", "origin/"+branch)
+	if err != nil {
+		return false, fmt.Errorf("failed to check remote branches: %v", err)
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// PrepareGitAgentWorkspace validates srcDir and prepares a workspace for an
+// agent working on branch. An existing branch gets instructions to check it
+// out and pull; otherwise the agent is told to create it. An empty branch
+// lets the agent name a new feature branch itself.
+func PrepareGitAgentWorkspace(srcDir, branch, task string) (*GitAgentWorkspace, error) {
+	repoDir, err := ValidateGitRepo(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := false
+	if branch != "" {
+		if existing, err = BranchExists(repoDir, branch); err != nil {
+			return nil, err
+		}
+	}
+
+	workspace, err := PrepareGitWorkspace(repoDir, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := NewBranchPrompt(branch, task)
+	if existing {
+		prompt = ExistingBranchPrompt(branch, task)
+	}
+	return &GitAgentWorkspace{Workspace: workspace, Prompt: prompt, ExistingBranch: existing}, nil
+}
+
+// NewBranchPrompt returns the prompt for an agent that creates a new feature
+// branch. Branch names without a feature/ prefix get one; an empty branch
+// leaves the name to the agent.
+func NewBranchPrompt(branch, task string) string {
+	checkout := "feature/<descriptive-name>"
+	push := "<branch-name>"
+	rule := "Always work on a new branch, never directly on main/master"
+	if branch != "" {
+		if !strings.HasPrefix(branch, "feature/") {
+			branch = "feature/" + branch
+		}
+		checkout, push = branch, branch
+		rule = fmt.Sprintf("Always work on the new branch '%s', never directly on main/master", branch)
+	}
+
+	return fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
+You are working on a git repository. You MUST follow these steps:
+
+1. First, create a new branch for your changes using: git checkout -b %s
+2. Make all the necessary changes to complete the task: %s
+3. Stage and commit your changes with a descriptive commit message
+   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
+   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
+   - Or stage files individually, explicitly excluding *_PLAN_*.md files
+4. Try to push the branch to the remote repository using: git push -u origin %s
+5. If the push fails due to authentication or permissions, that's okay - just report the status
+
+Remember:
+- %s
+- Make atomic, well-described commits
+- Include a clear commit message explaining what was changed and why
+- NEVER commit *_PLAN_*.md files - they're for your planning only
+
+Task: %s`, checkout, task, push, rule, task)
+}
+
+// ExistingBranchPrompt returns the prompt for an agent that continues work on an existing branch
+func ExistingBranchPrompt(branch, task string) string {
+	return fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
+You are working on a git repository with an existing branch. You MUST follow these steps:
+
+1. First, fetch the latest changes: git fetch origin
+2. Checkout the existing branch '%s' using: git checkout %s
+3. If the branch exists only on remote, use: git checkout -b %s origin/%s
+4. Pull the latest changes from the remote branch: git pull origin %s
+5. Make all the necessary changes to complete the task: %s
+6. Stage and commit your changes with a descriptive commit message
+   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
+   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
+   - Or stage files individually, explicitly excluding *_PLAN_*.md files
+7. Try to push the changes to the remote repository using: git push origin %s
+8. If the push fails due to authentication or permissions, that's okay - just report the status
+
+Remember:
+- You are working on the existing branch '%s'
+- Make atomic, well-described commits
+- Include a clear commit message explaining what was changed and why
+- Ensure you're up to date with the remote branch before making changes
+- NEVER commit *_PLAN_*.md files - they're for your planning only
+
+Task: %s`, branch, branch, branch, branch, branch, task, branch, branch, task)
+}
+
+// MergeBranchPrompt returns the prompt for an agent that merges source into
+// the checked out branch, resolving conflicts where it safely can
+func MergeBranchPrompt(source string) string {
+	return fmt.Sprintf(`IMPORTANT GIT MERGE INSTRUCTIONS:
+You are merging the branch '%s' into the currently checked out branch of a git repository. Follow these steps:
+
+1. Check the current branch and make sure the working tree is clean using: git status
+   If there are uncommitted changes, stop and report them - do not stash, commit or discard them
+2. Fetch the latest changes: git fetch origin
+3. Merge the branch using: git merge --no-ff %s
+   If the branch exists only on remote, use: git merge --no-ff origin/%s
+4. If there are conflicts, resolve each one sensibly, keeping the intent of both sides
+   - Build and run the tests if the project has them to check the resolution
+   - Stage the resolved files and complete the merge with: git commit --no-edit
+5. If you cannot resolve a conflict safely, abort with: git merge --abort
+   and report clearly which files conflict and why
+6. Try to push the result using: git push
+7. If the push fails due to authentication or permissions, that's okay - just report the status
+
+Remember:
+- NEVER force-push (no git push --force or --force-with-lease)
+- NEVER rewrite history (no rebase, reset --hard or amend of existing commits)
+- NEVER commit *_PLAN_*.md files - they're for your planning only
+
+Finally, report the merge result: whether it was a fast-forward, a clean merge or a merge with resolved conflicts, and explain how each conflict was resolved.`, source, source, source)
+}
+
+// VerifyGitRange checks that spec names a commit, or a range such as
+// HEAD~3..HEAD or main...feature whose ends resolve in repoDir. An empty end
+// of a range means HEAD, as in git.
+func VerifyGitRange(repoDir, spec string) error {
+	if spec == "" {
+		return fmt.Errorf("empty git ref")
+	}
+	ends := []string{spec}
+	if from, to, ok := strings.Cut(spec, "..."); ok {
+		ends = []string{from, to}
+	} else if from, to, ok := strings.Cut(spec, ".."); ok {
+		ends = []string{from, to}
+	}
+
+	for _, ref := range ends {
+		if ref == "" {
+			continue
+		}
+		// Refs are passed to git as arguments, never let them become options
+		if strings.HasPrefix(ref, "-") {
+			return fmt.Errorf("invalid git ref: %s", ref)
+		}
+		if _, err := runGit(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+			return fmt.Errorf("git ref does not resolve to a commit: %s", ref)
+		}
+	}
+	return nil
+}
+
+// GitBranches lists the branches of a repository
+type GitBranches struct {
+	Current string   // Checked out branch, empty when HEAD is detached
+	Local   []string // Local branches
+	Remote  []string // Remote tracking branches such as origin/main
+
+	// Tracking holds how far local branches with an upstream, usually on
+	// origin, are ahead of and behind it
+	Tracking map[string]BranchTracking
+}
+
+// BranchTracking compares a local branch with its upstream
+type BranchTracking struct {
+	Upstream string // Such as origin/main
+	Ahead    int    // Commits on the branch that are not on the upstream
+	Behind   int    // Commits on the upstream that are not on the branch
+	Gone     bool   // The upstream was deleted from the remote
+}
+
+// ListGitBranches returns the local and remote branches of repoDir. Failing to
+// list remote branches is not an error.
+func ListGitBranches(repoDir string) (*GitBranches, error) {
+	output, err := runGit(repoDir, "branch", "--format=%(refname:short)%09%(upstream:short)%09%(upstream:track,nobracket)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local branches: %v", err)
+	}
+	branches := &GitBranches{Tracking: make(map[string]BranchTracking)}
+	for _, line := range splitLines(output) {
+		fields := strings.Split(line, "\t")
+		branches.Local = append(branches.Local, fields[0])
+		if len(fields) > 1 && fields[1] != "" {
+			track := ""
+			if len(fields) > 2 {
+				track = fields[2]
+			}
+			branches.Tracking[fields[0]] = parseBranchTracking(fields[1], track)
+		}
+	}
+
+	if current, err := runGit(repoDir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		branches.Current = strings.TrimSpace(current)
+	}
+
+	if output, err := runGit(repoDir, "branch", "-r", "--format=%(refname:short)"); err == nil {
+		for _, branch := range splitLines(output) {
+			// Skip the remote's HEAD pointer, shown as "origin" or "origin/HEAD"
+			if !strings.Contains(branch, "/") || strings.HasSuffix(branch, "/HEAD") {
+				continue
+			}
+			branches.Remote = append(branches.Remote, branch)
+		}
+	}
+	return branches, nil
+}
+
+// parseBranchTracking parses git's upstream:track output, such as
+// "ahead 2, behind 1" or "gone". It is empty when the branch is up to date.
+func parseBranchTracking(upstream, track string) BranchTracking {
+	tracking := BranchTracking{Upstream: upstream}
+	for _, part := range strings.Split(track, ",") {
+		fields := strings.Fields(part)
+		switch {
+		case len(fields) == 1 && fields[0] == "gone":
+			tracking.Gone = true
+		case len(fields) == 2 && fields[0] == "ahead":
+			tracking.Ahead, _ = strconv.Atoi(fields[1])
+		case len(fields) == 2 && fields[0] == "behind":
+			tracking.Behind, _ = strconv.Atoi(fields[1])
+		}
+	}
+	return tracking
+}
+
+// Names returns the local branches followed by origin branches that have no
+// local counterpart, without the origin/ prefix
+func (g *GitBranches) Names() []string {
+	names := append([]string{}, g.Local...)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, remote := range g.Remote {
+		name := strings.TrimPrefix(remote, "origin/")
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names
+}
+
+// GitHead returns the commit checked out in repoDir
+func GitHead(repoDir string) (string, error) {
+	output, err := runGit(repoDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %v", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// UnpushedCommits returns the current branch of repoDir and its commits that
+// did not reach origin, as "<sha> <subject>" lines, newest first. Commits are
+// compared with origin/<branch>, or with every origin branch when the branch
+// was never pushed. A repository without an origin remote has nothing to push,
+// so no commits are returned.
+func UnpushedCommits(repoDir string) (string, []string, error) {
+	output, err := runGit(repoDir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", nil, fmt.Errorf("HEAD is not on a branch")
+	}
+	branch := strings.TrimSpace(output)
+
+	if _, err := runGit(repoDir, "remote", "get-url", "origin"); err != nil {
+		return branch, nil, nil
+	}
+
+	args := []string{"log", "--format=%h %s", "origin/" + branch + "..HEAD"}
+	if _, err := runGit(repoDir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch); err != nil {
+		args = []string{"log", "--format=%h %s", "HEAD", "--not", "--remotes=origin"}
+	}
+	output, err = runGit(repoDir, args...)
+	if err != nil {
+		return branch, nil, fmt.Errorf("failed to list unpushed commits: %v", err)
+	}
+	return branch, splitLines(output), nil
+}
+
+// splitLines returns the non-empty trimmed lines of output
+func splitLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateGitRepo(t *testing.T) {
+	repo := initTestRepo(t)
+	if dir, err := ValidateGitRepo(repo); err != nil || dir != repo {
+		t.Errorf("Expected %s, got %s (err %v)", repo, dir, err)
+	}
+
+	if _, err := ValidateGitRepo(t.TempDir()); err == nil || !strings.Contains(err.Error(), "not a git repository") {
+		t.Errorf("Expected not a git repository error, got %v", err)
+	}
+	if _, err := ValidateGitRepo("/nonexistent/mavis/repo"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestPrepareGitAgentWorkspace(t *testing.T) {
+	repo := initTestRepo(t)
+	oldRoot := WorktreeRoot
+	WorktreeRoot = t.TempDir()
+	t.Cleanup(func() { WorktreeRoot = oldRoot })
+
+	if _, err := runGit(repo, "branch", "existing"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	tests := []struct {
+		branch   string
+		existing bool
+		expected string
+	}{
+		{"", false, "git checkout -b feature/<descriptive-name>"},
+		{"login", false, "git checkout -b feature/login"},
+		{"existing", true, "git checkout existing"},
+	}
+	for _, tt := range tests {
+		workspace, err := PrepareGitAgentWorkspace(repo, tt.branch, "do the task")
+		if err != nil {
+			t.Fatalf("%q: expected no error, got %v", tt.branch, err)
+		}
+		if workspace.ExistingBranch != tt.existing {
+			t.Errorf("%q: expected existing %v, got %v", tt.branch, tt.existing, workspace.ExistingBranch)
+		}
+		if !strings.Contains(workspace.Prompt, tt.expected) || !strings.HasSuffix(workspace.Prompt, "Task: do the task") {
+			t.Errorf("%q: expected prompt containing %q, got %s", tt.branch, tt.expected, workspace.Prompt)
+		}
+		if err := workspace.Cleanup(); err != nil {
+			t.Errorf("%q: failed to clean up: %v", tt.branch, err)
+		}
+	}
+}
+
+func TestListGitBranches(t *testing.T) {
+	repo := initTestRepo(t)
+	if _, err := runGit(repo, "branch", "feature/x"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	branches, err := ListGitBranches(repo)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if branches.Current != "main" {
+		t.Errorf("Expected current branch main, got %q", branches.Current)
+	}
+	if strings.Join(branches.Local, ",") != "feature/x,main" {
+		t.Errorf("Expected local branches feature/x,main, got %v", branches.Local)
+	}
+	if len(branches.Remote) != 0 {
+		t.Errorf("Expected no remote branches, got %v", branches.Remote)
+	}
+}
+
+func TestListGitBranchesTracking(t *testing.T) {
+	origin := initTestRepo(t)
+	clone := t.TempDir()
+	if output, err := runGit(clone, "clone", "-q", origin, "."); err != nil {
+		t.Fatalf("Failed to clone: %v\n%s", err, output)
+	}
+	runGit(clone, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "local")
+	runGit(clone, "branch", "untracked")
+
+	branches, err := ListGitBranches(clone)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tracking, ok := branches.Tracking["main"]
+	if !ok {
+		t.Fatalf("Expected main to track origin/main, got %v", branches.Tracking)
+	}
+	if tracking.Upstream != "origin/main" || tracking.Ahead != 1 || tracking.Behind != 0 {
+		t.Errorf("Expected origin/main ahead 1 behind 0, got %+v", tracking)
+	}
+	if _, ok := branches.Tracking["untracked"]; ok {
+		t.Error("Expected no tracking for a branch without upstream")
+	}
+	if strings.Join(branches.Remote, ",") != "origin/main" {
+		t.Errorf("Expected remote origin/main, got %v", branches.Remote)
+	}
+}
+
+func TestParseBranchTracking(t *testing.T) {
+	tests := []struct {
+		track    string
+		expected BranchTracking
+	}{
+		{"", BranchTracking{Upstream: "origin/main"}},
+		{"ahead 2", BranchTracking{Upstream: "origin/main", Ahead: 2}},
+		{"behind 3", BranchTracking{Upstream: "origin/main", Behind: 3}},
+		{"ahead 2, behind 3", BranchTracking{Upstream: "origin/main", Ahead: 2, Behind: 3}},
+		{"gone", BranchTracking{Upstream: "origin/main", Gone: true}},
+	}
+	for _, tt := range tests {
+		if got := parseBranchTracking("origin/main", tt.track); got != tt.expected {
+			t.Errorf("%q: expected %+v, got %+v", tt.track, tt.expected, got)
+		}
+	}
+}
+
+func TestUnpushedCommits(t *testing.T) {
+	origin := initTestRepo(t)
+	clone := t.TempDir()
+	if output, err := runGit(clone, "clone", "-q", origin, "."); err != nil {
+		t.Fatalf("Failed to clone: %v\n%s", err, output)
+	}
+	commit := func(message string) {
+		if output, err := runGit(clone, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", message); err != nil {
+			t.Fatalf("Failed to commit: %v\n%s", err, output)
+		}
+	}
+
+	branch, commits, err := UnpushedCommits(clone)
+	if err != nil || branch != "main" || len(commits) != 0 {
+		t.Fatalf("Expected main with nothing unpushed, got %q %v (err %v)", branch, commits, err)
+	}
+
+	commit("first")
+	commit("second")
+	_, commits, err = UnpushedCommits(clone)
+	if err != nil || len(commits) != 2 || !strings.HasSuffix(commits[0], " second") {
+		t.Errorf("Expected 2 unpushed commits, newest first, got %v (err %v)", commits, err)
+	}
+
+	// A branch that was never pushed is compared with every origin branch
+	runGit(clone, "checkout", "-q", "-b", "feature")
+	commit("feature work")
+	branch, commits, err = UnpushedCommits(clone)
+	if err != nil || branch != "feature" || len(commits) != 3 {
+		t.Errorf("Expected 3 unpushed commits on feature, got %q %v (err %v)", branch, commits, err)
+	}
+
+	// Without an origin there is nowhere to push
+	_, commits, err = UnpushedCommits(initTestRepo(t))
+	if err != nil || len(commits) != 0 {
+		t.Errorf("Expected no commits without origin, got %v (err %v)", commits, err)
+	}
+}
+
+func TestGitBranchesNames(t *testing.T) {
+	branches := &GitBranches{
+		Local:  []string{"main", "dev"},
+		Remote: []string{"origin/main", "origin/release", "upstream/main"},
+	}
+	if names := strings.Join(branches.Names(), ","); names != "main,dev,release,upstream/main" {
+		t.Errorf("Expected main,dev,release,upstream/main, got %s", names)
+	}
+}
+
+func TestMergeBranchPrompt(t *testing.T) {
+	prompt := MergeBranchPrompt("feature/auth")
+	for _, expected := range []string{"git merge --no-ff feature/auth", "git merge --abort", "NEVER force-push"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("Expected prompt to contain %q", expected)
+		}
+	}
+}
+
+func TestVerifyGitRange(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "second")
+
+	for _, spec := range []string{"HEAD", "HEAD~1", "HEAD~1..HEAD", "main...HEAD", "HEAD~1.."} {
+		if err := VerifyGitRange(repo, spec); err != nil {
+			t.Errorf("%s: expected no error, got %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "nope", "HEAD~5..HEAD", "--output=/tmp/x", "HEAD..-p"} {
+		if err := VerifyGitRange(repo, spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
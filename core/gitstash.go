@@ -0,0 +1,61 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNothingToStash is returned by GitStash when the working tree is clean
+var ErrNothingToStash = errors.New("no local changes to save")
+
+// ErrNoStash is returned by GitStashPop when the stash is empty
+var ErrNoStash = errors.New("no stash entries found")
+
+// StashConflictError is returned by GitStashPop when the stash did not apply
+// cleanly. The stash entry is kept so nothing is lost.
+type StashConflictError struct {
+	Output string // git output listing the conflicting files
+}
+
+func (e *StashConflictError) Error() string {
+	return "stash applied with conflicts"
+}
+
+// GitStash stashes the uncommitted changes in repoDir, untracked files
+// included, and returns git's output
+func GitStash(repoDir, message string) (string, error) {
+	args := []string{"stash", "push", "--include-untracked"}
+	if message != "" {
+		args = append(args, "-m", message)
+	}
+	output, err := runGit(repoDir, args...)
+	output = strings.TrimSpace(output)
+	if err != nil {
+		return output, fmt.Errorf("git stash failed: %v\nOutput: %s", err, output)
+	}
+	if strings.Contains(output, "No local changes to save") {
+		return output, ErrNothingToStash
+	}
+	return output, nil
+}
+
+// GitStashPop applies and drops the most recent stash in repoDir
+func GitStashPop(repoDir string) (string, error) {
+	if list, err := runGit(repoDir, "stash", "list"); err == nil && strings.TrimSpace(list) == "" {
+		return "", ErrNoStash
+	}
+
+	output, err := runGit(repoDir, "stash", "pop")
+	output = strings.TrimSpace(output)
+	if err != nil {
+		if strings.Contains(output, "CONFLICT") {
+			return output, &StashConflictError{Output: output}
+		}
+		return output, fmt.Errorf("git stash pop failed: %v\nOutput: %s", err, output)
+	}
+	return output, nil
+}
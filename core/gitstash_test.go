@@ -0,0 +1,46 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitStash(t *testing.T) {
+	repo := initTestRepo(t)
+	readme := filepath.Join(repo, "README.md")
+
+	if _, err := GitStash(repo, ""); !errors.Is(err, ErrNothingToStash) {
+		t.Errorf("Expected ErrNothingToStash, got %v", err)
+	}
+	if _, err := GitStashPop(repo); !errors.Is(err, ErrNoStash) {
+		t.Errorf("Expected ErrNoStash, got %v", err)
+	}
+
+	os.WriteFile(readme, []byte("stashed\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "new.txt"), []byte("untracked\n"), 0644)
+	if _, err := GitStash(repo, "wip"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(readme); string(data) != "hello\n" {
+		t.Errorf("Expected clean README after stash, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "new.txt")); !os.IsNotExist(err) {
+		t.Error("Expected untracked file to be stashed")
+	}
+
+	// A conflicting change keeps the stash and reports the conflict
+	os.WriteFile(readme, []byte("conflict\n"), 0644)
+	runGit(repo, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-am", "change")
+	var conflict *StashConflictError
+	if _, err := GitStashPop(repo); !errors.As(err, &conflict) {
+		t.Fatalf("Expected StashConflictError, got %v", err)
+	}
+	if list, _ := runGit(repo, "stash", "list"); list == "" {
+		t.Error("Expected the stash to be kept after a conflict")
+	}
+}
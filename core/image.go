@@ -0,0 +1,150 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoImageConverter is returned by ConvertImageToPNG when no converter is installed
+var ErrNoImageConverter = errors.New("no image converter found (install ImageMagick, libwebp or libheif)")
+
+// Image formats detected by DetectImageFormat
+const (
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+	ImageFormatGIF  = "gif"
+	ImageFormatSVG  = "svg"
+	ImageFormatWebP = "webp"
+	ImageFormatHEIC = "heic"
+	ImageFormatAVIF = "avif"
+	ImageFormatBMP  = "bmp"
+)
+
+// IsAgentReadableImage reports whether agents can open images of format
+// directly. SVG is read as text.
+func IsAgentReadableImage(format string) bool {
+	switch format {
+	case ImageFormatPNG, ImageFormatJPEG, ImageFormatGIF, ImageFormatSVG:
+		return true
+	}
+	return false
+}
+
+// IsConvertibleImage reports whether images of format can be transcoded to PNG
+func IsConvertibleImage(format string) bool {
+	switch format {
+	case ImageFormatWebP, ImageFormatHEIC, ImageFormatAVIF, ImageFormatBMP:
+		return true
+	}
+	return false
+}
+
+// DetectImageFormat sniffs the content of the file at path and returns its
+// image format, or an error if it is not a recognised image
+func DetectImageFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detectImageFormat(header[:n])
+}
+
+// detectImageFormat identifies an image from its first bytes
+func detectImageFormat(header []byte) (string, error) {
+	// HEIC and AVIF are ISO media files identified by the brand in the ftyp box
+	if len(header) >= 12 && string(header[4:8]) == "ftyp" {
+		switch string(header[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			return ImageFormatHEIC, nil
+		case "avif", "avis":
+			return ImageFormatAVIF, nil
+		}
+	}
+
+	switch contentType := http.DetectContentType(header); contentType {
+	case "image/png":
+		return ImageFormatPNG, nil
+	case "image/jpeg":
+		return ImageFormatJPEG, nil
+	case "image/gif":
+		return ImageFormatGIF, nil
+	case "image/webp":
+		return ImageFormatWebP, nil
+	case "image/bmp":
+		return ImageFormatBMP, nil
+	default:
+		if strings.HasPrefix(contentType, "text/") && bytes.Contains(bytes.ToLower(header), []byte("<svg")) {
+			return ImageFormatSVG, nil
+		}
+		return "", fmt.Errorf("unrecognised image content (%s)", contentType)
+	}
+}
+
+// imageConverters are tried in order by ConvertImageToPNG. formats limits a
+// converter to some formats; nil means it handles them all.
+var imageConverters = []struct {
+	binary  string
+	formats []string
+	args    func(src, dst string) []string
+}{
+	{"sips", nil, func(src, dst string) []string { return []string{"-s", "format", "png", src, "--out", dst} }},
+	{"magick", nil, func(src, dst string) []string { return []string{src, dst} }},
+	{"convert", nil, func(src, dst string) []string { return []string{src, dst} }},
+	{"dwebp", []string{ImageFormatWebP}, func(src, dst string) []string { return []string{src, "-o", dst} }},
+	{"heif-convert", []string{ImageFormatHEIC, ImageFormatAVIF}, func(src, dst string) []string { return []string{src, dst} }},
+}
+
+// ConvertImageToPNG transcodes the image at src, of the given format, to a
+// PNG at dst using the first installed converter that succeeds
+func ConvertImageToPNG(src, dst, format string) error {
+	var lastErr error
+	for _, converter := range imageConverters {
+		if converter.formats != nil && !containsString(converter.formats, format) {
+			continue
+		}
+		path, err := exec.LookPath(converter.binary)
+		if err != nil {
+			continue
+		}
+
+		output, err := exec.Command(path, converter.args(src, dst)...).CombinedOutput()
+		if err == nil {
+			if converted, detectErr := DetectImageFormat(dst); detectErr == nil && converted == ImageFormatPNG {
+				return nil
+			}
+			err = fmt.Errorf("no PNG was produced")
+		}
+		os.Remove(dst)
+		lastErr = fmt.Errorf("%s failed: %v %s", converter.binary, err, strings.TrimSpace(string(output)))
+	}
+	if lastErr == nil {
+		return ErrNoImageConverter
+	}
+	return lastErr
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
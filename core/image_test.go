@@ -0,0 +1,65 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectImageFormat(t *testing.T) {
+	tests := map[string]string{
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR":                                       ImageFormatPNG,
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00":                                          ImageFormatJPEG,
+		"GIF89a\x01\x00\x01\x00":                                                    ImageFormatGIF,
+		"RIFF\x24\x00\x00\x00WEBPVP8 ":                                              ImageFormatWebP,
+		"\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic":                          ImageFormatHEIC,
+		"\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1":                          ImageFormatAVIF,
+		"<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>": ImageFormatSVG,
+	}
+	for header, expected := range tests {
+		if format, err := detectImageFormat([]byte(header)); err != nil || format != expected {
+			t.Errorf("Expected %s, got %q (err %v)", expected, format, err)
+		}
+	}
+
+	if _, err := detectImageFormat([]byte("just some text")); err == nil {
+		t.Error("Expected an error for text content")
+	}
+}
+
+func TestDetectImageFormatFile(t *testing.T) {
+	// A .jpg name does not matter, the content decides
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if format, err := DetectImageFormat(path); err != nil || format != ImageFormatPNG {
+		t.Errorf("Expected png, got %q (err %v)", format, err)
+	}
+}
+
+func TestImageFormatSupport(t *testing.T) {
+	for _, format := range []string{ImageFormatPNG, ImageFormatJPEG, ImageFormatGIF, ImageFormatSVG} {
+		if !IsAgentReadableImage(format) || IsConvertibleImage(format) {
+			t.Errorf("Expected %s to be readable without conversion", format)
+		}
+	}
+	for _, format := range []string{ImageFormatWebP, ImageFormatHEIC, ImageFormatAVIF, ImageFormatBMP} {
+		if IsAgentReadableImage(format) || !IsConvertibleImage(format) {
+			t.Errorf("Expected %s to need conversion", format)
+		}
+	}
+}
+
+func TestConvertImageToPNGWithoutConverter(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	err := ConvertImageToPNG(filepath.Join(dir, "in.webp"), filepath.Join(dir, "out.png"), ImageFormatWebP)
+	if !errors.Is(err, ErrNoImageConverter) {
+		t.Errorf("Expected ErrNoImageConverter, got %v", err)
+	}
+}
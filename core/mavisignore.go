@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MavisIgnoreFile lists paths (gitignore syntax) to leave out of temporary repository copies
+const MavisIgnoreFile = ".mavisignore"
+
+// defaultRsyncExcludes are dependency, cache and build directories that are
+// never worth copying. vendor/ is not listed because Go repositories often
+// commit it; projects that don't usually ignore it in .gitignore.
+var defaultRsyncExcludes = []string{
+	"node_modules",
+	".DS_Store",
+	".venv",
+	"venv",
+	"__pycache__",
+	".pytest_cache",
+	".mypy_cache",
+	".tox",
+	".next",
+	".gradle",
+	"target/",
+}
+
+// RsyncCopyArgs returns the rsync arguments used to copy a repository into a
+// temporary workspace, honouring the repository's .mavisignore
+func RsyncCopyArgs(srcDir, dstDir string) []string {
+	args := []string{"-av"}
+	args = append(args, RsyncExcludeArgs(srcDir)...)
+	return append(args, srcDir+"/", dstDir+"/")
+}
+
+// RsyncExcludeArgs translates the default excludes and the .gitignore and
+// .mavisignore files in dir into rsync --include and --exclude arguments.
+// Negated patterns in either file re-include paths excluded by any rule.
+func RsyncExcludeArgs(dir string) []string {
+	excludes := append([]string(nil), defaultRsyncExcludes...)
+	var includes []string
+	for _, name := range []string{".gitignore", MavisIgnoreFile} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("[MavisIgnore] Failed to read %s in %s: %v", name, dir, err)
+			}
+			continue
+		}
+		fileIncludes, fileExcludes := parseIgnorePatterns(string(data))
+		includes = append(includes, fileIncludes...)
+		excludes = append(excludes, fileExcludes...)
+	}
+
+	args := make([]string, 0, len(includes)+len(excludes))
+	// rsync uses the first matching rule, so negated patterns must come first
+	for _, pattern := range includes {
+		args = append(args, "--include="+pattern)
+	}
+	for _, pattern := range excludes {
+		args = append(args, "--exclude="+pattern)
+	}
+	return args
+}
+
+// parseIgnorePatterns converts gitignore-style lines into rsync patterns.
+// Negated ("!") patterns are returned as includes.
+func parseIgnorePatterns(content string) (includes, excludes []string) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negated := false
+		if strings.HasPrefix(line, "!") {
+			negated = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if line == "" {
+			continue
+		}
+
+		// In gitignore a slash anywhere but the end anchors the pattern to the
+		// repository root; rsync only anchors patterns with a leading slash
+		trimmed := strings.TrimSuffix(line, "/")
+		if strings.Contains(trimmed, "/") && !strings.HasPrefix(line, "/") && !strings.HasPrefix(line, "**/") {
+			line = "/" + line
+		}
+
+		if negated {
+			includes = append(includes, line)
+		} else {
+			excludes = append(excludes, line)
+		}
+	}
+	return includes, excludes
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRsyncExcludeArgsDefaults(t *testing.T) {
+	args := RsyncExcludeArgs(t.TempDir())
+	if len(args) != len(defaultRsyncExcludes) || args[0] != "--exclude=node_modules" || args[len(args)-1] != "--exclude=target/" {
+		t.Errorf("Expected only the default excludes, got %v", args)
+	}
+}
+
+func TestRsyncExcludeArgsMavisIgnore(t *testing.T) {
+	dir := t.TempDir()
+	content := "# build output\nbuild/\n.venv\n\n*.log\n!keep.log\ndocs/generated\n/tmp\n**/cache\n"
+	if err := os.WriteFile(filepath.Join(dir, MavisIgnoreFile), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", MavisIgnoreFile, err)
+	}
+
+	args := RsyncExcludeArgs(dir)
+	if args[0] != "--include=keep.log" {
+		t.Errorf("Expected negated pattern to come first, got %v", args)
+	}
+	args = args[1+len(defaultRsyncExcludes):]
+	expected := []string{
+		"--exclude=build/",
+		"--exclude=.venv",
+		"--exclude=*.log",
+		"--exclude=/docs/generated",
+		"--exclude=/tmp",
+		"--exclude=**/cache",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %v, got %v", expected, args)
+	}
+}
+
+func TestRsyncExcludeArgsGitIgnore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("vendor/\n*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MavisIgnoreFile), []byte("!node_modules\ndata/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", MavisIgnoreFile, err)
+	}
+
+	args := RsyncExcludeArgs(dir)
+	if args[0] != "--include=node_modules" {
+		t.Errorf("Expected negated pattern to come first, got %v", args)
+	}
+	tail := args[len(args)-3:]
+	expected := []string{"--exclude=vendor/", "--exclude=*.tmp", "--exclude=data/"}
+	if !reflect.DeepEqual(tail, expected) {
+		t.Errorf("Expected .gitignore then .mavisignore excludes %v, got %v", expected, tail)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/mdns"
+	"github.com/miekg/dns"
+)
+
+// Multicast DNS (RFC 6762) and DNS-SD (RFC 6763) responder that makes
+// <host>.local resolve to this machine and announces LAN servers as
+// _http._tcp services. Queries are answered by hashicorp/mdns; this file only
+// supplies the records.
+
+// mdnsGroup is the IPv4 mDNS multicast group and port
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const (
+	mdnsTTL         = 120 // Seconds answers may be cached
+	mdnsService     = "_http._tcp"
+	mdnsServiceType = mdnsService + ".local."
+	mdnsServicesAll = "_services._dns-sd._udp.local."
+)
+
+// MDNSResponder answers mDNS queries for a .local host name and the HTTP
+// services registered with Advertise. It implements mdns.Zone.
+type MDNSResponder struct {
+	host     string                    // Fully qualified host name, e.g. mavis.local.
+	addrs    func() []net.IP           // IPv4 addresses to answer with
+	server   *mdns.Server              // Nil in tests
+	conn     *net.UDPConn              // Socket on port 5353 announcements are sent from, nil in tests
+	mu       sync.Mutex                // Protects services
+	services map[int]*mdns.MDNSService // port -> advertised service
+	send     func(records []dns.RR)    // Sends an unsolicited response to the multicast group
+}
+
+// NormalizeMDNSHostname validates a host name to answer mDNS queries for and
+// returns it in lower case with the .local domain, which is added when missing
+// (e.g. "devbox" becomes "devbox.local")
+func NormalizeMDNSHostname(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if !strings.HasSuffix(name, ".local") {
+		name += ".local"
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("invalid host name: %q", name)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", fmt.Errorf("invalid host name: %q", name)
+			}
+		}
+	}
+	return name, nil
+}
+
+// NewMDNSResponder starts answering mDNS queries for hostname (e.g.
+// mavis.local). It fails if multicast is not available on this host.
+func NewMDNSResponder(hostname string) (*MDNSResponder, error) {
+	r := newMDNSResponder(hostname, localIPv4Addrs)
+
+	// Announcements must come from port 5353 to be trusted, so they get a
+	// socket of their own next to the server's listeners
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join mDNS multicast group: %w", err)
+	}
+	server, err := mdns.NewServer(&mdns.Config{Zone: r})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start mDNS server: %w", err)
+	}
+	r.conn = conn
+	r.server = server
+	r.send = r.sendMulticast
+
+	// Announce the host name so caches on the network pick it up
+	r.announce(r.hostRecords(mdnsTTL))
+	return r, nil
+}
+
+func newMDNSResponder(hostname string, addrs func() []net.IP) *MDNSResponder {
+	return &MDNSResponder{
+		host:     strings.ToLower(strings.TrimSuffix(hostname, ".")) + ".",
+		addrs:    addrs,
+		services: make(map[int]*mdns.MDNSService),
+		send:     func([]dns.RR) {},
+	}
+}
+
+// Advertise announces an HTTP service called name on port
+func (r *MDNSResponder) Advertise(port int, name string) {
+	instance := strings.ReplaceAll(name, ".", " ")
+	service, err := mdns.NewMDNSService(instance, mdnsService, "local.", r.host, port, r.addrs(), []string{"path=/"})
+	if err != nil {
+		log.Printf("[mDNS] Failed to advertise %s on port %d: %v", name, port, err)
+		return
+	}
+
+	r.mu.Lock()
+	r.services[port] = service
+	r.mu.Unlock()
+
+	r.announce(service.Records(dns.Question{Name: mdnsServiceType, Qtype: dns.TypePTR}))
+}
+
+// Withdraw removes the service on port, telling caches to drop it
+func (r *MDNSResponder) Withdraw(port int) {
+	r.mu.Lock()
+	service, ok := r.services[port]
+	delete(r.services, port)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// A goodbye is an announcement with a TTL of zero. Only the service
+	// records, the host name stays valid.
+	var records []dns.RR
+	for _, rr := range service.Records(dns.Question{Name: mdnsServiceType, Qtype: dns.TypePTR}) {
+		if rr.Header().Rrtype != dns.TypeA {
+			records = append(records, rr)
+		}
+	}
+	r.send(withTTL(records, 0))
+}
+
+// Close withdraws every service and stops answering queries
+func (r *MDNSResponder) Close() error {
+	r.mu.Lock()
+	ports := make([]int, 0, len(r.services))
+	for port := range r.services {
+		ports = append(ports, port)
+	}
+	r.mu.Unlock()
+	for _, port := range ports {
+		r.Withdraw(port)
+	}
+	r.send(r.hostRecords(0))
+
+	if r.server == nil {
+		return nil
+	}
+	r.conn.Close()
+	return r.server.Shutdown()
+}
+
+// Records answers a question for the mDNS server
+func (r *MDNSResponder) Records(q dns.Question) []dns.RR {
+	if strings.EqualFold(q.Name, r.host) {
+		if q.Qtype == dns.TypeA || q.Qtype == dns.TypeANY {
+			return r.hostRecords(mdnsTTL)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Each service answers for its own names; the shared service type and
+	// enumeration names would otherwise repeat the PTR and A records
+	var records []dns.RR
+	seen := make(map[string]bool)
+	for _, port := range r.sortedPorts() {
+		service := r.services[port]
+		question := q
+		for _, name := range []string{mdnsServicesAll, mdnsServiceType, service.Instance + "." + mdnsServiceType} {
+			if strings.EqualFold(q.Name, name) {
+				question.Name = name
+			}
+		}
+		for _, rr := range service.Records(question) {
+			if key := rr.String(); !seen[key] {
+				seen[key] = true
+				records = append(records, rr)
+			}
+		}
+	}
+	return records
+}
+
+// announce sends unsolicited responses twice, a second apart, as RFC 6762 asks
+func (r *MDNSResponder) announce(records []dns.RR) {
+	r.send(records)
+	go func() {
+		time.Sleep(time.Second)
+		r.send(records)
+	}()
+}
+
+// sendMulticast sends records to the multicast group as an unsolicited response
+func (r *MDNSResponder) sendMulticast(records []dns.RR) {
+	if len(records) == 0 {
+		return
+	}
+	msg := &dns.Msg{
+		MsgHdr:   dns.MsgHdr{Response: true, Authoritative: true},
+		Answer:   records,
+		Compress: true,
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		log.Printf("[mDNS] Failed to encode announcement: %v", err)
+		return
+	}
+	if _, err := r.conn.WriteToUDP(data, mdnsGroup); err != nil {
+		log.Printf("[mDNS] Failed to send announcement: %v", err)
+	}
+}
+
+// hostRecords returns the A records of the host
+func (r *MDNSResponder) hostRecords(ttl uint32) []dns.RR {
+	var records []dns.RR
+	for _, ip := range r.addrs() {
+		if ip4 := ip.To4(); ip4 != nil {
+			records = append(records, &dns.A{
+				Hdr: dns.RR_Header{Name: r.host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+				A:   ip4,
+			})
+		}
+	}
+	return records
+}
+
+// withTTL returns copies of records with their TTL set to ttl
+func withTTL(records []dns.RR, ttl uint32) []dns.RR {
+	copies := make([]dns.RR, 0, len(records))
+	for _, rr := range records {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = ttl
+		copies = append(copies, rr)
+	}
+	return copies
+}
+
+// sortedPorts returns the advertised ports in order. The caller must hold r.mu.
+func (r *MDNSResponder) sortedPorts() []int {
+	ports := make([]int, 0, len(r.services))
+	for port := range r.services {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// localIPv4Addrs returns the non-loopback IPv4 addresses of this host
+func localIPv4Addrs() []net.IP {
+	var ips []net.IP
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			ips = append(ips, ipnet.IP.To4())
+		}
+	}
+	return ips
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestMDNSResponderRecords(t *testing.T) {
+	r := newMDNSResponder("Mavis.local", func() []net.IP { return []net.IP{net.IPv4(192, 168, 1, 20)} })
+	r.Advertise(3000, "Mavis app (3000)")
+	r.Advertise(8080, "Mavis Files docs (8080)")
+
+	tests := []struct {
+		name    string
+		qtype   uint16
+		answers int
+	}{
+		{"mavis.local.", dns.TypeA, 1},
+		{"MAVIS.local.", dns.TypeANY, 1},
+		{mdnsServiceType, dns.TypePTR, 7}, // PTR, SRV and TXT for each service and the shared A record
+		{mdnsServicesAll, dns.TypePTR, 1},
+		{"Mavis app (3000)." + mdnsServiceType, dns.TypeSRV, 2},
+		{"mavis app (3000)." + mdnsServiceType, dns.TypeTXT, 1},
+		{"other.local.", dns.TypeA, 0},
+		{"mavis.local.", dns.TypeSRV, 0},
+	}
+	for _, tt := range tests {
+		records := r.Records(dns.Question{Name: tt.name, Qtype: tt.qtype, Qclass: dns.ClassINET})
+		if len(records) != tt.answers {
+			t.Errorf("%s: expected %d answers, got %d: %v", tt.name, tt.answers, len(records), records)
+		}
+	}
+
+	r.Withdraw(3000)
+	if records := r.Records(dns.Question{Name: mdnsServiceType, Qtype: dns.TypePTR}); len(records) != 4 {
+		t.Errorf("Expected 4 answers after withdrawing, got %d", len(records))
+	}
+}
+
+func TestMDNSResponderGoodbye(t *testing.T) {
+	r := newMDNSResponder("mavis.local", func() []net.IP { return []net.IP{net.IPv4(10, 0, 0, 5)} })
+	var sent [][]dns.RR
+	r.send = func(records []dns.RR) { sent = append(sent, records) }
+	r.Advertise(3000, "app")
+	sent = nil
+
+	r.Withdraw(3000)
+	if len(sent) != 1 {
+		t.Fatalf("Expected one goodbye, got %d", len(sent))
+	}
+	for _, rr := range sent[0] {
+		if rr.Header().Ttl != 0 {
+			t.Errorf("Expected TTL 0 in goodbye, got %s", rr)
+		}
+		if rr.Header().Rrtype == dns.TypeA {
+			t.Errorf("Expected the host record to stay valid, got %s", rr)
+		}
+	}
+	// The advertised records themselves are not modified
+	if records := r.Records(dns.Question{Name: "mavis.local.", Qtype: dns.TypeA}); len(records) != 1 || records[0].Header().Ttl != mdnsTTL {
+		t.Errorf("Expected the host record with TTL %d, got %v", mdnsTTL, records)
+	}
+}
+
+func TestNormalizeMDNSHostname(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"mavis.local", "mavis.local", true},
+		{"DevBox", "devbox.local", true},
+		{"build-01.local.", "build-01.local", true},
+		{"office.mavis", "office.mavis.local", true},
+		{"bad_name", "", false},
+		{"-mavis", "", false},
+		{"a..local", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeMDNSHostname(tt.input)
+		if (err == nil) != tt.valid || got != tt.expected {
+			t.Errorf("%q: expected %q (valid %v), got %q (err %v)", tt.input, tt.expected, tt.valid, got, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// OutputDir holds the full command output saved by TruncateOutput. It is under
+// data/temp, so the hourly temp cleanup removes it once nothing has been
+// written to it for a day.
+var OutputDir = filepath.Join("data", "temp", "output")
+
+// TruncateOutput returns output unchanged if it fits in limit bytes. Otherwise
+// it saves the full output to a file in OutputDir named after name and
+// returns its last limit bytes, where test failures and summaries usually
+// are, together with the absolute path of the file.
+func TruncateOutput(output string, limit int, name string) (string, string, error) {
+	if len(output) <= limit {
+		return output, "", nil
+	}
+
+	path, err := saveFullOutput(output, name)
+
+	start := len(output) - limit
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return "...\n" + output[start:], path, err
+}
+
+// FullOutputNote formats the hint appended after truncated output
+func FullOutputNote(path string) string {
+	return fmt.Sprintf("📎 Full output: `/download %s`", path)
+}
+
+// saveFullOutput writes output to a new file in OutputDir and returns its absolute path
+func saveFullOutput(output, name string) (string, error) {
+	dir, err := filepath.Abs(OutputDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.txt", name, time.Now().Format("20060102-150405.000")))
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		return "", fmt.Errorf("failed to save full output: %w", err)
+	}
+	return path, nil
+}
+
+// isOutputFile reports whether path is inside OutputDir, so saved output can
+// be downloaded even when MAVIS_ALLOWED_DIRS does not include the data directory
+func isOutputFile(path string) bool {
+	dir, err := filepath.Abs(OutputDir)
+	if err != nil {
+		return false
+	}
+	return isWithin(realPath(dir), realPath(path))
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	oldDir := OutputDir
+	OutputDir = t.TempDir()
+	t.Cleanup(func() { OutputDir = oldDir })
+
+	output, path, err := TruncateOutput("short", 100, "run-ls")
+	if output != "short" || path != "" || err != nil {
+		t.Errorf("Expected short output unchanged, got %q %q %v", output, path, err)
+	}
+
+	full := strings.Repeat("line\n", 50) + "é FAIL: TestSomething"
+	output, path, err = TruncateOutput(full, 21, "run-go test")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The cut falls inside é, so the partial rune is dropped
+	if output != "...\n FAIL: TestSomething" {
+		t.Errorf("Expected the tail of the output, got %q", output)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "run-go_test-") {
+		t.Errorf("Expected file named after the command, got %s", path)
+	}
+	if saved, err := os.ReadFile(path); err != nil || string(saved) != full {
+		t.Errorf("Expected full output saved, got %d bytes (err %v)", len(saved), err)
+	}
+	if note := FullOutputNote(path); !strings.Contains(note, "/download "+path) {
+		t.Errorf("Expected download command in %s", note)
+	}
+}
+
+func TestOutputFilesBypassAllowlist(t *testing.T) {
+	oldDir := OutputDir
+	OutputDir = t.TempDir()
+	t.Cleanup(func() { OutputDir = oldDir })
+	t.Cleanup(func() { SetAllowedDirs(nil) })
+
+	if err := SetAllowedDirs([]string{t.TempDir()}); err != nil {
+		t.Fatalf("Failed to set allowed dirs: %v", err)
+	}
+	if err := CheckAllowedPath(filepath.Join(OutputDir, "run-ls.txt")); err != nil {
+		t.Errorf("Expected saved output to be allowed, got %v", err)
+	}
+	if err := CheckAllowedPath(filepath.Join(filepath.Dir(OutputDir), "other.txt")); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Expected ErrPathNotAllowed, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ListeningPort is a TCP port with a listening socket on the host
+type ListeningPort struct {
+	Port      int
+	Addresses []string // Local addresses listening on the port, e.g. 127.0.0.1 or *
+	Process   string   // Owning process name, empty when unknown
+	PID       int      // Owning process ID, 0 when unknown
+}
+
+// commonDevPorts are probed when neither ss nor lsof is available
+var commonDevPorts = []int{
+	80, 443, 1313, 3000, 3001, 3306, 4000, 4200, 5000, 5173, 5432, 5500,
+	6379, 8000, 8008, 8080, 8081, 8443, 8888, 9000, 9090, 27017,
+}
+
+// ssProcessPattern extracts the first process name and PID from ss -p output
+var ssProcessPattern = regexp.MustCompile(`users:\(\("([^"]*)",pid=(\d+)`)
+
+// ssPIDPattern extracts every PID from ss -p output
+var ssPIDPattern = regexp.MustCompile(`pid=(\d+)`)
+
+// ListListeningPorts returns the listening TCP ports sorted by port. It uses
+// ss, then lsof, and falls back to probing common development ports, in which
+// case method is "probe" and no process information is available.
+func ListListeningPorts() (ports []ListeningPort, method string) {
+	if output, err := exec.Command("ss", "-Htlnp").Output(); err == nil {
+		return parseSSListen(string(output)), "ss"
+	}
+	if output, err := exec.Command("lsof", "-nP", "-iTCP", "-sTCP:LISTEN").Output(); err == nil {
+		return parseLsofListen(string(output)), "lsof"
+	}
+
+	for _, port := range commonDevPorts {
+		if IsPortInUse(strconv.Itoa(port)) {
+			ports = append(ports, ListeningPort{Port: port})
+		}
+	}
+	return ports, "probe"
+}
+
+// parseSSListen parses the output of `ss -Htlnp`
+func parseSSListen(output string) []ListeningPort {
+	var ports []ListeningPort
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "LISTEN" {
+			continue
+		}
+		port := ListeningPort{}
+		if !port.setAddress(fields[3]) {
+			continue
+		}
+		if match := ssProcessPattern.FindStringSubmatch(line); match != nil {
+			port.Process = match[1]
+			port.PID, _ = strconv.Atoi(match[2])
+		}
+		ports = append(ports, port)
+	}
+	return mergeListeningPorts(ports)
+}
+
+// parseLsofListen parses the output of `lsof -nP -iTCP -sTCP:LISTEN`
+func parseLsofListen(output string) []ListeningPort {
+	var ports []ListeningPort
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		// COMMAND PID USER FD TYPE DEVICE SIZE/OFF NODE NAME (LISTEN)
+		if len(fields) < 10 || fields[0] == "COMMAND" {
+			continue
+		}
+		port := ListeningPort{Process: fields[0]}
+		port.PID, _ = strconv.Atoi(fields[1])
+		if !port.setAddress(fields[8]) {
+			continue
+		}
+		ports = append(ports, port)
+	}
+	return mergeListeningPorts(ports)
+}
+
+// setAddress parses a local address such as 0.0.0.0:80, [::]:443, *:3000 or
+// 127.0.0.53%lo:53, reporting whether it held a valid port
+func (p *ListeningPort) setAddress(local string) bool {
+	i := strings.LastIndex(local, ":")
+	if i < 0 {
+		return false
+	}
+	port, err := strconv.Atoi(local[i+1:])
+	if err != nil {
+		return false
+	}
+	address := strings.Trim(local[:i], "[]")
+	if zone := strings.Index(address, "%"); zone >= 0 {
+		address = address[:zone]
+	}
+	if address == "0.0.0.0" || address == "::" {
+		address = "*"
+	}
+	p.Port = port
+	p.Addresses = []string{address}
+	return true
+}
+
+// mergeListeningPorts combines the sockets listening on the same port, such
+// as separate IPv4 and IPv6 listeners, and sorts them by port
+func mergeListeningPorts(sockets []ListeningPort) []ListeningPort {
+	byPort := make(map[int]*ListeningPort)
+	var ports []int
+	for _, socket := range sockets {
+		existing, ok := byPort[socket.Port]
+		if !ok {
+			socket := socket
+			byPort[socket.Port] = &socket
+			ports = append(ports, socket.Port)
+			continue
+		}
+		for _, address := range socket.Addresses {
+			if !containsString(existing.Addresses, address) {
+				existing.Addresses = append(existing.Addresses, address)
+			}
+		}
+		if existing.Process == "" {
+			existing.Process, existing.PID = socket.Process, socket.PID
+		}
+	}
+
+	sort.Ints(ports)
+	merged := make([]ListeningPort, 0, len(ports))
+	for _, port := range ports {
+		merged = append(merged, *byPort[port])
+	}
+	return merged
+}
+
+// ListeningPIDs returns the IDs of every process listening on TCP port, e.g.
+// all workers of a preforking server. ok is false when neither ss nor lsof is
+// installed, so the owners cannot be known.
+func ListeningPIDs(port int) (pids []int, ok bool) {
+	if output, err := exec.Command("ss", "-Htlnp").Output(); err == nil {
+		return parseSSListenPIDs(string(output), port), true
+	}
+	if _, err := exec.LookPath("lsof"); err == nil {
+		// lsof exits with an error when nothing matches
+		output, _ := exec.Command("lsof", "-t", "-nP", fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN").Output()
+		return parsePIDList(string(output)), true
+	}
+	return nil, false
+}
+
+// parseSSListenPIDs returns the PIDs of the sockets listening on port in the
+// output of `ss -Htlnp`
+func parseSSListenPIDs(output string, port int) []int {
+	var pids []int
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "LISTEN" {
+			continue
+		}
+		var socket ListeningPort
+		if !socket.setAddress(fields[3]) || socket.Port != port {
+			continue
+		}
+		for _, match := range ssPIDPattern.FindAllStringSubmatch(line, -1) {
+			pids = appendPID(pids, match[1])
+		}
+	}
+	return pids
+}
+
+// parsePIDList parses the one PID per line printed by `lsof -t`
+func parsePIDList(output string) []int {
+	var pids []int
+	for _, line := range splitLines(output) {
+		pids = appendPID(pids, strings.TrimSpace(line))
+	}
+	return pids
+}
+
+// appendPID adds the PID in s to pids unless it is invalid or already there
+func appendPID(pids []int, s string) []int {
+	pid, err := strconv.Atoi(s)
+	if err != nil || pid <= 0 {
+		return pids
+	}
+	for _, existing := range pids {
+		if existing == pid {
+			return pids
+		}
+	}
+	return append(pids, pid)
+}
+
+// FreePort stops every process listening on TCP port so it can be reused. The
+// processes get SIGTERM first and SIGKILL if they still run after grace. It
+// returns the PIDs that were signalled, and refuses to stop Mavis itself.
+func FreePort(port int, grace time.Duration) ([]int, error) {
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port: %d", port)
+	}
+
+	pids, ok := ListeningPIDs(port)
+	if !ok {
+		return nil, fmt.Errorf("neither ss nor lsof is installed, so the process using port %d cannot be found", port)
+	}
+	if len(pids) == 0 {
+		if IsPortInUse(strconv.Itoa(port)) {
+			return nil, fmt.Errorf("port %d is in use but its process is unknown; it may belong to another user", port)
+		}
+		return nil, nil
+	}
+	for _, pid := range pids {
+		if pid == os.Getpid() {
+			return nil, fmt.Errorf("port %d is used by Mavis itself", port)
+		}
+	}
+
+	var stopped []int
+	var errs []string
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			errs = append(errs, fmt.Sprintf("pid %d: %v", pid, err))
+			continue
+		}
+		stopped = append(stopped, pid)
+	}
+
+	deadline := time.Now().Add(grace)
+	for _, pid := range stopped {
+		for processRunning(pid) && time.Now().Before(deadline) {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if processRunning(pid) {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+
+	if len(errs) > 0 {
+		return stopped, fmt.Errorf("failed to stop %s", strings.Join(errs, ", "))
+	}
+	return stopped, nil
+}
+
+// processRunning reports whether a process with pid exists
+func processRunning(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSSListen(t *testing.T) {
+	output := `LISTEN 0      4096   127.0.0.53%lo:53     0.0.0.0:*    users:(("systemd-resolve",pid=412,fd=14))
+LISTEN 0      511          0.0.0.0:3000   0.0.0.0:*    users:(("node",pid=1234,fd=20))
+LISTEN 0      511             [::]:3000      [::]:*    users:(("node",pid=1234,fd=21))
+LISTEN 0      128          0.0.0.0:22     0.0.0.0:*
+`
+	ports := parseSSListen(output)
+	if len(ports) != 3 {
+		t.Fatalf("Expected 3 ports, got %+v", ports)
+	}
+	if ports[0].Port != 22 || ports[0].Process != "" {
+		t.Errorf("Expected port 22 without process, got %+v", ports[0])
+	}
+	if ports[1].Port != 53 || ports[1].Addresses[0] != "127.0.0.53" || ports[1].Process != "systemd-resolve" || ports[1].PID != 412 {
+		t.Errorf("Expected resolver on 127.0.0.53:53, got %+v", ports[1])
+	}
+	if ports[2].Port != 3000 || len(ports[2].Addresses) != 1 || ports[2].Addresses[0] != "*" || ports[2].Process != "node" {
+		t.Errorf("Expected node on *:3000 merged, got %+v", ports[2])
+	}
+}
+
+func TestParseLsofListen(t *testing.T) {
+	output := `COMMAND   PID USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+ruby    5001 me     12u  IPv4 0x1234567890abcdef      0t0  TCP *:3000 (LISTEN)
+postgres 88  me      7u  IPv6 0x1234567890abcdee      0t0  TCP [::1]:5432 (LISTEN)
+postgres 88  me      8u  IPv4 0x1234567890abcded      0t0  TCP 127.0.0.1:5432 (LISTEN)
+`
+	ports := parseLsofListen(output)
+	if len(ports) != 2 {
+		t.Fatalf("Expected 2 ports, got %+v", ports)
+	}
+	if ports[0].Port != 3000 || ports[0].Process != "ruby" || ports[0].PID != 5001 {
+		t.Errorf("Expected ruby on 3000, got %+v", ports[0])
+	}
+	if ports[1].Port != 5432 || strings.Join(ports[1].Addresses, ",") != "::1,127.0.0.1" {
+		t.Errorf("Expected postgres on ::1 and 127.0.0.1, got %+v", ports[1])
+	}
+}
+
+func TestListListeningPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ports, method := ListListeningPorts()
+	if method == "probe" {
+		t.Skip("Neither ss nor lsof is available")
+	}
+	for _, p := range ports {
+		if p.Port == port {
+			return
+		}
+	}
+	t.Errorf("Expected port %d in %s output, got %+v", port, method, ports)
+}
+
+func TestParseSSListenPIDs(t *testing.T) {
+	output := `LISTEN 0      511          0.0.0.0:3000   0.0.0.0:*    users:(("node",pid=1234,fd=20),("node",pid=1240,fd=20))
+LISTEN 0      511             [::]:3000      [::]:*    users:(("node",pid=1234,fd=21))
+LISTEN 0      511          0.0.0.0:30000  0.0.0.0:*    users:(("other",pid=99,fd=3))
+`
+	pids := parseSSListenPIDs(output, 3000)
+	if len(pids) != 2 || pids[0] != 1234 || pids[1] != 1240 {
+		t.Errorf("Expected pids [1234 1240], got %v", pids)
+	}
+	if pids := parseSSListenPIDs(output, 8080); len(pids) != 0 {
+		t.Errorf("Expected no pids for a free port, got %v", pids)
+	}
+}
+
+func TestParsePIDList(t *testing.T) {
+	pids := parsePIDList("1234\n1240\n1234\n\n")
+	if len(pids) != 2 || pids[0] != 1234 || pids[1] != 1240 {
+		t.Errorf("Expected pids [1234 1240], got %v", pids)
+	}
+}
+
+func TestFreePortInvalid(t *testing.T) {
+	if _, err := FreePort(70000, time.Second); err == nil {
+		t.Error("Expected an error for an invalid port")
+	}
+}
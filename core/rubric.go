@@ -0,0 +1,180 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRubricName is the built-in rubric that keeps the standard review prompts unchanged
+const DefaultRubricName = "default"
+
+// ReviewRubric is a named set of review instructions (markdown) that is
+// injected into the prompts of review agents
+type ReviewRubric struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Instructions string `json:"instructions"`
+}
+
+var defaultRubric = ReviewRubric{
+	Name:        DefaultRubricName,
+	Description: "Built-in review guidelines",
+}
+
+var (
+	rubrics     = map[string]ReviewRubric{}
+	rubricsFile string
+	rubricsMu   sync.RWMutex
+)
+
+// LoadReviewRubrics loads named rubrics from a JSON file containing an array of
+// rubrics. A missing file is not an error. The file is also where SaveReviewRubric writes.
+func LoadReviewRubrics(path string) error {
+	rubricsMu.Lock()
+	defer rubricsMu.Unlock()
+	rubricsFile = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read rubrics file: %w", err)
+	}
+
+	var list []ReviewRubric
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse rubrics file: %w", err)
+	}
+
+	loaded := make(map[string]ReviewRubric, len(list))
+	for _, rubric := range list {
+		name := strings.ToLower(strings.TrimSpace(rubric.Name))
+		if name == "" || name == DefaultRubricName {
+			continue
+		}
+		rubric.Name = name
+		loaded[name] = rubric
+	}
+	rubrics = loaded
+	return nil
+}
+
+// GetReviewRubric returns the rubric with the given name. An empty name
+// returns the default rubric.
+func GetReviewRubric(name string) (ReviewRubric, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == DefaultRubricName {
+		return defaultRubric, nil
+	}
+
+	rubricsMu.RLock()
+	defer rubricsMu.RUnlock()
+	rubric, ok := rubrics[name]
+	if !ok {
+		return ReviewRubric{}, fmt.Errorf("unknown review rubric %q", name)
+	}
+	return rubric, nil
+}
+
+// ListReviewRubrics returns all rubrics, the default first and the rest by name
+func ListReviewRubrics() []ReviewRubric {
+	rubricsMu.RLock()
+	defer rubricsMu.RUnlock()
+
+	list := make([]ReviewRubric, 0, len(rubrics)+1)
+	for _, rubric := range rubrics {
+		list = append(list, rubric)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return append([]ReviewRubric{defaultRubric}, list...)
+}
+
+// SaveReviewRubric adds or replaces a rubric and writes the rubrics file
+func SaveReviewRubric(rubric ReviewRubric) error {
+	rubric.Name = strings.ToLower(strings.TrimSpace(rubric.Name))
+	if rubric.Name == "" {
+		return fmt.Errorf("rubric name is required")
+	}
+	if rubric.Name == DefaultRubricName {
+		return fmt.Errorf("the %q rubric is built in and cannot be changed", DefaultRubricName)
+	}
+
+	rubricsMu.Lock()
+	defer rubricsMu.Unlock()
+	if rubricsFile == "" {
+		return fmt.Errorf("rubrics file is not configured")
+	}
+
+	updated := make(map[string]ReviewRubric, len(rubrics)+1)
+	for name, existing := range rubrics {
+		updated[name] = existing
+	}
+	updated[rubric.Name] = rubric
+
+	list := make([]ReviewRubric, 0, len(updated))
+	for _, r := range updated {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rubricsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(rubricsFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write rubrics file: %w", err)
+	}
+	rubrics = updated
+	return nil
+}
+
+// Apply returns prompt with the rubric instructions appended. The default
+// rubric returns the prompt unchanged.
+func (r ReviewRubric) Apply(prompt string) string {
+	instructions := strings.TrimSpace(r.Instructions)
+	if instructions == "" {
+		return prompt
+	}
+	return fmt.Sprintf(`%s
+
+REVIEW RUBRIC (%s):
+Apply the following team review standards when analyzing the changes. Where they
+conflict with the general guidance above, the rubric takes precedence.
+
+%s`, prompt, r.Name, instructions)
+}
+
+// ExtractRubricFlag removes a "--rubric <name>" or "--rubric=<name>" flag from
+// args and returns the remaining arguments and the rubric name
+func ExtractRubricFlag(args []string) ([]string, string, error) {
+	rest := make([]string, 0, len(args))
+	name := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--rubric":
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("--rubric requires a rubric name")
+			}
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--rubric="):
+			name = strings.TrimPrefix(arg, "--rubric=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, name, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractRubricFlag(t *testing.T) {
+	tests := []struct {
+		args     []string
+		rest     []string
+		name     string
+		hasError bool
+	}{
+		{[]string{"/review", "~/repo"}, []string{"/review", "~/repo"}, "", false},
+		{[]string{"/review", "--rubric", "security", "~/repo"}, []string{"/review", "~/repo"}, "security", false},
+		{[]string{"/pr", "~/repo", "url", "--rubric=perf"}, []string{"/pr", "~/repo", "url"}, "perf", false},
+		{[]string{"/review", "~/repo", "--rubric"}, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		rest, name, err := ExtractRubricFlag(tt.args)
+		if (err != nil) != tt.hasError {
+			t.Errorf("%v: expected error %v, got %v", tt.args, tt.hasError, err)
+			continue
+		}
+		if tt.hasError {
+			continue
+		}
+		if !reflect.DeepEqual(rest, tt.rest) || name != tt.name {
+			t.Errorf("%v: expected %v and %q, got %v and %q", tt.args, tt.rest, tt.name, rest, name)
+		}
+	}
+}
+
+func TestDefaultRubricKeepsPrompt(t *testing.T) {
+	rubric, err := GetReviewRubric("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rubric.Name != DefaultRubricName {
+		t.Errorf("Expected default rubric, got %q", rubric.Name)
+	}
+	if prompt := rubric.Apply("review this"); prompt != "review this" {
+		t.Errorf("Expected prompt to be unchanged, got %q", prompt)
+	}
+
+	if _, err := GetReviewRubric("missing"); err == nil {
+		t.Error("Expected error for unknown rubric")
+	}
+}
+
+func TestSaveAndLoadReviewRubrics(t *testing.T) {
+	t.Cleanup(func() {
+		rubrics = map[string]ReviewRubric{}
+		rubricsFile = ""
+	})
+
+	path := filepath.Join(t.TempDir(), "rubrics.json")
+	if err := LoadReviewRubrics(path); err != nil {
+		t.Fatalf("Expected missing file to be ignored, got %v", err)
+	}
+	if err := SaveReviewRubric(ReviewRubric{Name: DefaultRubricName, Instructions: "x"}); err == nil {
+		t.Error("Expected error when overriding the default rubric")
+	}
+	if err := SaveReviewRubric(ReviewRubric{Name: "Security", Instructions: "Check for injection."}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected rubrics file to be written, got %v", err)
+	}
+
+	// Reload from disk
+	rubrics = map[string]ReviewRubric{}
+	if err := LoadReviewRubrics(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rubric, err := GetReviewRubric("security")
+	if err != nil {
+		t.Fatalf("Expected saved rubric, got %v", err)
+	}
+	prompt := rubric.Apply("review this")
+	if !strings.HasPrefix(prompt, "review this") || !strings.Contains(prompt, "REVIEW RUBRIC (security)") || !strings.HasSuffix(prompt, "Check for injection.") {
+		t.Errorf("Expected rubric appended to prompt, got %q", prompt)
+	}
+
+	list := ListReviewRubrics()
+	if len(list) != 2 || list[0].Name != DefaultRubricName || list[1].Name != "security" {
+		t.Errorf("Expected default and security rubrics, got %+v", list)
+	}
+}
@@ -202,8 +202,22 @@ func NewID(length int) string {
 // - Paths starting with ~ (replaced with home directory)
 // - Relative paths (resolved from home directory)
 // - Absolute paths (returned as-is)
-// This ensures all paths are resolved consistently from the user's home directory
+// This ensures all paths are resolved consistently from the user's home directory.
+// Paths outside the directories set with SetAllowedDirs are rejected.
 func ResolvePath(path string) (string, error) {
+	absPath, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	// Reject paths outside the allowed directories, if any are configured
+	if err := CheckAllowedPath(absPath); err != nil {
+		return "", err
+	}
+	return absPath, nil
+}
+
+// expandPath is ResolvePath without the allowlist check
+func expandPath(path string) (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -0,0 +1,340 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorktreeRoot is the directory under which managed git worktrees are created
+var WorktreeRoot = filepath.Join(os.TempDir(), "mavis-worktrees")
+
+var (
+	activeWorktrees   = make(map[string]bool)
+	activeWorktreesMu sync.Mutex
+)
+
+// CopySizeWarningBytes is the source tree size above which copying a
+// repository into a workspace logs and reports a warning
+var CopySizeWarningBytes int64 = 1 << 30
+
+// Workspace is a working copy of a repository prepared for a git agent
+type Workspace struct {
+	Dir      string // Directory the agent works in
+	RepoDir  string // Original repository
+	Worktree bool   // True if Dir is a git worktree, false if it is an rsync copy
+	Warning  string // Set when the repository was unusually large to copy
+}
+
+// PrepareGitWorkspace creates a workspace for an agent working on branch, which
+// may be empty when the agent creates the branch itself.
+// Clean repositories get a detached git worktree keyed by branch, so only the
+// checkout cost is paid and history is shared with the original repository.
+// Repositories with uncommitted changes, or whose current branch is branch,
+// are copied with rsync instead.
+func PrepareGitWorkspace(repoDir, branch string) (*Workspace, error) {
+	if reason := worktreeUnsupported(repoDir, branch); reason != "" {
+		log.Printf("[Worktree] Falling back to rsync for %s: %s", repoDir, reason)
+		return copyWorkspace(repoDir)
+	}
+
+	dir := worktreeDir(repoDir, branch)
+
+	activeWorktreesMu.Lock()
+	if activeWorktrees[dir] {
+		activeWorktreesMu.Unlock()
+		log.Printf("[Worktree] Worktree %s is in use, falling back to rsync", dir)
+		return copyWorkspace(repoDir)
+	}
+	activeWorktrees[dir] = true
+	activeWorktreesMu.Unlock()
+
+	// Remove leftovers from a previous run that was not cleaned up
+	runGit(repoDir, "worktree", "prune")
+	if _, err := os.Stat(dir); err == nil {
+		runGit(repoDir, "worktree", "remove", "--force", dir)
+		os.RemoveAll(dir)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		releaseWorktree(dir)
+		return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if output, err := runGit(repoDir, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		releaseWorktree(dir)
+		return nil, fmt.Errorf("failed to add worktree: %v\nOutput: %s", err, output)
+	}
+
+	log.Printf("[Worktree] Created worktree %s for branch %s", dir, branch)
+	return &Workspace{Dir: dir, RepoDir: repoDir, Worktree: true}, nil
+}
+
+// Cleanup removes the workspace. Commits made in a worktree remain available
+// in the original repository.
+func (w *Workspace) Cleanup() error {
+	if !w.Worktree {
+		return os.RemoveAll(w.Dir)
+	}
+	defer releaseWorktree(w.Dir)
+
+	if output, err := runGit(w.RepoDir, "worktree", "remove", "--force", w.Dir); err != nil {
+		os.RemoveAll(w.Dir)
+		runGit(w.RepoDir, "worktree", "prune")
+		return fmt.Errorf("failed to remove worktree: %v\nOutput: %s", err, output)
+	}
+	log.Printf("[Worktree] Removed worktree %s", w.Dir)
+	return nil
+}
+
+// worktreeUnsupported returns why repoDir cannot be checked out as a worktree,
+// or an empty string if it can
+func worktreeUnsupported(repoDir, branch string) string {
+	status, err := runGit(repoDir, "status", "--porcelain")
+	if err != nil {
+		return "not a git repository"
+	}
+	if strings.TrimSpace(status) != "" {
+		return "working tree has uncommitted changes"
+	}
+	// git refuses to check out a branch in two worktrees at once
+	current, err := runGit(repoDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "repository has no commits"
+	}
+	if strings.TrimSpace(current) == branch {
+		return fmt.Sprintf("branch %s is checked out in the repository", branch)
+	}
+	return ""
+}
+
+// worktreeDir returns the managed worktree path for a repository and branch.
+// An empty branch, used when the agent picks the branch name, gets a unique key.
+func worktreeDir(repoDir, branch string) string {
+	sum := sha1.Sum([]byte(repoDir))
+	repoKey := filepath.Base(repoDir) + "-" + hex.EncodeToString(sum[:])[:8]
+	branchKey := strings.NewReplacer("/", "-", "\\", "-", " ", "-").Replace(branch)
+	if branchKey == "" {
+		branchKey = fmt.Sprintf("new-%d", time.Now().UnixNano())
+	}
+	return filepath.Join(WorktreeRoot, repoKey, branchKey)
+}
+
+// copyWorkspace copies repoDir into a new temp directory with rsync
+func copyWorkspace(repoDir string) (*Workspace, error) {
+	tempDir, err := os.MkdirTemp("", "git-agent-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	workspace := &Workspace{Dir: tempDir, RepoDir: repoDir}
+	if size, err := sourceTreeSize(repoDir); err != nil {
+		log.Printf("[Worktree] Failed to measure %s: %v", repoDir, err)
+	} else if size > CopySizeWarningBytes {
+		workspace.Warning = fmt.Sprintf("%s is %s; copying it may be slow. Add large paths to %s to skip them.",
+			repoDir, FormatBytes(size), MavisIgnoreFile)
+		log.Printf("[Worktree] [WARNING] %s", workspace.Warning)
+	}
+
+	// Use rsync to copy the directory, skipping ignored and default excluded paths
+	cmd := exec.Command("rsync", RsyncCopyArgs(repoDir, tempDir)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("failed to copy repository: %v\nOutput: %s", err, string(output))
+	}
+	return workspace, nil
+}
+
+// sourceTreeSize returns the total size of the files git does not ignore in
+// repoDir, which approximates how much a workspace copy transfers. Outside a
+// git repository it walks the tree, skipping the default excluded directories.
+func sourceTreeSize(repoDir string) (int64, error) {
+	output, err := runGit(repoDir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return walkTreeSize(repoDir)
+	}
+
+	var total int64
+	for _, name := range strings.Split(output, "\x00") {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(repoDir, name)); err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// walkTreeSize sums the regular files under dir, skipping default excluded directories
+func walkTreeSize(dir string) (int64, error) {
+	skip := make(map[string]bool, len(defaultRsyncExcludes))
+	for _, pattern := range defaultRsyncExcludes {
+		skip[strings.TrimSuffix(pattern, "/")] = true
+	}
+
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. "1.5 GiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// workspaceTempPatterns match the temp directories created for copied workspaces,
+// including the git-branch-agent-* directories created by older versions
+var workspaceTempPatterns = []string{"git-agent-*", "git-branch-agent-*"}
+
+// IsTemporaryWorkspace reports whether dir is a worktree or copy prepared by
+// PrepareGitWorkspace, which is removed once its agent finishes
+func IsTemporaryWorkspace(dir string) bool {
+	if rel, err := filepath.Rel(WorktreeRoot, dir); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return true
+	}
+	for _, pattern := range workspaceTempPatterns {
+		if matched, _ := filepath.Match(filepath.Join(os.TempDir(), pattern), dir); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanupStaleWorkspaces removes copied workspaces in the temp directory and
+// worktrees under WorktreeRoot that were last modified more than maxAge ago,
+// skipping those for which inUse returns true. It returns the number of
+// directories removed.
+func CleanupStaleWorkspaces(maxAge time.Duration, inUse func(dir string) bool) int {
+	removed := cleanupStaleWorktrees(maxAge, inUse)
+	for _, pattern := range workspaceTempPatterns {
+		matches, err := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		if err != nil {
+			continue
+		}
+		for _, dir := range matches {
+			info, err := os.Stat(dir)
+			if err != nil || !info.IsDir() || time.Since(info.ModTime()) < maxAge {
+				continue
+			}
+			if inUse != nil && inUse(dir) {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("[Worktree] Failed to remove stale workspace %s: %v", dir, err)
+				continue
+			}
+			log.Printf("[Worktree] Removed stale workspace %s", dir)
+			removed++
+		}
+	}
+	return removed
+}
+
+// cleanupStaleWorktrees removes worktrees under WorktreeRoot that are older
+// than maxAge and not in use, then prunes them from their repositories
+func cleanupStaleWorktrees(maxAge time.Duration, inUse func(dir string) bool) int {
+	matches, err := filepath.Glob(filepath.Join(WorktreeRoot, "*", "*"))
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	pruneRepos := make(map[string]bool)
+	for _, dir := range matches {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		activeWorktreesMu.Lock()
+		active := activeWorktrees[dir]
+		activeWorktreesMu.Unlock()
+		if active || (inUse != nil && inUse(dir)) {
+			continue
+		}
+
+		repoDir := worktreeRepo(dir)
+		if repoDir != "" {
+			runGit(repoDir, "worktree", "remove", "--force", dir)
+			pruneRepos[repoDir] = true
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("[Worktree] Failed to remove stale worktree %s: %v", dir, err)
+			continue
+		}
+		log.Printf("[Worktree] Removed stale worktree %s", dir)
+		removed++
+		// Drop the per-repository directory once its last worktree is gone
+		os.Remove(filepath.Dir(dir))
+	}
+
+	for repoDir := range pruneRepos {
+		runGit(repoDir, "worktree", "prune")
+	}
+	return removed
+}
+
+// worktreeRepo returns the repository a worktree belongs to, read from the
+// "gitdir: <repo>/.git/worktrees/<name>" line of its .git file, or an empty
+// string if dir is not a linked worktree
+func worktreeRepo(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = filepath.Clean(strings.TrimSpace(gitDir))
+	if filepath.Base(filepath.Dir(gitDir)) != "worktrees" {
+		return ""
+	}
+	return filepath.Dir(filepath.Dir(filepath.Dir(gitDir)))
+}
+
+func releaseWorktree(dir string) {
+	activeWorktreesMu.Lock()
+	delete(activeWorktrees, dir)
+	activeWorktreesMu.Unlock()
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// initTestRepo creates a git repository with a single commit
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "README.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if output, err := runGit(dir, args...); err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	return dir
+}
+
+func TestPrepareGitWorkspaceWorktree(t *testing.T) {
+	repo := initTestRepo(t)
+	oldRoot := WorktreeRoot
+	WorktreeRoot = t.TempDir()
+	t.Cleanup(func() { WorktreeRoot = oldRoot })
+
+	workspace, err := PrepareGitWorkspace(repo, "feature/test")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !workspace.Worktree {
+		t.Fatal("Expected a worktree for a clean repository")
+	}
+	if !strings.HasPrefix(workspace.Dir, WorktreeRoot) || !strings.HasSuffix(workspace.Dir, "feature-test") {
+		t.Errorf("Expected worktree under %s keyed by branch, got %s", WorktreeRoot, workspace.Dir)
+	}
+	if _, err := os.Stat(filepath.Join(workspace.Dir, "README.md")); err != nil {
+		t.Errorf("Expected README.md in worktree, got %v", err)
+	}
+
+	// A second agent on the same branch falls back to an rsync copy
+	// (which fails if rsync is not installed)
+	if second, err := PrepareGitWorkspace(repo, "feature/test"); err == nil {
+		if second.Worktree {
+			t.Error("Expected rsync fallback while the worktree is in use")
+		}
+		second.Cleanup()
+	}
+
+	if err := workspace.Cleanup(); err != nil {
+		t.Fatalf("Expected no error on cleanup, got %v", err)
+	}
+	if _, err := os.Stat(workspace.Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected worktree directory to be removed, got %v", err)
+	}
+	if output, _ := runGit(repo, "worktree", "list"); strings.Contains(output, workspace.Dir) {
+		t.Errorf("Expected worktree to be unregistered, got %s", output)
+	}
+}
+
+func TestWorktreeUnsupported(t *testing.T) {
+	repo := initTestRepo(t)
+
+	if reason := worktreeUnsupported(repo, "feature/x"); reason != "" {
+		t.Errorf("Expected clean repository to support worktrees, got %q", reason)
+	}
+	if reason := worktreeUnsupported(repo, "main"); reason == "" {
+		t.Error("Expected checked out branch to be unsupported")
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, "dirty.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if reason := worktreeUnsupported(repo, "feature/x"); reason == "" {
+		t.Error("Expected repository with uncommitted changes to be unsupported")
+	}
+
+	if reason := worktreeUnsupported(t.TempDir(), "feature/x"); reason == "" {
+		t.Error("Expected non-repository to be unsupported")
+	}
+}
+
+func TestSourceTreeSize(t *testing.T) {
+	dir := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("big.bin\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .gitignore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// README.md (6 bytes) and .gitignore (8 bytes); the ignored file is not counted
+	size, err := sourceTreeSize(dir)
+	if err != nil {
+		t.Fatalf("Failed to measure tree: %v", err)
+	}
+	if size != 14 {
+		t.Errorf("Expected 14 bytes, got %d", size)
+	}
+
+	// Outside git the tree is walked, skipping default excluded directories
+	plain := t.TempDir()
+	os.MkdirAll(filepath.Join(plain, "node_modules"), 0755)
+	os.WriteFile(filepath.Join(plain, "node_modules", "dep.js"), make([]byte, 4096), 0644)
+	os.WriteFile(filepath.Join(plain, "main.go"), make([]byte, 10), 0644)
+	if size, err := sourceTreeSize(plain); err != nil || size != 10 {
+		t.Errorf("Expected 10 bytes, got %d (err %v)", size, err)
+	}
+
+	if got := FormatBytes(3 << 29); got != "1.5 GiB" {
+		t.Errorf("Expected 1.5 GiB, got %s", got)
+	}
+}
+
+func TestCleanupStaleWorkspaces(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	oldRoot := WorktreeRoot
+	WorktreeRoot = filepath.Join(tmp, "worktrees")
+	t.Cleanup(func() { WorktreeRoot = oldRoot })
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"git-agent-old", "git-branch-agent-old", "git-agent-busy", "git-agent-new", "other-old"} {
+		dir := filepath.Join(tmp, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		if name != "git-agent-new" {
+			os.Chtimes(dir, old, old)
+		}
+	}
+
+	removed := CleanupStaleWorkspaces(24*time.Hour, func(dir string) bool {
+		return filepath.Base(dir) == "git-agent-busy"
+	})
+	if removed != 2 {
+		t.Errorf("Expected 2 workspaces removed, got %d", removed)
+	}
+	for name, shouldExist := range map[string]bool{
+		"git-agent-old":        false,
+		"git-branch-agent-old": false,
+		"git-agent-busy":       true,
+		"git-agent-new":        true,
+		"other-old":            true,
+	} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); (err == nil) != shouldExist {
+			t.Errorf("%s: expected exists=%v", name, shouldExist)
+		}
+	}
+}
+
+func TestCleanupStaleWorktrees(t *testing.T) {
+	repo := initTestRepo(t)
+	oldRoot := WorktreeRoot
+	WorktreeRoot = t.TempDir()
+	t.Cleanup(func() { WorktreeRoot = oldRoot })
+
+	stale, err := PrepareGitWorkspace(repo, "stale")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	busy, err := PrepareGitWorkspace(repo, "busy")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer busy.Cleanup()
+	// Simulate a worktree left behind by a previous run of the bot
+	releaseWorktree(stale.Dir)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(stale.Dir, old, old)
+	os.Chtimes(busy.Dir, old, old)
+
+	if removed := CleanupStaleWorkspaces(24*time.Hour, nil); removed != 1 {
+		t.Errorf("Expected 1 worktree removed, got %d", removed)
+	}
+	if _, err := os.Stat(stale.Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected stale worktree to be removed, got %v", err)
+	}
+	if _, err := os.Stat(busy.Dir); err != nil {
+		t.Errorf("Expected worktree in use to be kept, got %v", err)
+	}
+	list, _ := runGit(repo, "worktree", "list")
+	if strings.Contains(list, stale.Dir) {
+		t.Errorf("Expected stale worktree to be pruned from the repository, got:\n%s", list)
+	}
+}
+
+func TestIsTemporaryWorkspace(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	oldRoot := WorktreeRoot
+	WorktreeRoot = filepath.Join(tmp, "worktrees")
+	t.Cleanup(func() { WorktreeRoot = oldRoot })
+
+	tests := []struct {
+		dir      string
+		expected bool
+	}{
+		{filepath.Join(WorktreeRoot, "repo-1234", "main"), true},
+		{filepath.Join(tmp, "git-agent-42"), true},
+		{filepath.Join(tmp, "git-branch-agent-42"), true},
+		{WorktreeRoot, false},
+		{filepath.Join(tmp, "worktrees-other", "repo"), false},
+		{filepath.Join(tmp, "project"), false},
+		{"/home/user/project", false},
+	}
+	for _, tt := range tests {
+		if got := IsTemporaryWorkspace(tt.dir); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.dir, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrZipTooLarge is returned by ZipDirectory when the archive exceeds its size limit
+var ErrZipTooLarge = errors.New("zip archive exceeds the size limit")
+
+// ZipStats summarises an archive written by ZipDirectory
+type ZipStats struct {
+	Files   int   // Regular files added
+	Skipped int   // Symlinks, sockets, devices and unreadable files left out
+	Size    int64 // Size of the archive in bytes
+}
+
+// limitedWriter fails with ErrZipTooLarge once more than limit bytes are written
+type limitedWriter struct {
+	w       io.Writer
+	written int64
+	limit   int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.written+int64(len(p)) > l.limit {
+		return 0, ErrZipTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+// ZipDirectory streams a zip archive of dir to w, one file at a time, so
+// memory use does not grow with the tree. Only regular files and directories
+// are archived; symlinks are never followed. A positive limit aborts with
+// ErrZipTooLarge as soon as the archive grows past it.
+func ZipDirectory(dir string, w io.Writer, limit int64) (ZipStats, error) {
+	var stats ZipStats
+	counter := &limitedWriter{w: w, limit: limit}
+
+	// Never archive the archive itself when it is written inside dir
+	var output os.FileInfo
+	if file, ok := w.(*os.File); ok {
+		output, _ = file.Stat()
+	}
+
+	archive := zip.NewWriter(counter)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the whole archive
+			if path != dir {
+				stats.Skipped++
+				if entry != nil && entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			_, err := archive.Create(name + "/")
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			stats.Skipped++
+			return nil
+		}
+		if output != nil {
+			if info, err := entry.Info(); err == nil && os.SameFile(info, output) {
+				return nil
+			}
+		}
+
+		added, err := addZipFile(archive, path, name)
+		if err != nil {
+			return err
+		}
+		if added {
+			stats.Files++
+		} else {
+			stats.Skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	if err := archive.Close(); err != nil {
+		return stats, err
+	}
+	stats.Size = counter.written
+	return stats, nil
+}
+
+// addZipFile copies a regular file into the archive. It reports false if the
+// file could not be opened, which happens when it vanishes or is unreadable.
+func addZipFile(archive *zip.Writer, path, name string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return false, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(writer, file); err != nil {
+		return false, fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestZipDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("world"), 0644)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "link"))
+
+	var buf bytes.Buffer
+	stats, err := ZipDirectory(dir, &buf, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Files != 2 || stats.Skipped != 1 {
+		t.Errorf("Expected 2 files and 1 skipped, got %+v", stats)
+	}
+	if stats.Size != int64(buf.Len()) {
+		t.Errorf("Expected size %d, got %d", buf.Len(), stats.Size)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.txt,sub/,sub/b.txt" {
+		t.Errorf("Expected a.txt,sub/,sub/b.txt, got %v", names)
+	}
+}
+
+func TestZipDirectoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	// Random content so deflate cannot shrink it below the limit
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	os.WriteFile(filepath.Join(dir, "big.bin"), data, 0644)
+
+	if _, err := ZipDirectory(dir, &bytes.Buffer{}, 1024); !errors.Is(err, ErrZipTooLarge) {
+		t.Errorf("Expected ErrZipTooLarge, got %v", err)
+	}
+}
+
+func TestZipDirectorySkipsOwnArchive(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644)
+
+	out, err := os.Create(filepath.Join(dir, "out.zip"))
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer out.Close()
+
+	stats, err := ZipDirectory(dir, out, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Files != 1 {
+		t.Errorf("Expected only a.txt to be archived, got %d files", stats.Files)
+	}
+}
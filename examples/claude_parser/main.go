@@ -6,6 +6,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 	
 	"mavis/codeagent"
@@ -13,7 +15,8 @@ import (
 
 func main() {
 	// Example 1: Creating an interactive agent with Claude parser
-	fmt.Println("=== Claude Code CLI Parser Example ===\n")
+	fmt.Println("=== Claude Code CLI Parser Example ===")
+	fmt.Println()
 	
 	// Create a new interactive agent (which includes the parser)
 	agent := codeagent.NewInteractiveAgent("/path/to/project", "")
@@ -83,6 +86,15 @@ func main() {
 		fmt.Println(jsonExport)
 	}
 	
+	// Export as CSV (one row per message with token columns)
+	csvExport := agent.ExportHistory("csv")
+	fmt.Println("\nCSV export (first 500 chars):")
+	if len(csvExport) > 500 {
+		fmt.Println(csvExport[:500] + "...")
+	} else {
+		fmt.Println(csvExport)
+	}
+	
 	// Example 7: Searching for specific content
 	fmt.Println("\n--- Search for 'error' in messages ---")
 	searchFilter := codeagent.MessageFilter{
@@ -145,8 +157,24 @@ func ConversationHistoryHandler(agent *codeagent.InteractiveAgent) http.HandlerF
 		// Get query parameters for filtering
 		msgType := r.URL.Query().Get("type")
 		search := r.URL.Query().Get("search")
+		exclude := r.URL.Query().Get("exclude")
+		pattern := r.URL.Query().Get("regex")
 		format := r.URL.Query().Get("format")
 		
+		// Several types can be given as ?types=user,assistant
+		var types []string
+		if list := r.URL.Query().Get("types"); list != "" {
+			types = strings.Split(list, ",")
+		}
+		
+		// Reject bad patterns instead of returning an empty history
+		if pattern != "" {
+			if _, err := regexp.Compile(pattern); err != nil {
+				http.Error(w, "invalid regex: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		
 		if format != "" {
 			// Export in requested format
 			export := agent.ExportHistory(format)
@@ -157,6 +185,11 @@ func ConversationHistoryHandler(agent *codeagent.InteractiveAgent) http.HandlerF
 				w.Header().Set("Content-Type", "application/json")
 			case "markdown":
 				w.Header().Set("Content-Type", "text/markdown")
+			case "csv":
+				w.Header().Set("Content-Type", "text/csv")
+				w.Header().Set("Content-Disposition", `attachment; filename="conversation.csv"`)
+			case "html":
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			default:
 				w.Header().Set("Content-Type", "text/plain")
 			}
@@ -167,8 +200,11 @@ func ConversationHistoryHandler(agent *codeagent.InteractiveAgent) http.HandlerF
 		
 		// Build filter
 		filter := codeagent.MessageFilter{
-			Type:     msgType,
-			Contains: search,
+			Type:            msgType,
+			Types:           types,
+			Contains:        search,
+			ExcludeContains: exclude,
+			Regex:           pattern,
 		}
 		
 		// Get filtered history
@@ -3,14 +3,20 @@ module mavis
 go 1.24.1
 
 require (
+	github.com/creack/pty v1.1.24
 	github.com/go-telegram/bot v1.14.2
 	github.com/google/uuid v1.6.0
+	github.com/hashicorp/mdns v1.0.6
 	github.com/huin/goupnp v1.3.0
 	github.com/joho/godotenv v1.5.1
+	github.com/miekg/dns v1.1.55
 	maragu.dev/gomponents v1.1.0
 )
 
 require (
-	github.com/creack/pty v1.1.24 // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
 )
@@ -13,7 +13,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
@@ -34,17 +33,6 @@ var (
 	ProjectDir   string
 )
 
-// LAN server tracking
-var (
-	lanServerProcess *os.Process
-	lanHTTPServer    *http.Server
-	lanServerPort    string
-	lanServerWorkDir string
-	lanServerCmd     string
-	lanServerMutex   sync.Mutex
-	lanDomainName    = "mavis.local"
-)
-
 func main() {
 	log.Println("[STARTUP] Starting Mavis application...")
 
@@ -109,11 +97,127 @@ func main() {
 
 	// User store and authorization removed - single user mode
 
+	// Optional Claude CLI overrides
+	if claudeBinary := os.Getenv("MAVIS_CLAUDE_BINARY"); claudeBinary != "" {
+		codeagent.SetClaudeBinary(claudeBinary)
+		log.Printf("[STARTUP] Using Claude binary: %s", claudeBinary)
+	}
+	if claudeFlags := os.Getenv("MAVIS_CLAUDE_FLAGS"); claudeFlags != "" {
+		codeagent.SetDefaultFlags(strings.Fields(claudeFlags))
+		log.Printf("[STARTUP] Using Claude flags: %s", claudeFlags)
+	}
+
+	// Optional cap on the output kept per agent
+	if maxOutput := os.Getenv("MAVIS_MAX_OUTPUT_MB"); maxOutput != "" {
+		if mb, err := strconv.Atoi(maxOutput); err != nil || mb < 0 {
+			log.Printf("[STARTUP] Invalid MAVIS_MAX_OUTPUT_MB %q", maxOutput)
+		} else {
+			codeagent.SetMaxOutputSize(mb << 20)
+		}
+	}
+
 	log.Println("[STARTUP] Initializing code agent manager...")
 	// Initialize code agent manager
 	agentManager = codeagent.NewManager()
 	log.Println("[STARTUP] Code agent manager initialized")
 
+	// Optional stale agent watchdog
+	if staleTimeout := os.Getenv("MAVIS_STALE_TIMEOUT"); staleTimeout != "" {
+		if d, err := time.ParseDuration(staleTimeout); err != nil {
+			log.Printf("[STARTUP] Invalid MAVIS_STALE_TIMEOUT %q: %v", staleTimeout, err)
+		} else {
+			agentManager.SetAutoKillStale(os.Getenv("MAVIS_STALE_AUTOKILL") == "true")
+			agentManager.SetStaleTimeout(d)
+			log.Printf("[STARTUP] Stale agent watchdog enabled (timeout: %s)", d)
+		}
+	}
+
+	// Optional limit on agents running at once across all folders
+	if maxAgents := os.Getenv("MAVIS_MAX_CONCURRENT_AGENTS"); maxAgents != "" {
+		if n, err := strconv.Atoi(maxAgents); err != nil || n < 0 {
+			log.Printf("[STARTUP] Invalid MAVIS_MAX_CONCURRENT_AGENTS %q", maxAgents)
+		} else {
+			agentManager.SetMaxConcurrentAgents(n)
+			log.Printf("[STARTUP] At most %d agents run at once", n)
+		}
+	}
+
+	// Optional token budget
+	perAgentTokens, _ := strconv.Atoi(os.Getenv("MAVIS_TOKEN_BUDGET_AGENT"))
+	perDayTokens, _ := strconv.Atoi(os.Getenv("MAVIS_TOKEN_BUDGET_DAILY"))
+	if perAgentTokens > 0 || perDayTokens > 0 {
+		usageFile := filepath.Join(homeDir, ".config", "mavis", "token_usage.json")
+		if err := agentManager.SetTokenUsageFile(usageFile); err != nil {
+			log.Printf("[STARTUP] Failed to load token usage: %v", err)
+		}
+		agentManager.SetTokenBudget(perAgentTokens, perDayTokens)
+		log.Printf("[STARTUP] Token budget enabled (per agent: %d, per day: %d)", perAgentTokens, perDayTokens)
+	}
+
+	// Optional webhook notified when agents finish
+	if webhookURL := os.Getenv("MAVIS_WEBHOOK_URL"); webhookURL != "" {
+		agentManager.SetWebhook(webhookURL, os.Getenv("MAVIS_WEBHOOK_SECRET"))
+		log.Printf("[STARTUP] Agent completion webhook enabled")
+	}
+
+	// Optional scrollback size for interactive web sessions
+	if scrollback := os.Getenv("MAVIS_SCROLLBACK_LINES"); scrollback != "" {
+		if lines, err := strconv.Atoi(scrollback); err != nil || lines < 0 {
+			log.Printf("[STARTUP] Invalid MAVIS_SCROLLBACK_LINES %q", scrollback)
+		} else {
+			web.SetInteractiveScrollbackLines(lines)
+		}
+	}
+
+	// Optional idle timeout for interactive web sessions
+	if idleTimeout := os.Getenv("MAVIS_INTERACTIVE_IDLE_TIMEOUT"); idleTimeout != "" {
+		if d, err := time.ParseDuration(idleTimeout); err != nil {
+			log.Printf("[STARTUP] Invalid MAVIS_INTERACTIVE_IDLE_TIMEOUT %q: %v", idleTimeout, err)
+		} else {
+			web.SetInteractiveIdleTimeout(d)
+			log.Printf("[STARTUP] Interactive session idle timeout: %s", d)
+		}
+	}
+
+	// Optional per-token rate for /cost estimates
+	if rate := os.Getenv("MAVIS_TOKEN_COST_PER_MILLION"); rate != "" {
+		if perMillion, err := strconv.ParseFloat(rate, 64); err != nil || perMillion < 0 {
+			log.Printf("[STARTUP] Invalid MAVIS_TOKEN_COST_PER_MILLION %q", rate)
+		} else {
+			telegram.SetTokenCostRate(perMillion)
+		}
+	}
+
+	// Optional allowlist of directories commands may work in
+	if allowed := os.Getenv("MAVIS_ALLOWED_DIRS"); allowed != "" {
+		if err := core.SetAllowedDirs(filepath.SplitList(allowed)); err != nil {
+			log.Fatalf("[STARTUP] Invalid MAVIS_ALLOWED_DIRS: %v", err)
+		}
+		log.Printf("[STARTUP] Paths restricted to: %s", strings.Join(core.AllowedDirs(), ", "))
+	}
+
+	// Optional certificate for /start --tls HTTPS proxies and /serve --tls file servers
+	if certFile, keyFile := os.Getenv("MAVIS_TLS_CERT"), os.Getenv("MAVIS_TLS_KEY"); certFile != "" || keyFile != "" {
+		if err := web.SetTLSCertFiles(certFile, keyFile); err != nil {
+			log.Printf("[STARTUP] Invalid MAVIS_TLS_CERT/MAVIS_TLS_KEY, --tls will use a self-signed certificate: %v", err)
+		}
+	}
+
+	// Optional size limit for images sent to the bot
+	if maxUpload := os.Getenv("MAVIS_MAX_UPLOAD_MB"); maxUpload != "" {
+		if mb, err := strconv.Atoi(maxUpload); err != nil || mb <= 0 {
+			log.Printf("[STARTUP] Invalid MAVIS_MAX_UPLOAD_MB %q", maxUpload)
+		} else {
+			telegram.SetMaxUploadSize(int64(mb) << 20)
+		}
+	}
+
+	// Review rubrics for /review and /pr
+	rubricsFile := filepath.Join(homeDir, ".config", "mavis", "rubrics.json")
+	if err := core.LoadReviewRubrics(rubricsFile); err != nil {
+		log.Printf("[STARTUP] Failed to load review rubrics: %v", err)
+	}
+
 	log.Println("[STARTUP] Setting up agent callbacks...")
 	// Set callback for when queued agents start
 	agentManager.SetAgentStartCallback(func(agentID, folder, prompt, queueID string) {
@@ -160,6 +264,7 @@ func main() {
 	log.Println("[STARTUP] Telegram globals initialized")
 
 	web.InitializeGlobals(Bot, agentManager, AdminUserID, ProjectDir)
+	web.SetLANServerStatusFunc(telegram.IsLANServerRunning)
 	log.Println("[STARTUP] Web globals initialized")
 
 	core.InitializeGlobals(AdminUserID)
@@ -170,6 +275,17 @@ func main() {
 	telegram.InitializeUPnP()
 	log.Println("[STARTUP] UPnP initialization complete")
 
+	// Advertise LAN servers over mDNS (optional feature)
+	if os.Getenv("MAVIS_MDNS") == "true" {
+		// Optional host name instead of mavis.local
+		if v := os.Getenv("MAVIS_MDNS_NAME"); v != "" {
+			if err := telegram.SetLANDomainName(v); err != nil {
+				log.Printf("[STARTUP] Invalid MAVIS_MDNS_NAME, using mavis.local: %v", err)
+			}
+		}
+		telegram.InitializeMDNS()
+	}
+
 	log.Println("[STARTUP] Starting background processes...")
 	go telegram.MonitorAgentsProcess(ctx, Bot)
 	log.Println("[STARTUP] Agent monitor process started")
@@ -228,13 +344,25 @@ func main() {
 		sig := <-sigChan
 		log.Printf("[SIGNAL] Received signal: %s (%d)", sig.String(), sig)
 		
+		// Kill running agents so their claude processes are not orphaned
+		runningAgents := agentManager.RunningCount()
+		for _, err := range agentManager.KillAllAgents() {
+			log.Printf("[SIGNAL] [WARNING] %v", err)
+		}
+
 		// Send shutdown notification to admin
 		shutdownMsg := fmt.Sprintf("🛑 Mavis shutting down\n📡 Signal: %s", sig.String())
+		if runningAgents > 0 {
+			shutdownMsg += fmt.Sprintf("\n🤖 Terminated %d running agent(s)", runningAgents)
+		}
 		_, _ = Bot.SendMessage(context.Background(), &bot.SendMessageParams{
 			ChatID: AdminUserID,
 			Text:   shutdownMsg,
 		})
 
+		// Withdraw mDNS advertisements so other devices stop listing them
+		telegram.ShutdownMDNS()
+
 		// Cancel context to trigger graceful shutdown
 		cancel()
 		
@@ -401,6 +529,12 @@ func cleanupOldTempFiles(ctx context.Context) {
 					}
 				}
 			}
+
+			// Safety net for git agent workspaces that were not removed on completion
+			core.CleanupStaleWorkspaces(24*time.Hour, func(dir string) bool {
+				running, _ := agentManager.IsAgentRunningInFolder(dir)
+				return running
+			})
 		case <-ctx.Done():
 			return
 		}
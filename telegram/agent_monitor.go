@@ -38,154 +38,153 @@ func MonitorAgentsProcess(ctx context.Context, b *bot.Bot) {
 	// Log initial state
 	log.Printf("Agent monitor started. Checking agents every 5 seconds...")
 
+	// Completion events trigger an immediate check; the ticker is a fallback
+	// that also runs the delayed cleanup of finished agents
+	events := agentManager.LifecycleEvents()
+	defer agentManager.StopEvents(events)
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(5 * time.Second): // Check every 5 seconds
-			// Log monitoring cycle start
-			log.Printf("[AgentMonitor] Starting monitoring cycle...")
-			agents := agentManager.ListAgents()
-			log.Printf("[AgentMonitor] Found %d agents to check", len(agents))
-
-			for _, agent := range agents {
-				runningDuration := ""
-				if !agent.StartTime.IsZero() {
-					runningDuration = fmt.Sprintf(", running for: %v", time.Since(agent.StartTime).Round(time.Second))
-				}
-				log.Printf("[AgentMonitor] Checking agent %s, status: %s, notified: %v%s", agent.ID, agent.Status, notifiedAgents[agent.ID], runningDuration)
-				// Skip if we've already notified about this agent (unless removal failed)
-				if notifiedAgents[agent.ID] && !failedRemovals[agent.ID] {
-					log.Printf("[AgentMonitor] Agent %s already notified and not in failed removals, skipping", agent.ID)
-					continue
-				}
+		case event := <-events:
+			if !event.Type.IsTerminal() {
+				continue
+			}
+			log.Printf("[AgentMonitor] Agent %s %s, checking agents", event.AgentID, event.Type)
+		case <-ticker.C: // Check every 5 seconds
+		}
 
-				// Note: Zombie process detection removed - agents use CombinedOutput()
-				// which runs synchronously, so they cannot become zombie processes
-
-				// Check if agent has completed (finished, failed, or killed)
-				if agent.Status == codeagent.StatusFinished ||
-					agent.Status == codeagent.StatusFailed ||
-					agent.Status == codeagent.StatusKilled {
-
-					// In single-user mode, all agents belong to AdminUserID
-					userID := AdminUserID
-
-					// Mark as notified BEFORE sending to prevent any race condition
-					notifiedAgents[agent.ID] = true
-					log.Printf("[AgentMonitor] Marking agent %s as notified for user %d", agent.ID, userID)
-
-					// Send notification using SendLongMessage for full output (only if not retrying)
-					if !failedRemovals[agent.ID] {
-						notification := formatAgentCompletionNotification(agent, userID)
-						log.Printf("[AgentMonitor] Sending completion notification for agent %s, status: %s", agent.ID, agent.Status)
-
-						// For web users (userID 0), send to admin's Telegram
-						telegramUserID := userID
-						if userID == 0 {
-							telegramUserID = AdminUserID
-							log.Printf("[AgentMonitor] Web-launched agent %s, sending notification to admin (ID: %d)", agent.ID, AdminUserID)
-						}
-
-						core.SendLongMessage(ctx, b, telegramUserID, notification)
-						log.Printf("[AgentMonitor] Sent completion notification for agent %s to user %d", agent.ID, telegramUserID)
-
-						// TODO: Broadcast SSE event for web interface
-						// eventType := "agent_completed"
-						// if agent.Status == "failed" {
-						// 	eventType = "agent_failed"
-						// }
-						// BroadcastSSEEvent(eventType, map[string]interface{}{
-						// 	"agent_id":     agent.ID,
-						// 	"status":       agent.Status,
-						// 	"directory":    agent.Folder,
-						// 	"output":       agent.Output, // Include full output
-						// 	"error":        agent.Error,  // Include error if any
-						// 	"notification": notification, // Include formatted notification
-						// })
-					} else {
-						log.Printf("[AgentMonitor] Skipping notification for agent %s (failed removal retry)", agent.ID)
-					}
+		// Log monitoring cycle start
+		log.Printf("[AgentMonitor] Starting monitoring cycle...")
+		agents := agentManager.ListAgents()
+		log.Printf("[AgentMonitor] Found %d agents to check", len(agents))
 
-					// MODIFIED: Track completion time for 10-minute delayed cleanup
-					log.Printf("[AgentMonitor] Agent %s completed, will be removed after 10 minutes", agent.ID)
-					finishedAgentTimes[agent.ID] = time.Now()
-					
-					// We need to clear the running status for this folder to allow queue processing
-					// This is normally done by RemoveAgent, but since we're not removing, do it manually
-					if agent.Folder != "" {
-						log.Printf("[AgentMonitor] Clearing running status and processing queue for folder: %s", agent.Folder)
-						// Call ProcessQueueForFolder which will clear runningPerFolder and start next queued task
-						agentManager.ProcessQueueForFolder(agent.Folder)
-					}
-					
-					// Comment out the automatic removal
-					/*
-					// Remove the agent from the manager now that notification is sent
-					log.Printf("[AgentMonitor] Attempting to remove agent %s from manager (folder: %s)", agent.ID, agent.Folder)
-					if err := agentManager.RemoveAgent(agent.ID); err != nil {
-						log.Printf("[AgentMonitor] ERROR: Failed to remove agent %s: %v", agent.ID, err)
-						failedRemovals[agent.ID] = true
-						// Don't give up - we'll retry next cycle
-						log.Printf("[AgentMonitor] Agent %s marked for removal retry", agent.ID)
-					} else {
-						log.Printf("[AgentMonitor] Successfully removed agent %s after notification", agent.ID)
-						delete(failedRemovals, agent.ID)
-						delete(notifiedAgents, agent.ID) // Clear notification flag since agent is removed
-
-						// Clean up tracking immediately
-						UnregisterAgent(agent.ID)
-						log.Printf("[AgentMonitor] Cleaned up tracking for agent %s", agent.ID)
+		for _, agent := range agents {
+			runningDuration := ""
+			if !agent.StartTime.IsZero() {
+				runningDuration = fmt.Sprintf(", running for: %v", time.Since(agent.StartTime).Round(time.Second))
+			}
+			log.Printf("[AgentMonitor] Checking agent %s, status: %s, notified: %v%s", agent.ID, agent.Status, notifiedAgents[agent.ID], runningDuration)
+			// Skip if we've already notified about this agent (unless removal failed)
+			if notifiedAgents[agent.ID] && !failedRemovals[agent.ID] {
+				log.Printf("[AgentMonitor] Agent %s already notified and not in failed removals, skipping", agent.ID)
+				continue
+			}
+
+			// Note: Zombie process detection removed - agents use CombinedOutput()
+			// which runs synchronously, so they cannot become zombie processes
+
+			// Check if agent has completed (finished, failed, or killed)
+			if agent.Status == codeagent.StatusFinished ||
+				agent.Status == codeagent.StatusFailed ||
+				agent.Status == codeagent.StatusKilled {
+
+				// In single-user mode, all agents belong to AdminUserID
+				userID := AdminUserID
+
+				// Mark as notified BEFORE sending to prevent any race condition
+				notifiedAgents[agent.ID] = true
+				log.Printf("[AgentMonitor] Marking agent %s as notified for user %d", agent.ID, userID)
+
+				// Send notification using SendLongMessage for full output (only if not retrying)
+				if !failedRemovals[agent.ID] {
+					notification := formatAgentCompletionNotification(agent, userID)
+					log.Printf("[AgentMonitor] Sending completion notification for agent %s, status: %s", agent.ID, agent.Status)
+
+					// For web users (userID 0), send to admin's Telegram
+					telegramUserID := userID
+					if userID == 0 {
+						telegramUserID = AdminUserID
+						log.Printf("[AgentMonitor] Web-launched agent %s, sending notification to admin (ID: %d)", agent.ID, AdminUserID)
 					}
-					*/
+
+					core.SendLongMessage(ctx, b, telegramUserID, notification)
+					log.Printf("[AgentMonitor] Sent completion notification for agent %s to user %d", agent.ID, telegramUserID)
+				} else {
+					log.Printf("[AgentMonitor] Skipping notification for agent %s (failed removal retry)", agent.ID)
 				}
+
+				// MODIFIED: Track completion time for 10-minute delayed cleanup
+				log.Printf("[AgentMonitor] Agent %s completed, will be removed after 10 minutes", agent.ID)
+				finishedAgentTimes[agent.ID] = time.Now()
+				
+				// We need to clear the running status for this folder to allow queue processing
+				// This is normally done by RemoveAgent, but since we're not removing, do it manually
+				if agent.Folder != "" {
+					log.Printf("[AgentMonitor] Clearing running status and processing queue for folder: %s", agent.Folder)
+					// Call ProcessQueueForFolder which will clear runningPerFolder and start next queued task
+					agentManager.ProcessQueueForFolder(agent.Folder)
+				}
+				
+				// Comment out the automatic removal
+				/*
+				// Remove the agent from the manager now that notification is sent
+				log.Printf("[AgentMonitor] Attempting to remove agent %s from manager (folder: %s)", agent.ID, agent.Folder)
+				if err := agentManager.RemoveAgent(agent.ID); err != nil {
+					log.Printf("[AgentMonitor] ERROR: Failed to remove agent %s: %v", agent.ID, err)
+					failedRemovals[agent.ID] = true
+					// Don't give up - we'll retry next cycle
+					log.Printf("[AgentMonitor] Agent %s marked for removal retry", agent.ID)
+				} else {
+					log.Printf("[AgentMonitor] Successfully removed agent %s after notification", agent.ID)
+					delete(failedRemovals, agent.ID)
+					delete(notifiedAgents, agent.ID) // Clear notification flag since agent is removed
+
+					// Clean up tracking immediately
+					UnregisterAgent(agent.ID)
+					log.Printf("[AgentMonitor] Cleaned up tracking for agent %s", agent.ID)
+				}
+				*/
 			}
+		}
 
-			// Clean up agents that have been finished for more than 10 minutes
-			now := time.Now()
-			for agentID, finishTime := range finishedAgentTimes {
-				if now.Sub(finishTime) > 10*time.Minute {
-					log.Printf("[AgentMonitor] Agent %s has been finished for > 10 minutes, removing", agentID)
-					
-					// Remove the agent from the manager
-					if err := agentManager.RemoveAgent(agentID); err != nil {
-						log.Printf("[AgentMonitor] ERROR: Failed to remove agent %s: %v", agentID, err)
-						// Don't retry infinitely - if it fails after 10 minutes, remove from tracking
-						if now.Sub(finishTime) > 15*time.Minute {
-							log.Printf("[AgentMonitor] Giving up on removing agent %s after 15 minutes", agentID)
-							delete(finishedAgentTimes, agentID)
-							delete(notifiedAgents, agentID)
-							UnregisterAgent(agentID)
-						}
-					} else {
-						log.Printf("[AgentMonitor] Successfully removed agent %s after 10 minutes", agentID)
+		// Clean up agents that have been finished for more than 10 minutes
+		now := time.Now()
+		for agentID, finishTime := range finishedAgentTimes {
+			if now.Sub(finishTime) > 10*time.Minute {
+				log.Printf("[AgentMonitor] Agent %s has been finished for > 10 minutes, removing", agentID)
+				
+				// Remove the agent from the manager
+				if err := agentManager.RemoveAgent(agentID); err != nil {
+					log.Printf("[AgentMonitor] ERROR: Failed to remove agent %s: %v", agentID, err)
+					// Don't retry infinitely - if it fails after 10 minutes, remove from tracking
+					if now.Sub(finishTime) > 15*time.Minute {
+						log.Printf("[AgentMonitor] Giving up on removing agent %s after 15 minutes", agentID)
 						delete(finishedAgentTimes, agentID)
 						delete(notifiedAgents, agentID)
-						delete(failedRemovals, agentID)
 						UnregisterAgent(agentID)
 					}
+				} else {
+					log.Printf("[AgentMonitor] Successfully removed agent %s after 10 minutes", agentID)
+					delete(finishedAgentTimes, agentID)
+					delete(notifiedAgents, agentID)
+					delete(failedRemovals, agentID)
+					UnregisterAgent(agentID)
 				}
 			}
-			
-			// Clean up old agents from our tracking maps
-			// Remove agents that have been completed for more than 1 hour
-			for agentID, notified := range notifiedAgents {
-				if notified {
-					// Check if this agent still exists in the manager
-					found := false
-					for _, agent := range agents {
-						if agent.ID == agentID {
-							found = true
-							break
-						}
-					}
-					if !found {
-						// Agent no longer exists, clean up
-						delete(notifiedAgents, agentID)
-						delete(finishedAgentTimes, agentID)
-						UnregisterAgent(agentID)
+		}
+		
+		// Clean up old agents from our tracking maps
+		// Remove agents that have been completed for more than 1 hour
+		for agentID, notified := range notifiedAgents {
+			if notified {
+				// Check if this agent still exists in the manager
+				found := false
+				for _, agent := range agents {
+					if agent.ID == agentID {
+						found = true
+						break
 					}
 				}
+				if !found {
+					// Agent no longer exists, clean up
+					delete(notifiedAgents, agentID)
+					delete(finishedAgentTimes, agentID)
+					UnregisterAgent(agentID)
+				}
 			}
 		}
 	}
@@ -395,10 +394,11 @@ func performRecoveryCheck(b *bot.Bot) {
 	}
 
 	// 2. Check for folders with queues but no running agent
-	detailedQueueStatus := agentManager.GetDetailedQueueStatus()
+	detailedQueueStatus := agentManager.GetFolderQueues()
 	stuckQueues := 0
 
-	for folder, tasks := range detailedQueueStatus {
+	for folder, queue := range detailedQueueStatus {
+		tasks := queue.Tasks
 		if len(tasks) > 0 {
 			// Check if there's a running agent for this folder
 			hasRunning, agentID := agentManager.IsAgentRunningInFolder(folder)
@@ -5,6 +5,7 @@ package telegram
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"mavis/core"
@@ -12,6 +13,26 @@ import (
 	"github.com/go-telegram/bot/models"
 )
 
+// handleConfirmationReply runs or cancels the command waiting for confirmation
+// from the sender, reporting whether there was one
+func handleConfirmationReply(ctx context.Context, message *models.Message) bool {
+	if message.From == nil {
+		return false
+	}
+	confirmation, ok, expired := takeConfirmation(message.From.ID)
+	switch {
+	case expired:
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⌛ Confirmation to %s expired. Run the command again.", confirmation.Action))
+	case !ok:
+		return false
+	case strings.EqualFold(strings.TrimSpace(message.Text), "yes"):
+		confirmation.Run(ctx, message.Chat.ID)
+	default:
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❎ Cancelled, did not %s.", confirmation.Action))
+	}
+	return true
+}
+
 func HandleMessage(ctx context.Context, message *models.Message) {
 	// Handle Telegram commands for code agents
 	if strings.HasPrefix(message.Text, "/") {
@@ -29,19 +50,37 @@ func HandleMessage(ctx context.Context, message *models.Message) {
 			case "/ps":
 				handleAgentsCommand(ctx, message)
 				return
+			case "/find":
+				handleFindCommand(ctx, message)
+				return
 			case "/status":
 				handleStatusCommand(ctx, message)
 				return
+			case "/logs":
+				handleLogsCommand(ctx, message)
+				return
+			case "/cost":
+				handleCostCommand(ctx, message)
+				return
 			case "/stop":
 				// Check if it's the LAN stop command or agent stop command
-				if len(parts) == 1 {
-					// No arguments, it's LAN stop
+				if len(parts) == 1 || parts[1] == "all" || isLANServerPort(parts[1]) {
+					// No arguments, "all" or a server port, it's LAN stop
 					handleStopLANCommand(ctx, message)
 				} else {
 					// Has arguments, it's agent stop
 					handleStopCommand(ctx, message)
 				}
 				return
+			case "/stop_all":
+				handleStopAllLANCommand(ctx, message)
+				return
+			case "/kill_all":
+				handleKillAllCommand(ctx, message)
+				return
+			case "/retry":
+				handleRetryCommand(ctx, message)
+				return
 			case "/start":
 				handleStartCommand(ctx, message)
 				return
@@ -51,12 +90,24 @@ func HandleMessage(ctx context.Context, message *models.Message) {
 			case "/edit_branch":
 				handleGitBranchCommand(ctx, message)
 				return
+			case "/clone":
+				handleCloneCommand(ctx, message)
+				return
+			case "/branches":
+				handleBranchesCommand(ctx, message)
+				return
 			case "/review":
 				handleReviewCommand(ctx, message)
 				return
 			case "/pr":
 				handlePRCommand(ctx, message)
 				return
+			case "/rubrics":
+				handleRubricsCommand(ctx, message)
+				return
+			case "/rubric":
+				handleRubricCommand(ctx, message)
+				return
 			case "/approve":
 				handleApproveCommand(ctx, message)
 				return
@@ -66,18 +117,42 @@ func HandleMessage(ctx context.Context, message *models.Message) {
 			case "/download":
 				handleDownloadCommand(ctx, message)
 				return
+			case "/download_zip":
+				handleDownloadZipCommand(ctx, message)
+				return
 			case "/ls":
 				handleLsCommand(ctx, message)
 				return
 			case "/mkdir":
 				handleMkdirCommand(ctx, message)
 				return
+			case "/merge":
+				handleMergeCommand(ctx, message)
+				return
+			case "/stash":
+				handleStashCommand(ctx, message)
+				return
+			case "/stash_pop":
+				handleStashPopCommand(ctx, message)
+				return
 			case "/commit":
 				handleCommitCommand(ctx, message)
 				return
 			case "/serve":
 				handleServeCommand(ctx, message)
 				return
+			case "/serve_stats":
+				handleServeStatsCommand(ctx, message)
+				return
+			case "/ports":
+				handlePortsCommand(ctx, message)
+				return
+			case "/freeport":
+				handleFreePortCommand(ctx, message)
+				return
+			case "/upnp_status":
+				handleUPnPStatusCommand(ctx, message)
+				return
 			case "/diff":
 				handleDiffCommand(ctx, message)
 				return
@@ -98,6 +173,11 @@ func HandleMessage(ctx context.Context, message *models.Message) {
 		return
 	}
 
+	// A reply to a destructive command waiting for confirmation
+	if handleConfirmationReply(ctx, message) {
+		return
+	}
+
 	// For non-command messages, just show help
 	core.SendMessage(ctx, b, message.Chat.ID, "I'm Mavis, a code agent manager. Use /help to see available commands.")
 }
@@ -8,32 +8,235 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"mavis/codeagent"
 	"mavis/core"
 
 	"github.com/go-telegram/bot/models"
 )
 
 func handleCodeCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, dryRun := extractDryRunFlag(strings.Fields(message.Text), 2)
+	parts, opts := parseCodeOptions(parts)
 
 	if len(parts) < 3 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/code <directory> <task>`\n\nExample: `/code /home/project \"fix the bug in main.py\"`")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/code [--model=<model>] [--label=<key=value>] [--env KEY=VALUE] [--dry-run] <directory> <task>`\n\nExample: `/code /home/project \"fix the bug in main.py\"`")
 		return
 	}
+	if _, ok := opts.Labels["kind"]; !ok {
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels["kind"] = "code"
+	}
 
 	// Extract directory and task
 	directory := parts[1]
 	task := strings.Join(parts[2:], " ")
 
 	// Call the existing launch function
-	launchCodeAgentCommand(ctx, directory, task)
+	launchCodeAgentCommand(ctx, directory, task, opts, dryRun)
+}
+
+// parseCodeOptions extracts --option tokens that appear before the task text
+func parseCodeOptions(parts []string) ([]string, codeagent.AgentOptions) {
+	var opts codeagent.AgentOptions
+	args := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		// Options are only recognised before the task starts (command + directory)
+		if i > 0 && len(args) < 3 && strings.HasPrefix(part, "--model=") {
+			opts.Model = strings.TrimPrefix(part, "--model=")
+			continue
+		}
+		if i > 0 && len(args) < 3 && strings.HasPrefix(part, "--label=") {
+			if opts.Labels == nil {
+				opts.Labels = make(map[string]string)
+			}
+			key, value := parseLabel(strings.TrimPrefix(part, "--label="))
+			opts.Labels[key] = value
+			continue
+		}
+		if i > 0 && len(args) < 3 && (part == "--env" || strings.HasPrefix(part, "--env=")) {
+			variable := strings.TrimPrefix(part, "--env=")
+			if part == "--env" {
+				if i+1 == len(parts) {
+					break
+				}
+				i++
+				variable = parts[i]
+			}
+			if opts.Env == nil {
+				opts.Env = make(map[string]string)
+			}
+			key, value, _ := strings.Cut(variable, "=")
+			opts.Env[key] = value
+			continue
+		}
+		args = append(args, part)
+	}
+	return args, opts
 }
 
+// extractDryRunFlag removes a --dry-run flag given before the free-form task,
+// i.e. among the first fixedArgs positional arguments (the command included).
+// A --dry-run inside the task text is left alone.
+func extractDryRunFlag(parts []string, fixedArgs int) ([]string, bool) {
+	rest := make([]string, 0, len(parts))
+	dryRun := false
+	positional := 0
+	for _, part := range parts {
+		if part == "--dry-run" && positional > 0 && positional <= fixedArgs {
+			dryRun = true
+			continue
+		}
+		if !strings.HasPrefix(part, "--") {
+			positional++
+		}
+		rest = append(rest, part)
+	}
+	return rest, dryRun
+}
+
+// sendDryRun replies with the full prompt an agent would receive instead of launching it
+func sendDryRun(ctx context.Context, chatID int64, directory, prompt, planFilename string) {
+	core.SendLongMessage(ctx, b, chatID, fmt.Sprintf("🧪 *Dry run* - no agent was launched\n📁 Directory: %s\n\n📝 *Prompt:*\n```\n%s\n```",
+		directory, codeagent.PreviewPrompt(directory, prompt, planFilename)))
+}
+
+// parseLabel splits a "key=value" label. A bare value is shorthand for kind=value.
+func parseLabel(label string) (string, string) {
+	if key, value, ok := strings.Cut(label, "="); ok {
+		return key, value
+	}
+	return "kind", label
+}
+
+// handleAgentsCommand handles /ps. The argument is a status (running, failed,
+// ...), a label given as key=value, an existing folder, or else a bare kind label.
 func handleAgentsCommand(ctx context.Context, message *models.Message) {
-	listCodeAgentsCommand(ctx)
+	parts := strings.Fields(message.Text)
+	if len(parts) < 2 {
+		listCodeAgentsCommand(ctx)
+		return
+	}
+
+	arg := parts[1]
+	if status, ok := parseAgentStatus(arg); ok {
+		listFilteredAgentsCommand(ctx, codeagent.AgentFilter{Status: status}, string(status))
+		return
+	}
+	if !strings.Contains(arg, "=") {
+		if absDir, err := core.ResolvePath(arg); err == nil {
+			if info, err := os.Stat(absDir); err == nil && info.IsDir() {
+				listFilteredAgentsCommand(ctx, codeagent.AgentFilter{Folder: absDir}, absDir)
+				return
+			}
+		}
+	}
+	key, value := parseLabel(arg)
+	listCodeAgentsByLabelCommand(ctx, key, value)
+}
+
+// parseAgentStatus matches a /ps argument against the agent statuses
+func parseAgentStatus(arg string) (codeagent.AgentStatus, bool) {
+	for _, status := range []codeagent.AgentStatus{
+		codeagent.StatusPending,
+		codeagent.StatusRunning,
+		codeagent.StatusFinished,
+		codeagent.StatusFailed,
+		codeagent.StatusKilled,
+	} {
+		if strings.EqualFold(arg, string(status)) {
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// maxFindResults caps the number of agents listed by /find
+const maxFindResults = 20
+
+func handleFindCommand(ctx context.Context, message *models.Message) {
+	query, opts, err := parseFindArgs(strings.Fields(message.Text)[1:], time.Now())
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if query == "" {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/find [--status=<status>] [--since=<duration>] <query>`\n\nExample: `/find --since=7d auth.go`")
+		return
+	}
+
+	agents := agentManager.SearchAgents(query, opts)
+	if len(agents) == 0 {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🔍 No agents matching \"%s\".", query))
+		return
+	}
+	core.SendLongMessage(ctx, b, message.Chat.ID, formatFindResults(query, agents))
+}
+
+// parseFindArgs splits /find arguments into the query and search options.
+// Options are only recognised before the query.
+func parseFindArgs(args []string, now time.Time) (string, codeagent.SearchOptions, error) {
+	var opts codeagent.SearchOptions
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		option, value, _ := strings.Cut(args[0], "=")
+		switch option {
+		case "--status":
+			opts.Status = codeagent.AgentStatus(value)
+		case "--since":
+			since, err := parseSince(value)
+			if err != nil {
+				return "", opts, err
+			}
+			opts.StartTime = now.Add(-since)
+		default:
+			return "", opts, fmt.Errorf("unknown option %s", option)
+		}
+		args = args[1:]
+	}
+	return strings.Join(args, " "), opts, nil
+}
+
+// parseSince parses a duration such as 90m, 24h or 7d
+func parseSince(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid --since value: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	since, err := time.ParseDuration(value)
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("invalid --since value: %s", value)
+	}
+	return since, nil
+}
+
+// formatFindResults formats /find matches with their start times
+func formatFindResults(query string, agents []codeagent.AgentInfo) string {
+	message := fmt.Sprintf("🔍 *%d agent(s) matching \"%s\":*\n\n", len(agents), query)
+	if len(agents) > maxFindResults {
+		message += fmt.Sprintf("Showing the %d most recent.\n\n", maxFindResults)
+		agents = agents[:maxFindResults]
+	}
+	for _, agent := range agents {
+		message += fmt.Sprintf("%s `%s` - %s\n", statusEmoji(agent.Status), agent.ID, string(agent.Status))
+		message += fmt.Sprintf("   🕐 %s\n", agent.StartTime.Format("Mon Jan 2 15:04"))
+		message += fmt.Sprintf("   📁 %s\n", agent.Folder)
+		prompt := agent.Prompt
+		if len(prompt) > 80 {
+			prompt = prompt[:80] + "..."
+		}
+		message += fmt.Sprintf("   📝 %s\n\n", prompt)
+	}
+	return message
 }
 
 func handleStatusCommand(ctx context.Context, message *models.Message) {
@@ -48,6 +251,27 @@ func handleStatusCommand(ctx context.Context, message *models.Message) {
 	getCodeAgentDetailsCommand(ctx, agentID)
 }
 
+func handleLogsCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/logs <agent_id> [lines]`\n\nExample: `/logs abc123 50`")
+		return
+	}
+
+	lines := 30
+	if len(parts) > 2 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n <= 0 {
+			core.SendMessage(ctx, b, message.Chat.ID, "❌ Lines must be a positive number")
+			return
+		}
+		lines = n
+	}
+
+	getCodeAgentLogsCommand(ctx, parts[1], lines)
+}
+
 func handleStopCommand(ctx context.Context, message *models.Message) {
 	parts := strings.Fields(message.Text)
 
@@ -60,6 +284,102 @@ func handleStopCommand(ctx context.Context, message *models.Message) {
 	killCodeAgentCommand(ctx, agentID)
 }
 
+// handleRetryCommand relaunches a finished, failed or killed agent with its original folder, prompt and options
+func handleRetryCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/retry <agent_id>`\n\nExample: `/retry abc123`")
+		return
+	}
+
+	agentID := parts[1]
+	info, err := agentManager.GetAgentInfo(agentID)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Agent not found: %s", agentID))
+		return
+	}
+
+	// Git agents work in a temporary workspace that is gone once they finish
+	if core.IsTemporaryWorkspace(info.Folder) {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Cannot retry agent %s: it ran in a temporary git workspace that was removed when it finished.\n\nLaunch it again with `/new_branch` or `/edit_branch`.", agentID))
+		return
+	}
+
+	newID, err := agentManager.RetryAgent(ctx, agentID)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Cannot retry agent %s: %v", agentID, err))
+		return
+	}
+
+	if codeagent.IsWaitingForSlot(newID) {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⏳ Retry of agent %s queued!\n📁 Directory: %s\n\nThe agent will start automatically when a running agent completes and a slot is free.", agentID, info.Folder))
+		return
+	}
+	if strings.HasPrefix(newID, "queued-") {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⏳ Retry of agent %s queued!\n📁 Directory: %s\n\nThe agent will start automatically when the current agent in this folder completes.", agentID, info.Folder))
+		return
+	}
+
+	RegisterAgentForUser(newID, AdminUserID)
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🔁 Agent %s relaunched!\n🆔 New ID: `%s`\n📁 Directory: %s\n\nUse `/status %s` to check status.", agentID, newID, info.Folder, newID))
+}
+
+// handleKillAllCommand is the emergency stop: after a "yes" reply it drops all
+// queued tasks and kills every running agent
+func handleKillAllCommand(ctx context.Context, message *models.Message) {
+	if message.From.ID != AdminUserID {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Only admin can kill all agents.")
+		return
+	}
+
+	running := 0
+	for _, agent := range agentManager.ListAgents() {
+		if agent.Status == codeagent.StatusRunning {
+			running++
+		}
+	}
+	queued := 0
+	for _, count := range agentManager.GetQueueStatus() {
+		queued += count
+	}
+	if running == 0 && queued == 0 {
+		core.SendMessage(ctx, b, message.Chat.ID, "📋 No running agents or queued tasks to stop.")
+		return
+	}
+
+	requestConfirmation(message.From.ID, "kill all agents", killAllAgents)
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ This will kill %d running agent(s) and drop %d queued task(s).\n\nReply *yes* within %s to confirm, anything else cancels.", running, queued, confirmationTimeout))
+}
+
+// killAllAgents drops all queued tasks and kills every running agent once /kill_all is confirmed
+func killAllAgents(ctx context.Context, chatID int64) {
+	core.SendMessage(ctx, b, chatID, "🛑 Stopping all agents...")
+
+	// Clear the queues first so killed agents don't start the next queued task
+	dropped := agentManager.ClearQueues()
+	for _, task := range dropped {
+		core.GetQueueTracker().RemoveQueuedAgent(task.QueueID)
+	}
+
+	running := 0
+	for _, agent := range agentManager.ListAgents() {
+		if agent.Status == codeagent.StatusRunning {
+			running++
+		}
+	}
+	errs := agentManager.KillAllAgents()
+
+	msg := fmt.Sprintf("🛑 Killed %d agent(s) and dropped %d queued task(s).", running-len(errs), len(dropped))
+	if len(errs) > 0 {
+		msg += "\n\n⚠️ Some agents could not be stopped:"
+		for _, err := range errs {
+			msg += fmt.Sprintf("\n• %v", err)
+		}
+	}
+	core.SendMessage(ctx, b, chatID, msg)
+}
+
 func handleCleanupCommand(ctx context.Context, message *models.Message) {
 	// Only admin can cleanup stuck agents
 	if message.From.ID != AdminUserID {
@@ -82,7 +402,7 @@ func handleCleanupCommand(ctx context.Context, message *models.Message) {
 	}
 }
 
-func launchCodeAgentCommand(ctx context.Context, directory, task string) {
+func launchCodeAgentCommand(ctx context.Context, directory, task string, opts codeagent.AgentOptions, dryRun bool) {
 	// Use AdminUserID for single-user app
 	chatID := AdminUserID
 	// Resolve the directory path relative to home directory
@@ -108,18 +428,28 @@ func launchCodeAgentCommand(ctx context.Context, directory, task string) {
 	if len(pendingImages) > 0 {
 		// Append image information to the task
 		task += fmt.Sprintf("\n\nThe user has provided %d image(s) for this task:", len(pendingImages))
-		for i, imagePath := range pendingImages {
-			task += fmt.Sprintf("\n- Image %d: %s", i+1, imagePath)
+		for i, image := range pendingImages {
+			task += fmt.Sprintf("\n- Image %d: %s", i+1, image.Path)
 		}
 		task += "\n\nPlease analyze these images as part of the task. You can read them using the Read tool."
+	}
 
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, task, "")
+		return
+	}
+
+	if len(pendingImages) > 0 {
+		if size := pendingImagesSize(pendingImages); size >= largeUploadSize {
+			core.SendMessage(ctx, b, chatID, fmt.Sprintf("⚠️ The %d pending image(s) add up to %s, which may use a lot of the agent's context. Use `/clear_images` next time to drop them.", len(pendingImages), core.FormatBytes(size)))
+		}
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching code agent in %s...\n📸 Including %d pending image(s)", absDir, len(pendingImages)))
 	} else {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching code agent in %s...", absDir))
 	}
 
 	// Launch the agent
-	agentID, err := agentManager.LaunchAgent(ctx, absDir, task)
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, absDir, task, opts)
 	if err != nil {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
 		return
@@ -151,15 +481,13 @@ func launchCodeAgentCommand(ctx context.Context, directory, task string) {
 
 		queuedTasks := agentManager.GetQueuedTasksForFolder(absDir)
 
-		// TODO: Broadcast SSE event for queue update
-		// BroadcastSSEEvent("queue_update", map[string]interface{}{
-		// 	"directory":      absDir,
-		// 	"queue_position": queuePos,
-		// 	"total_queued":   queuedTasks,
-		// })
-
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏳ Agent queued!\n📁 Directory: %s\n📝 Task: %s\n🔢 Queue position: %s\n📊 Total queued tasks for this folder: %d\n\nThe agent will start automatically when the current agent in this folder completes.",
-			directory, task, queuePos, queuedTasks))
+		if codeagent.IsWaitingForSlot(agentID) {
+			core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏳ Agent queued!\n📁 Directory: %s\n📝 Task: %s\n🔢 Position in the global queue: %s\n\n%d agents are already running, the most allowed at once. The agent will start automatically when one of them completes.",
+				directory, task, queuePos, agentManager.MaxConcurrentAgents()))
+		} else {
+			core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏳ Agent queued!\n📁 Directory: %s\n📝 Task: %s\n🔢 Queue position: %s\n📊 Total queued tasks for this folder: %d\n\nThe agent will start automatically when the current agent in this folder completes.",
+				directory, task, queuePos, queuedTasks))
+		}
 
 		// Clear pending images even for queued agents
 		if len(pendingImages) > 0 {
@@ -176,44 +504,104 @@ func launchCodeAgentCommand(ctx context.Context, directory, task string) {
 		clearPendingImages(AdminUserID)
 	}
 
-	// TODO: Broadcast SSE event
-	// BroadcastSSEEvent("agent_started", map[string]interface{}{
-	// 	"agent_id":  agentID,
-	// 	"directory": absDir,
-	// 	"task":      task,
-	// })
+	modelInfo := ""
+	if opts.Model != "" {
+		modelInfo = fmt.Sprintf("\n🧠 Model: %s", opts.Model)
+	}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Code agent launched!\n🆔 ID: `%s`\n📝 Task: %s\n📁 Directory: %s\n\nUse `/status %s` to check status.",
-		agentID, task, directory, agentID))
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Code agent launched!\n🆔 ID: `%s`\n📝 Task: %s\n📁 Directory: %s%s\n\nUse `/status %s` to check status.",
+		agentID, task, directory, modelInfo, agentID))
 }
 
 func listCodeAgentsCommand(ctx context.Context) {
 	chatID := AdminUserID
-	agents := agentManager.ListAgents()
+	agents := agentManager.FilterAgents(codeagent.AgentFilter{})
 
 	if len(agents) == 0 {
 		core.SendMessage(ctx, b, chatID, "📋 No code agents running.")
 		return
 	}
 
-	message := "📋 *Code Agents:*\n\n"
+	message := "📋 *Code Agents:*\n\n" + formatAgentList(agents)
+
+	if concurrency := agentManager.GetConcurrencyStatus(); concurrency.Limit > 0 {
+		message += fmt.Sprintf("\n🎛️ Agent slots: %d/%d in use, %d waiting\n", concurrency.Running, concurrency.Limit, concurrency.Waiting)
+	}
+
+	// Add detailed queue status
+	detailedQueueStatus := agentManager.GetFolderQueues()
+	if len(detailedQueueStatus) > 0 {
+		message += "\n📊 *Queued Tasks:*\n" + formatQueuedTasks(detailedQueueStatus, queueHolders(detailedQueueStatus))
+	}
+
+	core.SendMessage(ctx, b, chatID, message)
+}
+
+// listFilteredAgentsCommand lists the agents matching filter, described by title
+func listFilteredAgentsCommand(ctx context.Context, filter codeagent.AgentFilter, title string) {
+	chatID := AdminUserID
+	agents := agentManager.FilterAgents(filter)
+	if len(agents) == 0 {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("📋 No code agents matching %s.", title))
+		return
+	}
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("📋 *Code Agents (%s):*\n\n", title)+formatAgentList(agents))
+}
+
+// listCodeAgentsByLabelCommand lists the agents and queued tasks with the label key=value
+func listCodeAgentsByLabelCommand(ctx context.Context, key, value string) {
+	chatID := AdminUserID
+	agents := agentManager.ListAgentsByLabel(key, value)
+
+	queued := make(map[string]codeagent.FolderQueue)
+	for folder, queue := range agentManager.GetFolderQueues() {
+		var tasks, waiting []codeagent.QueuedTask
+		for _, task := range queue.Tasks {
+			if labelValue, ok := task.Options.Labels[key]; ok && labelValue == value {
+				tasks = append(tasks, task)
+			}
+		}
+		for _, task := range queue.WaitingForSlot {
+			if labelValue, ok := task.Options.Labels[key]; ok && labelValue == value {
+				waiting = append(waiting, task)
+			}
+		}
+		if len(tasks) > 0 || len(waiting) > 0 {
+			queued[folder] = codeagent.FolderQueue{RunningAgentID: queue.RunningAgentID, Tasks: tasks, WaitingForSlot: waiting}
+		}
+	}
+
+	if len(agents) == 0 && len(queued) == 0 {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("📋 No code agents with label %s=%s.", key, value))
+		return
+	}
+
+	message := fmt.Sprintf("📋 *Code Agents (%s=%s):*\n\n", key, value) + formatAgentList(agents)
+	if len(queued) > 0 {
+		message += "\n📊 *Queued Tasks:*\n" + formatQueuedTasks(queued, queueHolders(queued))
+	}
+
+	core.SendMessage(ctx, b, chatID, message)
+}
+
+// formatAgentList formats agents for /ps, one block per agent
+func formatAgentList(agents []codeagent.AgentInfo) string {
+	message := ""
 	for _, agent := range agents {
-		status := "⏳"
-		switch agent.Status {
-		case "running":
-			status = "🟢"
-		case "finished":
-			status = "✅"
-		case "failed":
-			status = "❌"
-		case "killed":
-			status = "🔴"
-		}
-
-		message += fmt.Sprintf("%s `%s` - %s\n", status, agent.ID, string(agent.Status))
+		message += fmt.Sprintf("%s `%s` - %s\n", statusEmoji(agent.Status), agent.ID, string(agent.Status))
+		if agent.IsStale {
+			message += fmt.Sprintf("   ⚠️ Stale - no output since %s\n", agent.LastActivity.Format("15:04:05"))
+		}
 		if agent.Folder != "" {
 			message += fmt.Sprintf("   📁 %s\n", agent.Folder)
 		}
+		if agent.Duration > 0 {
+			if agent.EndTime.IsZero() {
+				message += fmt.Sprintf("   ⏱️ Running for %s\n", agent.Duration.Round(time.Second))
+			} else {
+				message += fmt.Sprintf("   ⏱️ Took %s\n", agent.Duration.Round(time.Second))
+			}
+		}
 		if agent.Prompt != "" {
 			// Truncate prompt if too long
 			prompt := agent.Prompt
@@ -222,30 +610,95 @@ func listCodeAgentsCommand(ctx context.Context) {
 			}
 			message += fmt.Sprintf("   📝 %s\n", prompt)
 		}
+		if labels := formatLabels(agent.Labels); labels != "" {
+			message += fmt.Sprintf("   🏷️ %s\n", labels)
+		}
 		message += "\n"
 	}
+	return message
+}
 
-	// Add detailed queue status
-	detailedQueueStatus := agentManager.GetDetailedQueueStatus()
-	if len(detailedQueueStatus) > 0 {
-		message += "\n📊 *Queued Tasks:*\n"
-		for folder, tasks := range detailedQueueStatus {
-			message += fmt.Sprintf("\n📁 *%s* (%d tasks):\n", folder, len(tasks))
-			for i, task := range tasks {
-				// Truncate prompt if too long
-				prompt := task.Prompt
-				if len(prompt) > 60 {
-					prompt = prompt[:60] + "..."
-				}
-				message += fmt.Sprintf("   %d. 📝 %s\n", i+1, prompt)
-				message += fmt.Sprintf("      🆔 Queue ID: %s\n", task.QueueID)
+// statusEmoji returns the emoji used for an agent status in agent lists
+func statusEmoji(status codeagent.AgentStatus) string {
+	switch status {
+	case codeagent.StatusRunning:
+		return "🟢"
+	case codeagent.StatusFinished:
+		return "✅"
+	case codeagent.StatusFailed:
+		return "❌"
+	case codeagent.StatusKilled:
+		return "🔴"
+	}
+	return "⏳"
+}
+
+// queueHolders returns the running agent holding each queued folder
+func queueHolders(queued map[string]codeagent.FolderQueue) map[string]codeagent.AgentInfo {
+	holders := make(map[string]codeagent.AgentInfo)
+	for folder, queue := range queued {
+		if queue.RunningAgentID == "" {
+			continue
+		}
+		if holder, ok := agentManager.GetRunningAgentForFolder(folder); ok {
+			holders[folder] = holder
+		}
+	}
+	return holders
+}
+
+// formatQueuedTasks formats queued tasks grouped by folder for /ps, with the
+// agent holding each folder from holders
+func formatQueuedTasks(queued map[string]codeagent.FolderQueue, holders map[string]codeagent.AgentInfo) string {
+	folders := make([]string, 0, len(queued))
+	for folder := range queued {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	message := ""
+	for _, folder := range folders {
+		queue := queued[folder]
+		message += fmt.Sprintf("\n📁 *%s* (%d tasks):\n", folder, len(queue.Tasks)+len(queue.WaitingForSlot))
+		if holder, ok := holders[folder]; ok {
+			message += fmt.Sprintf("   🔒 held by `%s` (running %s)\n", holder.ID, holder.Duration.Round(time.Second))
+		} else if queue.RunningAgentID != "" {
+			message += fmt.Sprintf("   🔒 held by `%s`\n", queue.RunningAgentID)
+		}
+		for i, task := range queue.Tasks {
+			// Truncate prompt if too long
+			prompt := task.Prompt
+			if len(prompt) > 60 {
+				prompt = prompt[:60] + "..."
+			}
+			message += fmt.Sprintf("   %d. 📝 %s\n", i+1, prompt)
+			message += fmt.Sprintf("      🆔 Queue ID: %s\n", task.QueueID)
+		}
+		for _, task := range queue.WaitingForSlot {
+			prompt := task.Prompt
+			if len(prompt) > 60 {
+				prompt = prompt[:60] + "..."
 			}
+			message += fmt.Sprintf("   ⏸️ 📝 %s (waiting for a free agent slot)\n", prompt)
+			message += fmt.Sprintf("      🆔 Queue ID: %s\n", task.QueueID)
 		}
 	}
+	return message
+}
 
-	core.SendMessage(ctx, b, chatID, message)
+// formatLabels formats labels as sorted key=value pairs
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
 }
 
+// maxDetailsOutput is the agent output shown inline by /status
+const maxDetailsOutput = 3000
+
 func getCodeAgentDetailsCommand(ctx context.Context, agentID string) {
 	chatID := AdminUserID
 	agentInfo, err := agentManager.GetAgentInfo(agentID)
@@ -271,6 +724,26 @@ func getCodeAgentDetailsCommand(ctx context.Context, agentID string) {
 	message := fmt.Sprintf("*Code Agent Details*\n\n🆔 ID: `%s`\n📊 Status: %s\n📝 Task: %s\n📁 Directory: %s\n🕐 Started: %s\n",
 		agentInfo.ID, status, agentInfo.Prompt, agentInfo.Folder, agentInfo.StartTime.Format("15:04:05"))
 
+	if agentInfo.Model != "" {
+		message += fmt.Sprintf("🧠 Model: %s\n", agentInfo.Model)
+	}
+
+	if len(agentInfo.EnvKeys) > 0 {
+		message += fmt.Sprintf("🔐 Env: %s\n", strings.Join(agentInfo.EnvKeys, ", "))
+	}
+
+	if agentInfo.IsStale {
+		message += fmt.Sprintf("⚠️ Stale: no output since %s\n", agentInfo.LastActivity.Format("15:04:05"))
+	}
+
+	if agentInfo.OutputTruncated {
+		message += "✂️ Output exceeded the size cap; only its beginning and end were kept\n"
+	}
+
+	if resources, err := agentManager.GetAgentResources(agentInfo.ID); err == nil {
+		message += fmt.Sprintf("📈 Resources: %s\n", resources)
+	}
+
 	if !agentInfo.EndTime.IsZero() {
 		message += fmt.Sprintf("🏁 Ended: %s\n", agentInfo.EndTime.Format("15:04:05"))
 		message += fmt.Sprintf("⏱️ Duration: %s\n", agentInfo.Duration.Round(time.Second))
@@ -284,9 +757,15 @@ func getCodeAgentDetailsCommand(ctx context.Context, agentID string) {
 		}
 	}
 
-	// Add full output if available
+	// Add the output if available, saving it to a file when it is too long for a message
 	if agentInfo.Output != "" {
-		message += fmt.Sprintf("\n📄 *Output:*\n```\n%s\n```", agentInfo.Output)
+		output, fullPath, err := core.TruncateOutput(agentInfo.Output, maxDetailsOutput, "agent-"+agentInfo.ID)
+		message += fmt.Sprintf("\n📄 *Output:*\n```\n%s\n```", output)
+		if fullPath != "" {
+			message += "\n" + core.FullOutputNote(fullPath)
+		} else if err != nil {
+			message += fmt.Sprintf("\n⚠️ Output truncated: %v", err)
+		}
 	}
 
 	// Add full error if available
@@ -298,6 +777,33 @@ func getCodeAgentDetailsCommand(ctx context.Context, agentID string) {
 	core.SendLongMessage(ctx, b, chatID, message)
 }
 
+func getCodeAgentLogsCommand(ctx context.Context, agentID string, lines int) {
+	chatID := AdminUserID
+	agent, err := agentManager.GetAgent(agentID)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Agent not found: %s", agentID))
+		return
+	}
+
+	agentInfo := agent.ToInfo()
+	message := fmt.Sprintf("📜 *Logs for agent* `%s` (last %d lines)\n📊 Status: %s\n", agentInfo.ID, lines, agentInfo.Status)
+
+	// Show when output was last seen so a stuck agent is easy to spot
+	if agentInfo.Status == codeagent.StatusRunning && !agentInfo.LastActivity.IsZero() {
+		idle := time.Since(agentInfo.LastActivity).Round(time.Second)
+		message += fmt.Sprintf("🕐 Last output: %s (%s ago)\n", agentInfo.LastActivity.Format("15:04:05"), idle)
+	}
+
+	tail := agent.GetOutputTail(lines)
+	if len(tail) == 0 {
+		message += "\n📭 No output yet"
+	} else {
+		message += fmt.Sprintf("\n```\n%s\n```", strings.Join(tail, "\n"))
+	}
+
+	core.SendLongMessage(ctx, b, chatID, message)
+}
+
 func killCodeAgentCommand(ctx context.Context, agentID string) {
 	chatID := AdminUserID
 	err := agentManager.KillAgent(agentID)
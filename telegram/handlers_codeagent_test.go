@@ -4,9 +4,14 @@
 package telegram
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"mavis/codeagent"
 )
 
 func TestGetCodeAgentDetailsWithCurrentPlan(t *testing.T) {
@@ -78,3 +83,168 @@ func TestGetCodeAgentDetailsWithoutCurrentPlan(t *testing.T) {
 		t.Errorf("Expected IsNotExist error, got: %v", err)
 	}
 }
+
+func TestParseCodeOptionsLabels(t *testing.T) {
+	args, opts := parseCodeOptions([]string{"/code", "--label=team=infra", "--label=review", "~/project", "task", "--label=x"})
+	if len(args) != 4 || args[3] != "--label=x" {
+		t.Errorf("Expected labels inside the task to stay in the task, got %v", args)
+	}
+	if opts.Labels["team"] != "infra" {
+		t.Errorf("Expected label team=infra, got %v", opts.Labels)
+	}
+	if opts.Labels["kind"] != "review" {
+		t.Errorf("Expected bare label to set kind=review, got %v", opts.Labels)
+	}
+}
+
+func TestParseCodeOptionsEnv(t *testing.T) {
+	args, opts := parseCodeOptions([]string{"/code", "--env", "NODE_ENV=test", "--env=API_KEY=a=b", "~/project", "task", "--env", "X=1"})
+	if len(args) != 5 || args[3] != "--env" || args[4] != "X=1" {
+		t.Errorf("Expected env options inside the task to stay in the task, got %v", args)
+	}
+	if opts.Env["NODE_ENV"] != "test" {
+		t.Errorf("Expected NODE_ENV=test, got %v", opts.Env)
+	}
+	if opts.Env["API_KEY"] != "a=b" {
+		t.Errorf("Expected API_KEY=a=b, got %v", opts.Env)
+	}
+	if len(opts.Env) != 2 {
+		t.Errorf("Expected 2 env vars, got %v", opts.Env)
+	}
+}
+
+func TestExtractDryRunFlag(t *testing.T) {
+	tests := []struct {
+		parts     []string
+		fixedArgs int
+		expected  string
+		dryRun    bool
+	}{
+		{[]string{"/code", "--dry-run", "~/project", "task"}, 2, "/code ~/project task", true},
+		{[]string{"/code", "--model=opus", "~/project", "--dry-run", "task"}, 2, "/code --model=opus ~/project task", true},
+		{[]string{"/code", "~/project", "add", "a", "--dry-run", "flag"}, 2, "/code ~/project add a --dry-run flag", false},
+		{[]string{"/commit", "~/project", "--dry-run"}, 1, "/commit ~/project --dry-run", false},
+		{[]string{"/pr", "~/project", "https://example.com/pull/1", "--dry-run"}, 3, "/pr ~/project https://example.com/pull/1", true},
+	}
+
+	for _, tt := range tests {
+		parts, dryRun := extractDryRunFlag(tt.parts, tt.fixedArgs)
+		if got := strings.Join(parts, " "); got != tt.expected || dryRun != tt.dryRun {
+			t.Errorf("extractDryRunFlag(%v, %d): expected %q dry-run=%v, got %q dry-run=%v", tt.parts, tt.fixedArgs, tt.expected, tt.dryRun, got, dryRun)
+		}
+	}
+}
+
+func TestParseFindArgs(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	query, opts, err := parseFindArgs([]string{"--status=failed", "--since=7d", "auth.go", "--since=1h"}, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if query != "auth.go --since=1h" {
+		t.Errorf("Expected options after the query to stay in the query, got %q", query)
+	}
+	if opts.Status != codeagent.StatusFailed {
+		t.Errorf("Expected status failed, got %s", opts.Status)
+	}
+	if expected := now.Add(-7 * 24 * time.Hour); !opts.StartTime.Equal(expected) {
+		t.Errorf("Expected start time %v, got %v", expected, opts.StartTime)
+	}
+
+	if _, _, err := parseFindArgs([]string{"--since=soon", "x"}, now); err == nil {
+		t.Error("Expected error for invalid --since value")
+	}
+	if _, _, err := parseFindArgs([]string{"--colour=red", "x"}, now); err == nil {
+		t.Error("Expected error for unknown option")
+	}
+}
+
+func TestParseAgentStatus(t *testing.T) {
+	if status, ok := parseAgentStatus("Failed"); !ok || status != codeagent.StatusFailed {
+		t.Errorf("Expected failed, got %q (ok %v)", status, ok)
+	}
+	if _, ok := parseAgentStatus("review"); ok {
+		t.Error("Expected review not to be a status")
+	}
+}
+
+func TestFormatAgentListDurations(t *testing.T) {
+	now := time.Now()
+	agents := []codeagent.AgentInfo{
+		{ID: "1", Status: codeagent.StatusRunning, StartTime: now.Add(-time.Minute), Duration: 90 * time.Second},
+		{ID: "2", Status: codeagent.StatusFinished, StartTime: now.Add(-time.Hour), EndTime: now, Duration: time.Hour},
+	}
+	message := formatAgentList(agents)
+	for _, expected := range []string{"Running for 1m30s", "Took 1h0m0s"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected %q in %s", expected, message)
+		}
+	}
+}
+
+func TestFormatQueuedTasksHolders(t *testing.T) {
+	queued := map[string]codeagent.FolderQueue{
+		"/repo": {RunningAgentID: "3", Tasks: []codeagent.QueuedTask{{Prompt: "next", QueueID: "q1"}}},
+		"/app":  {RunningAgentID: "5", Tasks: []codeagent.QueuedTask{{Prompt: "later", QueueID: "q2"}}},
+	}
+	holders := map[string]codeagent.AgentInfo{"/repo": {ID: "3", Duration: 4 * time.Minute}}
+
+	message := formatQueuedTasks(queued, holders)
+	if !strings.Contains(message, "🔒 held by `3` (running 4m0s)") {
+		t.Errorf("Expected holder with runtime, got %s", message)
+	}
+	if !strings.Contains(message, "🔒 held by `5`\n") {
+		t.Errorf("Expected holder without runtime, got %s", message)
+	}
+	if strings.Index(message, "/app") > strings.Index(message, "/repo") {
+		t.Errorf("Expected folders sorted, got %s", message)
+	}
+}
+
+func TestFormatQueuedTasksWaitingForSlot(t *testing.T) {
+	queued := map[string]codeagent.FolderQueue{
+		"/repo": {WaitingForSlot: []codeagent.QueuedTask{{Prompt: "blocked", QueueID: "q1"}}},
+	}
+
+	message := formatQueuedTasks(queued, nil)
+	if !strings.Contains(message, "*/repo* (1 tasks)") {
+		t.Errorf("Expected the waiting task to be counted, got %s", message)
+	}
+	if !strings.Contains(message, "blocked (waiting for a free agent slot)") {
+		t.Errorf("Expected the task marked as waiting for a slot, got %s", message)
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	const userID = 42
+	t.Cleanup(func() { takeConfirmation(userID) })
+
+	if _, ok, expired := takeConfirmation(userID); ok || expired {
+		t.Error("Expected no pending confirmation")
+	}
+
+	ran := false
+	requestConfirmation(userID, "kill all agents", func(ctx context.Context, chatID int64) { ran = true })
+	confirmation, ok, _ := takeConfirmation(userID)
+	if !ok || confirmation.Action != "kill all agents" {
+		t.Fatalf("Expected pending kill all agents, got %+v %v", confirmation, ok)
+	}
+	confirmation.Run(context.Background(), userID)
+	if !ran {
+		t.Error("Expected the confirmed action to run")
+	}
+	if _, ok, _ := takeConfirmation(userID); ok {
+		t.Error("Expected the confirmation to be used only once")
+	}
+
+	requestConfirmation(userID, "kill all agents", func(ctx context.Context, chatID int64) {})
+	confirmationsMutex.Lock()
+	confirmation = pendingConfirmations[userID]
+	confirmation.Expires = time.Now().Add(-time.Second)
+	pendingConfirmations[userID] = confirmation
+	confirmationsMutex.Unlock()
+	if _, ok, expired := takeConfirmation(userID); ok || !expired {
+		t.Errorf("Expected an expired confirmation, got ok %v expired %v", ok, expired)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"mavis/core"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// tokenCostPerMillion is the dollar cost of one million tokens used for /cost estimates (0 disables)
+var tokenCostPerMillion float64
+
+// SetTokenCostRate sets the dollar cost per million tokens used to estimate costs in /cost
+func SetTokenCostRate(perMillion float64) {
+	tokenCostPerMillion = perMillion
+}
+
+func handleCostCommand(ctx context.Context, message *models.Message) {
+	core.SendMessage(ctx, b, message.Chat.ID, formatCostReport(agentManager.TotalTokensUsed(), agentManager.AgentsWithoutTokenData(), agentManager.GetDailyTokenUsage(), tokenCostPerMillion))
+}
+
+// formatCostReport formats the /cost summary. unknown counts agents without
+// token data per folder; they are reported separately, not as zero tokens.
+func formatCostReport(totals map[string]int, unknown map[string]int, today int, perMillion float64) string {
+	folders := make(map[string]bool)
+	total := 0
+	for folder, tokens := range totals {
+		folders[folder] = true
+		total += tokens
+	}
+	unknownAgents := 0
+	for folder, count := range unknown {
+		folders[folder] = true
+		unknownAgents += count
+	}
+
+	if len(folders) == 0 {
+		return fmt.Sprintf("💰 No tracked agents.\n📅 Today: %s tokens", formatTokenCount(today))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💰 *Token Usage*\n\n")
+	sb.WriteString(fmt.Sprintf("🔢 Tracked agents: %s tokens\n", formatTokenCount(total)))
+	sb.WriteString(fmt.Sprintf("📅 Today: %s tokens\n", formatTokenCount(today)))
+	if perMillion > 0 {
+		sb.WriteString(fmt.Sprintf("💵 Estimated cost: $%.2f tracked, $%.2f today (at $%.2f per million tokens)\n",
+			float64(total)*perMillion/1e6, float64(today)*perMillion/1e6, perMillion))
+	}
+
+	names := make([]string, 0, len(folders))
+	for folder := range folders {
+		names = append(names, folder)
+	}
+	sort.Strings(names)
+
+	sb.WriteString("\n📁 *By folder:*\n")
+	for _, folder := range names {
+		line := fmt.Sprintf("• %s: %s tokens", folder, formatTokenCount(totals[folder]))
+		if _, ok := totals[folder]; !ok {
+			line = fmt.Sprintf("• %s: unknown", folder)
+		} else if unknown[folder] > 0 {
+			line += " + unknown"
+		}
+		if count := unknown[folder]; count > 0 {
+			line += fmt.Sprintf(" (%d agent(s) without token data)", count)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	if unknownAgents > 0 {
+		sb.WriteString(fmt.Sprintf("\n❓ %d agent(s) did not run in structured output mode, so their usage is unknown. Set a token budget to track all agents.", unknownAgents))
+	}
+	return sb.String()
+}
+
+// formatTokenCount formats n with thousands separators
+func formatTokenCount(n int) string {
+	s := fmt.Sprintf("%d", n)
+	if n < 0 {
+		return s
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
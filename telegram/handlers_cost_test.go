@@ -0,0 +1,43 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatCostReport(t *testing.T) {
+	totals := map[string]int{"/repo/a": 1500000, "/repo/b": 0}
+	unknown := map[string]int{"/repo/b": 1, "/repo/c": 2}
+
+	report := formatCostReport(totals, unknown, 2000000, 3)
+
+	for _, expected := range []string{
+		"Tracked agents: 1,500,000 tokens",
+		"Today: 2,000,000 tokens",
+		"$4.50 tracked, $6.00 today",
+		"• /repo/a: 1,500,000 tokens\n",
+		"• /repo/b: 0 tokens + unknown (1 agent(s) without token data)",
+		"• /repo/c: unknown (2 agent(s) without token data)",
+		"3 agent(s) did not run in structured output mode",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("Expected report to contain %q, got:\n%s", expected, report)
+		}
+	}
+
+	if report := formatCostReport(totals, unknown, 0, 0); strings.Contains(report, "Estimated cost") {
+		t.Errorf("Expected no cost estimate without a rate, got:\n%s", report)
+	}
+}
+
+func TestFormatTokenCount(t *testing.T) {
+	cases := map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567"}
+	for n, expected := range cases {
+		if got := formatTokenCount(n); got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
@@ -5,9 +5,11 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"mavis/core"
@@ -15,6 +17,9 @@ import (
 	"github.com/go-telegram/bot/models"
 )
 
+// maxDownloadSize is the largest file the standard Bot API lets bots send
+const maxDownloadSize = 50 * 1024 * 1024 // 50MB
+
 func handleDownloadCommand(ctx context.Context, message *models.Message) {
 	parts := strings.Fields(message.Text)
 	if len(parts) < 2 {
@@ -50,8 +55,7 @@ func handleDownloadCommand(ctx context.Context, message *models.Message) {
 	}
 
 	// Check file size (Telegram has a 50MB limit for bots)
-	const maxFileSize = 50 * 1024 * 1024 // 50MB
-	if info.Size() > maxFileSize {
+	if info.Size() > maxDownloadSize {
 		// Inform user about the file size limitation
 		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ File too large: %s (%.2f MB)\n\n📋 *File Size Limitations:*\n• Standard Bot API: 50MB maximum\n• Self-hosted Bot API Server: 2GB maximum\n\n💡 *Solution:* To send files up to 2GB, you need to set up a self-hosted Telegram Bot API server.\nLearn more: https://github.com/tdlib/telegram-bot-api",
 			info.Name(), float64(info.Size())/(1024*1024)))
@@ -69,6 +73,71 @@ func handleDownloadCommand(ctx context.Context, message *models.Message) {
 	}
 }
 
+func handleDownloadZipCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /download_zip <directory>\n\nExample: /download_zip ~/myproject/dist")
+		return
+	}
+
+	// Join all parts after the command in case the path has spaces
+	path := strings.Join(parts[1:], " ")
+
+	absPath, err := core.ResolvePath(path)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Error resolving path: %v", err))
+		return
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Directory not found: %s", absPath))
+		return
+	}
+	if !info.IsDir() {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Path is not a directory: %s\n\nUse `/download %s` for a single file.", absPath, path))
+		return
+	}
+
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🗜️ Zipping %s...", absPath))
+
+	// Each request gets its own temp directory so concurrent downloads never collide
+	tempDir, err := os.MkdirTemp("", "mavis-zip-*")
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to create temp directory: %v", err))
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	zipPath := filepath.Join(tempDir, filepath.Base(absPath)+".zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to create archive: %v", err))
+		return
+	}
+	stats, err := core.ZipDirectory(absPath, zipFile, maxDownloadSize)
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, core.ErrZipTooLarge) {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Archive of %s is larger than the 50MB bot limit.\n\n💡 Zip a smaller subdirectory, or clean build artifacts first.", absPath))
+		return
+	}
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to create archive: %v", err))
+		return
+	}
+
+	caption := fmt.Sprintf("📦 *Sending archive:* `%s`\n📏 *Size:* %.2f MB\n📄 *Files:* %d\n📍 *Path:* `%s`",
+		filepath.Base(zipPath), float64(stats.Size)/(1024*1024), stats.Files, path)
+	if stats.Skipped > 0 {
+		caption += fmt.Sprintf("\n⏭️ *Skipped:* %d (symlinks, sockets or unreadable files)", stats.Skipped)
+	}
+
+	if err := core.SendFile(ctx, b, message.Chat.ID, zipPath, caption); err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to send file: %v", err))
+	}
+}
+
 func handleLsCommand(ctx context.Context, message *models.Message) {
 	parts := strings.Fields(message.Text)
 	path := "~" // Default to home directory
@@ -250,25 +319,28 @@ func handleRunCommand(ctx context.Context, message *models.Message) {
 		responseMsg.WriteString("✅ *Command completed successfully*\n\n")
 	}
 
-	// Add output if any
+	// Add output if any, keeping the tail inline and saving the rest to a file
+	var fullOutputNote string
 	if len(output) > 0 {
 		responseMsg.WriteString("📄 *Output:*\n```\n")
-		outputStr := string(output)
-		// Truncate output if too long
-		if len(outputStr) > 3000 {
-			outputStr = outputStr[:2997] + "..."
-		}
+		outputStr, fullPath, err := core.TruncateOutput(string(output), 3000, "run-"+filepath.Base(command))
 		responseMsg.WriteString(outputStr)
 		responseMsg.WriteString("\n```")
+		if fullPath != "" {
+			fullOutputNote = "\n" + core.FullOutputNote(fullPath)
+		} else if err != nil {
+			fullOutputNote = fmt.Sprintf("\n⚠️ Output truncated: %v", err)
+		}
 	} else {
 		responseMsg.WriteString("ℹ️ *No output produced*")
 	}
 
-	// Send the response
+	// Send the response, never cutting off the full output note
 	response := responseMsg.String()
-	if len(response) > 4000 {
-		response = response[:3997] + "..."
+	if len(response) > 4000-len(fullOutputNote) {
+		response = response[:3997-len(fullOutputNote)] + "..."
 	}
+	response += fullOutputNote
 
 	core.SendMessage(ctx, b, message.Chat.ID, response)
 }
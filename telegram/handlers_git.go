@@ -5,13 +5,16 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"mavis/codeagent"
 	"mavis/core"
 
 	"github.com/go-telegram/bot/models"
@@ -24,9 +27,9 @@ func generateUniquePlanFilename(commandType string) string {
 }
 
 func handleGitCodeCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, dryRun := extractDryRunFlag(strings.Fields(message.Text), 2)
 	if len(parts) < 3 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide directory and task.\nUsage: /new_branch <directory> <task>\n\nExample: /new_branch ~/myproject implement new feature")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide directory and task.\nUsage: /new_branch [--dry-run] <directory> <task>\n\nExample: /new_branch ~/myproject implement new feature")
 		return
 	}
 
@@ -38,84 +41,218 @@ func handleGitCodeCommand(ctx context.Context, message *models.Message) {
 		return
 	}
 
-	launchGitCodeAgent(ctx, directory, task)
+	launchGitCodeAgent(ctx, directory, task, dryRun)
 }
 
-func launchGitCodeAgent(ctx context.Context, directory, task string) {
+func launchGitCodeAgent(ctx context.Context, directory, task string, dryRun bool) {
 	chatID := AdminUserID
-	// Resolve the directory path relative to home directory
-	absDir, err := core.ResolvePath(directory)
+	absDir, err := core.ValidateGitRepo(directory)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Error resolving directory path: %v", err))
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
 		return
 	}
 
-	// Check if directory exists
-	info, err := os.Stat(absDir)
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, core.NewBranchPrompt("", task), "")
+		return
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔍 Checking git repository status in %s...", absDir))
+
+	// Check out a worktree, or copy the repository if it has uncommitted changes
+	core.SendMessage(ctx, b, chatID, "📋 Preparing temporary workspace...")
+
+	workspace, err := core.PrepareGitAgentWorkspace(absDir, "", task)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Directory not found: %s", absDir))
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to prepare workspace: %v", err))
 		return
 	}
-	if !info.IsDir() {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Path is not a directory: %s", absDir))
+	tempDir := workspace.Dir
+	if workspace.Warning != "" {
+		core.SendMessage(ctx, b, chatID, "⚠️ "+workspace.Warning)
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching git-aware code agent in temporary workspace...\n📁 Original: %s\n📁 Workspace: %s", absDir, tempDir))
+
+	// Launch the agent with the git-specific prompt
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, tempDir, workspace.Prompt, cleanupWorkspaceOnCompletion(agentKind("branch"), workspace.Workspace))
+	if err != nil {
+		workspace.Cleanup()
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
+		return
+	}
+	if strings.HasPrefix(agentID, "queued-") {
+		sendAgentQueued(ctx, chatID, agentID, "Git-aware code agent", tempDir, task)
 		return
 	}
 
-	// Check if it's a git repository
-	gitDir := filepath.Join(absDir, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Directory is not a git repository: %s", absDir))
+	// Register the agent for this user to receive notifications
+	RegisterAgentForUser(agentID, AdminUserID)
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Git-aware code agent launched!\n🆔 ID: `%s`\n📝 Task: %s\n📁 Original Directory: %s\n📁 Working Directory: %s\n🌿 The agent will create a new branch and attempt to push changes\n\nUse `/status %s` to check status.",
+		agentID, task, directory, tempDir, agentID))
+}
+
+// maxBranchesListed caps the local and remote branches listed by /branches
+const maxBranchesListed = 40
+
+func handleBranchesCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /branches <directory>\n\nExample: /branches ~/myproject")
 		return
 	}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔍 Checking git repository status in %s...", absDir))
+	absDir, err := core.ValidateGitRepo(strings.Join(parts[1:], " "))
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
 
-	// Create a temp directory
-	tempDir, err := os.MkdirTemp("", "git-agent-*")
+	branches, err := core.ListGitBranches(absDir)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to create temp directory: %v", err))
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
 		return
 	}
+	core.SendLongMessage(ctx, b, message.Chat.ID, formatBranches(absDir, branches))
+}
 
-	// Copy the repository to temp directory
-	core.SendMessage(ctx, b, chatID, "📋 Copying repository to temporary workspace...")
+// formatBranches formats /branches output, marking the current branch
+func formatBranches(dir string, branches *core.GitBranches) string {
+	message := fmt.Sprintf("🌿 *Branches in %s*\n", dir)
+	message += formatBranchSection("Local", branches.Local, branches.Current, branches.Tracking)
+	message += formatBranchSection("Remote", branches.Remote, "", nil)
+	return message
+}
 
-	// Use rsync to copy the directory, excluding .git if needed
-	cmd := exec.Command("rsync", "-av", "--exclude=node_modules", "--exclude=.DS_Store", absDir+"/", tempDir+"/")
-	output, err := cmd.CombinedOutput()
+// formatBranchSection lists up to maxBranchesListed branches under a heading,
+// with how far each is ahead of and behind its upstream when tracking has it
+func formatBranchSection(title string, names []string, current string, tracking map[string]core.BranchTracking) string {
+	section := fmt.Sprintf("\n*%s (%d):*\n", title, len(names))
+	if len(names) == 0 {
+		return section + "   none\n"
+	}
+	for i, name := range names {
+		if i == maxBranchesListed {
+			section += fmt.Sprintf("   ... and %d more\n", len(names)-maxBranchesListed)
+			break
+		}
+		status := ""
+		if t, ok := tracking[name]; ok {
+			status = " " + formatBranchTracking(t)
+		}
+		if name == current {
+			section += fmt.Sprintf("👉 `%s` (current)%s\n", name, status)
+		} else {
+			section += fmt.Sprintf("   `%s`%s\n", name, status)
+		}
+	}
+	return section
+}
+
+// formatBranchTracking describes a branch's position relative to its upstream
+func formatBranchTracking(t core.BranchTracking) string {
+	switch {
+	case t.Gone:
+		return fmt.Sprintf("· %s gone", t.Upstream)
+	case t.Ahead == 0 && t.Behind == 0:
+		return fmt.Sprintf("· up to date with %s", t.Upstream)
+	default:
+		return fmt.Sprintf("· ↑%d ↓%d %s", t.Ahead, t.Behind, t.Upstream)
+	}
+}
+
+func handleCloneCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a git URL.\nUsage:\n• `/clone <git_url> [target_dir]` - Clone into your home directory\n• `/clone <git_url> <task>` - Clone and launch an agent (task of two or more words)\n\nExamples:\n• `/clone https://github.com/owner/repo.git ~/projects/repo`\n• `/clone https://github.com/owner/repo.git fix the failing tests`")
+		return
+	}
+
+	url := strings.TrimSpace(parts[1])
+
+	// A URL alone or followed by a single word is a plain clone, longer text is a task
+	if len(parts) <= 3 {
+		target := ""
+		if len(parts) == 3 {
+			target = parts[2]
+		}
+		cloneIntoHome(ctx, message.Chat.ID, url, target)
+		return
+	}
+
+	task := strings.TrimSpace(strings.Join(parts[2:], " "))
+
+	if !core.IsGitURL(url) {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Not a git URL: %s\n\nUse an https or ssh URL, e.g. https://github.com/owner/repo.git or git@github.com:owner/repo.git", url))
+		return
+	}
+
+	launchCloneAgent(ctx, url, task)
+}
+
+// cloneIntoHome clones url into target under the home directory, reporting
+// each phase of git's progress
+func cloneIntoHome(ctx context.Context, chatID int64, url, target string) {
+	if !core.IsRemoteGitURL(url) {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Not a git URL: %s\n\nUse an https or ssh URL, e.g. https://github.com/owner/repo.git or git@github.com:owner/repo.git", url))
+		return
+	}
+	dir, err := core.ResolveCloneTarget(url, target)
 	if err != nil {
-		os.RemoveAll(tempDir)
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to copy repository: %v\nOutput: %s", err, string(output)))
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
 		return
 	}
 
-	// Prepare the git-specific prompt
-	gitPrompt := fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
-You are working on a git repository. You MUST follow these steps:
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("📥 Cloning %s into %s...", url, dir))
 
-1. First, create a new branch for your changes using: git checkout -b feature/<descriptive-name>
-2. Make all the necessary changes to complete the task: %s
-3. Stage and commit your changes with a descriptive commit message
-   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
-   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
-   - Or stage files individually, explicitly excluding *_PLAN_*.md files
-4. Try to push the branch to the remote repository using: git push -u origin <branch-name>
-5. If the push fails due to authentication or permissions, that's okay - just report the status
+	lastPhase := ""
+	err = core.CloneInto(url, dir, func(line string) {
+		// Only the first line of each phase ("Receiving objects", "Resolving deltas"...)
+		phase, _, found := strings.Cut(line, ":")
+		if !found || phase == lastPhase {
+			return
+		}
+		lastPhase = phase
+		core.SendMessage(ctx, b, chatID, "⏳ "+phase+"...")
+	})
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
 
-Remember:
-- Always work on a new branch, never directly on main/master
-- Make atomic, well-described commits
-- Include a clear commit message explaining what was changed and why
-- NEVER commit *_PLAN_*.md files - they're for your planning only
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Cloned %s\n📁 %s\n\nStart working with `/code %s <task>`", url, dir, dir))
+}
 
-Task: %s`, task, task)
+func launchCloneAgent(ctx context.Context, url, task string) {
+	chatID := AdminUserID
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching git-aware code agent in temporary workspace...\n📁 Original: %s\n📁 Workspace: %s", absDir, tempDir))
+	// Updating an existing clone resets it, so never do that under a running agent
+	dir, err := core.CloneDir(url)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if running, agentID := agentManager.IsAgentRunningInFolder(dir); running {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("⚠️ Agent `%s` is still working in %s.\n\nWait for it to finish or stop it with `/stop %s`, then try again.", agentID, dir, agentID))
+		return
+	}
 
-	// Launch the agent with the git-specific prompt
-	agentID, err := agentManager.LaunchAgent(ctx, tempDir, gitPrompt)
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("📥 Cloning %s...", url))
+
+	repo, err := core.CloneRepository(url)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to clone repository: %v", err))
+		return
+	}
+
+	cloneStatus := "cloned"
+	if repo.Reused {
+		cloneStatus = "updated existing clone"
+	}
+
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, repo.Dir, core.NewBranchPrompt("", task), agentKind("clone"))
 	if err != nil {
-		os.RemoveAll(tempDir)
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
 		return
 	}
@@ -123,8 +260,42 @@ Task: %s`, task, task)
 	// Register the agent for this user to receive notifications
 	RegisterAgentForUser(agentID, AdminUserID)
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Git-aware code agent launched!\n🆔 ID: `%s`\n📝 Task: %s\n📁 Original Directory: %s\n📁 Working Directory: %s\n🌿 The agent will create a new branch and attempt to push changes\n\nUse `/status %s` to check status.",
-		agentID, task, directory, tempDir, agentID))
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Git-aware code agent launched on cloned repository!\n🆔 ID: `%s`\n📝 Task: %s\n🔗 Repository: %s (%s)\n🌿 Default branch: %s\n📁 Workspace: %s\n🌿 The agent will create a new branch and attempt to push changes\n\nUse `/status %s` to check status.",
+		agentID, task, url, cloneStatus, repo.DefaultBranch, repo.Dir, agentID))
+}
+
+// cleanupWorkspaceOnCompletion adds to opts the removal of a workspace once
+// its agent finishes, also when the launch waits in a queue first. Commits
+// made in a worktree stay in the original repository.
+func cleanupWorkspaceOnCompletion(opts codeagent.AgentOptions, workspace *core.Workspace) codeagent.AgentOptions {
+	opts.OnCreate = func(agent *codeagent.Agent) {
+		agent.AddCompletionCallback(func(a *codeagent.Agent) {
+			if err := workspace.Cleanup(); err != nil {
+				log.Printf("[Worktree] Failed to clean up workspace for agent %s: %v", a.ID, err)
+			}
+		})
+	}
+	return opts
+}
+
+// sendAgentQueued tells chatID that an agent waits in a queue, and tracks it
+// so the start callback notifies the user when it starts
+func sendAgentQueued(ctx context.Context, chatID int64, placeholder, title, dir, task string) {
+	if _, queueID, found := strings.Cut(placeholder, "-qid-"); found {
+		core.GetQueueTracker().RegisterQueuedAgent(queueID, AdminUserID, dir, task)
+	}
+	if codeagent.IsWaitingForSlot(placeholder) {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏳ %s queued!\n📝 Task: %s\n📁 Directory: %s\n\n%d agents are already running, the most allowed at once. The agent will start automatically when one of them completes.",
+			title, task, dir, agentManager.MaxConcurrentAgents()))
+		return
+	}
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏳ %s queued!\n📝 Task: %s\n📁 Directory: %s\n\nThe agent will start automatically when the current agent in this folder completes.",
+		title, task, dir))
+}
+
+// agentKind returns launch options that label an agent with its kind, for /ps filtering
+func agentKind(kind string) codeagent.AgentOptions {
+	return codeagent.AgentOptions{Labels: map[string]string{"kind": kind}}
 }
 
 func handleGitBranchCommand(ctx context.Context, message *models.Message) {
@@ -148,129 +319,176 @@ func handleGitBranchCommand(ctx context.Context, message *models.Message) {
 
 func launchGitBranchAgent(ctx context.Context, directory, branch, task string) {
 	chatID := AdminUserID
-	// Resolve the directory path relative to home directory
-	absDir, err := core.ResolvePath(directory)
+	absDir, err := core.ValidateGitRepo(directory)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Error resolving directory path: %v", err))
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
 		return
 	}
 
-	// Check if directory exists
-	info, err := os.Stat(absDir)
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔍 Checking git repository and branch status in %s...", absDir))
+
+	// /new_branch creates branches, this command only continues existing ones
+	if exists, _ := core.BranchExists(absDir, branch); !exists {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Branch '%s' does not exist locally or remotely.\n\n💡 Use `/new_branch` to create a new branch, or check the branch name and try again.", branch))
+		return
+	}
+
+	// Check out a worktree, or copy the repository if it has uncommitted changes
+	core.SendMessage(ctx, b, chatID, "📋 Preparing temporary workspace...")
+
+	workspace, err := core.PrepareGitAgentWorkspace(absDir, branch, task)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Directory not found: %s", absDir))
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to prepare workspace: %v", err))
 		return
 	}
-	if !info.IsDir() {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Path is not a directory: %s", absDir))
+	tempDir := workspace.Dir
+	if workspace.Warning != "" {
+		core.SendMessage(ctx, b, chatID, "⚠️ "+workspace.Warning)
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching git-aware code agent for existing branch...\n📁 Original: %s\n📁 Workspace: %s\n🌿 Branch: %s", absDir, tempDir, branch))
+
+	// Launch the agent with the git branch-specific prompt
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, tempDir, workspace.Prompt, cleanupWorkspaceOnCompletion(agentKind("branch"), workspace.Workspace))
+	if err != nil {
+		workspace.Cleanup()
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
+		return
+	}
+	if strings.HasPrefix(agentID, "queued-") {
+		sendAgentQueued(ctx, chatID, agentID, "Git-aware code agent", tempDir, task)
 		return
 	}
 
-	// Check if it's a git repository
-	gitDir := filepath.Join(absDir, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Directory is not a git repository: %s", absDir))
+	// Register the agent for this user to receive notifications
+	RegisterAgentForUser(agentID, AdminUserID)
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Git-aware code agent launched for existing branch!\n🆔 ID: `%s`\n📝 Task: %s\n🌿 Branch: %s\n📁 Original Directory: %s\n📁 Working Directory: %s\n\nThe agent will work on the existing branch and attempt to push changes.\n\nUse `/status %s` to check status.",
+		agentID, task, branch, directory, tempDir, agentID))
+}
+
+func handleCommitCommand(ctx context.Context, message *models.Message) {
+	parts, dryRun := extractDryRunFlag(strings.Fields(message.Text), 1)
+	parts, messageOnly := extractMessageOnlyFlag(parts)
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /commit [--dry-run] <directory> [--message-only]\n\nExample: /commit ~/myproject")
 		return
 	}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔍 Checking git repository and branch status in %s...", absDir))
+	directory := strings.TrimSpace(strings.Join(parts[1:], " "))
 
-	// Check if the branch exists
-	checkBranchCmd := exec.Command("git", "branch", "--list", branch)
-	checkBranchCmd.Dir = absDir
-	branchOutput, _ := checkBranchCmd.CombinedOutput()
+	if directory == "" {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Directory is required.\n\nExample: /commit ~/myproject")
+		return
+	}
 
-	// Check remote branches too
-	checkRemoteBranchCmd := exec.Command("git", "branch", "-r", "--list", fmt.Sprintf("origin/%s", branch))
-	checkRemoteBranchCmd.Dir = absDir
-	remoteBranchOutput, _ := checkRemoteBranchCmd.CombinedOutput()
+	launchCommitAgent(ctx, directory, dryRun, messageOnly)
+}
 
-	branchExists := len(strings.TrimSpace(string(branchOutput))) > 0 || len(strings.TrimSpace(string(remoteBranchOutput))) > 0
+// extractMessageOnlyFlag removes --message-only from the /commit arguments
+func extractMessageOnlyFlag(parts []string) ([]string, bool) {
+	rest := make([]string, 0, len(parts))
+	messageOnly := false
+	for _, part := range parts {
+		if part == "--message-only" {
+			messageOnly = true
+			continue
+		}
+		rest = append(rest, part)
+	}
+	return rest, messageOnly
+}
 
-	if !branchExists {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Branch '%s' does not exist locally or remotely.\n\n💡 Use `/new_branch` to create a new branch, or check the branch name and try again.", branch))
+func handleMergeCommand(ctx context.Context, message *models.Message) {
+	parts, dryRun := extractDryRunFlag(strings.Fields(message.Text), 2)
+	if len(parts) != 3 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide directory and source branch.\nUsage: /merge [--dry-run] <directory> <source_branch>\n\nExample: /merge ~/myproject feature/add-auth")
 		return
 	}
+	directory, source := parts[1], parts[2]
 
-	// Create a temp directory
-	tempDir, err := os.MkdirTemp("", "git-branch-agent-*")
+	absDir, err := core.ValidateGitRepo(directory)
 	if err != nil {
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to create temp directory: %v", err))
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if exists, _ := core.BranchExists(absDir, source); !exists {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Branch '%s' does not exist locally or remotely.\n\n💡 Use `/branches %s` to list the available branches.", source, directory))
 		return
 	}
 
-	// Copy the repository to temp directory
-	core.SendMessage(ctx, b, chatID, "📋 Copying repository to temporary workspace...")
+	mergePrompt := core.MergeBranchPrompt(source)
+	if dryRun {
+		sendDryRun(ctx, message.Chat.ID, absDir, mergePrompt, "")
+		return
+	}
 
-	// Use rsync to copy the directory, excluding .git if needed
-	cmd := exec.Command("rsync", "-av", "--exclude=node_modules", "--exclude=.DS_Store", absDir+"/", tempDir+"/")
-	output, err := cmd.CombinedOutput()
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, absDir, mergePrompt, agentKind("merge"))
 	if err != nil {
-		os.RemoveAll(tempDir)
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to copy repository: %v\nOutput: %s", err, string(output)))
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
 		return
 	}
 
-	// Prepare the git-specific prompt for existing branch
-	gitBranchPrompt := fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
-You are working on a git repository with an existing branch. You MUST follow these steps:
-
-1. First, fetch the latest changes: git fetch origin
-2. Checkout the existing branch '%s' using: git checkout %s
-3. If the branch exists only on remote, use: git checkout -b %s origin/%s
-4. Pull the latest changes from the remote branch: git pull origin %s
-5. Make all the necessary changes to complete the task: %s
-6. Stage and commit your changes with a descriptive commit message
-   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
-   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
-   - Or stage files individually, explicitly excluding *_PLAN_*.md files
-7. Try to push the changes to the remote repository using: git push origin %s
-8. If the push fails due to authentication or permissions, that's okay - just report the status
-
-Remember:
-- You are working on the existing branch '%s'
-- Make atomic, well-described commits
-- Include a clear commit message explaining what was changed and why
-- Ensure you're up to date with the remote branch before making changes
-- NEVER commit *_PLAN_*.md files - they're for your planning only
+	// Register the agent for this user to receive notifications
+	RegisterAgentForUser(agentID, AdminUserID)
 
-Task: %s`, branch, branch, branch, branch, branch, task, branch, branch, task)
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ Merge agent launched!\n🆔 ID: `%s`\n🌿 Merging: %s\n📁 Directory: %s\n\nThe agent will merge the branch into the current one, resolve conflicts where it safely can and push without force.\n\nUse `/status %s` to check status.",
+		agentID, source, directory, agentID))
+}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching git-aware code agent for existing branch...\n📁 Original: %s\n📁 Workspace: %s\n🌿 Branch: %s", absDir, tempDir, branch))
+func handleStashCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+	if len(parts) < 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /stash <directory> [message]\n\nExample: /stash ~/myproject half-done refactor")
+		return
+	}
 
-	// Launch the agent with the git branch-specific prompt
-	agentID, err := agentManager.LaunchAgent(ctx, tempDir, gitBranchPrompt)
+	absDir, err := core.ValidateGitRepo(parts[1])
 	if err != nil {
-		os.RemoveAll(tempDir)
-		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
 		return
 	}
 
-	// Register the agent for this user to receive notifications
-	RegisterAgentForUser(agentID, AdminUserID)
-
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Git-aware code agent launched for existing branch!\n🆔 ID: `%s`\n📝 Task: %s\n🌿 Branch: %s\n📁 Original Directory: %s\n📁 Working Directory: %s\n\nThe agent will work on the existing branch and attempt to push changes.\n\nUse `/status %s` to check status.",
-		agentID, task, branch, directory, tempDir, agentID))
+	output, err := core.GitStash(absDir, strings.Join(parts[2:], " "))
+	if errors.Is(err, core.ErrNothingToStash) {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("ℹ️ No local changes to stash in %s", absDir))
+		return
+	}
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("📦 Stashed changes in %s\n```\n%s\n```\nUse `/stash_pop %s` to restore them.", absDir, output, parts[1]))
 }
 
-func handleCommitCommand(ctx context.Context, message *models.Message) {
+func handleStashPopCommand(ctx context.Context, message *models.Message) {
 	parts := strings.Fields(message.Text)
 	if len(parts) < 2 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /commit <directory>\n\nExample: /commit ~/myproject")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory.\nUsage: /stash_pop <directory>\n\nExample: /stash_pop ~/myproject")
 		return
 	}
 
-	directory := strings.TrimSpace(strings.Join(parts[1:], " "))
-
-	if directory == "" {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Directory is required.\n\nExample: /commit ~/myproject")
+	absDir, err := core.ValidateGitRepo(strings.Join(parts[1:], " "))
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
 		return
 	}
 
-	launchCommitAgent(ctx, directory)
+	output, err := core.GitStashPop(absDir)
+	var conflict *core.StashConflictError
+	switch {
+	case errors.Is(err, core.ErrNoStash):
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("ℹ️ No stashed changes in %s", absDir))
+	case errors.As(err, &conflict):
+		core.SendLongMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ Stash applied with conflicts in %s. The stash was kept; resolve the conflicts, then drop it with `/run %s git stash drop`.\n```\n%s\n```", absDir, absDir, conflict.Output))
+	case err != nil:
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+	default:
+		core.SendLongMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ Restored stashed changes in %s\n```\n%s\n```", absDir, output))
+	}
 }
 
-func launchCommitAgent(ctx context.Context, directory string) {
+func launchCommitAgent(ctx context.Context, directory string, dryRun, messageOnly bool) {
 	chatID := AdminUserID
 	// Resolve the directory path relative to home directory
 	absDir, err := core.ResolvePath(directory)
@@ -320,23 +538,104 @@ Remember:
 - If there are no changes to commit, report that clearly
 
 Your task: Review the changes, commit them with an appropriate message, and push to remote.`
+	kind, title, summary := "commit", "Commit agent", "Commit and push the uncommitted changes"
+	if messageOnly {
+		commitPrompt = commitMessagePrompt
+		kind, title, summary = "commit-message", "Commit message agent", "Suggest a commit message for the staged changes"
+	}
+
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, commitPrompt, "")
+		return
+	}
+
+	if messageOnly {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching Claude Code to suggest a commit message...\n📁 Directory: %s", absDir))
+	} else {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching Claude Code to commit changes...\n📁 Directory: %s", absDir))
+	}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching Claude Code to commit changes...\n📁 Directory: %s", absDir))
+	opts := agentKind(kind)
+	if !messageOnly {
+		opts = verifyPushOnCompletion(opts, absDir, chatID)
+	}
 
 	// Launch the agent with the commit-specific prompt
-	agentID, err := agentManager.LaunchAgent(ctx, absDir, commitPrompt)
+	agentID, err := agentManager.LaunchAgentWithOptions(ctx, absDir, commitPrompt, opts)
 	if err != nil {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
 		return
 	}
 
+	if strings.HasPrefix(agentID, "queued-") {
+		sendAgentQueued(ctx, chatID, agentID, title, absDir, summary)
+		return
+	}
+
 	// Register the agent for this user to receive notifications
 	RegisterAgentForUser(agentID, AdminUserID)
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Commit agent launched!\n🆔 ID: `%s`\n📁 Directory: %s\n\nThe agent will:\n• Review uncommitted changes\n• Create a meaningful commit\n• Push to the remote repository\n\nUse `/status %s` to check status.",
-		agentID, directory, agentID))
+	if messageOnly {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Commit message agent launched!\n🆔 ID: `%s`\n📁 Directory: %s\n\nThe agent will review the staged changes and reply with a suggested commit message. Nothing will be committed or pushed.\n\nUse `/status %s` to check status.",
+			agentID, directory, agentID))
+	} else {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Commit agent launched!\n🆔 ID: `%s`\n📁 Directory: %s\n\nThe agent will:\n• Review uncommitted changes\n• Create a meaningful commit\n• Push to the remote repository\n\nUse `/status %s` to check status.",
+			agentID, directory, agentID))
+	}
 }
 
+// verifyPushOnCompletion adds to opts a check that warns chatID when the
+// commit agent made a commit but it did not reach origin, usually because the
+// push failed to authenticate. HEAD is recorded when the agent is created, so
+// a launch that waited in a queue compares against the state it started from.
+func verifyPushOnCompletion(opts codeagent.AgentOptions, dir string, chatID int64) codeagent.AgentOptions {
+	opts.OnCreate = func(agent *codeagent.Agent) {
+		headBefore, _ := core.GitHead(dir)
+		agent.AddCompletionCallback(func(a *codeagent.Agent) {
+			if head, err := core.GitHead(dir); err != nil || head == headBefore {
+				return // Nothing was committed
+			}
+			branch, commits, err := core.UnpushedCommits(dir)
+			if err != nil {
+				log.Printf("[Commit] Failed to verify push for agent %s: %v", a.ID, err)
+				return
+			}
+			if len(commits) > 0 {
+				core.SendMessage(context.Background(), b, chatID, formatUnpushedWarning(dir, branch, commits))
+			}
+		})
+	}
+	return opts
+}
+
+// formatUnpushedWarning lists the commits a push did not deliver
+func formatUnpushedWarning(dir, branch string, commits []string) string {
+	message := fmt.Sprintf("⚠️ Commit succeeded but push did not reach remote\n📁 %s\n🌿 %s has %d commit(s) not on origin:\n", dir, branch, len(commits))
+	for i, commit := range commits {
+		if i == maxBranchesListed {
+			message += fmt.Sprintf("... and %d more\n", len(commits)-maxBranchesListed)
+			break
+		}
+		message += fmt.Sprintf("• `%s`\n", commit)
+	}
+	return message + fmt.Sprintf("\nCheck the remote's credentials and push again with `/run %s git push`.", dir)
+}
+
+// commitMessagePrompt asks an agent to suggest a message for the staged changes without committing
+const commitMessagePrompt = `IMPORTANT COMMIT MESSAGE INSTRUCTIONS:
+You are tasked with writing a commit message for the changes that are already staged in a git repository. Follow these steps:
+
+1. Review the staged changes using: git diff --cached
+2. If nothing is staged, say so clearly and stop
+3. Write a commit message: a short summary line (50 characters or fewer), a blank line, then a body explaining what changed and why
+
+Rules:
+- This is a read-only task: do NOT run git add, git commit, git push, git stash or any other command that changes the repository
+- Do NOT modify, create or delete any files
+- Only consider staged changes; ignore unstaged and untracked files
+
+Your task: Reply with the suggested commit message only, inside a code block.`
+
 func handleDiffCommand(ctx context.Context, message *models.Message) {
 	parts := strings.Fields(message.Text)
 	path := "." // Default to current directory
@@ -603,17 +902,36 @@ func sendGitDiff(ctx context.Context, repoDir, filename string, staged bool) {
 }
 
 func handleReviewCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, rubric, ok := parseRubricFlag(ctx, message)
+	if !ok {
+		return
+	}
+	parts, gitRange, err := extractRangeFlag(parts)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	parts, dryRun := extractDryRunFlag(parts, 3)
 	if len(parts) < 2 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workspace directory.\nUsage:\n• `/review <directory>` - Review pending changes\n• `/review <directory> <pr_url>` - Review PR\n\nExamples:\n• `/review ~/myproject`\n• `/review ~/myproject https://github.com/owner/repo/pull/123`")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workspace directory.\nUsage:\n• `/review [--rubric <name>] [--dry-run] <directory>` - Review pending changes\n• `/review [--rubric <name>] [--dry-run] <directory> <pr_url>` - Review PR\n• `/review [--rubric <name>] [--dry-run] <directory> --range <gitref>` - Review a commit or range\n\nExamples:\n• `/review ~/myproject`\n• `/review ~/myproject https://github.com/owner/repo/pull/123`\n• `/review ~/myproject --range HEAD~3..HEAD`\n• `/review --rubric security ~/myproject`")
 		return
 	}
 
 	directory := strings.TrimSpace(parts[1])
 
+	// A range review takes no PR URL
+	if gitRange != "" {
+		if len(parts) > 2 {
+			core.SendMessage(ctx, b, message.Chat.ID, "❌ `--range` cannot be combined with a PR URL.\n\nExample: `/review ~/myproject --range HEAD~3..HEAD`")
+			return
+		}
+		launchRangeReviewAgent(ctx, directory, gitRange, rubric, dryRun)
+		return
+	}
+
 	// If only directory is provided, review pending changes
 	if len(parts) == 2 {
-		launchPendingChangesReviewAgent(ctx, directory)
+		launchPendingChangesReviewAgent(ctx, directory, rubric, dryRun)
 		return
 	}
 
@@ -624,10 +942,56 @@ func handleReviewCommand(ctx context.Context, message *models.Message) {
 		return
 	}
 
-	launchPRReviewAgent(ctx, directory, prURL)
+	launchPRReviewAgent(ctx, directory, prURL, rubric, dryRun)
+}
+
+// extractRangeFlag removes --range <gitref> (or --range=<gitref>) from the /review arguments
+func extractRangeFlag(parts []string) ([]string, string, error) {
+	rest := make([]string, 0, len(parts))
+	gitRange := ""
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		switch {
+		case part == "--range":
+			if i+1 >= len(parts) {
+				return nil, "", fmt.Errorf("--range requires a commit or range, e.g. HEAD~3..HEAD")
+			}
+			gitRange = parts[i+1]
+			i++
+		case strings.HasPrefix(part, "--range="):
+			gitRange = strings.TrimPrefix(part, "--range=")
+		default:
+			rest = append(rest, part)
+		}
+	}
+	return rest, gitRange, nil
+}
+
+// parseRubricFlag splits the message into arguments with any --rubric flag
+// removed and looks up the rubric. It reports failures to the user.
+func parseRubricFlag(ctx context.Context, message *models.Message) ([]string, core.ReviewRubric, bool) {
+	parts, name, err := core.ExtractRubricFlag(strings.Fields(message.Text))
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return nil, core.ReviewRubric{}, false
+	}
+	rubric, err := core.GetReviewRubric(name)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v\n\nUse `/rubrics` to list available rubrics.", err))
+		return nil, core.ReviewRubric{}, false
+	}
+	return parts, rubric, true
+}
+
+// rubricNote describes a non-default rubric for launch messages
+func rubricNote(rubric core.ReviewRubric) string {
+	if rubric.Name == core.DefaultRubricName {
+		return ""
+	}
+	return fmt.Sprintf("\n📏 Rubric: %s", rubric.Name)
 }
 
-func launchPRReviewAgent(ctx context.Context, directory, prURL string) {
+func launchPRReviewAgent(ctx context.Context, directory, prURL string, rubric core.ReviewRubric, dryRun bool) {
 	chatID := AdminUserID
 	// Resolve the directory path relative to home directory
 	absDir, err := core.ResolvePath(directory)
@@ -685,11 +1049,17 @@ Remember:
 - Send your review message directly to the output (it will be sent to Telegram)
 
 PR URL: %s`, prURL, prURL, prURL, prURL, prURL)
+	prReviewPrompt = rubric.Apply(prReviewPrompt)
+	planFilename := generateUniquePlanFilename("PR_REVIEW")
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching PR review agent...\n📁 Repository: %s\n🔗 PR: %s", absDir, prURL))
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, prReviewPrompt, planFilename)
+		return
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching PR review agent...\n📁 Repository: %s\n🔗 PR: %s%s", absDir, prURL, rubricNote(rubric)))
 
 	// Launch the agent with the PR review prompt and unique plan file
-	planFilename := generateUniquePlanFilename("PR_REVIEW")
 	agentID, err := agentManager.LaunchAgentWithPlanFile(ctx, absDir, prReviewPrompt, planFilename)
 	if err != nil {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
@@ -703,7 +1073,7 @@ PR URL: %s`, prURL, prURL, prURL, prURL, prURL)
 		agentID, prURL, directory, agentID))
 }
 
-func launchPendingChangesReviewAgent(ctx context.Context, directory string) {
+func launchPendingChangesReviewAgent(ctx context.Context, directory string, rubric core.ReviewRubric, dryRun bool) {
 	chatID := AdminUserID
 	// Resolve the directory path relative to home directory
 	absDir, err := core.ResolvePath(directory)
@@ -761,11 +1131,17 @@ Remember:
 - Point out specific files and line numbers when mentioning issues
 - If everything looks good, say so briefly
 - Send your review message directly to the output (it will be sent to Telegram)`
+	pendingChangesPrompt = rubric.Apply(pendingChangesPrompt)
+	planFilename := generateUniquePlanFilename("REVIEW")
+
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, pendingChangesPrompt, planFilename)
+		return
+	}
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching pending changes review agent...\n📁 Repository: %s", absDir))
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching pending changes review agent...\n📁 Repository: %s%s", absDir, rubricNote(rubric)))
 
 	// Launch the agent with the pending changes review prompt and unique plan file
-	planFilename := generateUniquePlanFilename("REVIEW")
 	agentID, err := agentManager.LaunchAgentWithPlanFile(ctx, absDir, pendingChangesPrompt, planFilename)
 	if err != nil {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
@@ -779,10 +1155,91 @@ Remember:
 		agentID, directory, agentID))
 }
 
+func launchRangeReviewAgent(ctx context.Context, directory, gitRange string, rubric core.ReviewRubric, dryRun bool) {
+	chatID := AdminUserID
+	absDir, err := core.ValidateGitRepo(directory)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if err := core.VerifyGitRange(absDir, gitRange); err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔍 Preparing to review %s...", gitRange))
+
+	rangeReviewPrompt := rubric.Apply(rangeReviewPrompt(gitRange))
+	planFilename := generateUniquePlanFilename("REVIEW")
+
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, rangeReviewPrompt, planFilename)
+		return
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching review agent for %s...\n📁 Repository: %s%s", gitRange, absDir, rubricNote(rubric)))
+
+	// Launch the agent with the range review prompt and unique plan file
+	agentID, err := agentManager.LaunchAgentWithPlanFile(ctx, absDir, rangeReviewPrompt, planFilename)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
+		return
+	}
+
+	// Register the agent for this user to receive notifications
+	RegisterAgentForUser(agentID, AdminUserID)
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Review agent launched!\n🆔 ID: `%s`\n📁 Repository: %s\n🔀 Changes: %s\n\nThe agent will:\n• Read the commits and diff\n• Review code quality and bugs\n• Send the review to this Telegram chat\n\nUse `/status %s` to check status.",
+		agentID, directory, gitRange, agentID))
+}
+
+// rangeReviewPrompt returns the review prompt for a single commit or a range such as HEAD~3..HEAD
+func rangeReviewPrompt(gitRange string) string {
+	inspect := fmt.Sprintf(`   - Run: git show --stat %s
+   - Run: git show %s`, gitRange, gitRange)
+	subject := fmt.Sprintf("the commit %s", gitRange)
+	if strings.Contains(gitRange, "..") {
+		inspect = fmt.Sprintf(`   - Run: git log --oneline %s
+   - Run: git diff --stat %s
+   - Run: git diff %s`, gitRange, gitRange, gitRange)
+		subject = fmt.Sprintf("the changes in the range %s", gitRange)
+	}
+
+	return fmt.Sprintf(`IMPORTANT COMMIT REVIEW INSTRUCTIONS:
+You are tasked with reviewing %s in this git repository. Follow these steps carefully:
+
+1. First, inspect the changes:
+%s
+
+2. Analyze the changes:
+   - Look for potential bugs, security issues, or performance problems
+   - Check if the implementation aligns with the commit messages
+   - Verify that the code follows project conventions and best practices
+   - Check for missing tests or documentation
+
+3. Write a concise review of the changes:
+   - DO NOT make any commits, push changes or modify files
+   - Structure your review as follows:
+     * Summary of changes (files modified, added, deleted)
+     * List any bugs or issues found (if any)
+     * Code improvement suggestions (following the project conventions and best practices) (if any)
+     * Recommendations for next steps
+
+Remember:
+- Be concise and focused on the actual changes
+- Point out specific files and line numbers when mentioning issues
+- If everything looks good, say so briefly
+- Send your review message directly to the output (it will be sent to Telegram)`, subject, inspect)
+}
+
 func handlePRCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, rubric, ok := parseRubricFlag(ctx, message)
+	if !ok {
+		return
+	}
+	parts, dryRun := extractDryRunFlag(parts, 3)
 	if len(parts) < 3 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workspace directory and PR URL.\nUsage: `/pr <directory> <pr_url>`\n\nExample: `/pr ~/myproject https://github.com/owner/repo/pull/123`")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workspace directory and PR URL.\nUsage: `/pr [--rubric <name>] [--dry-run] <directory> <pr_url>`\n\nExample: `/pr ~/myproject https://github.com/owner/repo/pull/123`")
 		return
 	}
 
@@ -794,10 +1251,10 @@ func handlePRCommand(ctx context.Context, message *models.Message) {
 		return
 	}
 
-	launchPRCommentAgent(ctx, message.Chat.ID, directory, prURL)
+	launchPRCommentAgent(ctx, message.Chat.ID, directory, prURL, rubric, dryRun)
 }
 
-func launchPRCommentAgent(ctx context.Context, chatID int64, directory, prURL string) {
+func launchPRCommentAgent(ctx context.Context, chatID int64, directory, prURL string, rubric core.ReviewRubric, dryRun bool) {
 	// Resolve the directory path relative to home directory
 	absDir, err := core.ResolvePath(directory)
 	if err != nil {
@@ -863,11 +1320,17 @@ CRITICAL RULES:
 - DO NOT run the command twice
 
 PR URL: %s`, prURL, prURL, prURL, prURL, prURL, prURL)
+	prCommentPrompt = rubric.Apply(prCommentPrompt)
+	planFilename := generateUniquePlanFilename("PR_COMMENT")
 
-	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching PR review agent...\n📁 Repository: %s\n🔗 PR: %s", absDir, prURL))
+	if dryRun {
+		sendDryRun(ctx, chatID, absDir, prCommentPrompt, planFilename)
+		return
+	}
+
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("🚀 Launching PR review agent...\n📁 Repository: %s\n🔗 PR: %s%s", absDir, prURL, rubricNote(rubric)))
 
 	// Launch the agent with the PR comment prompt and unique plan file
-	planFilename := generateUniquePlanFilename("PR_COMMENT")
 	agentID, err := agentManager.LaunchAgentWithPlanFile(ctx, absDir, prCommentPrompt, planFilename)
 	if err != nil {
 		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ Failed to launch agent: %v", err))
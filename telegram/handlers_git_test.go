@@ -0,0 +1,222 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mavis/codeagent"
+	"mavis/core"
+)
+
+func TestFormatBranches(t *testing.T) {
+	branches := &core.GitBranches{
+		Current: "main",
+		Local:   []string{"dev", "main"},
+	}
+	message := formatBranches("/repo", branches)
+	if !strings.Contains(message, "👉 `main` (current)") {
+		t.Errorf("Expected current branch to be marked, got %s", message)
+	}
+	if !strings.Contains(message, "*Remote (0):*\n   none") {
+		t.Errorf("Expected empty remote section, got %s", message)
+	}
+
+	branches.Tracking = map[string]core.BranchTracking{
+		"main": {Upstream: "origin/main", Ahead: 2, Behind: 1},
+		"dev":  {Upstream: "origin/dev"},
+	}
+	message = formatBranches("/repo", branches)
+	if !strings.Contains(message, "👉 `main` (current) · ↑2 ↓1 origin/main") {
+		t.Errorf("Expected ahead/behind counts, got %s", message)
+	}
+	if !strings.Contains(message, "`dev` · up to date with origin/dev") {
+		t.Errorf("Expected up to date branch, got %s", message)
+	}
+
+	for i := 0; i < maxBranchesListed+5; i++ {
+		branches.Remote = append(branches.Remote, fmt.Sprintf("origin/b%d", i))
+	}
+	message = formatBranches("/repo", branches)
+	if !strings.Contains(message, "... and 5 more") {
+		t.Errorf("Expected truncated remote list, got %s", message)
+	}
+	if strings.Contains(message, fmt.Sprintf("origin/b%d", maxBranchesListed)) {
+		t.Error("Expected branches beyond the limit to be omitted")
+	}
+}
+
+func TestFormatUnpushedWarning(t *testing.T) {
+	message := formatUnpushedWarning("/repo", "main", []string{"abc1234 Fix login", "def5678 Add tests"})
+	for _, expected := range []string{
+		"⚠️ Commit succeeded but push did not reach remote",
+		"main has 2 commit(s) not on origin",
+		"• `abc1234 Fix login`",
+		"`/run /repo git push`",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected message to contain %q, got %s", expected, message)
+		}
+	}
+}
+
+func TestExtractMessageOnlyFlag(t *testing.T) {
+	parts, messageOnly := extractMessageOnlyFlag([]string{"/commit", "~/repo", "--message-only"})
+	if !messageOnly {
+		t.Error("Expected message-only mode")
+	}
+	if strings.Join(parts, " ") != "/commit ~/repo" {
+		t.Errorf("Expected '/commit ~/repo', got %q", strings.Join(parts, " "))
+	}
+
+	if _, messageOnly := extractMessageOnlyFlag([]string{"/commit", "~/repo"}); messageOnly {
+		t.Error("Expected message-only mode to be off by default")
+	}
+}
+
+func TestExtractRangeFlag(t *testing.T) {
+	parts, gitRange, err := extractRangeFlag([]string{"/review", "~/repo", "--range", "HEAD~3..HEAD"})
+	if err != nil || gitRange != "HEAD~3..HEAD" || strings.Join(parts, " ") != "/review ~/repo" {
+		t.Errorf("Expected range HEAD~3..HEAD and '/review ~/repo', got %q %q (err %v)", gitRange, parts, err)
+	}
+	if _, gitRange, _ := extractRangeFlag([]string{"/review", "~/repo", "--range=abc123"}); gitRange != "abc123" {
+		t.Errorf("Expected range abc123, got %q", gitRange)
+	}
+	if _, _, err := extractRangeFlag([]string{"/review", "~/repo", "--range"}); err == nil {
+		t.Error("Expected error for --range without a value")
+	}
+}
+
+func TestRangeReviewPrompt(t *testing.T) {
+	if prompt := rangeReviewPrompt("abc123"); !strings.Contains(prompt, "git show abc123") {
+		t.Errorf("Expected git show for a single commit, got %s", prompt)
+	}
+	if prompt := rangeReviewPrompt("HEAD~3..HEAD"); !strings.Contains(prompt, "git diff HEAD~3..HEAD") {
+		t.Errorf("Expected git diff for a range, got %s", prompt)
+	}
+}
+
+// setupQueuedGitTest installs a fake claude binary that sleeps for the number
+// of seconds in the agent folder's "delay" file, and a manager that runs one
+// agent at a time
+func setupQueuedGitTest(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	script := filepath.Join(t.TempDir(), "fake-claude")
+	content := "#!/bin/sh\nsleep $(cat delay 2>/dev/null || echo 0)\necho done\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	codeagent.SetClaudeBinary(script)
+	t.Cleanup(func() { codeagent.SetClaudeBinary("") })
+
+	oldRoot := core.WorktreeRoot
+	core.WorktreeRoot = t.TempDir()
+	t.Cleanup(func() { core.WorktreeRoot = oldRoot })
+
+	originalManager := agentManager
+	agentManager = codeagent.NewManager()
+	agentManager.SetMaxConcurrentAgents(1)
+	t.Cleanup(func() { agentManager = originalManager })
+}
+
+// initGitRepo creates a git repository with a single commit
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"add", "README.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	return dir
+}
+
+// runBlockingAgent launches an agent that holds the only slot for delay seconds
+func runBlockingAgent(t *testing.T, delay string) *codeagent.Agent {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "delay"), []byte(delay), 0644); err != nil {
+		t.Fatalf("Failed to write delay file: %v", err)
+	}
+	id, err := agentManager.LaunchAgent(context.Background(), dir, "block")
+	if err != nil {
+		t.Fatalf("Failed to launch blocking agent: %v", err)
+	}
+	agent, err := agentManager.GetAgent(id)
+	if err != nil {
+		t.Fatalf("Expected blocking agent %s to run, got %v", id, err)
+	}
+	return agent
+}
+
+// waitForQueuedAgent releases the blocking agent's slot and waits for the
+// agent that was queued behind it to finish
+func waitForQueuedAgent(t *testing.T, blocker *codeagent.Agent) *codeagent.Agent {
+	t.Helper()
+	<-blocker.Done()
+	if err := agentManager.RemoveAgent(blocker.ID); err != nil {
+		t.Fatalf("Failed to remove blocking agent: %v", err)
+	}
+	agents := agentManager.ListAgents()
+	if len(agents) != 1 {
+		t.Fatalf("Expected the queued agent to start, got %d agents", len(agents))
+	}
+	agent, err := agentManager.GetAgent(agents[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get queued agent: %v", err)
+	}
+	select {
+	case <-agent.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the queued agent")
+	}
+	return agent
+}
+
+func TestQueuedGitLaunchCleansUpWorkspace(t *testing.T) {
+	setupQueuedGitTest(t)
+	repo := initGitRepo(t)
+
+	blocker := runBlockingAgent(t, "1")
+	launchGitCodeAgent(context.Background(), repo, "do the task", false)
+
+	worktrees, _ := filepath.Glob(filepath.Join(core.WorktreeRoot, "*", "*"))
+	if len(worktrees) != 1 {
+		t.Fatalf("Expected one workspace for the queued launch, got %v", worktrees)
+	}
+	if status := agentManager.GetConcurrencyStatus(); status.Waiting != 1 {
+		t.Fatalf("Expected the git launch to wait for a slot, got %+v", status)
+	}
+
+	waitForQueuedAgent(t, blocker)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(worktrees[0]); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected workspace %s to be removed once the queued agent finished", worktrees[0])
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
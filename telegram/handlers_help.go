@@ -14,28 +14,48 @@ import (
 func handleHelpCommand(ctx context.Context, message *models.Message) {
 	helpText := "📚 *Available Commands*\n\n" +
 		"*LAN Server Commands:*\n" +
-		"• `/start <workdir> <port> <command...>` - Start LAN server with build command\n" +
-		"• `/serve <directory> [port]` - Serve static files on LAN (default port: 8080)\n" +
-		"• `/stop` - Stop LAN server\n\n" +
+		"• `/start [--tls] <workdir> <port> <command...>` - Start LAN server with build command (--tls adds an HTTPS proxy)\n" +
+		"• `/serve <directory> [port] [--auth user:pass] [--no-listing] [--spa] [--dotfiles] [--tls] [--public]` - Serve static files on LAN (default port: 8080), optionally behind a login, without directory listings, as a single-page app, with dotfiles or over HTTPS; only exposed via UPnP with --auth, --tls or --public\n" +
+		"• `/serve_stats [port]` - Show request count and top paths of a /serve file server\n" +
+		"• `/stop [port|all]` - Stop LAN server (lists them when several are running)\n" +
+		"• `/stop_all` - Stop every LAN server\n" +
+		"• `/ports` - List listening TCP ports with their process, to pick a free one\n" +
+		"• `/freeport <port>` - Stop whatever process listens on a port, after you confirm\n" +
+		"• `/upnp_status` - Show UPnP port mappings, external IP and lease time left\n\n" +
 		"*Code Agent Commands:*\n" +
-		"• `/code <directory> <task>` - Launch a new code agent\n" +
+		"• `/code [--model=<model>] [--env KEY=VALUE] <directory> <task>` - Launch a new code agent\n" +
 		"• `/new_branch <directory> <task>` - Launch git-aware agent (creates branch & pushes)\n" +
 		"• `/edit_branch <directory> <branch> <task>` - Launch git-aware agent on existing branch\n" +
-		"• `/commit <directory>` - Commit and push current changes\n" +
+		"• `/clone <git_url> [target_dir]` - Clone a repository into your home directory\n" +
+		"• `/clone <git_url> <task>` - Clone a repository and launch git-aware agent on it (task of two or more words)\n" +
+		"• `/branches <directory>` - List local and remote branches, marking the current one and showing ahead/behind counts versus the upstream\n" +
+		"• `/commit <directory> [--message-only]` - Commit and push current changes, or only suggest a message for staged changes\n" +
+		"• `/merge <directory> <source_branch>` - Launch agent to merge a branch into the current one, resolving conflicts\n" +
+		"• `/stash <directory> [message]` - Stash uncommitted changes, untracked files included\n" +
+		"• `/stash_pop <directory>` - Restore the most recent stash\n" +
 		"• `/diff [path]` - Show git diffs (directory: all files, file: single diff)\n" +
-		"• `/review <directory>` - Review pending changes in workspace\n" +
-		"• `/review <directory> <pr_url>` - Review PR and send result to Telegram\n" +
-		"• `/pr <directory> <pr_url>` - Review PR, post comment, and approve if ready\n" +
+		"• `/review [--rubric <name>] <directory>` - Review pending changes in workspace\n" +
+		"• `/review [--rubric <name>] <directory> <pr_url>` - Review PR and send result to Telegram\n" +
+		"• `/review [--rubric <name>] <directory> --range <gitref>` - Review a commit or a range like `HEAD~3..HEAD`\n" +
+		"• `/pr [--rubric <name>] <directory> <pr_url>` - Review PR, post comment, and approve if ready\n" +
+		"• `/rubrics` - List review rubrics\n" +
+		"• `/rubric <name> <instructions>` - Add or replace a review rubric\n" +
 		"• `/approve <directory> <pr_url>` - Review PR and always approve (with comments)\n" +
-		"• `/ps` - List all active code agents\n" +
+		"• `/ps [status|folder|label]` - List code agents, newest first; filter by status (`running`, `failed`...), folder, or label (`kind=review` or just `review`)\n" +
+		"• `/find [--status=<status>] [--since=<duration>] <query>` - Search agents by prompt and output\n" +
 		"• `/status <agent_id>` - Get details of a specific agent\n" +
-		"• `/stop <agent_id>` - Kill a running agent\n\n" +
+		"• `/logs <agent_id> [lines]` - Show the last lines of an agent's output (default 30)\n" +
+		"• `/cost` - Show token usage by folder and the estimated cost\n" +
+		"• `/stop <agent_id>` - Kill a running agent\n" +
+		"• `/retry <agent_id>` - Relaunch a finished, failed or killed agent with the same folder and prompt\n" +
+		"• `/kill_all` - Emergency stop: kill every running agent and drop all queued tasks (asks for confirmation)\n\n" +
 		"*Image Commands:*\n" +
-		"• Send images directly to include them in the next `/code` command\n" +
-		"• `/images` - Show pending images\n" +
+		"• Send images directly to include them in the next `/code` command (WebP, HEIC and AVIF are converted to PNG when ImageMagick or a similar tool is installed)\n" +
+		"• `/images` - Show pending images with their format and size\n" +
 		"• `/clear_images` - Clear all pending images\n\n" +
 		"*File & Directory Commands:*\n" +
 		"• `/download <file_path>` - Download a file (up to 50MB)\n" +
+		"• `/download_zip <directory>` - Download a directory as a zip archive (up to 50MB)\n" +
 		"• `/ls [directory]` - List directory contents\n" +
 		"• `/mkdir <directory>` - Create a new directory\n" +
 		"• `/run <workspace> <command> [args...]` - Run command in workspace\n\n"
@@ -54,21 +74,45 @@ func handleHelpCommand(ctx context.Context, message *models.Message) {
 		"• `/help` - Show this help message\n\n" +
 		"*Examples:*\n" +
 		"• `/start ~/reservas_rb 3000 rails s` - Start Rails app on LAN\n" +
+		"• `/start --tls ~/app 3000 npm run dev` - Start app behind an HTTPS proxy\n" +
 		"• `/serve ~/public_html` - Serve static files on port 8080\n" +
 		"• `/serve ~/docs 3000` - Serve static files on port 3000\n" +
-		"• `/stop` - Stop LAN server\n" +
+		"• `/serve ~/docs 3000 --auth me:secret` - Require a login before serving files\n" +
+		"• `/serve ~/docs --tls` - Serve files over HTTPS\n" +
+		"• `/serve ~/app/dist --spa` - Serve a single-page app, routing unknown paths to index.html\n" +
+		"• `/serve_stats 3000` - See what was downloaded from the file server on port 3000\n" +
+		"• `/stop 3000` - Stop the LAN server on port 3000\n" +
+		"• `/freeport 5173` - Free port 5173 held by a leftover dev server\n" +
 		"• `/code /home/project \"fix the bug in main.py\"`\n" +
+		"• `/code --model=opus ~/myproject \"review the auth module\"` - Use a specific model\n" +
+		"• `/code --env NODE_ENV=test ~/myproject \"fix the failing tests\"` - Set environment variables for the agent\n" +
 		"• `/ps`\n" +
+		"• `/ps commit` - Only agents labelled kind=commit\n" +
+		"• `/ps failed` - Only failed agents\n" +
+		"• `/ps ~/myproject` - Only agents working in ~/myproject\n" +
+		"• `/find --since=7d auth.go` - Agents from the last week that mention auth.go\n" +
 		"• `/status abc123`\n" +
+		"• `/logs abc123 50` - Last 50 output lines\n" +
+		"• `/cost` - Token usage and estimated cost\n" +
 		"• `/stop abc123` - Stop specific agent\n" +
+		"• `/retry abc123` - Relaunch a failed agent\n" +
 		"• `/new_branch /my/repo \"add error handling to API\"`\n" +
+		"• `/new_branch --dry-run /my/repo \"add error handling to API\"` - Show the full prompt without launching (also `/code`, `/commit`, `/review`, `/pr`)\n" +
 		"• `/edit_branch ~/myproject feature/auth \"fix authentication bug\"`\n" +
+		"• `/clone git@github.com:owner/repo.git ~/projects/repo` - Clone only\n" +
+		"• `/clone git@github.com:owner/repo.git \"add a CONTRIBUTING guide\"`\n" +
+		"• `/branches ~/myproject` - Recall branch names before `/edit_branch`\n" +
 		"• `/commit ~/myproject` - Commit and push changes\n" +
+		"• `/commit ~/myproject --message-only` - Suggest a commit message for staged changes without committing\n" +
+		"• `/merge ~/myproject feature/auth` - Merge feature/auth into the checked out branch\n" +
+		"• `/stash ~/myproject wip login form` - Put local changes aside before launching an agent\n" +
 		"• `/diff ~/myproject` - Show all git diffs in project\n" +
 		"• `/diff ~/myproject/main.go` - Show diff for single file\n" +
 		"• `/review ~/myproject` - Review pending changes\n" +
 		"• `/review ~/myproject https://github.com/owner/repo/pull/123` - Review PR\n" +
+		"• `/review ~/myproject --range HEAD~3..HEAD` - Review the last three commits\n" +
 		"• `/pr ~/myproject https://github.com/owner/repo/pull/123` - Review PR & post comment\n" +
+		"• `/review --rubric security ~/myproject` - Review pending changes with a custom rubric\n" +
 		"• `/approve ~/myproject https://github.com/owner/repo/pull/123` - Review & approve PR\n" +
 		"• `/run ~/myapp npm test` - Run tests in myapp workspace\n" +
 		"• `/run . python script.py --verbose` - Run Python script in current dir"
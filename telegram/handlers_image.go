@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"mavis/core"
@@ -18,6 +19,22 @@ import (
 	"github.com/go-telegram/bot/models"
 )
 
+// defaultMaxUploadSize matches the largest file the Telegram Bot API lets bots download
+const defaultMaxUploadSize = 20 << 20
+
+// largeUploadSize is the size from which a download progress message is sent
+// and from which /code warns about the images it includes
+const largeUploadSize = 5 << 20
+
+// maxUploadSize is the largest image accepted from the chat
+var maxUploadSize int64 = defaultMaxUploadSize
+
+// SetMaxUploadSize sets the largest image accepted from the chat, in bytes.
+// Limits above 20 MB need a local Bot API server.
+func SetMaxUploadSize(size int64) {
+	maxUploadSize = size
+}
+
 func HandlePhotoMessage(ctx context.Context, message *models.Message) {
 	userID := AdminUserID
 
@@ -25,19 +42,19 @@ func HandlePhotoMessage(ctx context.Context, message *models.Message) {
 	photo := message.Photo[len(message.Photo)-1]
 
 	// Download the photo
-	filePath, err := downloadTelegramFile(ctx, photo.FileID, userID, "jpg")
+	image, err := downloadPendingImage(ctx, message.Chat.ID, photo.FileID, int64(photo.FileSize), userID, "jpg")
 	if err != nil {
 		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to download photo: %v", err))
 		return
 	}
 
 	// Add to pending images
-	addPendingImage(userID, filePath)
+	addPendingImage(userID, image)
 
 	// Get pending count
 	count := getPendingImageCount(userID)
 
-	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("📸 Photo saved! You have %d pending image(s).\n\nThese images will be included in your next `/code` command.", count))
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("📸 Photo saved (%s)! You have %d pending image(s).\n\nThese images will be included in your next `/code` command.", core.FormatBytes(image.Size), count))
 }
 
 func HandleDocumentMessage(ctx context.Context, message *models.Message) {
@@ -46,9 +63,9 @@ func HandleDocumentMessage(ctx context.Context, message *models.Message) {
 
 	// Check if it's an image file
 	isImage := false
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".svg"}
+	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".svg", ".heic", ".heif", ".avif"}
 	for _, ext := range imageExts {
-		if filepath.Ext(doc.FileName) == ext {
+		if strings.ToLower(filepath.Ext(doc.FileName)) == ext {
 			isImage = true
 			break
 		}
@@ -60,21 +77,75 @@ func HandleDocumentMessage(ctx context.Context, message *models.Message) {
 	}
 
 	// Download the document
-	filePath, err := downloadTelegramFile(ctx, doc.FileID, userID, filepath.Ext(doc.FileName)[1:])
+	image, err := downloadPendingImage(ctx, message.Chat.ID, doc.FileID, doc.FileSize, userID, filepath.Ext(doc.FileName)[1:])
 	if err != nil {
 		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to download image: %v", err))
 		return
 	}
 
 	// Add to pending images
-	addPendingImage(userID, filePath)
+	addPendingImage(userID, image)
 
 	// Get pending count
 	count := getPendingImageCount(userID)
 
-	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🖼️ Image saved! You have %d pending image(s).\n\nThese images will be included in your next `/code` command.", count))
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🖼️ Image saved (%s)! You have %d pending image(s).\n\nThese images will be included in your next `/code` command.", core.FormatBytes(image.Size), count))
+}
+
+// downloadPendingImage checks the size Telegram reports for a file against
+// maxUploadSize, announces large downloads and saves the file to disk
+func downloadPendingImage(ctx context.Context, chatID int64, fileID string, size int64, userID int64, extension string) (pendingImage, error) {
+	if size > maxUploadSize {
+		return pendingImage{}, fmt.Errorf("file is %s, the limit is %s", core.FormatBytes(size), core.FormatBytes(maxUploadSize))
+	}
+	if size >= largeUploadSize {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("⏬ Downloading %s...", core.FormatBytes(size)))
+	}
+
+	path, err := downloadTelegramFile(ctx, fileID, userID, extension)
+	if err != nil {
+		return pendingImage{}, err
+	}
+	return prepareImage(ctx, chatID, path)
 }
 
+// prepareImage checks the content of a downloaded image. Formats agents
+// cannot open are converted to PNG when a converter is installed; anything
+// else is removed and rejected.
+func prepareImage(ctx context.Context, chatID int64, path string) (pendingImage, error) {
+	format, err := core.DetectImageFormat(path)
+	if err != nil {
+		os.Remove(path)
+		return pendingImage{}, fmt.Errorf("not a supported image: %v. Please send PNG, JPEG, GIF or SVG", err)
+	}
+
+	if !core.IsAgentReadableImage(format) {
+		if !core.IsConvertibleImage(format) {
+			os.Remove(path)
+			return pendingImage{}, fmt.Errorf("%s images are not supported. Please send PNG, JPEG, GIF or SVG", strings.ToUpper(format))
+		}
+
+		pngPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".png"
+		err := core.ConvertImageToPNG(path, pngPath, format)
+		os.Remove(path)
+		if err != nil {
+			return pendingImage{}, fmt.Errorf("agents can't open %s images and converting to PNG failed: %v. Please send PNG or JPEG", strings.ToUpper(format), err)
+		}
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🔄 Converted %s image to PNG", strings.ToUpper(format)))
+		path = pngPath
+		format = core.ImageFormatPNG
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return pendingImage{}, fmt.Errorf("failed to read saved image: %w", err)
+	}
+	return pendingImage{Path: path, Size: info.Size(), Format: format}, nil
+}
+
+// downloadTelegramFile streams a file from Telegram to the user's temp
+// directory and returns its path. Files larger than maxUploadSize
+// are rejected and partial downloads are removed.
 func downloadTelegramFile(ctx context.Context, fileID string, userID int64, extension string) (string, error) {
 	// Get file info from Telegram
 	file, err := b.GetFile(ctx, &bot.GetFileParams{
@@ -83,6 +154,9 @@ func downloadTelegramFile(ctx context.Context, fileID string, userID int64, exte
 	if err != nil {
 		return "", fmt.Errorf("failed to get file info: %w", err)
 	}
+	if file.FileSize > maxUploadSize {
+		return "", fmt.Errorf("file is %s, the limit is %s", core.FormatBytes(file.FileSize), core.FormatBytes(maxUploadSize))
+	}
 
 	// Create user temp directory
 	userTempDir := filepath.Join("data", "temp", fmt.Sprintf("user_%d", userID))
@@ -96,25 +170,56 @@ func downloadTelegramFile(ctx context.Context, fileID string, userID int64, exte
 
 	// Download file
 	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.Token(), file.FilePath)
-	resp, err := http.Get(fileURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download file: %s", resp.Status)
+	}
 
-	// Save to local file
-	out, err := os.Create(localPath)
+	if _, err := saveUpload(localPath, resp.Body, maxUploadSize); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// saveUpload streams r to path without buffering it in memory. If r holds
+// more than limit bytes or the copy fails, the partial file is removed.
+func saveUpload(path string, r io.Reader, limit int64) (int64, error) {
+	out, err := os.Create(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return 0, fmt.Errorf("failed to create file: %w", err)
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	written, err := io.Copy(out, io.LimitReader(r, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = fmt.Errorf("file is larger than the limit of %s", core.FormatBytes(limit))
+	} else if err != nil {
+		err = fmt.Errorf("failed to save file: %w", err)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+		os.Remove(path)
+		return 0, err
 	}
+	return written, nil
+}
 
-	return localPath, nil
+// pendingImagesSize returns the combined size of images
+func pendingImagesSize(images []pendingImage) int64 {
+	var total int64
+	for _, image := range images {
+		total += image.Size
+	}
+	return total
 }
 
 func handleImagesCommand(ctx context.Context, message *models.Message) {
@@ -126,10 +231,14 @@ func handleImagesCommand(ctx context.Context, message *models.Message) {
 		return
 	}
 
-	msg := fmt.Sprintf("📸 *Pending Images: %d*\n\n", len(pendingImages))
-	for i, imagePath := range pendingImages {
-		filename := filepath.Base(imagePath)
-		msg += fmt.Sprintf("%d. `%s`\n", i+1, filename)
+	msg := fmt.Sprintf("📸 *Pending Images: %d* (%s)\n\n", len(pendingImages), core.FormatBytes(pendingImagesSize(pendingImages)))
+	for i, image := range pendingImages {
+		filename := filepath.Base(image.Path)
+		warning := ""
+		if image.Size >= largeUploadSize {
+			warning = " ⚠️"
+		}
+		msg += fmt.Sprintf("%d. `%s` - %s, %s%s\n", i+1, filename, strings.ToUpper(image.Format), core.FormatBytes(image.Size), warning)
 	}
 	msg += "\nThese images will be included in your next `/code` command.\nUse `/clear_images` to remove them."
 
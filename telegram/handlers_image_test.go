@@ -0,0 +1,36 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	written, err := saveUpload(path, strings.NewReader("12345"), 5)
+	if err != nil || written != 5 {
+		t.Fatalf("Expected 5 bytes written, got %d (err %v)", written, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "12345" {
+		t.Errorf("Expected file contents 12345, got %q", data)
+	}
+
+	if _, err := saveUpload(path, strings.NewReader("123456"), 5); err == nil || !strings.Contains(err.Error(), "larger than the limit") {
+		t.Errorf("Expected limit error, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the partial file to be removed")
+	}
+}
+
+func TestPendingImagesSize(t *testing.T) {
+	images := []pendingImage{{Path: "a.png", Size: 100}, {Path: "b.jpg", Size: 23}}
+	if size := pendingImagesSize(images); size != 123 {
+		t.Errorf("Expected 123, got %d", size)
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -21,10 +24,104 @@ import (
 	"github.com/go-telegram/bot/models"
 )
 
+// lanServer is a server started with /start (a process) or /serve (a Go file server)
+type lanServer struct {
+	port       string
+	workDir    string
+	command    string
+	process    *os.Process    // Set for /start
+	httpServer *http.Server   // Set for /serve
+	accessLog  *web.AccessLog // Set for /serve
+	tlsProxy   *http.Server   // HTTPS reverse proxy, set for /start --tls
+	tlsPort    string         // Port of tlsProxy
+}
+
+// tlsProxyBasePort is the first port tried for /start --tls proxies
+const tlsProxyBasePort = "8443"
+
+// publicPort returns the port exposed through UPnP: the HTTPS proxy when
+// there is one, otherwise the server itself
+func (s *lanServer) publicPort() string {
+	if s.tlsProxy != nil {
+		return s.tlsPort
+	}
+	return s.port
+}
+
+// release shuts down the HTTPS proxy and removes the UPnP mapping and mDNS
+// advertisement of the server
+func (s *lanServer) release() {
+	if s.tlsProxy != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.tlsProxy.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to stop HTTPS proxy on port %s: %v", s.tlsPort, err)
+		}
+	}
+
+	if upnpManager != nil {
+		publicPort, _ := strconv.Atoi(s.publicPort())
+		upnpManager.UnmapPort(publicPort)
+	}
+	if mdnsResponder != nil {
+		portInt, _ := strconv.Atoi(s.port)
+		mdnsResponder.Withdraw(portInt)
+	}
+}
+
+// stop kills the process or shuts down the file server and releases the
+// HTTPS proxy and UPnP mapping. The caller must hold lanServerMutex and
+// remove s from lanServers.
+func (s *lanServer) stop() error {
+	var err error
+	if s.process != nil {
+		err = s.process.Kill()
+
+		// Also try to kill any process using the port
+		killPortCmd := exec.Command("sh", "-c", fmt.Sprintf("lsof -ti:%s | xargs kill -9 2>/dev/null || true", s.port))
+		killPortCmd.Run()
+	}
+	if s.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = s.httpServer.Shutdown(shutdownCtx)
+	}
+
+	s.release()
+	return err
+}
+
+// advertiseMDNS announces a LAN server on port over mDNS when it is enabled
+func advertiseMDNS(port, name string) {
+	if mdnsResponder == nil {
+		return
+	}
+	portInt, _ := strconv.Atoi(port)
+	mdnsResponder.Advertise(portInt, fmt.Sprintf("%s (%s)", name, port))
+}
+
+// describe formats the server for /stop replies
+func (s *lanServer) describe() string {
+	description := fmt.Sprintf("🔌 Port: %s\n📁 Workdir: %s\n🛠️ Command: %s", s.port, s.workDir, s.command)
+	if s.tlsProxy != nil {
+		description += fmt.Sprintf("\n🔒 HTTPS proxy: port %s", s.tlsPort)
+	}
+	return description
+}
+
+// extractTLSFlag removes a leading --tls from the /start arguments (before the
+// workdir, so build commands keep their own flags) and reports whether it was present
+func extractTLSFlag(parts []string) ([]string, bool) {
+	if len(parts) > 1 && parts[1] == "--tls" {
+		return append([]string{parts[0]}, parts[2:]...), true
+	}
+	return parts, false
+}
+
 func handleStartCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, useTLS := extractTLSFlag(strings.Fields(message.Text))
 	if len(parts) < 4 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workdir, port, and build command.\nUsage: /start <workdir> <port> <build command...>\n\nExample: /start ~/reservas_rb 3000 rails s")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide workdir, port, and build command.\nUsage: /start [--tls] <workdir> <port> <build command...>\n\nExample: /start ~/reservas_rb 3000 rails s\n\nWith --tls, an HTTPS reverse proxy is started in front of the server and exposed through UPnP instead of the plain HTTP port.")
 		return
 	}
 
@@ -59,9 +156,9 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 	lanServerMutex.Lock()
 	defer lanServerMutex.Unlock()
 
-	// Check if server is already running
-	if lanServerProcess != nil {
-		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ LAN server is already running!\n📁 Workdir: %s\n🔌 Port: %s\n🛠️ Command: %s\n\nUse /stop to stop it first.", lanServerWorkDir, lanServerPort, lanServerCmd))
+	// Check if one of our servers already uses the port
+	if existing, ok := lanServers[port]; ok {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ A LAN server is already running on port %s!\n%s\n\nUse `/stop %s` to stop it first, or pick another port.", port, existing.describe(), port))
 		return
 	}
 
@@ -114,10 +211,9 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 	}
 
 	// Store the process info
-	lanServerProcess = buildCmd.Process
-	lanServerPort = port
-	lanServerWorkDir = absWorkdir
-	lanServerCmd = buildCmdStr
+	server := &lanServer{port: port, workDir: absWorkdir, command: buildCmdStr, process: buildCmd.Process}
+	lanServers[port] = server
+	advertiseMDNS(port, "Mavis "+filepath.Base(absWorkdir))
 
 	// Get local IP addresses
 	var ipAddresses []string
@@ -132,8 +228,19 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 		}
 	}
 
+	// Front the server with an HTTPS reverse proxy
+	if useTLS {
+		if err := startTLSProxy(server, ipAddresses); err != nil {
+			core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ Failed to start the HTTPS proxy: %v\n\nThe server is running over plain HTTP only.", err))
+		}
+	}
+	scheme, publicPort := "http", server.publicPort()
+	if server.tlsProxy != nil {
+		scheme = "https"
+	}
+
 	// Try to set up UPnP port mapping
-	portInt, _ := strconv.Atoi(port)
+	portInt, _ := strconv.Atoi(publicPort)
 
 	// Attempt UPnP mapping in a goroutine to not block startup
 	go func() {
@@ -152,7 +259,7 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 					core.SendMessage(ctx, b, message.Chat.ID, "⚠️ UPnP succeeded but couldn't get public IP. Server is accessible on LAN.")
 				} else {
 					// Send success message with public URL
-					publicURL := fmt.Sprintf("http://%s:%s", publicIP, port)
+					publicURL := fmt.Sprintf("%s://%s:%s", scheme, publicIP, publicPort)
 					core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ UPnP mapping successful!\n\n🌍 *Public URL:* %s\n\n⚠️ *Important:* This URL is accessible from the internet!", publicURL))
 				}
 			}
@@ -169,7 +276,12 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 	for _, ip := range ipAddresses {
 		accessURLs.WriteString(fmt.Sprintf("  📡 LAN: http://%s:%s\n", ip, port))
 	}
-	accessURLs.WriteString(fmt.Sprintf("  🎯 mDNS: http://%s:%s (if available)\n", lanDomainName, port))
+	if mdnsResponder != nil {
+		accessURLs.WriteString(fmt.Sprintf("  🎯 mDNS: http://%s:%s\n", lanDomainName, port))
+	}
+	if server.tlsProxy != nil {
+		accessURLs.WriteString(formatTLSAccessURLs(server.tlsPort, ipAddresses, web.TLSCertificateSource()))
+	}
 
 	// Success message
 	successMsg := fmt.Sprintf("✅ LAN server started successfully!\n📁 Workdir: %s\n🔌 Port: %s\n🛠️ Build command: %s\n%s\n💡 *Note:* Attempting to expose to internet via UPnP...", absWorkdir, port, buildCmdStr, accessURLs.String())
@@ -182,29 +294,24 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 		err := buildCmd.Wait()
 
 		lanServerMutex.Lock()
-		if lanServerProcess != nil {
-			// Clean up UPnP mapping
-			if lanServerPort != "" && upnpManager != nil {
-				portInt, _ := strconv.Atoi(lanServerPort)
-				upnpManager.UnmapPort(portInt)
-			}
+		// A server stopped with /stop has already been removed
+		if lanServers[port] == server {
+			// Clean up the HTTPS proxy, UPnP mapping and mDNS advertisement
+			server.release()
 
 			// Clean up
-			lanServerProcess = nil
-			lanServerPort = ""
-			lanServerWorkDir = ""
-			lanServerCmd = ""
+			delete(lanServers, port)
 			lanServerMutex.Unlock()
 
 			// Build error message with reason
-			errorMsg := "⚠️ LAN server has stopped"
+			errorMsg := fmt.Sprintf("⚠️ LAN server on port %s has stopped", port)
 			if err != nil {
 				// Get the output that was captured
 				output := buildOutput.String()
 				if output != "" {
-					errorMsg = fmt.Sprintf("⚠️ LAN server has stopped.\n❌ *Reason:* %v\n\n📋 *Output:*\n```\n%s\n```", err, output)
+					errorMsg = fmt.Sprintf("⚠️ LAN server on port %s has stopped.\n❌ *Reason:* %v\n\n📋 *Output:*\n```\n%s\n```", port, err, output)
 				} else {
-					errorMsg = fmt.Sprintf("⚠️ LAN server has stopped.\n❌ *Reason:* %v", err)
+					errorMsg = fmt.Sprintf("⚠️ LAN server on port %s has stopped.\n❌ *Reason:* %v", port, err)
 				}
 			}
 
@@ -215,63 +322,264 @@ func handleStartCommand(ctx context.Context, message *models.Message) {
 	}()
 }
 
+// startTLSProxy starts an HTTPS reverse proxy in front of server on the first
+// free port from tlsProxyBasePort. The caller must hold lanServerMutex.
+func startTLSProxy(server *lanServer, ipAddresses []string) error {
+	proxyPort, err := core.FindAvailablePort(tlsProxyBasePort)
+	if err != nil {
+		return err
+	}
+	if proxyPort == server.port {
+		// The dev server may still be binding its port
+		next, _ := strconv.Atoi(proxyPort)
+		if proxyPort, err = core.FindAvailablePort(strconv.Itoa(next + 1)); err != nil {
+			return err
+		}
+	}
+
+	hosts := append([]string{"localhost", "127.0.0.1", lanDomainName}, ipAddresses...)
+	proxy, err := web.StartTLSProxy(proxyPort, server.port, hosts)
+	if err != nil {
+		return err
+	}
+	server.tlsProxy, server.tlsPort = proxy, proxyPort
+	return nil
+}
+
+// formatTLSAccessURLs lists the HTTPS URLs of a /start --tls proxy. certSource
+// is "self-signed" or the configured certificate file.
+func formatTLSAccessURLs(tlsPort string, ipAddresses []string, certSource string) string {
+	var urls strings.Builder
+	urls.WriteString(fmt.Sprintf("  🔒 HTTPS: https://localhost:%s\n", tlsPort))
+	for _, ip := range ipAddresses {
+		urls.WriteString(fmt.Sprintf("  🔒 HTTPS LAN: https://%s:%s\n", ip, tlsPort))
+	}
+	urls.WriteString(formatCertificateNote(certSource))
+	return urls.String()
+}
+
+// formatCertificateNote says which certificate an HTTPS server uses. certSource
+// is "self-signed" or the configured certificate file.
+func formatCertificateNote(certSource string) string {
+	if certSource == "self-signed" {
+		return "  ⚠️ Self-signed certificate: browsers will warn until you accept it. Set MAVIS_TLS_CERT and MAVIS_TLS_KEY to use your own (e.g. Let's Encrypt) certificate.\n"
+	}
+	return fmt.Sprintf("  📜 Certificate: %s\n", certSource)
+}
+
+// IsLANServerRunning reports whether a LAN server started with /start or /serve is running
+func IsLANServerRunning() bool {
+	lanServerMutex.Lock()
+	defer lanServerMutex.Unlock()
+	return len(lanServers) > 0
+}
+
+// isLANServerPort reports whether a LAN server is running on port, so
+// `/stop <port>` can be told apart from `/stop <agent_id>`
+func isLANServerPort(port string) bool {
+	lanServerMutex.Lock()
+	defer lanServerMutex.Unlock()
+	_, ok := lanServers[port]
+	return ok
+}
+
+// handleStopLANCommand handles `/stop`, `/stop <port>` and `/stop all`. Without
+// arguments a single server is stopped; with several running they are listed.
 func handleStopLANCommand(ctx context.Context, message *models.Message) {
+	target := ""
+	if parts := strings.Fields(message.Text); len(parts) > 1 {
+		target = parts[1]
+	}
+	stopLANServers(ctx, message.Chat.ID, target)
+}
+
+// handleStopAllLANCommand handles `/stop_all`, the same as `/stop all`
+func handleStopAllLANCommand(ctx context.Context, message *models.Message) {
+	stopLANServers(ctx, message.Chat.ID, "all")
+}
+
+// stopLANServers stops the servers selected by target, each releasing its
+// own UPnP mapping, TLS proxy and mDNS record
+func stopLANServers(ctx context.Context, chatID int64, target string) {
 	lanServerMutex.Lock()
 	defer lanServerMutex.Unlock()
 
-	if lanServerProcess == nil && lanHTTPServer == nil {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ No LAN server is currently running.")
+	ports, reply := selectLANServers(target)
+	if reply != "" {
+		core.SendMessage(ctx, b, chatID, reply)
 		return
 	}
 
-	workdir := lanServerWorkDir
-	port := lanServerPort
-	cmd := lanServerCmd
+	for _, port := range ports {
+		server := lanServers[port]
+		delete(lanServers, port)
+		if err := server.stop(); err != nil {
+			core.SendMessage(ctx, b, chatID, fmt.Sprintf("⚠️ Error while stopping the server on port %s: %v", port, err))
+		}
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("🛑 LAN server stopped.\n%s", server.describe()))
+	}
+}
 
-	// Stop process-based server if running
-	if lanServerProcess != nil {
-		// Kill the server process
-		if err := lanServerProcess.Kill(); err != nil {
-			core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to stop LAN server process: %v", err))
+// selectLANServers returns the ports target ("", "all" or a port) stops, or
+// the reply to send instead when there is nothing to stop or the choice is
+// ambiguous. The caller must hold lanServerMutex.
+func selectLANServers(target string) ([]string, string) {
+	switch {
+	case len(lanServers) == 0:
+		return nil, "❌ No LAN server is currently running."
+	case target == "all":
+		return sortedLANServerPorts(), ""
+	case target != "":
+		if _, ok := lanServers[target]; !ok {
+			return nil, fmt.Sprintf("❌ No LAN server is running on port %s.", target)
 		}
+		return []string{target}, ""
+	case len(lanServers) == 1:
+		return sortedLANServerPorts(), ""
+	default:
+		return nil, formatLANServerList()
+	}
+}
 
-		// Also try to kill any process using the port
-		if lanServerPort != "" {
-			killPortCmd := exec.Command("sh", "-c", fmt.Sprintf("lsof -ti:%s | xargs kill -9 2>/dev/null || true", lanServerPort))
-			killPortCmd.Run()
+// sortedLANServerPorts returns the ports of running servers in numeric order.
+// The caller must hold lanServerMutex.
+func sortedLANServerPorts() []string {
+	ports := make([]string, 0, len(lanServers))
+	for port := range lanServers {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, _ := strconv.Atoi(ports[i])
+		b, _ := strconv.Atoi(ports[j])
+		return a < b
+	})
+	return ports
+}
+
+// formatLANServerList lists the running servers with how to stop them.
+// The caller must hold lanServerMutex.
+func formatLANServerList() string {
+	message := fmt.Sprintf("🌐 *%d LAN servers running:*\n\n", len(lanServers))
+	for _, port := range sortedLANServerPorts() {
+		message += lanServers[port].describe() + "\n\n"
+	}
+	return message + "Use `/stop <port>` to stop one, or `/stop_all` to stop them all."
+}
+
+// maxServeStatsPaths is the number of top paths shown by /serve_stats
+const maxServeStatsPaths = 10
+
+// handleServeStatsCommand reports the requests handled by a /serve file
+// server. Without a port the only running file server is used.
+func handleServeStatsCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+
+	lanServerMutex.Lock()
+	var servers []*lanServer
+	for _, port := range sortedLANServerPorts() {
+		if server := lanServers[port]; server.accessLog != nil && (len(parts) < 2 || port == parts[1]) {
+			servers = append(servers, server)
 		}
 	}
+	lanServerMutex.Unlock()
 
-	// Stop Go HTTP server if running
-	if lanHTTPServer != nil {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	switch {
+	case len(servers) == 0 && len(parts) > 1:
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ No file server started with /serve is running on port %s.", parts[1]))
+		return
+	case len(servers) == 0:
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ No file server started with /serve is running.")
+		return
+	case len(servers) > 1:
+		ports := make([]string, len(servers))
+		for i, server := range servers {
+			ports[i] = server.port
+		}
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Several file servers are running on ports %s.\nUsage: /serve_stats <port>", strings.Join(ports, ", ")))
+		return
+	}
 
-		if err := lanHTTPServer.Shutdown(shutdownCtx); err != nil {
-			core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ Warning: HTTP server shutdown error: %v", err))
+	server := servers[0]
+	core.SendMessage(ctx, b, message.Chat.ID, formatServeStats(server.port, server.workDir, server.accessLog.Stats(maxServeStatsPaths)))
+}
+
+// formatServeStats formats the /serve_stats report
+func formatServeStats(port, workDir string, stats web.AccessStats) string {
+	message := fmt.Sprintf("📊 *File Server Stats*\n\n🔌 Port: %s\n📁 Directory: %s\n📨 Requests: %d\n📦 Sent: %s\n", port, workDir, stats.Total, core.FormatBytes(stats.Bytes))
+	if len(stats.TopPaths) == 0 {
+		return message + "\nNo requests yet."
+	}
+
+	message += "\n*Top paths:*\n"
+	for _, path := range stats.TopPaths {
+		message += fmt.Sprintf("• %s - %d\n", path.Path, path.Count)
+	}
+	return message
+}
+
+// extractAuthFlag removes `--auth user:pass` or `--auth=user:pass` from parts
+// and returns the credentials, nil when the flag is absent
+func extractAuthFlag(parts []string) ([]string, *web.BasicAuth, error) {
+	rest := make([]string, 0, len(parts))
+	var credentials string
+	found := false
+	for i := 0; i < len(parts); i++ {
+		switch {
+		case parts[i] == "--auth":
+			if i+1 >= len(parts) {
+				return nil, nil, fmt.Errorf("--auth needs credentials as user:pass")
+			}
+			credentials, found = parts[i+1], true
+			i++
+		case strings.HasPrefix(parts[i], "--auth="):
+			credentials, found = strings.TrimPrefix(parts[i], "--auth="), true
+		default:
+			rest = append(rest, parts[i])
 		}
 	}
+	if !found {
+		return rest, nil, nil
+	}
 
-	// Clean up UPnP mapping
-	if lanServerPort != "" && upnpManager != nil {
-		portInt, _ := strconv.Atoi(lanServerPort)
-		upnpManager.UnmapPort(portInt)
+	username, password, ok := strings.Cut(credentials, ":")
+	if !ok || username == "" || password == "" {
+		return nil, nil, fmt.Errorf("--auth needs credentials as user:pass")
 	}
+	return rest, &web.BasicAuth{Username: username, Password: password}, nil
+}
 
-	// Clean up
-	lanServerProcess = nil
-	lanHTTPServer = nil
-	lanServerPort = ""
-	lanServerWorkDir = ""
-	lanServerCmd = ""
+// extractNoListingFlag removes --no-listing from parts and reports whether it was present
+func extractNoListingFlag(parts []string) ([]string, bool) {
+	return extractBoolFlag(parts, "--no-listing")
+}
 
-	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🛑 LAN server stopped.\n📁 Workdir: %s\n🔌 Port: %s\n🛠️ Command: %s", workdir, port, cmd))
+// extractBoolFlag removes flag from parts and reports whether it was present
+func extractBoolFlag(parts []string, flag string) ([]string, bool) {
+	rest := make([]string, 0, len(parts))
+	found := false
+	for _, part := range parts {
+		if part == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, part)
+	}
+	return rest, found
 }
 
 func handleServeCommand(ctx context.Context, message *models.Message) {
-	parts := strings.Fields(message.Text)
+	parts, auth, err := extractAuthFlag(strings.Fields(message.Text))
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ %v\nUsage: /serve <directory> [port] [--auth user:pass] [--no-listing] [--spa] [--dotfiles] [--tls] [--public]", err))
+		return
+	}
+	parts, noListing := extractNoListingFlag(parts)
+	parts, spa := extractBoolFlag(parts, "--spa")
+	parts, showDotfiles := extractBoolFlag(parts, "--dotfiles")
+	parts, useTLS := extractBoolFlag(parts, "--tls")
+	parts, public := extractBoolFlag(parts, "--public")
 	if len(parts) < 2 {
-		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory to serve.\nUsage: /serve <directory> [port]\n\nExample: /serve ~/myproject 8080\n\nIf port is not specified, it defaults to 8080.")
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Please provide a directory to serve.\nUsage: /serve <directory> [port] [--auth user:pass] [--no-listing] [--spa] [--dotfiles] [--tls] [--public]\n\nExample: /serve ~/myproject 8080 --auth me:secret --tls\n\nIf port is not specified, it defaults to 8080. With --auth, visitors must log in with HTTP Basic Auth. With --no-listing, directories without an index.html are not listed. With --spa, paths that do not exist serve the root index.html for client-side routing. Dotfiles such as .env are hidden unless --dotfiles is given. With --tls, files are served over HTTPS. The server is only exposed to the internet through UPnP with --auth or --tls, or with --public.")
 		return
 	}
 
@@ -309,9 +617,9 @@ func handleServeCommand(ctx context.Context, message *models.Message) {
 	lanServerMutex.Lock()
 	defer lanServerMutex.Unlock()
 
-	// Check if server is already running
-	if lanServerProcess != nil || lanHTTPServer != nil {
-		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ LAN server is already running!\n📁 Workdir: %s\n🔌 Port: %s\n🛠️ Command: %s\n\nUse /stop to stop it first.", lanServerWorkDir, lanServerPort, lanServerCmd))
+	// Check if one of our servers already uses the port
+	if existing, ok := lanServers[port]; ok {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ A LAN server is already running on port %s!\n%s\n\nUse `/stop %s` to stop it first, or pick another port.", port, existing.describe(), port))
 		return
 	}
 
@@ -328,19 +636,32 @@ func handleServeCommand(ctx context.Context, message *models.Message) {
 		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ Using port %s instead", port))
 	}
 
-	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🚀 Starting LAN file server...\n📁 Directory: %s\n🔌 Port: %s\n🛠️ Server: Go HTTP Server", absWorkdir, port))
-
-	// Start the Go file server
-	_, err = web.StartFileServer(absWorkdir, port)
-	if err != nil {
-		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to start LAN file server: %v", err))
-		return
+	authStatus := "🔓 Auth: none"
+	command := fmt.Sprintf("Go file server on port %s", port)
+	if auth != nil {
+		authStatus = fmt.Sprintf("🔒 Auth: basic (user %s)", auth.Username)
+		command += " with basic auth"
+	}
+	if noListing {
+		authStatus += "\n🙈 Directory listing: off"
+		command += ", no directory listing"
+	}
+	if spa {
+		authStatus += "\n🧭 SPA fallback: missing paths serve index.html"
+		command += ", SPA fallback"
+	}
+	if showDotfiles {
+		authStatus += "\n👀 Dotfiles: shown"
+		command += ", dotfiles shown"
+	}
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+		authStatus += "\n🔒 HTTPS: on"
+		command += ", HTTPS"
 	}
 
-	// Store the server info
-	lanServerPort = port
-	lanServerWorkDir = absWorkdir
-	lanServerCmd = fmt.Sprintf("Go file server on port %s", port)
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("🚀 Starting LAN file server...\n📁 Directory: %s\n🔌 Port: %s\n🛠️ Server: Go HTTP Server\n%s", absWorkdir, port, authStatus))
 
 	// Get local IP addresses
 	var ipAddresses []string
@@ -355,11 +676,39 @@ func handleServeCommand(ctx context.Context, message *models.Message) {
 		}
 	}
 
+	// Start the Go file server
+	opts := web.FileServerOptions{Auth: auth, NoListing: noListing, SPA: spa, ShowDotfiles: showDotfiles, TLS: useTLS}
+	if useTLS {
+		opts.TLSHosts = append([]string{"localhost", "127.0.0.1", lanDomainName}, ipAddresses...)
+	}
+	httpServer, accessLog, err := web.StartFileServer(absWorkdir, port, opts)
+	if err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to start LAN file server: %v", err))
+		return
+	}
+
+	// Store the server info
+	lanServers[port] = &lanServer{
+		port:       port,
+		workDir:    absWorkdir,
+		command:    command,
+		httpServer: httpServer,
+		accessLog:  accessLog,
+	}
+	advertiseMDNS(port, "Mavis Files "+filepath.Base(absWorkdir))
+
 	// Try to set up UPnP port mapping
 	portInt, _ := strconv.Atoi(port)
+	upnpNote := "💡 *Note:* Attempting to expose to internet via UPnP..."
+	if !serveUPnPAllowed(auth != nil, useTLS, public) {
+		upnpNote = "🔒 *LAN only:* UPnP mapping was skipped because the server has neither `--auth` nor `--tls`. Restart with one of them, or add `--public` to expose it anyway."
+	}
 
 	// Attempt UPnP mapping in a goroutine to not block startup
 	go func() {
+		if !serveUPnPAllowed(auth != nil, useTLS, public) {
+			return
+		}
 		core.SendMessage(ctx, b, message.Chat.ID, "🔌 Attempting UPnP port mapping...")
 
 		if upnpManager != nil {
@@ -375,8 +724,11 @@ func handleServeCommand(ctx context.Context, message *models.Message) {
 					core.SendMessage(ctx, b, message.Chat.ID, "⚠️ UPnP succeeded but couldn't get public IP. Server is accessible on LAN.")
 				} else {
 					// Send success message with public URL
-					publicURL := fmt.Sprintf("http://%s:%s", publicIP, port)
+					publicURL := fmt.Sprintf("%s://%s:%s", scheme, publicIP, port)
 					core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ UPnP mapping successful!\n\n🌍 *Public URL:* %s\n\n⚠️ *Important:* This URL is accessible from the internet!", publicURL))
+					if auth == nil {
+						core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ *No authentication:* anyone who finds %s can browse and download every file in %s.\nUse `/stop %s` and restart with `--auth user:pass` to require a login.", publicURL, absWorkdir, port))
+					}
 				}
 			}
 		} else {
@@ -388,14 +740,196 @@ func handleServeCommand(ctx context.Context, message *models.Message) {
 	// Build access URLs
 	var accessURLs strings.Builder
 	accessURLs.WriteString("\n🌐 *Access URLs:*\n")
-	accessURLs.WriteString(fmt.Sprintf("  🏠 Local: http://localhost:%s\n", port))
+	accessURLs.WriteString(fmt.Sprintf("  🏠 Local: %s://localhost:%s\n", scheme, port))
 	for _, ip := range ipAddresses {
-		accessURLs.WriteString(fmt.Sprintf("  📡 LAN: http://%s:%s\n", ip, port))
+		accessURLs.WriteString(fmt.Sprintf("  📡 LAN: %s://%s:%s\n", scheme, ip, port))
+	}
+	if mdnsResponder != nil {
+		accessURLs.WriteString(fmt.Sprintf("  🎯 mDNS: %s://%s:%s\n", scheme, lanDomainName, port))
+	}
+	if useTLS {
+		accessURLs.WriteString(formatCertificateNote(web.TLSCertificateSource()))
 	}
-	accessURLs.WriteString(fmt.Sprintf("  🎯 mDNS: http://%s:%s (if available)\n", lanDomainName, port))
 
 	// Success message
-	successMsg := fmt.Sprintf("✅ LAN file server started successfully!\n📁 Serving: %s\n🔌 Port: %s\n📄 Server: Go HTTP Server\n%s\n💡 *Note:* Attempting to expose to internet via UPnP...", absWorkdir, port, accessURLs.String())
+	successMsg := fmt.Sprintf("✅ LAN file server started successfully!\n📁 Serving: %s\n🔌 Port: %s\n📄 Server: Go HTTP Server\n%s\n%s\n%s", absWorkdir, port, authStatus, accessURLs.String(), upnpNote)
 
 	core.SendMessage(ctx, b, message.Chat.ID, successMsg)
 }
+
+// serveUPnPAllowed reports whether a /serve file server may be exposed to the
+// internet: only behind a login or HTTPS, unless --public overrides it
+func serveUPnPAllowed(auth, useTLS, public bool) bool {
+	return auth || useTLS || public
+}
+
+func handlePortsCommand(ctx context.Context, message *models.Message) {
+	ports, method := core.ListListeningPorts()
+
+	lanServerMutex.Lock()
+	mavisPorts := make(map[int]bool)
+	for port, server := range lanServers {
+		if portInt, err := strconv.Atoi(port); err == nil {
+			mavisPorts[portInt] = true
+		}
+		if tlsPort, err := strconv.Atoi(server.tlsPort); err == nil {
+			mavisPorts[tlsPort] = true
+		}
+	}
+	lanServerMutex.Unlock()
+
+	core.SendLongMessage(ctx, b, message.Chat.ID, formatListeningPorts(ports, method, mavisPorts))
+}
+
+// formatListeningPorts formats the /ports report. mavisPorts marks the ports
+// of LAN servers started with /start or /serve.
+func formatListeningPorts(ports []core.ListeningPort, method string, mavisPorts map[int]bool) string {
+	message := fmt.Sprintf("🔌 *Listening TCP Ports* (via %s)\n\n", method)
+	if method == "probe" {
+		message = "🔌 *Listening TCP Ports*\n\nℹ️ Neither ss nor lsof is installed, so only common development ports were checked and process names are unknown.\n\n"
+	}
+	if len(ports) == 0 {
+		return message + "No listening ports found."
+	}
+
+	for _, port := range ports {
+		line := fmt.Sprintf("• `%d`", port.Port)
+		if port.Process != "" {
+			line += " - " + port.Process
+			if port.PID > 0 {
+				line += fmt.Sprintf(" (pid %d)", port.PID)
+			}
+		}
+		if len(port.Addresses) > 0 {
+			line += " on " + strings.Join(port.Addresses, ", ")
+		}
+		if mavisPorts[port.Port] {
+			line += " 🌐 Mavis LAN server"
+		}
+		message += line + "\n"
+	}
+	return message + "\nPick a free port for `/start` or `/serve`; `/serve` finds the next free one if the port is taken."
+}
+
+// handleFreePortCommand handles `/freeport <port>`: it stops whatever process
+// listens on the port after the user confirms. Ports of Mavis LAN servers are
+// left to /stop, which also releases their proxy and UPnP mapping.
+func handleFreePortCommand(ctx context.Context, message *models.Message) {
+	parts := strings.Fields(message.Text)
+	if len(parts) != 2 {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: /freeport <port>\n\nExample: /freeport 3000")
+		return
+	}
+	port, err := strconv.Atoi(parts[1])
+	if err != nil || port < 1 || port > 65535 {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Invalid port: %s", parts[1]))
+		return
+	}
+
+	if serverPort := lanServerUsingPort(port); serverPort != "" {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("ℹ️ Port %d is used by a Mavis LAN server. Use `/stop %s` to stop it.", port, serverPort))
+		return
+	}
+
+	pids, ok := core.ListeningPIDs(port)
+	switch {
+	case !ok:
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Neither ss nor lsof is installed, so the process using the port cannot be found.")
+		return
+	case len(pids) == 0 && core.IsPortInUse(strconv.Itoa(port)):
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Port %d is in use but its process is unknown; it may belong to another user.", port))
+		return
+	case len(pids) == 0:
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ Port %d is already free.", port))
+		return
+	}
+
+	process := ""
+	ports, _ := core.ListListeningPorts()
+	for _, listening := range ports {
+		if listening.Port == port {
+			process = listening.Process
+		}
+	}
+
+	requestConfirmation(message.From.ID, fmt.Sprintf("free port %d", port), func(ctx context.Context, chatID int64) {
+		freePort(ctx, chatID, port)
+	})
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("⚠️ This will stop %s listening on port %d.\n\nReply *yes* within %s to confirm, anything else cancels.", describePortOwners(process, pids), port, confirmationTimeout))
+}
+
+// freePort stops the processes on port once /freeport is confirmed
+func freePort(ctx context.Context, chatID int64, port int) {
+	pids, err := core.FreePort(port, 5*time.Second)
+	if err != nil {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if len(pids) == 0 {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Port %d is already free.", port))
+		return
+	}
+	if core.IsPortInUse(strconv.Itoa(port)) {
+		core.SendMessage(ctx, b, chatID, fmt.Sprintf("⚠️ Stopped %s but port %d is still in use.", describePortOwners("", pids), port))
+		return
+	}
+	core.SendMessage(ctx, b, chatID, fmt.Sprintf("✅ Stopped %s, port %d is free.", describePortOwners("", pids), port))
+}
+
+// describePortOwners names the processes holding a port, e.g. "node (pid 1234)"
+func describePortOwners(process string, pids []int) string {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+	description := "pid " + strings.Join(ids, ", ")
+	if len(pids) > 1 {
+		description = "pids " + strings.Join(ids, ", ")
+	}
+	if process == "" {
+		return description
+	}
+	return fmt.Sprintf("%s (%s)", process, description)
+}
+
+// lanServerUsingPort returns the port of the LAN server listening on port,
+// directly or through its HTTPS proxy, or "" when there is none
+func lanServerUsingPort(port int) string {
+	lanServerMutex.Lock()
+	defer lanServerMutex.Unlock()
+	for serverPort, server := range lanServers {
+		if serverPort == strconv.Itoa(port) || server.tlsPort == strconv.Itoa(port) {
+			return serverPort
+		}
+	}
+	return ""
+}
+
+func handleUPnPStatusCommand(ctx context.Context, message *models.Message) {
+	if upnpManager == nil {
+		core.SendMessage(ctx, b, message.Chat.ID, "ℹ️ UPnP is not available: no UPnP router was found at startup.")
+		return
+	}
+	core.SendMessage(ctx, b, message.Chat.ID, formatUPnPStatus(upnpManager.GetExternalIP(), upnpManager.GetInternalIP(), upnpManager.Mappings()))
+}
+
+// formatUPnPStatus formats the /upnp_status report
+func formatUPnPStatus(externalIP, internalIP string, mappings []UPnPMappingStatus) string {
+	if externalIP == "" {
+		externalIP = "unknown"
+	}
+	message := fmt.Sprintf("🌐 *UPnP Status*\n\n🌍 External IP: %s\n🏠 Internal IP: %s\n\n", externalIP, internalIP)
+	if len(mappings) == 0 {
+		return message + "No active port mappings."
+	}
+
+	message += fmt.Sprintf("*%d active mapping(s):*\n", len(mappings))
+	for _, mapping := range mappings {
+		lease := "permanent"
+		if mapping.Lease > 0 {
+			lease = fmt.Sprintf("%s left of %s lease", mapping.Remaining.Round(time.Second), mapping.Lease)
+		}
+		message += fmt.Sprintf("• %d → %d/%s - %s\n   ⏳ %s\n", mapping.ExternalPort, mapping.InternalPort, mapping.Protocol, mapping.Description, lease)
+	}
+	return message
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"mavis/core"
+)
+
+func TestLANServerRegistry(t *testing.T) {
+	lanServerMutex.Lock()
+	old := lanServers
+	lanServers = map[string]*lanServer{
+		"8080": {port: "8080", workDir: "/srv/docs", command: "Go file server on port 8080"},
+		"3000": {port: "3000", workDir: "/srv/app", command: "rails s"},
+	}
+	lanServerMutex.Unlock()
+	defer func() {
+		lanServerMutex.Lock()
+		lanServers = old
+		lanServerMutex.Unlock()
+	}()
+
+	if !IsLANServerRunning() {
+		t.Error("Expected LAN servers to be reported as running")
+	}
+	if !isLANServerPort("3000") || isLANServerPort("1") {
+		t.Error("Expected only running server ports to be recognised")
+	}
+
+	lanServerMutex.Lock()
+	ports := strings.Join(sortedLANServerPorts(), ",")
+	list := formatLANServerList()
+	lanServerMutex.Unlock()
+
+	if ports != "3000,8080" {
+		t.Errorf("Expected ports in numeric order 3000,8080, got %s", ports)
+	}
+	if !strings.Contains(list, "2 LAN servers running") || strings.Index(list, "rails s") > strings.Index(list, "Go file server") {
+		t.Errorf("Expected both servers listed by port, got %s", list)
+	}
+
+	tests := []struct {
+		target string
+		ports  string
+		reply  string
+	}{
+		{"", "", "2 LAN servers running"},
+		{"all", "3000,8080", ""},
+		{"8080", "8080", ""},
+		{"9999", "", "No LAN server is running on port 9999"},
+	}
+	lanServerMutex.Lock()
+	for _, tt := range tests {
+		ports, reply := selectLANServers(tt.target)
+		if strings.Join(ports, ",") != tt.ports || (tt.reply == "") != (reply == "") || !strings.Contains(reply, tt.reply) {
+			t.Errorf("Target %q: expected ports %q and reply %q, got %v and %q", tt.target, tt.ports, tt.reply, ports, reply)
+		}
+	}
+	delete(lanServers, "8080")
+	if ports, reply := selectLANServers(""); strings.Join(ports, ",") != "3000" || reply != "" {
+		t.Errorf("Expected the only server to be selected, got %v and %q", ports, reply)
+	}
+	lanServers = map[string]*lanServer{}
+	if _, reply := selectLANServers("all"); !strings.Contains(reply, "No LAN server") {
+		t.Errorf("Expected no servers reply, got %q", reply)
+	}
+	lanServerMutex.Unlock()
+}
+
+func TestExtractBoolFlag(t *testing.T) {
+	parts, found := extractBoolFlag(strings.Fields("/serve ~/docs --tls 3000"), "--tls")
+	if !found || strings.Join(parts, " ") != "/serve ~/docs 3000" {
+		t.Errorf("Expected --tls found and removed, got %v (found %v)", parts, found)
+	}
+	if _, found := extractBoolFlag(strings.Fields("/serve ~/docs 3000"), "--tls"); found {
+		t.Error("Expected --tls not to be found")
+	}
+}
+
+func TestServeUPnPAllowed(t *testing.T) {
+	tests := []struct {
+		auth, useTLS, public bool
+		expected             bool
+	}{
+		{false, false, false, false},
+		{true, false, false, true},
+		{false, true, false, true},
+		{false, false, true, true},
+	}
+	for _, tt := range tests {
+		if got := serveUPnPAllowed(tt.auth, tt.useTLS, tt.public); got != tt.expected {
+			t.Errorf("auth=%v tls=%v public=%v: expected %v, got %v", tt.auth, tt.useTLS, tt.public, tt.expected, got)
+		}
+	}
+}
+
+func TestExtractAuthFlag(t *testing.T) {
+	parts, auth, err := extractAuthFlag(strings.Fields("/serve ~/docs --auth me:p:ss 3000"))
+	if err != nil || auth == nil || auth.Username != "me" || auth.Password != "p:ss" {
+		t.Fatalf("Expected me/p:ss, got %+v (err %v)", auth, err)
+	}
+	if strings.Join(parts, " ") != "/serve ~/docs 3000" {
+		t.Errorf("Expected flag removed, got %v", parts)
+	}
+
+	if _, auth, err := extractAuthFlag(strings.Fields("/serve ~/docs --auth=me:secret")); err != nil || auth == nil || auth.Username != "me" {
+		t.Errorf("Expected --auth= form to be parsed, got %+v (err %v)", auth, err)
+	}
+	if _, auth, err := extractAuthFlag(strings.Fields("/serve ~/docs")); err != nil || auth != nil {
+		t.Errorf("Expected no auth, got %+v (err %v)", auth, err)
+	}
+	for _, text := range []string{"/serve ~/docs --auth", "/serve ~/docs --auth me", "/serve ~/docs --auth :secret"} {
+		if _, _, err := extractAuthFlag(strings.Fields(text)); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestExtractNoListingFlag(t *testing.T) {
+	parts, noListing := extractNoListingFlag(strings.Fields("/serve --no-listing ~/docs 3000"))
+	if !noListing || strings.Join(parts, " ") != "/serve ~/docs 3000" {
+		t.Errorf("Expected flag removed and set, got %v %v", parts, noListing)
+	}
+	if _, noListing := extractNoListingFlag(strings.Fields("/serve ~/docs")); noListing {
+		t.Error("Expected no flag")
+	}
+}
+
+func TestFormatListeningPorts(t *testing.T) {
+	ports := []core.ListeningPort{
+		{Port: 22, Addresses: []string{"*"}},
+		{Port: 3000, Addresses: []string{"*"}, Process: "node", PID: 1234},
+	}
+	message := formatListeningPorts(ports, "ss", map[int]bool{3000: true})
+	for _, expected := range []string{"via ss", "`22` on *", "`3000` - node (pid 1234) on * 🌐 Mavis LAN server"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Expected %q in %s", expected, message)
+		}
+	}
+
+	if message := formatListeningPorts(nil, "probe", nil); !strings.Contains(message, "Neither ss nor lsof") || !strings.Contains(message, "No listening ports") {
+		t.Errorf("Expected probe note and empty list, got %s", message)
+	}
+}
+
+func TestExtractTLSFlag(t *testing.T) {
+	parts, useTLS := extractTLSFlag(strings.Fields("/start --tls ~/app 3000 npm run dev"))
+	if !useTLS || strings.Join(parts, " ") != "/start ~/app 3000 npm run dev" {
+		t.Errorf("Expected flag removed and set, got %v %v", parts, useTLS)
+	}
+
+	// Flags of the build command are left alone
+	parts, useTLS = extractTLSFlag(strings.Fields("/start ~/app 3000 server --tls"))
+	if useTLS || len(parts) != 5 {
+		t.Errorf("Expected the build command flag to be kept, got %v %v", parts, useTLS)
+	}
+}
+
+func TestFormatTLSAccessURLs(t *testing.T) {
+	urls := formatTLSAccessURLs("8443", []string{"192.168.1.20"}, "self-signed")
+	for _, expected := range []string{"https://localhost:8443", "https://192.168.1.20:8443", "Self-signed certificate"} {
+		if !strings.Contains(urls, expected) {
+			t.Errorf("Expected %q in %s", expected, urls)
+		}
+	}
+	if urls := formatTLSAccessURLs("8443", nil, "/etc/letsencrypt/live/x/fullchain.pem"); !strings.Contains(urls, "📜 Certificate: /etc/letsencrypt") {
+		t.Errorf("Expected certificate path, got %s", urls)
+	}
+}
+
+func TestDescribePortOwners(t *testing.T) {
+	if got := describePortOwners("node", []int{1234}); got != "node (pid 1234)" {
+		t.Errorf("Expected node (pid 1234), got %q", got)
+	}
+	if got := describePortOwners("", []int{1234, 1240}); got != "pids 1234, 1240" {
+		t.Errorf("Expected pids 1234, 1240, got %q", got)
+	}
+}
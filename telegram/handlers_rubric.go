@@ -0,0 +1,49 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mavis/core"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func handleRubricsCommand(ctx context.Context, message *models.Message) {
+	var sb strings.Builder
+	sb.WriteString("📏 *Review Rubrics*\n\n")
+	for _, rubric := range core.ListReviewRubrics() {
+		sb.WriteString(fmt.Sprintf("• `%s`", rubric.Name))
+		if rubric.Description != "" {
+			sb.WriteString(" - " + rubric.Description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse `/review --rubric <name> ...` or `/pr --rubric <name> ...` to apply one.\nAdd or replace one with `/rubric <name> <instructions>`.")
+
+	core.SendMessage(ctx, b, message.Chat.ID, sb.String())
+}
+
+func handleRubricCommand(ctx context.Context, message *models.Message) {
+	// Split off the command and name, keeping the instructions' line breaks
+	fields := strings.SplitN(strings.TrimSpace(message.Text), " ", 3)
+	if len(fields) < 3 || strings.TrimSpace(fields[2]) == "" {
+		core.SendMessage(ctx, b, message.Chat.ID, "❌ Usage: `/rubric <name> <instructions>`\n\nExample: `/rubric security Focus on injection, authentication and secrets handling. Flag any unsafe input parsing.`")
+		return
+	}
+
+	rubric := core.ReviewRubric{
+		Name:         fields[1],
+		Instructions: strings.TrimSpace(fields[2]),
+	}
+	if err := core.SaveReviewRubric(rubric); err != nil {
+		core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("❌ Failed to save rubric: %v", err))
+		return
+	}
+
+	core.SendMessage(ctx, b, message.Chat.ID, fmt.Sprintf("✅ Review rubric `%s` saved.\n\nUse `/review --rubric %s <directory>` to apply it.", strings.ToLower(rubric.Name), strings.ToLower(rubric.Name)))
+}
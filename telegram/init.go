@@ -4,12 +4,15 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
 	"sync"
+	"time"
 
 	"mavis/codeagent"
+	"mavis/core"
 
 	"github.com/go-telegram/bot"
 )
@@ -21,16 +24,16 @@ var (
 	AdminUserID  int64
 
 	// Image tracking for users
-	userPendingImages  = make(map[int64][]string) // userID -> array of image paths
+	userPendingImages  = make(map[int64][]pendingImage) // userID -> images waiting for the next /code
 	pendingImagesMutex sync.RWMutex
 
-	// LAN server tracking
-	lanServerProcess *os.Process
-	lanHTTPServer    *http.Server
-	lanServerPort    string
-	lanServerWorkDir string
-	lanServerCmd     string
-	lanServerMutex   sync.Mutex
+	// Destructive commands waiting for a "yes" reply
+	pendingConfirmations = make(map[int64]pendingConfirmation) // userID -> confirmation
+	confirmationsMutex   sync.Mutex
+
+	// LAN server tracking, keyed by port
+	lanServers     = make(map[string]*lanServer)
+	lanServerMutex sync.Mutex
 	
 	// LAN domain name for mDNS
 	lanDomainName = "mavis.local"
@@ -43,11 +46,18 @@ func InitializeGlobals(botInstance *bot.Bot, manager *codeagent.Manager, adminID
 	AdminUserID = adminID
 }
 
-func addPendingImage(userID int64, imagePath string) {
+// pendingImage is a downloaded image waiting to be passed to the next /code
+type pendingImage struct {
+	Path   string
+	Size   int64  // Size in bytes
+	Format string // Format detected from the content, e.g. png
+}
+
+func addPendingImage(userID int64, image pendingImage) {
 	pendingImagesMutex.Lock()
 	defer pendingImagesMutex.Unlock()
 
-	userPendingImages[userID] = append(userPendingImages[userID], imagePath)
+	userPendingImages[userID] = append(userPendingImages[userID], image)
 }
 
 func getPendingImageCount(userID int64) int {
@@ -57,11 +67,11 @@ func getPendingImageCount(userID int64) int {
 	return len(userPendingImages[userID])
 }
 
-func getPendingImages(userID int64) []string {
+func getPendingImages(userID int64) []pendingImage {
 	pendingImagesMutex.RLock()
 	defer pendingImagesMutex.RUnlock()
 
-	images := make([]string, len(userPendingImages[userID]))
+	images := make([]pendingImage, len(userPendingImages[userID]))
 	copy(images, userPendingImages[userID])
 	return images
 }
@@ -72,8 +82,8 @@ func clearPendingImages(userID int64) {
 
 	// Delete the image files
 	if images, exists := userPendingImages[userID]; exists {
-		for _, imagePath := range images {
-			os.Remove(imagePath)
+		for _, image := range images {
+			os.Remove(image.Path)
 		}
 	}
 
@@ -98,6 +108,11 @@ func InitializeUPnP() {
 	}
 	
 	upnpManager = manager
+	upnpManager.SetRenewalFailureHandler(func(port int, err error) {
+		if b != nil && AdminUserID != 0 {
+			core.SendMessage(context.Background(), b, AdminUserID, fmt.Sprintf("⚠️ UPnP mapping for port %d could not be renewed: %v\n\nThe public URL may stop working; it will be retried at the next renewal. Use `/upnp_status` to check.", port, err))
+		}
+	})
 	upnpManager.StartRefreshTimer()
 	
 	if upnpManager.GetExternalIP() != "" {
@@ -106,3 +121,77 @@ func InitializeUPnP() {
 	}
 }
 
+
+// confirmationTimeout is how long a destructive command waits for "yes"
+const confirmationTimeout = 30 * time.Second
+
+// pendingConfirmation is a destructive command waiting for the user to reply "yes"
+type pendingConfirmation struct {
+	Action  string // What will happen, e.g. "kill all agents"
+	Expires time.Time
+	Run     func(ctx context.Context, chatID int64)
+}
+
+// requestConfirmation stores action for userID, replacing any earlier one,
+// until it is confirmed or confirmationTimeout passes
+func requestConfirmation(userID int64, action string, run func(ctx context.Context, chatID int64)) {
+	confirmationsMutex.Lock()
+	defer confirmationsMutex.Unlock()
+
+	pendingConfirmations[userID] = pendingConfirmation{
+		Action:  action,
+		Expires: time.Now().Add(confirmationTimeout),
+		Run:     run,
+	}
+}
+
+// takeConfirmation removes and returns the confirmation pending for userID.
+// expired is set when there was one but it timed out.
+func takeConfirmation(userID int64) (confirmation pendingConfirmation, ok bool, expired bool) {
+	confirmationsMutex.Lock()
+	defer confirmationsMutex.Unlock()
+
+	confirmation, ok = pendingConfirmations[userID]
+	if !ok {
+		return pendingConfirmation{}, false, false
+	}
+	delete(pendingConfirmations, userID)
+	if time.Now().After(confirmation.Expires) {
+		return confirmation, false, true
+	}
+	return confirmation, true, false
+}
+
+// Global mDNS responder, nil unless enabled and multicast is available
+var mdnsResponder *core.MDNSResponder
+
+// SetLANDomainName changes the .local name answered over mDNS and shown in
+// LAN server URLs. Call it before InitializeMDNS.
+func SetLANDomainName(name string) error {
+	normalized, err := core.NormalizeMDNSHostname(name)
+	if err != nil {
+		return err
+	}
+	lanDomainName = normalized
+	return nil
+}
+
+// InitializeMDNS starts answering mDNS queries for lanDomainName so LAN
+// servers are reachable at http://mavis.local:<port> (or the configured name)
+func InitializeMDNS() {
+	responder, err := core.NewMDNSResponder(lanDomainName)
+	if err != nil {
+		// mDNS is optional, so just log the error
+		log.Printf("mDNS initialization failed (this is optional): %v", err)
+		return
+	}
+	mdnsResponder = responder
+	log.Printf("mDNS enabled - answering for %s", lanDomainName)
+}
+
+// ShutdownMDNS withdraws every advertised server and stops the responder
+func ShutdownMDNS() {
+	if mdnsResponder != nil {
+		mdnsResponder.Close()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,6 +16,10 @@ import (
 	"github.com/huin/goupnp/dcps/internetgateway2"
 )
 
+// upnpLeaseDuration is the lease requested for port mappings. Mappings are
+// renewed at half the lease so they never lapse while a server is running.
+const upnpLeaseDuration = time.Hour
+
 // UPnPManager handles UPnP port forwarding
 type UPnPManager struct {
 	client       upnpClient
@@ -22,6 +27,7 @@ type UPnPManager struct {
 	mu           sync.Mutex
 	externalIP   string
 	internalIP   string
+	onRenewalFailure func(port int, err error) // Called when a mapping cannot be renewed
 }
 
 type portMapping struct {
@@ -29,6 +35,18 @@ type portMapping struct {
 	externalPort int
 	protocol     string
 	description  string
+	lease        time.Duration // Zero if the router only accepts permanent mappings
+	renewedAt    time.Time
+}
+
+// UPnPMappingStatus describes an active port mapping for /upnp_status
+type UPnPMappingStatus struct {
+	InternalPort int
+	ExternalPort int
+	Protocol     string
+	Description  string
+	Lease        time.Duration // Zero for a permanent mapping
+	Remaining    time.Duration // Lease time left before the mapping expires
 }
 
 // upnpClient interface to abstract the specific IGD client
@@ -122,24 +140,12 @@ func (m *UPnPManager) MapPort(internal, external int, protocol, description stri
 		protocol = "TCP"
 	}
 
-	// Use internal IP
-	internalClient := m.internalIP
-	if internalClient == "" {
+	// Mappings point at our internal IP
+	if m.internalIP == "" {
 		return fmt.Errorf("internal IP not available")
 	}
 
-	// Add port mapping with 0 lease duration (permanent until removed)
-	err := m.client.AddPortMapping(
-		"",                    // remoteHost (empty = any)
-		uint16(external),      // externalPort
-		protocol,              // protocol
-		uint16(internal),      // internalPort
-		internalClient,        // internalClient
-		true,                  // enabled
-		description,           // description
-		0,                     // leaseDuration (0 = permanent)
-	)
-
+	lease, err := m.addPortMapping(internal, external, protocol, description)
 	if err != nil {
 		return fmt.Errorf("failed to add port mapping: %w", err)
 	}
@@ -150,12 +156,74 @@ func (m *UPnPManager) MapPort(internal, external int, protocol, description stri
 		externalPort: external,
 		protocol:     protocol,
 		description:  description,
+		lease:        lease,
+		renewedAt:    time.Now(),
 	}
 
 	log.Printf("Successfully mapped port %d:%d (%s) - %s", external, internal, protocol, description)
 	return nil
 }
 
+// addPortMapping adds a mapping with upnpLeaseDuration, falling back to a
+// permanent mapping for routers that reject leases. It returns the lease used.
+// The caller must hold m.mu.
+func (m *UPnPManager) addPortMapping(internal, external int, protocol, description string) (time.Duration, error) {
+	err := m.client.AddPortMapping(
+		"",                                    // remoteHost (empty = any)
+		uint16(external),                      // externalPort
+		protocol,                              // protocol
+		uint16(internal),                      // internalPort
+		m.internalIP,                          // internalClient
+		true,                                  // enabled
+		description,                           // description
+		uint32(upnpLeaseDuration/time.Second), // leaseDuration
+	)
+	if err == nil {
+		return upnpLeaseDuration, nil
+	}
+
+	// Some routers only support permanent mappings (UPnP error 725)
+	log.Printf("UPnP: Leased mapping for port %d failed (%v), retrying as permanent", external, err)
+	if err := m.client.AddPortMapping("", uint16(external), protocol, uint16(internal), m.internalIP, true, description, 0); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// SetRenewalFailureHandler sets a function called when a mapping cannot be renewed
+func (m *UPnPManager) SetRenewalFailureHandler(handler func(port int, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onRenewalFailure = handler
+}
+
+// Mappings returns the active port mappings sorted by external port
+func (m *UPnPManager) Mappings() []UPnPMappingStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]UPnPMappingStatus, 0, len(m.mappedPorts))
+	for _, mapping := range m.mappedPorts {
+		status := UPnPMappingStatus{
+			InternalPort: mapping.internalPort,
+			ExternalPort: mapping.externalPort,
+			Protocol:     mapping.protocol,
+			Description:  mapping.description,
+			Lease:        mapping.lease,
+		}
+		if mapping.lease > 0 {
+			status.Remaining = mapping.renewedAt.Add(mapping.lease).Sub(now)
+			if status.Remaining < 0 {
+				status.Remaining = 0
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ExternalPort < statuses[j].ExternalPort })
+	return statuses
+}
+
 // UnmapPort removes a port mapping
 func (m *UPnPManager) UnmapPort(port int) {
 	m.mu.Lock()
@@ -200,6 +268,8 @@ func (m *UPnPManager) UnmapAllPorts() {
 
 // GetExternalIP returns the external IP address
 func (m *UPnPManager) GetExternalIP() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.externalIP
 }
 
@@ -208,38 +278,54 @@ func (m *UPnPManager) GetInternalIP() string {
 	return m.internalIP
 }
 
-// RefreshMappings refreshes all port mappings (useful for routers that timeout mappings)
+// RefreshMappings renews all port mappings before their lease expires and
+// refreshes the external IP. Mappings that fail to renew are reported to the
+// renewal failure handler and retried on the next refresh.
 func (m *UPnPManager) RefreshMappings() error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	if m.client == nil {
+		m.mu.Unlock()
 		return fmt.Errorf("UPnP client not initialized")
 	}
 
-	for _, mapping := range m.mappedPorts {
-		err := m.client.AddPortMapping(
-			"",
-			uint16(mapping.externalPort),
-			mapping.protocol,
-			uint16(mapping.internalPort),
-			m.internalIP,
-			true,
-			mapping.description,
-			0,
-		)
+	type failure struct {
+		port int
+		err  error
+	}
+	var failures []failure
+	for port, mapping := range m.mappedPorts {
+		lease, err := m.addPortMapping(mapping.internalPort, mapping.externalPort, mapping.protocol, mapping.description)
 		if err != nil {
 			log.Printf("Failed to refresh port mapping %d: %v", mapping.externalPort, err)
+			failures = append(failures, failure{port, err})
+			continue
 		}
+		mapping.lease = lease
+		mapping.renewedAt = time.Now()
+		m.mappedPorts[port] = mapping
+	}
+	if externalIP, err := m.client.GetExternalIPAddress(); err == nil && externalIP != "" {
+		m.externalIP = externalIP
 	}
+	handler := m.onRenewalFailure
+	m.mu.Unlock()
 
+	// Notify outside the lock, the handler may send messages
+	if handler != nil {
+		for _, f := range failures {
+			handler(f.port, f.err)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to renew %d port mapping(s)", len(failures))
+	}
 	return nil
 }
 
-// StartRefreshTimer starts a timer to periodically refresh mappings
+// StartRefreshTimer starts a timer that renews mappings at half their lease
 func (m *UPnPManager) StartRefreshTimer() {
 	go func() {
-		ticker := time.NewTicker(30 * time.Minute)
+		ticker := time.NewTicker(upnpLeaseDuration / 2)
 		defer ticker.Stop()
 
 		for range ticker.C {
@@ -0,0 +1,105 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeUPnPClient records mappings and can reject leased or all mappings
+type fakeUPnPClient struct {
+	leases       []uint32
+	rejectLeases bool
+	fail         bool
+}
+
+func (c *fakeUPnPClient) AddPortMapping(remoteHost string, externalPort uint16, protocol string,
+	internalPort uint16, internalClient string, enabled bool, description string, leaseDuration uint32) error {
+	if c.fail || (c.rejectLeases && leaseDuration > 0) {
+		return errors.New("725 OnlyPermanentLeasesSupported")
+	}
+	c.leases = append(c.leases, leaseDuration)
+	return nil
+}
+
+func (c *fakeUPnPClient) DeletePortMapping(remoteHost string, externalPort uint16, protocol string) error {
+	return nil
+}
+
+func (c *fakeUPnPClient) GetExternalIPAddress() (string, error) {
+	return "203.0.113.7", nil
+}
+
+func newTestUPnPManager(client *fakeUPnPClient) *UPnPManager {
+	return &UPnPManager{client: client, mappedPorts: make(map[int]portMapping), internalIP: "192.168.1.2"}
+}
+
+func TestUPnPMapPortLease(t *testing.T) {
+	client := &fakeUPnPClient{}
+	manager := newTestUPnPManager(client)
+	if err := manager.MapPort(8080, 8080, "TCP", "test"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	mappings := manager.Mappings()
+	if len(mappings) != 1 || mappings[0].Lease != upnpLeaseDuration || mappings[0].Remaining <= 0 {
+		t.Fatalf("Expected one leased mapping, got %+v", mappings)
+	}
+
+	// Routers that only accept permanent mappings get one
+	client = &fakeUPnPClient{rejectLeases: true}
+	manager = newTestUPnPManager(client)
+	if err := manager.MapPort(8080, 8080, "TCP", "test"); err != nil {
+		t.Fatalf("Expected fallback to a permanent mapping, got %v", err)
+	}
+	if mappings := manager.Mappings(); mappings[0].Lease != 0 {
+		t.Errorf("Expected permanent mapping, got lease %s", mappings[0].Lease)
+	}
+}
+
+func TestUPnPRefreshMappings(t *testing.T) {
+	client := &fakeUPnPClient{}
+	manager := newTestUPnPManager(client)
+	manager.MapPort(3000, 3000, "TCP", "test")
+
+	if err := manager.RefreshMappings(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(client.leases) != 2 {
+		t.Errorf("Expected the mapping to be renewed, got %d AddPortMapping calls", len(client.leases))
+	}
+	if manager.GetExternalIP() != "203.0.113.7" {
+		t.Errorf("Expected external IP to be refreshed, got %s", manager.GetExternalIP())
+	}
+
+	var failedPort int
+	manager.SetRenewalFailureHandler(func(port int, err error) { failedPort = port })
+	client.fail = true
+	if err := manager.RefreshMappings(); err == nil {
+		t.Error("Expected an error when renewal fails")
+	}
+	if failedPort != 3000 {
+		t.Errorf("Expected failure handler for port 3000, got %d", failedPort)
+	}
+	if len(manager.Mappings()) != 1 {
+		t.Error("Expected the mapping to be kept for the next renewal")
+	}
+}
+
+func TestFormatUPnPStatus(t *testing.T) {
+	message := formatUPnPStatus("", "192.168.1.2", nil)
+	if !strings.Contains(message, "External IP: unknown") || !strings.Contains(message, "No active port mappings") {
+		t.Errorf("Expected empty status, got %s", message)
+	}
+
+	message = formatUPnPStatus("203.0.113.7", "192.168.1.2", []UPnPMappingStatus{
+		{InternalPort: 3000, ExternalPort: 3000, Protocol: "TCP", Description: "app", Lease: time.Hour, Remaining: 45 * time.Minute},
+		{InternalPort: 8080, ExternalPort: 8080, Protocol: "TCP", Description: "files"},
+	})
+	if !strings.Contains(message, "45m0s left of 1h0m0s lease") || !strings.Contains(message, "permanent") {
+		t.Errorf("Expected lease details, got %s", message)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// AccessLog logs the requests handled by a file server and counts them per path
+type AccessLog struct {
+	mu    sync.Mutex
+	total int
+	bytes int64
+	paths map[string]int
+}
+
+// PathCount is the number of requests for a single path
+type PathCount struct {
+	Path  string
+	Count int
+}
+
+// AccessStats summarises the requests recorded by an AccessLog
+type AccessStats struct {
+	Total    int
+	Bytes    int64
+	TopPaths []PathCount // Most requested paths first
+}
+
+// NewAccessLog creates an empty access log
+func NewAccessLog() *AccessLog {
+	return &AccessLog{paths: make(map[string]int)}
+}
+
+// Wrap returns a handler that serves requests with next and records each one
+func (a *AccessLog) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+		log.Printf("FileServer: %s %s %s %d %d bytes", remoteIP, r.Method, r.URL.Path, rec.status, rec.bytes)
+
+		a.mu.Lock()
+		a.total++
+		a.bytes += rec.bytes
+		a.paths[r.URL.Path]++
+		a.mu.Unlock()
+	})
+}
+
+// Stats returns the request totals and the n most requested paths
+func (a *AccessLog) Stats(n int) AccessStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := AccessStats{Total: a.total, Bytes: a.bytes}
+	for path, count := range a.paths {
+		stats.TopPaths = append(stats.TopPaths, PathCount{Path: path, Count: count})
+	}
+	sort.Slice(stats.TopPaths, func(i, j int) bool {
+		if stats.TopPaths[i].Count != stats.TopPaths[j].Count {
+			return stats.TopPaths[i].Count > stats.TopPaths[j].Count
+		}
+		return stats.TopPaths[i].Path < stats.TopPaths[j].Path
+	})
+	if len(stats.TopPaths) > n {
+		stats.TopPaths = stats.TopPaths[:n]
+	}
+	return stats
+}
+
+// statusRecorder captures the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes through so streamed downloads are not buffered
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
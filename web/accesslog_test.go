@@ -0,0 +1,66 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAccessLogWrapsFileServer(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	accessLog := NewAccessLog()
+	server := httptest.NewServer(accessLog.Wrap(NewFileServer(root)))
+	defer server.Close()
+
+	// Range requests still get partial content through the middleware
+	req, _ := http.NewRequest("GET", server.URL+"/hello.txt", nil)
+	req.Header.Set("Range", "bytes=6-10")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Range request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "world" {
+		t.Errorf("Expected 206 \"world\", got %d %q", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{"/hello.txt", "/", "/missing"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	stats := accessLog.Stats(1)
+	if stats.Total != 4 {
+		t.Errorf("Expected 4 requests, got %d", stats.Total)
+	}
+	if len(stats.TopPaths) != 1 || stats.TopPaths[0] != (PathCount{Path: "/hello.txt", Count: 2}) {
+		t.Errorf("Expected /hello.txt requested twice, got %v", stats.TopPaths)
+	}
+	if stats.Bytes < int64(len("world")+len("hello world")) {
+		t.Errorf("Expected at least both file bodies counted, got %d bytes", stats.Bytes)
+	}
+}
+
+func TestStatusRecorderDefaultsToOK(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	rec.Write([]byte("abc"))
+	rec.WriteHeader(http.StatusNotFound)
+	if rec.status != http.StatusOK || rec.bytes != 3 {
+		t.Errorf("Expected status 200 and 3 bytes, got %d and %d", rec.status, rec.bytes)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mavis/codeagent"
+)
+
+func TestHandleAgentOutputNotFound(t *testing.T) {
+	setupTest(t)
+
+	for _, path := range []string{"/api/agents/missing/output", "/api/agents/missing", "/api/agents//output"} {
+		rec := httptest.NewRecorder()
+		handleAgentOutput(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handleAgentOutput(rec, httptest.NewRequest("POST", "/api/agents/missing/output", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestAgentOutput(t *testing.T) {
+	finished := agentOutput(codeagent.AgentInfo{
+		ID:          "7",
+		Status:      codeagent.StatusFailed,
+		Output:      "full output",
+		Error:       "exit status 1",
+		PlanContent: "## Plan\n- step",
+		Duration:    95 * time.Second,
+	})
+	expected := AgentOutput{ID: "7", Status: "failed", Output: "full output", Error: "exit status 1", Plan: "## Plan\n- step", Duration: "1m35s"}
+	if finished != expected {
+		t.Errorf("Expected %+v, got %+v", expected, finished)
+	}
+
+	// Running agents report the plan file as it is now
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "CURRENT_PLAN.md"), []byte("## Plan\n- live"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	running := agentOutput(codeagent.AgentInfo{
+		ID:        "8",
+		Folder:    dir,
+		Status:    codeagent.StatusRunning,
+		StartTime: time.Now().Add(-time.Minute),
+	})
+	if running.Plan != "## Plan\n- live" {
+		t.Errorf("Expected the current plan, got %q", running.Plan)
+	}
+	if running.Duration == "" {
+		t.Error("Expected a duration for a running agent")
+	}
+
+	data, err := json.Marshal(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	for _, key := range []string{"id", "status", "output", "error", "plan", "duration"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected JSON key %q", key)
+		}
+	}
+}
+
+func TestHandleConcurrency(t *testing.T) {
+	setupTest(t)
+	agentManager.SetMaxConcurrentAgents(3)
+	defer agentManager.SetMaxConcurrentAgents(0)
+
+	rec := httptest.NewRecorder()
+	handleConcurrency(rec, httptest.NewRequest("GET", "/api/concurrency", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var status codeagent.ConcurrencyStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Limit != 3 || status.Running != 0 || status.Waiting != 0 {
+		t.Errorf("Expected limit 3 with nothing running, got %+v", status)
+	}
+
+	rec = httptest.NewRecorder()
+	handleConcurrency(rec, httptest.NewRequest("POST", "/api/concurrency", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
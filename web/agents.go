@@ -2,7 +2,9 @@ package web
 
 import (
 	"fmt"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +28,7 @@ type AgentStatus struct {
 	Error        string
 	PlanContent  string
 	Command      string
+	Resources    string // CPU and memory of a running agent, empty when not sampled
 }
 
 // safeSubstring safely extracts a substring, handling cases where the string is shorter than requested
@@ -54,21 +57,42 @@ func getStatusClass(agent AgentStatus) string {
 	return "running"
 }
 
+// Element IDs of the kanban columns
+const (
+	queueColumnID    = "queue-column"
+	planningColumnID = "planning-column"
+	runningColumnID  = "running-column"
+	finishedColumnID = "finished-column"
+)
+
+// agentColumn returns the ID of the kanban column an agent belongs in
+func agentColumn(agent AgentStatus) string {
+	switch agent.Status {
+	case "queued", "preparing":
+		return queueColumnID
+	case "active", "running":
+		// Check if agent has progress to determine if it's planning or running
+		trimmedProgress := strings.TrimSpace(agent.Progress)
+		if trimmedProgress == "" || strings.Contains(trimmedProgress, "(The AI will update progress here as it works)") {
+			return planningColumnID
+		}
+		return runningColumnID
+	case "finished", "failed", "killed", "stopped", "error":
+		return finishedColumnID
+	default:
+		return runningColumnID
+	}
+}
+
 // categorizeAgents sorts agents into their respective columns and sorts each column by ID
 func categorizeAgents(agents []AgentStatus) (planning, queued, running, finished []AgentStatus) {
 	for _, agent := range agents {
-		switch agent.Status {
-		case "queued", "preparing":
+		switch agentColumn(agent) {
+		case queueColumnID:
 			queued = append(queued, agent)
-		case "active", "running":
-			// Check if agent has progress to determine if it's planning or running
-			trimmedProgress := strings.TrimSpace(agent.Progress)
-			if trimmedProgress == "" || strings.Contains(trimmedProgress, "(The AI will update progress here as it works)") {
-				planning = append(planning, agent)
-			} else {
-				running = append(running, agent)
-			}
-		case "finished", "failed", "killed", "stopped", "error":
+		case planningColumnID:
+			planning = append(planning, agent)
+		case finishedColumnID:
 			finished = append(finished, agent)
 		default:
 			running = append(running, agent)
@@ -114,10 +138,13 @@ func getRunningTime(agent AgentStatus) string {
 	return " for " + formatDuration(elapsed)
 }
 
-func AgentsSection(agents []AgentStatus, modalParam string, workDir string, branches []string) g.Node {
+func AgentsSection(agents []AgentStatus, page AgentPage, modalParam string, workDir string, branches []string) g.Node {
 	// Categorize agents
 	planning, queued, running, finished := categorizeAgents(agents)
 
+	// Only finished agents are paged; the other columns are always complete
+	pagedTotal := page.Total - (len(agents) - len(finished))
+
 	// Check if we should show the create modal based on query params
 	showModal := modalParam == "create"
 
@@ -132,6 +159,7 @@ func AgentsSection(agents []AgentStatus, modalParam string, workDir string, bran
 					g.Text("+ New Agent"),
 				),
 			),
+			AgentSearchForm(page),
 		),
 		h.Div(h.Class("kanban-container"),
 			// Queue Column
@@ -140,7 +168,7 @@ func AgentsSection(agents []AgentStatus, modalParam string, workDir string, bran
 					h.H3(g.Text("Queue")),
 					h.Span(h.Class("kanban-count"), g.Text(fmt.Sprintf("(%d)", len(queued)))),
 				),
-				h.Div(h.ID("queue-column"), h.Class("kanban-cards"),
+				h.Div(h.ID(queueColumnID), h.Class("kanban-cards"),
 					g.Group(g.Map(queued, func(agent AgentStatus) g.Node {
 						return AgentCard(agent)
 					})),
@@ -152,7 +180,7 @@ func AgentsSection(agents []AgentStatus, modalParam string, workDir string, bran
 					h.H3(g.Text("Planning")),
 					h.Span(h.Class("kanban-count"), g.Text(fmt.Sprintf("(%d)", len(planning)))),
 				),
-				h.Div(h.ID("planning-column"), h.Class("kanban-cards"),
+				h.Div(h.ID(planningColumnID), h.Class("kanban-cards"),
 					g.Group(g.Map(planning, func(agent AgentStatus) g.Node {
 						return AgentCard(agent)
 					})),
@@ -164,7 +192,7 @@ func AgentsSection(agents []AgentStatus, modalParam string, workDir string, bran
 					h.H3(g.Text("Coding")),
 					h.Span(h.Class("kanban-count"), g.Text(fmt.Sprintf("(%d)", len(running)))),
 				),
-				h.Div(h.ID("running-column"), h.Class("kanban-cards"),
+				h.Div(h.ID(runningColumnID), h.Class("kanban-cards"),
 					g.Group(g.Map(running, func(agent AgentStatus) g.Node {
 						return AgentCard(agent)
 					})),
@@ -176,17 +204,98 @@ func AgentsSection(agents []AgentStatus, modalParam string, workDir string, bran
 					h.H3(g.Text("Finished")),
 					h.Span(h.Class("kanban-count"), g.Text(fmt.Sprintf("(%d)", len(finished)))),
 				),
-				h.Div(h.ID("finished-column"), h.Class("kanban-cards"),
+				h.Div(h.ID(finishedColumnID), h.Class("kanban-cards"),
 					g.Group(g.Map(finished, func(agent AgentStatus) g.Node {
 						return AgentCard(agent)
 					})),
 				),
+				AgentPager(page, len(finished), pagedTotal),
 			),
 		),
 		// Render modal if query param is set
 		g.If(showModal,
 			CreateAgentModal(workDir, branches),
 		),
+		// Update cards in place from /events instead of reloading the page
+		g.If(liveAgentUpdates(page),
+			h.Script(h.Src("/static/js/agents-live.js"), h.Defer()),
+		),
+	)
+}
+
+// liveAgentUpdates reports whether the agents page is kept up to date by
+// /events. Searches, status and folder filters and older pages keep
+// reloading instead, since live cards would not respect them.
+func liveAgentUpdates(page AgentPage) bool {
+	return !page.filtered() && page.Status == "" && page.Offset == 0
+}
+
+// agentStatusFilters are the statuses offered by the search form's filter
+var agentStatusFilters = []string{"running", "queued", "finished", "failed", "killed"}
+
+// AgentSearchForm renders the search box for agent prompts and output, with
+// status and folder filters
+func AgentSearchForm(page AgentPage) g.Node {
+	return h.Form(h.Method("get"), h.Action("/agents"), h.Class("agent-search"),
+		h.Input(
+			h.Type("search"),
+			h.Name("q"),
+			h.Value(page.Query),
+			h.Placeholder("Search prompts and output..."),
+			h.Class("form-control"),
+		),
+		h.Input(
+			h.Type("text"),
+			h.Name("folder"),
+			h.Value(page.Folder),
+			h.Placeholder("Folder, e.g. ~/myproject"),
+			h.Class("form-control"),
+		),
+		h.Select(h.Name("status"), h.Class("form-control"),
+			h.Option(h.Value(""), g.Text("All statuses")),
+			g.Group(g.Map(agentStatusFilters, func(status string) g.Node {
+				return h.Option(h.Value(status), g.If(status == page.Status, h.Selected()), g.Text(status))
+			})),
+		),
+		h.Button(h.Type("submit"), h.Class("btn btn-secondary"), g.Text("Search")),
+		g.If(page.filtered() || page.Status != "", h.A(h.Href("/agents"), h.Class("btn btn-secondary"), g.Text("Clear"))),
+	)
+}
+
+// AgentPager renders links to the newer and older pages of finished agents.
+// It renders nothing when everything fits on one page.
+func AgentPager(page AgentPage, shown, total int) g.Node {
+	hasNewer := page.Offset > 0
+	hasOlder := page.Offset+shown < total
+	if !hasNewer && !hasOlder {
+		return nil
+	}
+
+	pageURL := func(offset int) string {
+		query := url.Values{}
+		query.Set("offset", strconv.Itoa(max(offset, 0)))
+		query.Set("limit", strconv.Itoa(page.Limit))
+		if page.Status != "" {
+			query.Set("status", page.Status)
+		}
+		if page.Query != "" {
+			query.Set("q", page.Query)
+		}
+		if page.Folder != "" {
+			query.Set("folder", page.Folder)
+		}
+		return "/agents?" + query.Encode()
+	}
+
+	first := 0
+	if shown > 0 {
+		first = page.Offset + 1
+	}
+
+	return h.Div(h.Class("kanban-pager"),
+		g.If(hasNewer, h.A(h.Href(pageURL(page.Offset-page.Limit)), g.Text("← Newer"))),
+		h.Span(h.Class("kanban-count"), g.Text(fmt.Sprintf("%d–%d of %d", first, page.Offset+shown, total))),
+		g.If(hasOlder, h.A(h.Href(pageURL(page.Offset+page.Limit)), g.Text("Older →"))),
 	)
 }
 
@@ -257,7 +366,9 @@ func AgentCard(agent AgentStatus) g.Node {
 		h.Div(h.Class("agent-task"),
 			h.P(g.Text(agent.Task)),
 		),
-		h.Div(h.Class("agent-stats")),
+		h.Div(h.Class("agent-stats"),
+			g.If(agent.Resources != "", h.Span(h.Class("agent-resources"), g.Text(agent.Resources))),
+		),
 
 		// Show plan or progress based on whether agent is in planning state
 		g.If(agent.Status == "running" || agent.Status == "active",
@@ -375,6 +486,16 @@ func CreateAgentModal(workDir string, branches []string) g.Node {
 							h.Small(h.Class("text-muted"), g.Text("If specified: uses existing branch or creates new feature branch")),
 						),
 
+						h.Div(h.Class("form-group"),
+							h.Label(h.For("model"), g.Text("Model (optional)")),
+							h.Input(
+								h.Type("text"),
+								h.ID("model"),
+								h.Name("model"),
+								h.Placeholder("Leave empty for the Claude CLI default, e.g. opus or sonnet"),
+							),
+						),
+
 						h.Div(h.Class("form-group"),
 							h.Label(h.For("task"), g.Text("Task Description")),
 							h.Textarea(
@@ -0,0 +1,127 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAgentPage(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected AgentPage
+	}{
+		{"", AgentPage{Offset: 0, Limit: defaultAgentPageLimit}},
+		{"?offset=20&limit=10&status=failed", AgentPage{Offset: 20, Limit: 10, Status: "failed"}},
+		{"?offset=-5&limit=0", AgentPage{Offset: 0, Limit: defaultAgentPageLimit}},
+		{"?offset=abc&limit=100000", AgentPage{Offset: 0, Limit: maxAgentPageLimit}},
+		{"?status=running&folder=~/myproject&q=+tests+", AgentPage{Limit: defaultAgentPageLimit, Status: "running", Folder: "~/myproject", Query: "tests"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/agents"+tt.query, nil)
+		if got := parseAgentPage(req); got != tt.expected {
+			t.Errorf("Query %q: expected %+v, got %+v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestAgentPager(t *testing.T) {
+	if node := AgentPager(AgentPage{Limit: 10}, 5, 5); node != nil {
+		t.Errorf("Expected no pager when everything fits on one page")
+	}
+
+	var buf bytes.Buffer
+	page := AgentPage{Offset: 10, Limit: 10, Status: "finished"}
+	if err := AgentPager(page, 10, 25).Render(&buf); err != nil {
+		t.Fatalf("Failed to render pager: %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{
+		"/agents?limit=10&amp;offset=0&amp;status=finished",
+		"/agents?limit=10&amp;offset=20&amp;status=finished",
+		"11–20 of 25",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected pager to contain %q, got %s", expected, html)
+		}
+	}
+}
+
+func TestSearchAgentsStatus(t *testing.T) {
+	setupTest(t)
+
+	req := httptest.NewRequest("GET", "/agents?q=no-agent-has-this-prompt&offset=5", nil)
+	agents, page := GetAgentsStatusPage(parseAgentPage(req))
+	if len(agents) != 0 || page.Total != 0 {
+		t.Errorf("Expected no matching agents, got %d (total %d)", len(agents), page.Total)
+	}
+	if page.Offset != 0 {
+		t.Errorf("Expected search results not to be paged, got offset %d", page.Offset)
+	}
+
+	var buf bytes.Buffer
+	if err := AgentSearchForm(page).Render(&buf); err != nil {
+		t.Fatalf("Failed to render search form: %v", err)
+	}
+	if !strings.Contains(buf.String(), `value="no-agent-has-this-prompt"`) {
+		t.Errorf("Expected search box to keep the query, got %s", buf.String())
+	}
+}
+
+func TestFolderFilter(t *testing.T) {
+	setupTest(t)
+
+	req := httptest.NewRequest("GET", "/agents?folder=~/no-such-project&offset=5", nil)
+	agents, page := GetAgentsStatusPage(parseAgentPage(req))
+	if len(agents) != 0 || page.Offset != 0 {
+		t.Errorf("Expected no agents and no paging, got %d (offset %d)", len(agents), page.Offset)
+	}
+
+	var buf bytes.Buffer
+	if err := AgentSearchForm(AgentPage{Folder: "~/app", Status: "failed"}).Render(&buf); err != nil {
+		t.Fatalf("Failed to render search form: %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{`name="folder" value="~/app"`, `<option value="failed" selected>`, "Clear"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected search form to contain %q, got %s", expected, html)
+		}
+	}
+
+	buf.Reset()
+	if err := AgentPager(AgentPage{Offset: 10, Limit: 10, Folder: "~/app"}, 10, 25).Render(&buf); err != nil {
+		t.Fatalf("Failed to render pager: %v", err)
+	}
+	if !strings.Contains(buf.String(), "folder=~%2Fapp") {
+		t.Errorf("Expected pager links to keep the folder, got %s", buf.String())
+	}
+}
+
+func TestLiveAgentUpdates(t *testing.T) {
+	setupTest(t)
+
+	tests := []struct {
+		page     AgentPage
+		expected bool
+	}{
+		{AgentPage{Limit: 10}, true},
+		{AgentPage{Limit: 10, Offset: 10}, false},
+		{AgentPage{Limit: 10, Status: "failed"}, false},
+		{AgentPage{Limit: 10, Query: "tests"}, false},
+		{AgentPage{Limit: 10, Folder: "~/app"}, false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := AgentsSection(nil, tt.page, "", "", nil).Render(&buf); err != nil {
+			t.Fatalf("Failed to render agents section: %v", err)
+		}
+		if live := strings.Contains(buf.String(), "/static/js/agents-live.js"); live != tt.expected {
+			t.Errorf("%+v: expected live updates %v, got %v", tt.page, tt.expected, live)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuth holds the credentials a file server requires
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Wrap returns a handler that serves requests with next only when they carry
+// matching credentials, answering 401 otherwise
+func (a *BasicAuth) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || !a.matches(username, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Mavis", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matches compares the credentials in constant time. Hashing first keeps the
+// comparison time independent of the lengths too.
+func (a *BasicAuth) matches(username, password string) bool {
+	gotUser, wantUser := sha256.Sum256([]byte(username)), sha256.Sum256([]byte(a.Username))
+	gotPass, wantPass := sha256.Sum256([]byte(password)), sha256.Sum256([]byte(a.Password))
+	userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:])
+	passOK := subtle.ConstantTimeCompare(gotPass[:], wantPass[:])
+	return userOK&passOK == 1
+}
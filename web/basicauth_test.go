@@ -0,0 +1,44 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuthWrap(t *testing.T) {
+	auth := &BasicAuth{Username: "me", Password: "secret"}
+	handler := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name     string
+		user     string
+		pass     string
+		setAuth  bool
+		expected int
+	}{
+		{"no credentials", "", "", false, http.StatusUnauthorized},
+		{"wrong password", "me", "nope", true, http.StatusUnauthorized},
+		{"wrong user", "you", "secret", true, http.StatusUnauthorized},
+		{"valid", "me", "secret", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/file.txt", nil)
+		if tt.setAuth {
+			req.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.expected, w.Code)
+		}
+		if tt.expected == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
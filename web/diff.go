@@ -0,0 +1,263 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	g "maragu.dev/gomponents"
+	h "maragu.dev/gomponents/html"
+)
+
+// DiffFile is one file of a unified diff
+type DiffFile struct {
+	OldPath string // Empty for added files
+	NewPath string // Empty for deleted files
+	Status  string // modified, added, deleted, renamed or binary
+	Hunks   []DiffHunk
+	Added   int
+	Removed int
+}
+
+// Path returns the path to show for the file
+func (f DiffFile) Path() string {
+	switch {
+	case f.NewPath == "":
+		return f.OldPath
+	case f.Status == "renamed" && f.OldPath != f.NewPath:
+		return f.OldPath + " → " + f.NewPath
+	default:
+		return f.NewPath
+	}
+}
+
+// DiffHunk is a run of changes introduced by an @@ header
+type DiffHunk struct {
+	Header string // The @@ line, including the enclosing function git prints
+	Lines  []DiffLine
+}
+
+// DiffLine is a line of a hunk. Kind is '+', '-', ' ' for context or '\\'
+// for "\ No newline at end of file".
+type DiffLine struct {
+	Kind   byte
+	Text   string
+	OldNum int // Line number in the old file, 0 for added lines
+	NewNum int // Line number in the new file, 0 for removed lines
+}
+
+// hunkHeaderPattern extracts the start lines from "@@ -12,5 +12,7 @@"
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+const (
+	// diffContextKeep is the context shown around changes; longer unchanged
+	// runs are collapsed
+	diffContextKeep = 3
+	// diffOpenLimit is the most changed lines a file can have and still be
+	// expanded when the page loads
+	diffOpenLimit = 300
+)
+
+// parseDiff splits the output of git diff into files and hunks
+func parseDiff(raw string) []DiffFile {
+	var files []DiffFile
+	var file *DiffFile
+	var hunk *DiffHunk
+	oldNum, newNum := 0, 0
+
+	for _, line := range strings.Split(strings.TrimRight(raw, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, DiffFile{Status: "modified"})
+			file, hunk = &files[len(files)-1], nil
+			file.OldPath, file.NewPath = splitDiffGitPaths(strings.TrimPrefix(line, "diff --git "))
+		case file == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "new file mode"):
+			file.Status, file.OldPath = "added", ""
+		case hunk == nil && strings.HasPrefix(line, "deleted file mode"):
+			file.Status, file.NewPath = "deleted", ""
+		case hunk == nil && strings.HasPrefix(line, "rename from "):
+			file.Status, file.OldPath = "renamed", strings.TrimPrefix(line, "rename from ")
+		case hunk == nil && strings.HasPrefix(line, "rename to "):
+			file.Status, file.NewPath = "renamed", strings.TrimPrefix(line, "rename to ")
+		case hunk == nil && strings.HasPrefix(line, "Binary files "):
+			file.Status = "binary"
+		case hunk == nil && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ")):
+			// Paths are already known from the diff --git line
+		case strings.HasPrefix(line, "@@"):
+			file.Hunks = append(file.Hunks, DiffHunk{Header: line})
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			if match := hunkHeaderPattern.FindStringSubmatch(line); match != nil {
+				oldNum, _ = strconv.Atoi(match[1])
+				newNum, _ = strconv.Atoi(match[2])
+			}
+		case hunk == nil:
+			continue
+		case strings.HasPrefix(line, "+"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '+', Text: line[1:], NewNum: newNum})
+			newNum++
+			file.Added++
+		case strings.HasPrefix(line, "-"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '-', Text: line[1:], OldNum: oldNum})
+			oldNum++
+			file.Removed++
+		case strings.HasPrefix(line, "\\"):
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: '\\', Text: line})
+		default:
+			// Context, which some tools strip to an empty line
+			text := strings.TrimPrefix(line, " ")
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: ' ', Text: text, OldNum: oldNum, NewNum: newNum})
+			oldNum++
+			newNum++
+		}
+	}
+	return files
+}
+
+// splitDiffGitPaths splits "a/old b/new" from a diff --git line
+func splitDiffGitPaths(paths string) (string, string) {
+	if i := strings.Index(paths, " b/"); i >= 0 && strings.HasPrefix(paths, "a/") {
+		return paths[2:i], paths[i+3:]
+	}
+	fields := strings.Fields(paths)
+	if len(fields) == 2 {
+		return fields[0], fields[1]
+	}
+	return paths, paths
+}
+
+// diffSegment is a run of lines that is either shown or collapsed
+type diffSegment struct {
+	lines     []DiffLine
+	collapsed bool
+}
+
+// segmentHunk collapses runs of context longer than twice diffContextKeep,
+// keeping diffContextKeep lines next to each change
+func segmentHunk(lines []DiffLine) []diffSegment {
+	var segments []diffSegment
+	shown := func(run []DiffLine) {
+		if len(run) == 0 {
+			return
+		}
+		if n := len(segments); n > 0 && !segments[n-1].collapsed {
+			segments[n-1].lines = append(segments[n-1].lines, run...)
+			return
+		}
+		segments = append(segments, diffSegment{lines: run})
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != ' ' {
+			shown(lines[i : i+1])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(lines) && lines[end].Kind == ' ' {
+			end++
+		}
+		run := lines[i:end]
+
+		keepBefore, keepAfter := diffContextKeep, diffContextKeep
+		if i == 0 {
+			keepBefore = 0 // Nothing above to give context to
+		}
+		if end == len(lines) {
+			keepAfter = 0
+		}
+		if len(run) <= keepBefore+keepAfter+1 {
+			shown(run)
+		} else {
+			shown(run[:keepBefore])
+			segments = append(segments, diffSegment{lines: run[keepBefore : len(run)-keepAfter], collapsed: true})
+			shown(run[len(run)-keepAfter:])
+		}
+		i = end
+	}
+	return segments
+}
+
+// DiffView renders parsed diff files as collapsible per-file sections
+func DiffView(files []DiffFile) g.Node {
+	added, removed := 0, 0
+	for _, file := range files {
+		added += file.Added
+		removed += file.Removed
+	}
+
+	return h.Div(h.Class("diff-view"),
+		h.Div(h.Class("diff-summary"),
+			g.Text(fmt.Sprintf("%d file(s) changed, ", len(files))),
+			h.Span(h.Class("diff-count-add"), g.Text(fmt.Sprintf("+%d", added))),
+			g.Text(" "),
+			h.Span(h.Class("diff-count-remove"), g.Text(fmt.Sprintf("−%d", removed))),
+		),
+		g.Group(g.Map(files, DiffFileSection)),
+	)
+}
+
+// DiffFileSection renders one file, expanded unless it has many changes
+func DiffFileSection(file DiffFile) g.Node {
+	return h.Details(h.Class("diff-file"),
+		g.If(file.Added+file.Removed <= diffOpenLimit, g.Attr("open")),
+		h.Summary(h.Class("diff-file-header"),
+			h.Span(h.Class("diff-status diff-status-"+file.Status), g.Text(file.Status)),
+			h.Span(h.Class("diff-path"), g.Text(file.Path())),
+			h.Span(h.Class("diff-count-add"), g.Text(fmt.Sprintf("+%d", file.Added))),
+			h.Span(h.Class("diff-count-remove"), g.Text(fmt.Sprintf("−%d", file.Removed))),
+		),
+		g.If(file.Status == "binary", h.Div(h.Class("diff-note"), g.Text("Binary file not shown"))),
+		g.Group(g.Map(file.Hunks, func(hunk DiffHunk) g.Node {
+			return h.Div(h.Class("diff-hunk-block"),
+				h.Div(h.Class("diff-hunk"), g.Text(hunk.Header)),
+				g.Group(g.Map(segmentHunk(hunk.Lines), func(segment diffSegment) g.Node {
+					lines := g.Group(g.Map(segment.lines, DiffLineRow))
+					if !segment.collapsed {
+						return lines
+					}
+					return h.Details(h.Class("diff-collapsed"),
+						h.Summary(g.Text(fmt.Sprintf("⋯ %d unchanged lines", len(segment.lines)))),
+						lines,
+					)
+				})),
+			)
+		})),
+	)
+}
+
+// DiffLineRow renders a line with its old and new line numbers
+func DiffLineRow(line DiffLine) g.Node {
+	class := "diff-line diff-context"
+	switch line.Kind {
+	case '+':
+		class = "diff-line diff-add"
+	case '-':
+		class = "diff-line diff-remove"
+	case '\\':
+		class = "diff-line diff-note"
+	}
+
+	number := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	marker := string(line.Kind)
+	if line.Kind == '\\' {
+		marker = ""
+	}
+
+	return h.Div(h.Class(class),
+		h.Span(h.Class("diff-num"), g.Text(number(line.OldNum))),
+		h.Span(h.Class("diff-num"), g.Text(number(line.NewNum))),
+		h.Span(h.Class("diff-code"), g.Text(marker+line.Text)),
+	)
+}
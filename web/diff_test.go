@@ -0,0 +1,185 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,5 @@ package main
+ import "fmt"
+-func old() {}
++func new() {}
++func extra() {}
+ 
+ func main() {
+@@ -20,2 +21,2 @@ func main() {
+-	fmt.Println("a")
++	fmt.Println("b")
+\ No newline at end of file
+diff --git a/added.txt b/added.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/added.txt
+@@ -0,0 +1 @@
++hello
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.go b/new.go
+similarity index 100%
+rename from old.go
+rename to new.go
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+`
+
+func TestParseDiff(t *testing.T) {
+	files := parseDiff(sampleDiff)
+	if len(files) != 5 {
+		t.Fatalf("Expected 5 files, got %d", len(files))
+	}
+
+	modified := files[0]
+	if modified.Status != "modified" || modified.Path() != "main.go" {
+		t.Errorf("Expected modified main.go, got %s %s", modified.Status, modified.Path())
+	}
+	if modified.Added != 3 || modified.Removed != 2 {
+		t.Errorf("Expected +3 -2, got +%d -%d", modified.Added, modified.Removed)
+	}
+	if len(modified.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(modified.Hunks))
+	}
+
+	first := modified.Hunks[0].Lines
+	if len(first) != 6 {
+		t.Fatalf("Expected 6 lines in first hunk, got %d", len(first))
+	}
+	if first[1].Kind != '-' || first[1].OldNum != 2 || first[1].NewNum != 0 {
+		t.Errorf("Expected removed line 2, got %+v", first[1])
+	}
+	if first[3].Kind != '+' || first[3].NewNum != 3 || first[3].Text != "func extra() {}" {
+		t.Errorf("Expected added line 3, got %+v", first[3])
+	}
+	if first[4].Kind != ' ' || first[4].OldNum != 3 || first[4].NewNum != 4 {
+		t.Errorf("Expected empty context line 3/4, got %+v", first[4])
+	}
+
+	second := modified.Hunks[1].Lines
+	if second[0].OldNum != 20 || second[1].NewNum != 21 {
+		t.Errorf("Expected second hunk at 20/21, got %d/%d", second[0].OldNum, second[1].NewNum)
+	}
+	if last := second[len(second)-1]; last.Kind != '\\' {
+		t.Errorf("Expected no-newline marker, got %+v", last)
+	}
+
+	if files[1].Status != "added" || files[1].Path() != "added.txt" || files[1].Added != 1 {
+		t.Errorf("Expected added added.txt, got %+v", files[1])
+	}
+	if files[2].Status != "deleted" || files[2].Path() != "gone.txt" || files[2].Removed != 1 {
+		t.Errorf("Expected deleted gone.txt, got %+v", files[2])
+	}
+	if files[3].Status != "renamed" || files[3].Path() != "old.go → new.go" {
+		t.Errorf("Expected renamed old.go → new.go, got %s %s", files[3].Status, files[3].Path())
+	}
+	if files[4].Status != "binary" || len(files[4].Hunks) != 0 {
+		t.Errorf("Expected binary logo.png without hunks, got %+v", files[4])
+	}
+}
+
+func TestParseDiffNotADiff(t *testing.T) {
+	if files := parseDiff("fatal: not a git repository"); len(files) != 0 {
+		t.Errorf("Expected no files, got %d", len(files))
+	}
+}
+
+// contextLines returns n context lines
+func contextLines(n int) []DiffLine {
+	lines := make([]DiffLine, n)
+	for i := range lines {
+		lines[i] = DiffLine{Kind: ' ', Text: fmt.Sprintf("line %d", i)}
+	}
+	return lines
+}
+
+func TestSegmentHunk(t *testing.T) {
+	change := DiffLine{Kind: '+', Text: "changed"}
+
+	// A long run between two changes keeps 3 lines on each side
+	lines := append([]DiffLine{change}, contextLines(12)...)
+	lines = append(lines, change)
+	segments := segmentHunk(lines)
+	if len(segments) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(segments))
+	}
+	if len(segments[0].lines) != 4 || segments[0].collapsed {
+		t.Errorf("Expected change and 3 context lines shown, got %d (collapsed %v)", len(segments[0].lines), segments[0].collapsed)
+	}
+	if len(segments[1].lines) != 6 || !segments[1].collapsed {
+		t.Errorf("Expected 6 collapsed lines, got %d (collapsed %v)", len(segments[1].lines), segments[1].collapsed)
+	}
+	if len(segments[2].lines) != 4 || segments[2].collapsed {
+		t.Errorf("Expected 3 context lines and change shown, got %d", len(segments[2].lines))
+	}
+
+	// Short runs are left alone
+	lines = append([]DiffLine{change}, contextLines(6)...)
+	lines = append(lines, change)
+	if segments := segmentHunk(lines); len(segments) != 1 || len(segments[0].lines) != 8 {
+		t.Errorf("Expected a single shown segment, got %d", len(segments))
+	}
+
+	// Context at the end of a hunk only keeps the lines after the change
+	lines = append([]DiffLine{change}, contextLines(10)...)
+	segments = segmentHunk(lines)
+	if len(segments) != 2 || len(segments[0].lines) != 4 || len(segments[1].lines) != 7 {
+		t.Errorf("Expected 4 shown and 7 collapsed lines, got %d segments", len(segments))
+	}
+}
+
+func TestDiffView(t *testing.T) {
+	files := parseDiff(sampleDiff)
+	files[0].Hunks[0].Lines = append(files[0].Hunks[0].Lines, contextLines(12)...)
+	files[0].Hunks[0].Lines = append(files[0].Hunks[0].Lines, DiffLine{Kind: '-', Text: "x"})
+
+	html, err := renderNode(DiffView(files))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, want := range []string{
+		"5 file(s) changed",
+		`class="diff-line diff-add"`,
+		`class="diff-line diff-remove"`,
+		"⋯ 8 unchanged lines",
+		"diff-status-renamed",
+		"Binary file not shown",
+		"<details class=\"diff-file\" open>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected output to contain %q", want)
+		}
+	}
+}
+
+func TestGitDiffFallback(t *testing.T) {
+	html, err := renderNode(GitDiff("some plain output"))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(html, "git-diff") || strings.Contains(html, "diff-view") {
+		t.Errorf("Expected plain rendering, got %s", html)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"mavis/codeagent"
+
+	g "maragu.dev/gomponents"
+)
+
+// sseKeepAlive is how often a comment is sent so proxies keep idle streams open
+const sseKeepAlive = 25 * time.Second
+
+// sseOutputInterval limits how often output re-renders an agent's card, so
+// progress shows up without a message per line of output
+const sseOutputInterval = 3 * time.Second
+
+// sseAgentEvent is the data of an "agent" event: a change to one agent and
+// its re-rendered card
+type sseAgentEvent struct {
+	Type    codeagent.AgentEventType `json:"type"`
+	AgentID string                   `json:"agent_id"`
+	Status  string                   `json:"status"`
+	Folder  string                   `json:"folder"`
+	Prompt  string                   `json:"prompt"`
+	Column  string                   `json:"column"` // ID of the kanban column the card belongs in
+	HTML    string                   `json:"html"`
+	Time    time.Time                `json:"time"`
+}
+
+// sseQueueEvent is the data of a "queue" event: every queued task
+type sseQueueEvent struct {
+	Count int    `json:"count"`
+	HTML  string `json:"html"` // Cards of the queue column
+}
+
+// handleEvents streams agent status changes, queue updates and completions as
+// server-sent events until the client disconnects
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The server's write timeout would otherwise cut the stream off
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[SSE] Could not clear write deadline: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	events := agentManager.Events()
+	defer agentManager.StopEvents(events)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	lastOutput := make(map[string]time.Time)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == codeagent.EventOutput {
+				if time.Since(lastOutput[event.AgentID]) < sseOutputInterval {
+					continue
+				}
+				lastOutput[event.AgentID] = time.Now()
+			}
+			if event.Type.IsTerminal() {
+				delete(lastOutput, event.AgentID)
+			}
+			if err := writeAgentEvent(w, event); err != nil {
+				log.Printf("[SSE] Client gone: %v", err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeAgentEvent writes the SSE messages for event: the agent's card, and
+// the queue column whenever the queue may have changed
+func writeAgentEvent(w http.ResponseWriter, event codeagent.AgentEvent) error {
+	if event.Type != codeagent.EventQueued {
+		data, err := agentEventData(event)
+		if err != nil {
+			return err
+		}
+		if err := writeSSE(w, "agent", data); err != nil {
+			return err
+		}
+	}
+
+	switch event.Type {
+	case codeagent.EventQueued, codeagent.EventLaunched, codeagent.EventFinished, codeagent.EventFailed, codeagent.EventKilled:
+		return writeSSE(w, "queue", queueEventData())
+	}
+	return nil
+}
+
+// agentEventData renders the card of the agent in event
+func agentEventData(event codeagent.AgentEvent) (sseAgentEvent, error) {
+	infos := agentStatusInfos([]codeagent.AgentInfo{event.Info})
+	agent := toAgentStatus(infos[0])
+
+	html, err := renderNode(AgentCard(agent))
+	return sseAgentEvent{
+		Type:    event.Type,
+		AgentID: event.AgentID,
+		Status:  agent.Status,
+		Folder:  event.Info.Folder,
+		Prompt:  event.Info.Prompt,
+		Column:  agentColumn(agent),
+		HTML:    html,
+		Time:    event.Time,
+	}, err
+}
+
+// queueEventData renders the cards of every queued task
+func queueEventData() sseQueueEvent {
+	queued := queuedStatusInfos()
+	sort.Slice(queued, func(i, j int) bool { return queued[i].ID < queued[j].ID })
+	var html strings.Builder
+	for _, task := range queued {
+		if err := AgentCard(toAgentStatus(task)).Render(&html); err != nil {
+			log.Printf("[SSE] Failed to render queued task %s: %v", task.ID, err)
+		}
+	}
+	return sseQueueEvent{Count: len(queued), HTML: html.String()}
+}
+
+// writeSSE writes one server-sent event with data encoded as JSON
+func writeSSE(w http.ResponseWriter, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
+
+// renderNode renders a component to a string
+func renderNode(node g.Node) (string, error) {
+	var html strings.Builder
+	err := node.Render(&html)
+	return html.String(), err
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mavis/codeagent"
+)
+
+func TestWriteAgentEvent(t *testing.T) {
+	setupTest(t)
+
+	rec := httptest.NewRecorder()
+	event := codeagent.AgentEvent{
+		Type:    codeagent.EventFinished,
+		AgentID: "42",
+		Info: codeagent.AgentInfo{
+			ID:       "42",
+			Folder:   "/tmp/project",
+			Prompt:   "fix the tests",
+			Status:   codeagent.StatusFinished,
+			Output:   "all green",
+			Duration: 90 * time.Second,
+		},
+	}
+	if err := writeAgentEvent(rec, event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: agent\ndata: ") || !strings.Contains(body, "\n\nevent: queue\ndata: ") {
+		t.Fatalf("Expected an agent and a queue event, got %s", body)
+	}
+	line := strings.SplitN(strings.TrimPrefix(body, "event: agent\ndata: "), "\n", 2)[0]
+	var data sseAgentEvent
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		t.Fatalf("Failed to decode agent event: %v", err)
+	}
+	if data.AgentID != "42" || data.Status != "finished" || data.Column != finishedColumnID {
+		t.Errorf("Expected agent 42 finished in %s, got %+v", finishedColumnID, data)
+	}
+	if !strings.Contains(data.HTML, `id="agent-42"`) || !strings.Contains(data.HTML, "all green") {
+		t.Errorf("Expected the rendered card, got %s", data.HTML)
+	}
+
+	// Output events only update the card
+	rec = httptest.NewRecorder()
+	event.Type = codeagent.EventOutput
+	event.Info.Status = codeagent.StatusRunning
+	writeAgentEvent(rec, event)
+	if body := rec.Body.String(); strings.Contains(body, "event: queue") || !strings.Contains(body, `"column":"planning-column"`) {
+		t.Errorf("Expected only a planning card update, got %s", body)
+	}
+}
+
+func TestHandleEventsStream(t *testing.T) {
+	setupTest(t)
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleEvents(w, r)
+		close(done)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", contentType)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "retry: 3000\n" {
+		t.Errorf("Expected retry line, got %q (err %v)", line, err)
+	}
+
+	// Disconnecting ends the handler
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the handler to return after the client disconnected")
+	}
+}
@@ -19,7 +19,20 @@ import (
 
 // FileServer serves files from a directory with directory listing
 type FileServer struct {
-	root string
+	root         string
+	noListing    bool // Answer 403 instead of listing directories without an index.html
+	showDotfiles bool // List and serve names starting with a dot, such as .env or .git
+	spa          bool // Serve the root index.html for paths that do not exist
+}
+
+// FileServerOptions configures a file server started with StartFileServer
+type FileServerOptions struct {
+	Auth         *BasicAuth // Require HTTP Basic Auth when set
+	NoListing    bool       // Do not list directories that have no index.html
+	ShowDotfiles bool       // List and serve dotfiles, which are hidden and answer 404 otherwise
+	SPA          bool       // Fall back to the root index.html for client-side routing
+	TLS          bool       // Serve HTTPS with the configured or the stored self-signed certificate
+	TLSHosts     []string   // Host names and addresses for a self-signed certificate
 }
 
 // NewFileServer creates a new file server
@@ -63,10 +76,21 @@ func (fs *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Dotfiles often hold secrets (.env, .git/config), so they are not served
+	// unless enabled; they answer 404 as if they did not exist
+	if !fs.showDotfiles && hasDotSegment(urlPath) {
+		log.Printf("FileServer: Not Found - dotfile path %s is hidden", urlPath)
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	// Get file info
 	info, err := os.Stat(fsPath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			if fs.spa && fs.serveSPAIndex(w, r) {
+				return
+			}
 			log.Printf("FileServer: Not Found - path %s does not exist", fsPath)
 			http.Error(w, "Not Found", http.StatusNotFound)
 			return
@@ -76,8 +100,26 @@ func (fs *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If it's a directory, serve directory listing
+	// If it's a directory, serve its index.html or a directory listing
 	if info.IsDir() {
+		indexPath := filepath.Join(fsPath, "index.html")
+		if indexInfo, err := os.Stat(indexPath); err == nil && indexInfo.Mode().IsRegular() {
+			// Redirect to the trailing slash so relative links in the page resolve
+			if !strings.HasSuffix(urlPath, "/") {
+				target := urlPath + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			fs.serveFile(w, r, indexPath)
+			return
+		}
+		if fs.noListing {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 		fs.serveDirectory(w, r, fsPath, urlPath)
 		return
 	}
@@ -86,6 +128,28 @@ func (fs *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fs.serveFile(w, r, fsPath)
 }
 
+// serveSPAIndex serves the root index.html in place of a missing path, so a
+// single-page app can route it on the client. It reports whether there was
+// an index.html to serve.
+func (fs *FileServer) serveSPAIndex(w http.ResponseWriter, r *http.Request) bool {
+	indexPath := filepath.Join(fs.root, "index.html")
+	if info, err := os.Stat(indexPath); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	fs.serveFile(w, r, indexPath)
+	return true
+}
+
+// hasDotSegment reports whether a segment of urlPath starts with a dot
+func hasDotSegment(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
 // serveFile serves a single file with support for range requests
 func (fs *FileServer) serveFile(w http.ResponseWriter, r *http.Request, path string) {
 	file, err := os.Open(path)
@@ -218,7 +282,7 @@ func (fs *FileServer) serveDirectory(w http.ResponseWriter, r *http.Request, fsP
 		}
 
 		// Skip hidden files
-		if strings.HasPrefix(entry.Name(), ".") {
+		if !fs.showDotfiles && strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 
@@ -487,26 +551,50 @@ func getContentType(ext string) string {
 	}
 }
 
-// StartFileServer starts the file server on the specified port
-func StartFileServer(root string, port string) (*http.Server, error) {
+// StartFileServer starts the file server on the specified port. Requests are
+// recorded in the returned access log.
+func StartFileServer(root string, port string, opts FileServerOptions) (*http.Server, *AccessLog, error) {
 	// Create file server
 	fs := NewFileServer(root)
+	fs.noListing = opts.NoListing
+	fs.showDotfiles = opts.ShowDotfiles
+	fs.spa = opts.SPA
+	accessLog := NewAccessLog()
+
+	var handler http.Handler = fs
+	if opts.Auth != nil {
+		handler = opts.Auth.Wrap(handler)
+	}
 
 	log.Printf("StartFileServer: Starting file server on port %s, serving directory: %s", port, fs.root)
 
 	// Create HTTP server with longer timeouts for large files
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      fs,
+		Handler:      accessLog.Wrap(handler),
 		ReadTimeout:  5 * time.Minute,  // Increased from 10 seconds
 		WriteTimeout: 30 * time.Minute, // Increased to allow large file downloads
 		IdleTimeout:  120 * time.Second,
 	}
 
+	if opts.TLS {
+		tlsConfig, err := serverTLSConfig(opts.TLSHosts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		server.TLSConfig = tlsConfig
+	}
+
 	// Start server in goroutine
 	go func() {
-		log.Printf("StartFileServer: Listening on %s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("StartFileServer: Listening on %s (TLS: %v)", server.Addr, opts.TLS)
+		var err error
+		if opts.TLS {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			// Server failed to start or crashed
 			log.Printf("StartFileServer: Server error: %v", err)
 		}
@@ -515,5 +603,5 @@ func StartFileServer(root string, port string) (*http.Server, error) {
 	// Give server a moment to start
 	time.Sleep(100 * time.Millisecond)
 
-	return server, nil
+	return server, accessLog, nil
 }
@@ -0,0 +1,135 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileServerIndexAndListing(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "site"), 0755)
+	os.MkdirAll(filepath.Join(root, "private"), 0755)
+	os.WriteFile(filepath.Join(root, "site", "index.html"), []byte("<h1>home</h1>"), 0644)
+	os.WriteFile(filepath.Join(root, "private", "secret.txt"), []byte("secret"), 0644)
+
+	tests := []struct {
+		noListing bool
+		path      string
+		status    int
+		body      string
+	}{
+		{false, "/site/", http.StatusOK, "<h1>home</h1>"},
+		{false, "/site", http.StatusMovedPermanently, ""},
+		{false, "/private/", http.StatusOK, "secret.txt"},
+		{true, "/site/", http.StatusOK, "<h1>home</h1>"},
+		{true, "/private/", http.StatusForbidden, ""},
+		{true, "/private/secret.txt", http.StatusOK, "secret"},
+	}
+	for _, tt := range tests {
+		fs := NewFileServer(root)
+		fs.noListing = tt.noListing
+		w := httptest.NewRecorder()
+		fs.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s (no listing %v): expected status %d, got %d", tt.path, tt.noListing, tt.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s (no listing %v): expected body containing %q, got %q", tt.path, tt.noListing, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestFileServerDotfilesAndSPA(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".git"), 0755)
+	os.MkdirAll(filepath.Join(root, "assets"), 0755)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("TOKEN=secret"), 0644)
+	os.WriteFile(filepath.Join(root, ".git", "config"), []byte("[core]"), 0644)
+	os.WriteFile(filepath.Join(root, "assets", "app.js"), []byte("app()"), 0644)
+
+	tests := []struct {
+		showDotfiles bool
+		spa          bool
+		path         string
+		status       int
+		body         string
+	}{
+		{false, false, "/.env", http.StatusNotFound, ""},
+		{false, false, "/.git/config", http.StatusNotFound, ""},
+		{true, false, "/.env", http.StatusOK, "TOKEN=secret"},
+		{true, false, "/", http.StatusOK, ".git/"},
+		{false, false, "/dashboard/settings", http.StatusNotFound, ""},
+		{false, true, "/dashboard/settings", http.StatusOK, "<div id=app>"},
+		{false, true, "/assets/app.js", http.StatusOK, "app()"},
+		{false, true, "/.env", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		if tt.spa {
+			os.WriteFile(filepath.Join(root, "index.html"), []byte("<div id=app>"), 0644)
+		} else {
+			os.Remove(filepath.Join(root, "index.html"))
+		}
+		fs := NewFileServer(root)
+		fs.showDotfiles, fs.spa = tt.showDotfiles, tt.spa
+		w := httptest.NewRecorder()
+		fs.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s (dotfiles %v, spa %v): expected status %d, got %d", tt.path, tt.showDotfiles, tt.spa, tt.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s (dotfiles %v, spa %v): expected body containing %q, got %q", tt.path, tt.showDotfiles, tt.spa, tt.body, w.Body.String())
+		}
+	}
+
+	// Listings leave dotfiles out unless they are shown
+	w := httptest.NewRecorder()
+	NewFileServer(root).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if strings.Contains(w.Body.String(), ".env") {
+		t.Errorf("Expected .env to be hidden from the listing, got %s", w.Body.String())
+	}
+}
+
+func TestStartFileServerTLS(t *testing.T) {
+	useTempTLSDir(t)
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0644)
+
+	// Reserve a free port for the server
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	server, _, err := StartFileServer(root, port, FileServerOptions{TLS: true, TLSHosts: []string{"localhost", "127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer server.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://127.0.0.1:" + port + "/hello.txt")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("Expected hello, got %q", body)
+	}
+	if resp.TLS == nil {
+		t.Error("Expected the file to be served over TLS")
+	}
+}
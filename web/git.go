@@ -3,6 +3,8 @@ package web
 import (
 	"strings"
 
+	"mavis/core"
+
 	g "maragu.dev/gomponents"
 	h "maragu.dev/gomponents/html"
 )
@@ -79,6 +81,22 @@ func GitSection(folderPath string, diff string, showDiff bool) g.Node {
 							),
 						),
 
+						h.Div(h.Class("form-group"),
+							h.Label(h.For("pr-rubric"), g.Text("Review Rubric:")),
+							h.Select(
+								h.ID("pr-rubric"),
+								h.Name("rubric"),
+								h.Class("form-control"),
+								g.Group(g.Map(core.ListReviewRubrics(), func(rubric core.ReviewRubric) g.Node {
+									label := rubric.Name
+									if rubric.Description != "" {
+										label += " - " + rubric.Description
+									}
+									return h.Option(h.Value(rubric.Name), g.Text(label))
+								})),
+							),
+						),
+
 						h.Div(h.Class("form-group button-group"),
 							h.Button(
 								h.Type("submit"),
@@ -116,6 +134,11 @@ func GitDiff(diff string) g.Node {
 		return h.Div(h.Class("no-changes"), g.Text("No changes to commit"))
 	}
 
+	if files := parseDiff(diff); len(files) > 0 {
+		return DiffView(files)
+	}
+
+	// Not a git diff, show it as plain text
 	lines := strings.Split(diff, "\n")
 	return h.Div(h.Class("git-diff"),
 		h.Pre(
@@ -5,6 +5,7 @@ package web
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -112,7 +113,7 @@ func renderInteractiveAgentCard(agent *InteractiveAgent) g.Node {
 	statusClass := "running"
 	statusText := "Running"
 	
-	switch agent.Status {
+	switch agent.GetStatus() {
 	case "failed":
 		statusClass = "failed"
 		statusText = "Failed"
@@ -137,8 +138,8 @@ func renderInteractiveAgentCard(agent *InteractiveAgent) g.Node {
 			h.Div(h.Class("time-info"),
 				h.Span(g.Text(fmt.Sprintf("Started: %s", agent.StartTime.Format("3:04 PM")))),
 				h.Br(),
-				h.Span(g.Text(fmt.Sprintf("Last active: %s", formatTimeAgo(agent.LastActive)))),
-				g.If(agent.Status == "running",
+				h.Span(g.Text(fmt.Sprintf("Last active: %s", formatTimeAgo(agent.GetLastActive())))),
+				g.If(agent.GetStatus() == "running",
 					g.Group([]g.Node{
 						h.Br(),
 						h.Span(g.Text(fmt.Sprintf("Duration: %s", formatDuration(time.Since(agent.StartTime))))),
@@ -148,15 +149,15 @@ func renderInteractiveAgentCard(agent *InteractiveAgent) g.Node {
 		),
 		
 		// Error if any
-		g.If(agent.Error != "",
+		g.If(agent.GetError() != "",
 			h.Div(h.Class("error-message"),
-				h.Pre(g.Text(agent.Error)),
+				h.Pre(g.Text(agent.GetError())),
 			),
 		),
 		
 		// Actions
 		h.Div(h.Class("card-actions"),
-			g.If(agent.Status == "running",
+			g.If(agent.GetStatus() == "running",
 				g.Group([]g.Node{
 					h.A(
 						h.Href(fmt.Sprintf("/interactive?modal=session-%s", agent.ID)),
@@ -175,7 +176,7 @@ func renderInteractiveAgentCard(agent *InteractiveAgent) g.Node {
 					),
 				}),
 			),
-			g.If(agent.Status != "running",
+			g.If(agent.GetStatus() != "running",
 				h.Form(
 					h.Method("POST"),
 					h.Action(fmt.Sprintf("/api/interactive/%s/delete", agent.ID)),
@@ -244,6 +245,30 @@ func InteractiveCreateModal(dirParam string) g.Node {
 					),
 				),
 				
+				// Terminal size
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("cols"), g.Text("Terminal Size (optional)")),
+					h.Div(h.Class("terminal-size-inputs"),
+						h.Input(
+							h.Type("number"),
+							h.ID("cols"),
+							h.Name("cols"),
+							h.Min("1"),
+							h.Max("1000"),
+							h.Placeholder(fmt.Sprintf("%d columns", codeagent.DefaultTerminalCols)),
+						),
+						h.Input(
+							h.Type("number"),
+							h.ID("rows"),
+							h.Name("rows"),
+							h.Min("1"),
+							h.Max("1000"),
+							h.Placeholder(fmt.Sprintf("%d rows", codeagent.DefaultTerminalRows)),
+						),
+					),
+					h.Small(h.Class("help-text"), g.Text("Match your browser window so the rendered output lines up")),
+				),
+				
 				// Info
 				h.Div(h.Class("info-box"),
 					h.P(g.Text("This will start an interactive Claude session in the selected directory.")),
@@ -276,12 +301,13 @@ func InteractiveSessionView(sessionID string) g.Node {
 	
 	// Get token status
 	tokenStatus := agent.GetLastTokenStatus()
+	cols, rows := agent.GetSize()
 	
 	return h.Div(h.ID("session-modal"), h.Class("modal"), h.Style("display: flex;"),
 		h.A(h.Href("/interactive"), h.Class("modal-backdrop"), g.Attr("aria-label", "Close modal")),
 		h.Div(h.Class("modal-content modal-large"),
 			h.Div(h.Class("modal-header"),
-				h.H3(g.Text(fmt.Sprintf("Session %s - %s", sessionID[:8], agent.Status))),
+				h.H3(g.Text(fmt.Sprintf("Session %s - %s", sessionID[:8], agent.GetStatus()))),
 				h.A(h.Href("/interactive"), h.Class("close-btn"), g.Text("×")),
 			),
 			
@@ -291,18 +317,26 @@ func InteractiveSessionView(sessionID string) g.Node {
 				h.P(h.Class("folder-info"), g.Text(fmt.Sprintf("Working in: %s", agent.Folder))),
 				
 				// Show error prominently if failed
-				g.If(agent.Status == "failed" && agent.Error != "",
+				g.If(agent.GetStatus() == "failed" && agent.GetError() != "",
 					h.Div(h.Class("error-box"),
 						h.Strong(g.Text("Error: ")),
-						h.Pre(g.Text(agent.Error)),
+						h.Pre(g.Text(agent.GetError())),
 					),
 				),
 				
 				// Conversation area - use iframe for streaming
-				h.Div(h.Class("session-output"),
+				h.Div(h.Class("session-output"), h.Data("session-id", sessionID),
 					g.Raw(fmt.Sprintf(`<iframe src="/stream/interactive/%s" title="Conversation Stream"></iframe>`, sessionID)),
 				),
 				
+				h.P(h.Class("help-text"),
+					h.A(
+						h.Href(fmt.Sprintf("/stream/interactive/%s?raw=1", sessionID)),
+						h.Target("_blank"),
+						g.Text("View raw terminal output"),
+					),
+				),
+				
 				// Token status
 				g.If(tokenStatus != "",
 					h.Div(h.Class("token-status"),
@@ -311,7 +345,7 @@ func InteractiveSessionView(sessionID string) g.Node {
 				),
 				
 				// Actions (only if running)
-				g.If(agent.Status == "running",
+				g.If(agent.GetStatus() == "running",
 					h.Div(h.Class("session-actions"),
 						h.A(
 							h.Href(fmt.Sprintf("/interactive?modal=session-%s-input", sessionID)),
@@ -328,6 +362,23 @@ func InteractiveSessionView(sessionID string) g.Node {
 								g.Text("Stop Session"),
 							),
 						),
+						h.Form(
+							h.Method("POST"),
+							h.Action(fmt.Sprintf("/api/interactive/%s/resize", sessionID)),
+							h.Class("resize-form"),
+							h.Style("display: inline-block; margin-left: 0.5rem;"),
+							h.Input(h.Type("number"), h.Name("cols"), h.Min("1"), h.Max("1000"), h.Value(strconv.Itoa(cols)), g.Attr("aria-label", "Columns")),
+							g.Text(" × "),
+							h.Input(h.Type("number"), h.Name("rows"), h.Min("1"), h.Max("1000"), h.Value(strconv.Itoa(rows)), g.Attr("aria-label", "Rows")),
+							h.Button(
+								h.Type("submit"),
+								h.Class("btn btn-secondary"),
+								g.Text("Resize"),
+							),
+						),
+						TerminalKeyForm(sessionID),
+						// Size the terminal to the conversation area instead of the default
+						h.Script(h.Src("/static/js/interactive-resize.js"), h.Defer()),
 					),
 				),
 			),
@@ -335,6 +386,37 @@ func InteractiveSessionView(sessionID string) g.Node {
 	)
 }
 
+// terminalKeyButtons are the keys offered in the session modal, with their labels
+var terminalKeyButtons = []struct{ key, label string }{
+	{"ctrl-c", "Ctrl+C"},
+	{"esc", "Esc"},
+	{"up", "↑"},
+	{"down", "↓"},
+	{"tab", "Tab"},
+	{"enter", "Enter"},
+}
+
+// TerminalKeyForm renders buttons that send control keys to a session without a trailing Enter
+func TerminalKeyForm(sessionID string) g.Node {
+	buttons := make([]g.Node, 0, len(terminalKeyButtons))
+	for _, button := range terminalKeyButtons {
+		buttons = append(buttons, h.Button(
+			h.Type("submit"),
+			h.Name("key"),
+			h.Value(button.key),
+			h.Class("btn btn-secondary btn-sm"),
+			g.Text(button.label),
+		))
+	}
+	return h.Form(
+		h.Method("POST"),
+		h.Action(fmt.Sprintf("/api/interactive/%s/key", sessionID)),
+		h.Class("key-form"),
+		g.Attr("aria-label", "Send key"),
+		g.Group(buttons),
+	)
+}
+
 // InteractiveInputModal renders the input form for sending messages
 func InteractiveInputModal(sessionID string) g.Node {
 	agent := interactiveManager.GetAgent(sessionID)
@@ -8,8 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"html"
+	"log"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,18 @@ import (
 
 var interactiveManager = codeagent.NewInteractiveAgentManager()
 
+// SetInteractiveScrollbackLines sets how many lines that scrolled off the
+// terminal new interactive sessions keep for reconnecting clients
+func SetInteractiveScrollbackLines(lines int) {
+	interactiveManager.SetScrollbackLimit(lines)
+}
+
+// SetInteractiveIdleTimeout stops interactive sessions that nobody is watching
+// and that have had no input or output for longer than d
+func SetInteractiveIdleTimeout(d time.Duration) {
+	interactiveManager.SetIdleTimeout(d)
+}
+
 // createTempMCPConfig creates a temporary MCP config file and returns the path
 func createTempMCPConfig(workDir string, selectedMCPs []string) string {
 	if len(selectedMCPs) == 0 {
@@ -49,6 +63,16 @@ type InteractiveAgentStatus struct {
 type CreateInteractiveRequest struct {
 	WorkDir      string   `json:"work_dir"`
 	SelectedMCPs []string `json:"selected_mcps"`
+	Cols         int      `json:"cols,omitempty"`
+	Rows         int      `json:"rows,omitempty"`
+}
+
+// parseTerminalSize reads the optional cols and rows form values.
+// Missing or invalid values are returned as zero.
+func parseTerminalSize(r *http.Request) (int, int) {
+	cols, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("cols")))
+	rows, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("rows")))
+	return cols, rows
 }
 
 func handleInteractiveRoutes(w http.ResponseWriter, r *http.Request) {
@@ -62,10 +86,10 @@ func handleInteractiveRoutes(w http.ResponseWriter, r *http.Request) {
 			statuses[i] = InteractiveAgentStatus{
 				ID:         agent.ID,
 				Folder:     agent.Folder,
-				Status:     agent.Status,
+				Status:     agent.GetStatus(),
 				StartTime:  agent.StartTime,
-				LastActive: agent.LastActive,
-				Error:      agent.Error,
+				LastActive: agent.GetLastActive(),
+				Error:      agent.GetError(),
 			}
 		}
 		
@@ -80,6 +104,7 @@ func handleInteractiveRoutes(w http.ResponseWriter, r *http.Request) {
 		req.WorkDir = r.FormValue("work_dir")
 		r.ParseForm()
 		req.SelectedMCPs = r.Form["selected_mcps"]
+		req.Cols, req.Rows = parseTerminalSize(r)
 		
 		if req.WorkDir == "" {
 			SetErrorFlash(w, "Work directory is required")
@@ -102,7 +127,7 @@ func handleInteractiveRoutes(w http.ResponseWriter, r *http.Request) {
 		}
 		
 		// Create and start agent
-		agent, err := interactiveManager.CreateAgent(context.Background(), absDir, mcpConfig)
+		agent, err := interactiveManager.CreateAgentWithSize(context.Background(), absDir, mcpConfig, req.Cols, req.Rows)
 		if err != nil {
 			SetErrorFlash(w, fmt.Sprintf("Failed to create interactive agent: %v", err))
 			http.Redirect(w, r, "/interactive", http.StatusSeeOther)
@@ -139,6 +164,10 @@ func handleInteractiveAgentAction(w http.ResponseWriter, r *http.Request) {
 			handleInteractiveDelete(w, r, agentID)
 		case "input":
 			handleInteractiveInput(w, r, agentID)
+		case "resize":
+			handleInteractiveResize(w, r, agentID)
+		case "key":
+			handleInteractiveKey(w, r, agentID)
 		default:
 			http.Error(w, "Unknown action", http.StatusBadRequest)
 		}
@@ -225,6 +254,47 @@ func handleInteractiveInput(w http.ResponseWriter, r *http.Request, agentID stri
 	http.Redirect(w, r, fmt.Sprintf("/interactive?modal=session-%s", agentID), http.StatusSeeOther)
 }
 
+func handleInteractiveKey(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	agent := interactiveManager.GetAgent(agentID)
+	if agent == nil {
+		SetErrorFlash(w, "Session not found")
+		http.Redirect(w, r, "/interactive", http.StatusSeeOther)
+		return
+	}
+	
+	if err := agent.SendKey(r.FormValue("key")); err != nil {
+		SetErrorFlash(w, fmt.Sprintf("Failed to send key: %v", err))
+	}
+	
+	http.Redirect(w, r, fmt.Sprintf("/interactive?modal=session-%s", agentID), http.StatusSeeOther)
+}
+
+func handleInteractiveResize(w http.ResponseWriter, r *http.Request, agentID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	agent := interactiveManager.GetAgent(agentID)
+	if agent == nil {
+		SetErrorFlash(w, "Session not found")
+		http.Redirect(w, r, "/interactive", http.StatusSeeOther)
+		return
+	}
+	
+	cols, rows := parseTerminalSize(r)
+	if err := agent.Resize(cols, rows); err != nil {
+		SetErrorFlash(w, fmt.Sprintf("Failed to resize terminal: %v", err))
+	}
+	
+	http.Redirect(w, r, fmt.Sprintf("/interactive?modal=session-%s", agentID), http.StatusSeeOther)
+}
+
 // handleInteractiveStream provides HTTP streaming of conversation updates
 func handleInteractiveStream(w http.ResponseWriter, r *http.Request) {
 	// Extract session ID from URL
@@ -241,6 +311,11 @@ func handleInteractiveStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if r.URL.Query().Get("raw") == "1" {
+		handleInteractiveRawStream(w, r, agent)
+		return
+	}
+	
 	// Set headers for chunked transfer encoding
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -315,7 +390,7 @@ body {
 		case <-ctx.Done():
 			return
 			
-		case <-updates:
+		case _, ok := <-updates:
 			// Get latest messages
 			messages = agent.GetMessageHistory()
 			
@@ -331,6 +406,196 @@ body {
 			
 			flusher.Flush()
 			
+			// The channel is closed when the session ends or we fall behind
+			if !ok {
+				return
+			}
+			
+		case <-ticker.C:
+			// Send keep-alive comment
+			fmt.Fprint(w, "<!-- keepalive -->\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// interactiveSocketMessage is a JSON message on the interactive terminal WebSocket.
+// The server sends "scrollback", "screen", "status" and "error" messages; clients
+// send "input" (text followed by Enter), "key" (see codeagent.TerminalKeys) and
+// "resize". A client message that is not JSON is sent as input.
+type interactiveSocketMessage struct {
+	Type   string   `json:"type"`
+	Lines  []string `json:"lines,omitempty"`
+	Status string   `json:"status,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	Data   string   `json:"data,omitempty"`
+	Key    string   `json:"key,omitempty"`
+	Cols   int      `json:"cols,omitempty"`
+	Rows   int      `json:"rows,omitempty"`
+}
+
+// handleInteractiveSocketRoute serves /interactive/{id}/ws
+func handleInteractiveSocketRoute(w http.ResponseWriter, r *http.Request) {
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[2] != "ws" {
+		http.NotFound(w, r)
+		return
+	}
+	
+	agent := interactiveManager.GetAgent(pathParts[1])
+	if agent == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		log.Printf("[Interactive] WebSocket upgrade failed for session %s: %v", agent.ID, err)
+		return
+	}
+	defer conn.Close()
+	
+	serveInteractiveSocket(r.Context(), conn, agent)
+}
+
+// serveInteractiveSocket streams screen updates to conn and applies the client's input
+func serveInteractiveSocket(ctx context.Context, conn *wsConn, agent *codeagent.InteractiveAgent) {
+	subID, updates := agent.Subscribe()
+	defer agent.Unsubscribe(subID)
+	
+	send := func(msg interactiveSocketMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return conn.WriteText(string(data))
+	}
+	sendScreen := func() error {
+		return send(interactiveSocketMessage{Type: "screen", Lines: agent.GetOutput(), Status: agent.GetStatus()})
+	}
+	
+	// The subscription starts with the backlog; send it as structured messages instead
+	for drained := false; !drained; {
+		select {
+		case <-updates:
+		default:
+			drained = true
+		}
+	}
+	if scrollback := agent.GetScrollback(); len(scrollback) > 0 {
+		if err := send(interactiveSocketMessage{Type: "scrollback", Lines: scrollback}); err != nil {
+			return
+		}
+	}
+	if err := sendScreen(); err != nil {
+		return
+	}
+	
+	// Read client messages until the connection closes
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			text, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := applyInteractiveSocketMessage(agent, text); err != nil {
+				send(interactiveSocketMessage{Type: "error", Error: err.Error()})
+			}
+		}
+	}()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-closed:
+			return
+		case _, ok := <-updates:
+			if !ok {
+				sendScreen()
+				send(interactiveSocketMessage{Type: "status", Status: agent.GetStatus(), Error: agent.GetError()})
+				return
+			}
+			if err := sendScreen(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// applyInteractiveSocketMessage sends a client message to the session
+func applyInteractiveSocketMessage(agent *codeagent.InteractiveAgent, text string) error {
+	var msg interactiveSocketMessage
+	if err := json.Unmarshal([]byte(text), &msg); err != nil {
+		return agent.SendInput(text)
+	}
+	
+	switch msg.Type {
+	case "input":
+		return agent.SendInput(msg.Data)
+	case "key":
+		return agent.SendKey(msg.Key)
+	case "resize":
+		return agent.Resize(msg.Cols, msg.Rows)
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+}
+
+// handleInteractiveRawStream streams the unfiltered terminal screen for debugging.
+// Each snapshot is appended to the page and CSS hides all but the latest one.
+func handleInteractiveRawStream(w http.ResponseWriter, r *http.Request, agent *codeagent.InteractiveAgent) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Raw Terminal Output</title>
+<link rel="stylesheet" href="/static/css/minimal.css">
+<style>
+body { margin: 0; padding: 20px; background: var(--surface); }
+.raw-screen { font-family: monospace; font-size: 0.8rem; white-space: pre; margin: 0; }
+.raw-screen:not(:last-of-type) { display: none; }
+.raw-scrollback { font-family: monospace; font-size: 0.8rem; white-space: pre; margin: 0; color: var(--text-secondary); }
+</style>
+</head>
+<body>
+`)
+	if scrollback := agent.GetScrollback(); len(scrollback) > 0 {
+		fmt.Fprintf(w, "<pre class=\"raw-scrollback\">%s</pre>\n", html.EscapeString(strings.Join(scrollback, "\n")))
+	}
+	flusher.Flush()
+	
+	subID, screens := agent.SubscribeRaw()
+	defer agent.Unsubscribe(subID)
+	
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+			
+		case screen, ok := <-screens:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "<pre class=\"raw-screen\">%s</pre>\n", html.EscapeString(screen))
+			flusher.Flush()
+			
 		case <-ticker.C:
 			// Send keep-alive comment
 			fmt.Fprint(w, "<!-- keepalive -->\n")
@@ -250,4 +250,20 @@ func TestInteractiveHandlers(t *testing.T) {
 			t.Log("No flash cookie found, but redirect is correct")
 		}
 	})
-}
\ No newline at end of file
+}
+func TestTerminalKeyForm(t *testing.T) {
+	var buf strings.Builder
+	if err := TerminalKeyForm("abc123").Render(&buf); err != nil {
+		t.Fatalf("Failed to render key form: %v", err)
+	}
+	html := buf.String()
+
+	if !strings.Contains(html, `action="/api/interactive/abc123/key"`) {
+		t.Errorf("Expected form to post to the key action, got %s", html)
+	}
+	for _, key := range []string{"ctrl-c", "esc", "up", "down", "tab", "enter"} {
+		if !strings.Contains(html, `name="key" value="`+key+`"`) {
+			t.Errorf("Expected a button for %s", key)
+		}
+	}
+}
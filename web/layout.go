@@ -86,7 +86,8 @@ func DashboardLayoutWithRefresh(w http.ResponseWriter, r *http.Request, children
 					),
 					h.Main(h.ID("main-content"), h.Class("section"), g.Group(children)),
 				),
-				// JavaScript removed - all functionality is server-side now
+				// JavaScript is limited to live agent updates, added by AgentsSection,
+				// and terminal sizing, added by InteractiveSessionView
 			},
 		},
 	)
@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"mavis/codeagent"
 )
 
 func TestMCPModal(t *testing.T) {
@@ -510,7 +512,7 @@ func TestMCPAgentIntegration(t *testing.T) {
 
 		// Try to create agent with failing MCP
 		selectedMCPs := []string{failingMCP.ID}
-		_, err := createCodeAgent("test task", tmpDir, selectedMCPs)
+		_, err := createCodeAgent("test task", tmpDir, selectedMCPs, codeagent.AgentOptions{})
 
 		if err == nil {
 			t.Error("Expected error when creating agent with failing MCP, got nil")
@@ -0,0 +1,111 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mavis/codeagent"
+)
+
+// lanServerStatus reports whether the LAN server is up; nil leaves the metric out
+var lanServerStatus func() bool
+
+// SetLANServerStatusFunc sets how /metrics checks whether the LAN server is running
+func SetLANServerStatusFunc(fn func() bool) {
+	lanServerStatus = fn
+}
+
+// RegisterMetrics adds the Prometheus /metrics endpoint to mux
+func RegisterMetrics(mux *http.ServeMux) {
+	mux.HandleFunc("/metrics", handleMetrics)
+}
+
+// handleMetrics serves agent, queue, token and LAN server metrics in the
+// Prometheus text exposition format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, renderMetrics())
+}
+
+// renderMetrics collects the current metrics from the agent manager
+func renderMetrics() string {
+	var sb strings.Builder
+
+	writeMetricHeader(&sb, "mavis_agents_launched_total", "counter", "Code agents launched since startup.")
+	fmt.Fprintf(&sb, "mavis_agents_launched_total %d\n", agentManager.LaunchedCount())
+
+	statusCounts := map[codeagent.AgentStatus]int{}
+	for _, agent := range agentManager.ListAgents() {
+		statusCounts[agent.Status]++
+	}
+	writeMetricHeader(&sb, "mavis_agents", "gauge", "Tracked code agents by status.")
+	for _, status := range []codeagent.AgentStatus{
+		codeagent.StatusPending,
+		codeagent.StatusRunning,
+		codeagent.StatusFinished,
+		codeagent.StatusFailed,
+		codeagent.StatusKilled,
+	} {
+		fmt.Fprintf(&sb, "mavis_agents{status=\"%s\"} %d\n", status, statusCounts[status])
+	}
+
+	writeMetricHeader(&sb, "mavis_queue_depth", "gauge", "Tasks queued per folder.")
+	queueStatus := agentManager.GetQueueStatus()
+	for _, folder := range sortedKeys(queueStatus) {
+		fmt.Fprintf(&sb, "mavis_queue_depth{folder=\"%s\"} %d\n", escapeLabelValue(folder), queueStatus[folder])
+	}
+
+	concurrency := agentManager.GetConcurrencyStatus()
+	writeMetricHeader(&sb, "mavis_agent_limit", "gauge", "Most agents allowed to run at once, 0 when unlimited.")
+	fmt.Fprintf(&sb, "mavis_agent_limit %d\n", concurrency.Limit)
+	writeMetricHeader(&sb, "mavis_agents_waiting_for_slot", "gauge", "Tasks waiting for the agent limit rather than for their folder.")
+	fmt.Fprintf(&sb, "mavis_agents_waiting_for_slot %d\n", concurrency.Waiting)
+
+	writeMetricHeader(&sb, "mavis_agent_tokens", "gauge", "Tokens used by tracked agents per folder, for agents that report token usage.")
+	tokens := agentManager.TotalTokensUsed()
+	for _, folder := range sortedKeys(tokens) {
+		fmt.Fprintf(&sb, "mavis_agent_tokens{folder=\"%s\"} %d\n", escapeLabelValue(folder), tokens[folder])
+	}
+
+	writeMetricHeader(&sb, "mavis_tokens_used_today", "gauge", "Tokens used today, including running agents.")
+	fmt.Fprintf(&sb, "mavis_tokens_used_today %d\n", agentManager.GetDailyTokenUsage())
+
+	if lanServerStatus != nil {
+		up := 0
+		if lanServerStatus() {
+			up = 1
+		}
+		writeMetricHeader(&sb, "mavis_lan_server_up", "gauge", "Whether a LAN server is running (1) or not (0).")
+		fmt.Fprintf(&sb, "mavis_lan_server_up %d\n", up)
+	}
+
+	return sb.String()
+}
+
+func writeMetricHeader(sb *strings.Builder, name, metricType, help string) {
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// escapeLabelValue escapes a Prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	setupTest(t)
+	SetLANServerStatusFunc(func() bool { return true })
+	defer SetLANServerStatusFunc(nil)
+
+	mux := http.NewServeMux()
+	RegisterMetrics(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected text/plain content type, got %s", contentType)
+	}
+
+	body := w.Body.String()
+	for _, expected := range []string{
+		"# TYPE mavis_agents_launched_total counter\n",
+		"mavis_agents_launched_total ",
+		`mavis_agents{status="running"} `,
+		"# TYPE mavis_queue_depth gauge\n",
+		"mavis_agent_limit 0\n",
+		"mavis_agents_waiting_for_slot 0\n",
+		"mavis_tokens_used_today ",
+		"mavis_lan_server_up 1\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/metrics", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("Expected escaped label value, got %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+// Keeps the agents page up to date from the /events stream instead of
+// reloading it, so scroll position and open forms are kept.
+(function () {
+  if (!window.EventSource) {
+    return;
+  }
+
+  // Show the number of cards in a column's header
+  function recount(column) {
+    var count = column.parentElement.querySelector('.kanban-header .kanban-count');
+    if (count) {
+      count.textContent = '(' + column.children.length + ')';
+    }
+  }
+
+  // Replace the agent's card, moving it to the column it now belongs in.
+  // Cards are kept sorted by ID like the server renders them.
+  function placeCard(data) {
+    var old = document.getElementById('agent-' + data.agent_id);
+    if (old) {
+      var oldColumn = old.parentElement;
+      old.remove();
+      recount(oldColumn);
+    }
+
+    var column = document.getElementById(data.column);
+    if (!column || !data.html) {
+      return;
+    }
+    var template = document.createElement('template');
+    template.innerHTML = data.html.trim();
+    var card = template.content.firstElementChild;
+    var next = null;
+    for (var i = 0; i < column.children.length; i++) {
+      if (column.children[i].id > card.id) {
+        next = column.children[i];
+        break;
+      }
+    }
+    column.insertBefore(card, next);
+    recount(column);
+  }
+
+  var source = new EventSource('/events');
+  source.addEventListener('agent', function (event) {
+    placeCard(JSON.parse(event.data));
+  });
+  source.addEventListener('queue', function (event) {
+    var column = document.getElementById('queue-column');
+    if (column) {
+      column.innerHTML = JSON.parse(event.data).html;
+      recount(column);
+    }
+  });
+})();
@@ -0,0 +1,67 @@
+// Sizes an interactive session's terminal to the conversation area. The
+// area is measured in monospace character cells and sent as a "resize"
+// message on the session's WebSocket when the page loads and whenever the
+// window is resized.
+(function () {
+  var output = document.querySelector('.session-output[data-session-id]');
+  if (!output || !window.WebSocket) {
+    return;
+  }
+  var sessionID = output.getAttribute('data-session-id');
+
+  // Measure one character cell in the font the stream page renders with
+  function cellSize() {
+    var probe = document.createElement('pre');
+    probe.className = 'terminal-probe';
+    probe.style.position = 'absolute';
+    probe.style.visibility = 'hidden';
+    probe.style.whiteSpace = 'pre';
+    probe.textContent = 'MMMMMMMMMM';
+    output.appendChild(probe);
+    var rect = probe.getBoundingClientRect();
+    output.removeChild(probe);
+    return { width: rect.width / 10, height: rect.height };
+  }
+
+  function terminalSize() {
+    var cell = cellSize();
+    if (!cell.width || !cell.height) {
+      return null;
+    }
+    return {
+      cols: Math.max(20, Math.floor(output.clientWidth / cell.width)),
+      rows: Math.max(5, Math.floor(output.clientHeight / cell.height))
+    };
+  }
+
+  var scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+  var socket = new WebSocket(scheme + window.location.host + '/interactive/' + encodeURIComponent(sessionID) + '/ws');
+  var sent = null;
+
+  function sendSize() {
+    var size = terminalSize();
+    if (!size || socket.readyState !== WebSocket.OPEN) {
+      return;
+    }
+    if (sent && sent.cols === size.cols && sent.rows === size.rows) {
+      return;
+    }
+    socket.send(JSON.stringify({ type: 'resize', cols: size.cols, rows: size.rows }));
+    sent = size;
+
+    // Keep the manual resize form in step with the measured size
+    var form = document.querySelector('.resize-form');
+    if (form) {
+      form.elements.cols.value = size.cols;
+      form.elements.rows.value = size.rows;
+    }
+  }
+
+  socket.addEventListener('open', sendSize);
+
+  var pending = null;
+  window.addEventListener('resize', function () {
+    clearTimeout(pending);
+    pending = setTimeout(sendSize, 250);
+  });
+})();
@@ -0,0 +1,235 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TLSDir holds the self-signed certificate generated for HTTPS servers. It is
+// reused across restarts so a browser that accepted it once keeps accepting it.
+var TLSDir = filepath.Join("data", "tls")
+
+// TLS certificate files used by StartTLSProxy and HTTPS file servers, e.g. a
+// Let's Encrypt certificate obtained with certbot. Empty means self-signed.
+var (
+	tlsCertFile string
+	tlsKeyFile  string
+	tlsMutex    sync.RWMutex
+)
+
+// SetTLSCertFiles configures the certificate and key served by HTTPS proxies.
+// Both files are read when a proxy starts, so renewed certificates are picked
+// up by the next /start.
+func SetTLSCertFiles(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("both a certificate and a key file are required")
+	}
+	if certFile != "" {
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	}
+
+	tlsMutex.Lock()
+	defer tlsMutex.Unlock()
+	tlsCertFile, tlsKeyFile = certFile, keyFile
+	return nil
+}
+
+// TLSCertificateSource describes where HTTPS proxies get their certificate
+func TLSCertificateSource() string {
+	tlsMutex.RLock()
+	defer tlsMutex.RUnlock()
+	if tlsCertFile == "" {
+		return "self-signed"
+	}
+	return tlsCertFile
+}
+
+// loadTLSCertificate returns the configured certificate, or the stored
+// self-signed one valid for hosts
+func loadTLSCertificate(hosts []string) (tls.Certificate, error) {
+	tlsMutex.RLock()
+	certFile, keyFile := tlsCertFile, tlsKeyFile
+	tlsMutex.RUnlock()
+
+	if certFile != "" {
+		return tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	return storedSelfSignedCertificate(hosts)
+}
+
+// storedSelfSignedCertificate loads the self-signed certificate from TLSDir,
+// creating it on first use and again when it expires or does not cover hosts
+func storedSelfSignedCertificate(hosts []string) (tls.Certificate, error) {
+	tlsMutex.Lock()
+	defer tlsMutex.Unlock()
+
+	certPath := filepath.Join(TLSDir, "self-signed.crt")
+	keyPath := filepath.Join(TLSDir, "self-signed.key")
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil && certificateCovers(cert, hosts) {
+		return cert, nil
+	}
+
+	certPEM, keyPEM, err := selfSignedPEM(hosts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(TLSDir, 0700); err != nil {
+		log.Printf("Failed to create TLS directory: %v", err)
+	} else if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		log.Printf("Failed to save self-signed key: %v", err)
+	} else if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		log.Printf("Failed to save self-signed certificate: %v", err)
+	} else {
+		log.Printf("Saved self-signed certificate to %s", certPath)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// certificateCovers reports whether cert is valid for another day and for
+// every one of hosts
+func certificateCovers(cert tls.Certificate, hosts []string) bool {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false
+		}
+	}
+	if time.Now().Add(24 * time.Hour).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfSignedCertificate creates a one-year certificate for hosts, which may be
+// host names or IP addresses
+func SelfSignedCertificate(hosts []string) (tls.Certificate, error) {
+	certPEM, keyPEM, err := selfSignedPEM(hosts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// selfSignedPEM creates a one-year certificate for hosts and returns it and
+// its key PEM encoded
+func selfSignedPEM(hosts []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Mavis"}, CommonName: "Mavis dev server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// serverTLSConfig returns the TLS settings for an HTTPS server valid for hosts
+func serverTLSConfig(hosts []string) (*tls.Config, error) {
+	cert, err := loadTLSCertificate(hosts)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// StartTLSProxy serves HTTPS on port and forwards every request to the HTTP
+// server on targetPort on this host. hosts are used for a self-signed
+// certificate when no certificate files are configured.
+func StartTLSProxy(port, targetPort string, hosts []string) (*http.Server, error) {
+	tlsConfig, err := serverTLSConfig(hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", targetPort)}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded() // X-Forwarded-Proto: https, so apps build https links
+			r.Out.Host = r.In.Host
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("StartTLSProxy: Upstream error for %s: %v", r.URL.Path, err)
+			http.Error(w, "Bad Gateway: the dev server is not responding", http.StatusBadGateway)
+		},
+	}
+
+	listener, err := tls.Listen("tcp", ":"+port, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on port %s: %w", port, err)
+	}
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           proxy,
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	go func() {
+		log.Printf("StartTLSProxy: Forwarding https://:%s to %s", port, target)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("StartTLSProxy: Server error: %v", err)
+		}
+	}()
+
+	return server, nil
+}
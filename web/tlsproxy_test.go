@@ -0,0 +1,122 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempTLSDir keeps the self-signed certificate of a test out of data/
+func useTempTLSDir(t *testing.T) {
+	old := TLSDir
+	TLSDir = t.TempDir()
+	t.Cleanup(func() { TLSDir = old })
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := SelfSignedCertificate([]string{"localhost", "mavis.local", "192.168.1.20"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("mavis.local"); err != nil {
+		t.Errorf("Expected certificate valid for mavis.local, got %v", err)
+	}
+	if err := leaf.VerifyHostname("192.168.1.20"); err != nil {
+		t.Errorf("Expected certificate valid for 192.168.1.20, got %v", err)
+	}
+}
+
+func TestStoredSelfSignedCertificate(t *testing.T) {
+	useTempTLSDir(t)
+
+	first, err := storedSelfSignedCertificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(TLSDir, "self-signed.key")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key saved with mode 0600, got %v (err %v)", info, err)
+	}
+
+	// The stored certificate is reused while it covers the hosts
+	again, err := storedSelfSignedCertificate([]string{"localhost"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(again.Certificate[0]) != string(first.Certificate[0]) {
+		t.Error("Expected the stored certificate to be reused")
+	}
+
+	// A new address needs a new certificate
+	other, err := storedSelfSignedCertificate([]string{"localhost", "192.168.1.50"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(other.Certificate[0]) == string(first.Certificate[0]) {
+		t.Error("Expected a new certificate for a host the stored one does not cover")
+	}
+	if !certificateCovers(other, []string{"192.168.1.50"}) {
+		t.Error("Expected the new certificate to cover 192.168.1.50")
+	}
+}
+
+func TestSetTLSCertFiles(t *testing.T) {
+	if err := SetTLSCertFiles("cert.pem", ""); err == nil {
+		t.Error("Expected an error when the key file is missing")
+	}
+	if err := SetTLSCertFiles("/nonexistent/cert.pem", "/nonexistent/key.pem"); err == nil {
+		t.Error("Expected an error for unreadable files")
+	}
+	if source := TLSCertificateSource(); source != "self-signed" {
+		t.Errorf("Expected self-signed, got %s", source)
+	}
+}
+
+func TestStartTLSProxy(t *testing.T) {
+	useTempTLSDir(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Forwarded-Proto")+" "+r.URL.Path)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	_, backendPort, _ := net.SplitHostPort(backendURL.Host)
+
+	// Reserve a free port for the proxy
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	_, proxyPort, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	proxy, err := StartTLSProxy(proxyPort, backendPort, []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer proxy.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://127.0.0.1:" + proxyPort + "/hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "https /hello" {
+		t.Errorf("Expected https /hello, got %q", body)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// maxFileUploadSize is the largest file accepted by POST /upload
+const maxFileUploadSize = 100 << 20 // 100 MB
+
+// uploadError is an error answered with status
+type uploadError struct {
+	status int
+	err    error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+
+// handleFileUpload saves the multipart "file" field into the directory in the
+// "dir" field, which must be inside the home directory. An existing file is
+// only replaced when "overwrite" is true. Answers JSON with the saved path.
+func handleFileUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	// The form is a plain multipart POST, which any web page can submit, so
+	// only accept uploads from pages served by this server
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if !sameOrigin(origin, r.Host) {
+		log.Printf("Upload rejected: cross-origin request from %q", origin)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Cross-origin uploads are not allowed"})
+		return
+	}
+
+	path, size, err := saveUploadedFile(w, r)
+	if err != nil {
+		status := http.StatusBadRequest
+		var uploadErr *uploadError
+		if errors.As(err, &uploadErr) {
+			status = uploadErr.status
+		}
+		log.Printf("Upload rejected: %v", err)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Uploaded %s (%d bytes)", path, size)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path": path,
+		"size": size,
+	})
+}
+
+// saveUploadedFile validates and writes the upload, returning the saved path and size
+func saveUploadedFile(w http.ResponseWriter, r *http.Request) (string, int64, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", 0, &uploadError{http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds the %d MB limit", maxFileUploadSize>>20)}
+		}
+		return "", 0, fmt.Errorf("invalid multipart form: %w", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", 0, fmt.Errorf("missing file: %w", err)
+	}
+	defer file.Close()
+
+	target, err := uploadTarget(r.FormValue("dir"), header.Filename)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// O_NOFOLLOW keeps a symlink swapped in after uploadTarget checked the
+	// name from redirecting the write
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL | syscall.O_NOFOLLOW
+	if r.FormValue("overwrite") == "true" {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC | syscall.O_NOFOLLOW
+	}
+	dst, err := os.OpenFile(target, flags, 0644)
+	if err != nil {
+		if errors.Is(err, syscall.ELOOP) {
+			return "", 0, &uploadError{http.StatusForbidden, fmt.Errorf("refusing to write through a symlink: %s", target)}
+		}
+		if errors.Is(err, os.ErrExist) {
+			return "", 0, &uploadError{http.StatusConflict, fmt.Errorf("file already exists: %s (set overwrite=true to replace it)", target)}
+		}
+		return "", 0, &uploadError{http.StatusInternalServerError, err}
+	}
+
+	size, err := io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return "", 0, &uploadError{http.StatusInternalServerError, fmt.Errorf("failed to save file: %w", err)}
+	}
+	return target, size, nil
+}
+
+// uploadTarget resolves the path an upload named filename is written to in
+// dir, rejecting directories outside home and names that would escape dir
+func uploadTarget(dir, filename string) (string, error) {
+	if strings.TrimSpace(dir) == "" {
+		return "", fmt.Errorf("dir is required")
+	}
+	absDir, err := ResolvePath(dir)
+	if err != nil {
+		return "", &uploadError{http.StatusForbidden, err}
+	}
+	// Compare resolved paths so a symlink inside home cannot point outside it
+	absDir, err = filepath.EvalSymlinks(absDir)
+	if err != nil {
+		return "", fmt.Errorf("directory not found: %s", dir)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", &uploadError{http.StatusInternalServerError, err}
+	}
+	if resolved, err := filepath.EvalSymlinks(home); err == nil {
+		home = resolved
+	}
+	if absDir != home && !strings.HasPrefix(absDir, home+string(filepath.Separator)) {
+		return "", &uploadError{http.StatusForbidden, fmt.Errorf("directory is outside the home directory: %s", absDir)}
+	}
+
+	info, err := os.Stat(absDir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("directory not found: %s", absDir)
+	}
+
+	// Only the base name of the uploaded file is used
+	name := filepath.Base(filepath.Clean("/" + filename))
+	if name == "/" || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid file name: %q", filename)
+	}
+	target := filepath.Join(absDir, name)
+
+	// Prevent directory traversal attacks
+	if !strings.HasPrefix(target, absDir+string(filepath.Separator)) {
+		return "", &uploadError{http.StatusForbidden, fmt.Errorf("invalid file name: %q", filename)}
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", &uploadError{http.StatusForbidden, fmt.Errorf("refusing to write through a symlink: %s", target)}
+	}
+	return target, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2024 Mavis Contributors
+// SPDX-License-Identifier: MIT
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func uploadRequest(t *testing.T, fields map[string]string, filename, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadRequestWithHeaders(t, fields, filename, content, nil)
+}
+
+func uploadRequestWithHeaders(t *testing.T, fields map[string]string, filename, content string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	handleFileUpload(rec, req)
+	return rec
+}
+
+func TestHandleFileUpload(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, "project"), 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	rec := uploadRequest(t, map[string]string{"dir": "~/project"}, "notes.txt", "hello")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	}
+	json.NewDecoder(rec.Body).Decode(&response)
+	expected := filepath.Join(home, "project", "notes.txt")
+	if response.Path != expected || response.Size != 5 {
+		t.Errorf("Expected %s with 5 bytes, got %s with %d", expected, response.Path, response.Size)
+	}
+	if content, _ := os.ReadFile(expected); string(content) != "hello" {
+		t.Errorf("Expected hello, got %q", content)
+	}
+
+	// An existing file is only replaced on request
+	if rec := uploadRequest(t, map[string]string{"dir": "~/project"}, "notes.txt", "again"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d", rec.Code)
+	}
+	if rec := uploadRequest(t, map[string]string{"dir": "~/project", "overwrite": "true"}, "notes.txt", "again"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if content, _ := os.ReadFile(expected); string(content) != "again" {
+		t.Errorf("Expected again, got %q", content)
+	}
+
+	// Directories in the file name are dropped
+	if rec := uploadRequest(t, map[string]string{"dir": "~/project"}, "../../escape.txt", "x"); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(home, "project", "escape.txt")); err != nil {
+		t.Errorf("Expected file saved in the target dir, got %v", err)
+	}
+
+	tests := []struct {
+		dir      string
+		expected int
+	}{
+		{"", http.StatusBadRequest},
+		{"~/missing", http.StatusBadRequest},
+		{"~/project/../..", http.StatusForbidden},
+		{os.TempDir(), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := uploadRequest(t, map[string]string{"dir": tt.dir}, "a.txt", "x"); rec.Code != tt.expected {
+			t.Errorf("%q: expected %d, got %d: %s", tt.dir, tt.expected, rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/upload", nil)
+	rec = httptest.NewRecorder()
+	handleFileUpload(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rec.Code)
+	}
+}
+
+func TestHandleFileUploadSymlinks(t *testing.T) {
+	home := t.TempDir()
+	outside := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Symlink(outside, filepath.Join(home, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// A directory symlink inside home that points outside it is rejected
+	if rec := uploadRequest(t, map[string]string{"dir": "~/link"}, "notes.txt", "x"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a symlinked directory, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(outside, "notes.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written outside home, got %v", err)
+	}
+
+	// So is overwriting a target that is a symlink
+	victim := filepath.Join(outside, "victim.txt")
+	os.WriteFile(victim, []byte("original"), 0644)
+	if err := os.Symlink(victim, filepath.Join(home, "victim.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	rec := uploadRequest(t, map[string]string{"dir": "~", "overwrite": "true"}, "victim.txt", "changed")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a symlinked target, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if content, _ := os.ReadFile(victim); string(content) != "original" {
+		t.Errorf("Expected the symlink target to be untouched, got %q", content)
+	}
+}
+
+func TestHandleFileUploadCrossOrigin(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	for _, headers := range []map[string]string{
+		{"Origin": "https://attacker.example"},
+		{"Referer": "https://attacker.example/page"},
+	} {
+		rec := uploadRequestWithHeaders(t, map[string]string{"dir": "~", "overwrite": "true"}, ".bashrc", "x", headers)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%v: expected 403, got %d", headers, rec.Code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".bashrc")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file written for cross-origin uploads, got %v", err)
+	}
+
+	// httptest requests are addressed to example.com
+	rec := uploadRequestWithHeaders(t, map[string]string{"dir": "~"}, "notes.txt", "x", map[string]string{"Origin": "http://example.com"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected same-origin upload to succeed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
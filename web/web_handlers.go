@@ -16,6 +16,9 @@ import (
 	"strings"
 	"time"
 
+	"mavis/codeagent"
+	"mavis/core"
+
 	g "maragu.dev/gomponents"
 )
 
@@ -24,6 +27,7 @@ type CreateAgentRequest struct {
 	WorkDir      string   `json:"work_dir"`
 	Branch       string   `json:"branch"`
 	SelectedMCPs []string `json:"selected_mcps"`
+	Model        string   `json:"model"`
 }
 
 // Login handler removed - authentication disabled for local network use
@@ -35,9 +39,7 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 	if strings.Contains(r.Header.Get("Accept"), "application/json") {
 		switch path {
 		case "/agents":
-			agents := GetAllAgentsStatusJSON()
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(agents)
+			handleWebAgents(w, r)
 		case "/files":
 			// Return file listing as JSON
 			dir := r.URL.Query().Get("path")
@@ -63,33 +65,10 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For regular page requests, render the full dashboard
-	agents := GetAllAgentsStatusJSON()
+	agents, page := GetAgentsStatusPage(parseAgentPage(r))
 	agentStatuses := make([]AgentStatus, len(agents))
 	for i, agent := range agents {
-		// Get progress for running agents
-		progress := ""
-		plan := ""
-		if agent.Status == "running" || agent.Status == "active" {
-			progress = getAgentProgress(agent.ID)
-			plan = getAgentPlan(agent.ID)
-		}
-
-		agentStatuses[i] = AgentStatus{
-			ID:           agent.ID,
-			Task:         agent.Task,
-			Status:       agent.Status,
-			StartTime:    agent.StartTime,
-			LastActive:   agent.LastActive,
-			MessagesSent: agent.MessagesSent,
-			QueueStatus:  agent.QueueStatus,
-			IsStale:      agent.IsStale,
-			Progress:     progress,
-			Plan:         plan,
-			Output:       agent.Output,
-			Duration:     agent.Duration,
-			Error:        agent.Error,
-			PlanContent:  agent.PlanContent,
-		}
+		agentStatuses[i] = toAgentStatus(agent)
 	}
 
 	// Get query parameters
@@ -104,8 +83,8 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 			// Check if it's a git repository
 			if isGitRepo(absDir) {
 				// Get branches
-				if branchList, err := listGitBranches(absDir); err == nil {
-					branches = branchList
+				if branchList, err := core.ListGitBranches(absDir); err == nil {
+					branches = branchList.Names()
 				}
 			}
 		}
@@ -116,7 +95,7 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 
 	switch path {
 	case "/", "/agents":
-		content = AgentsSection(agentStatuses, modalParam, dirParam, branches)
+		content = AgentsSection(agentStatuses, page, modalParam, dirParam, branches)
 	case "/files":
 		dir := r.URL.Query().Get("path")
 		if dir == "" {
@@ -158,11 +137,13 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 	case "/interactive":
 		content = InteractiveSection(modalParam, dirParam)
 	default:
-		content = AgentsSection(agentStatuses, modalParam, dirParam, branches)
+		content = AgentsSection(agentStatuses, page, modalParam, dirParam, branches)
 	}
 
-	// Only enable auto-refresh on agents page when no modal is open
-	shouldAutoRefresh := modalParam != "create" && (path == "/" || path == "/agents")
+	// Only enable auto-refresh on agents page when no modal is open and the
+	// page is not kept up to date by server-sent events
+	isAgentsPage := path == "/" || path == "/agents"
+	shouldAutoRefresh := isAgentsPage && modalParam != "create" && !liveAgentUpdates(page)
 	if shouldAutoRefresh {
 		_ = DashboardLayout(w, r, content).Render(w)
 	} else {
@@ -170,6 +151,39 @@ func handleDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// toAgentStatus converts a status entry to what the agent cards render,
+// reading the progress and plan of running agents
+func toAgentStatus(agent AgentStatusInfo) AgentStatus {
+	progress := ""
+	plan := ""
+	resources := ""
+	if agent.Status == "running" || agent.Status == "active" {
+		progress = getAgentProgress(agent.ID)
+		plan = getAgentPlan(agent.ID)
+		if stats, err := agentManager.GetAgentResources(agent.ID); err == nil {
+			resources = stats.String()
+		}
+	}
+
+	return AgentStatus{
+		ID:           agent.ID,
+		Task:         agent.Task,
+		Status:       agent.Status,
+		StartTime:    agent.StartTime,
+		LastActive:   agent.LastActive,
+		MessagesSent: agent.MessagesSent,
+		QueueStatus:  agent.QueueStatus,
+		IsStale:      agent.IsStale,
+		Progress:     progress,
+		Plan:         plan,
+		Output:       agent.Output,
+		Duration:     agent.Duration,
+		Error:        agent.Error,
+		PlanContent:  agent.PlanContent,
+		Resources:    resources,
+	}
+}
+
 func handleAgentStatus(w http.ResponseWriter, r *http.Request) {
 	pathParts := strings.Split(r.URL.Path, "/")
 	if len(pathParts) < 4 {
@@ -204,6 +218,76 @@ func handleAgentStatus(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// AgentOutput is the JSON answer of GET /api/agents/{id}/output
+type AgentOutput struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Output   string `json:"output"`
+	Error    string `json:"error"`
+	Plan     string `json:"plan"`
+	Duration string `json:"duration"`
+}
+
+// handleAgentOutput answers GET /api/agents/{id}/output with the agent's
+// full output, error and plan
+func handleAgentOutput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Path is /api/agents/{id}/output
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 4 || pathParts[3] != "output" || pathParts[2] == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	info, err := agentManager.GetAgentInfo(pathParts[2])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(agentOutput(info))
+}
+
+// agentOutput builds the output answer for an agent. Running agents report
+// their CURRENT_PLAN.md as it is now, others the plan kept when they ended.
+func agentOutput(info codeagent.AgentInfo) AgentOutput {
+	result := AgentOutput{
+		ID:     info.ID,
+		Status: string(info.Status),
+		Output: info.Output,
+		Error:  info.Error,
+		Plan:   info.PlanContent,
+	}
+
+	if info.Status == codeagent.StatusRunning {
+		if content, err := os.ReadFile(filepath.Join(info.Folder, "CURRENT_PLAN.md")); err == nil {
+			result.Plan = string(content)
+		}
+		if !info.StartTime.IsZero() {
+			result.Duration = time.Since(info.StartTime).Round(time.Second).String()
+		}
+	} else if info.Duration > 0 {
+		result.Duration = info.Duration.Round(time.Second).String()
+	}
+	return result
+}
+
+// handleConcurrency answers GET /api/concurrency with the global agent limit,
+// the agents counting against it and the tasks waiting for a free slot
+func handleConcurrency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agentManager.GetConcurrencyStatus())
+}
+
 func handleStopAgent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -301,6 +385,7 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 		req.Task = r.FormValue("task")
 		req.WorkDir = r.FormValue("work_dir")
 		req.Branch = r.FormValue("branch")
+		req.Model = strings.TrimSpace(r.FormValue("model"))
 		// Get selected MCPs from form checkboxes
 		r.ParseForm()
 		req.SelectedMCPs = r.Form["selected_mcps"]
@@ -311,7 +396,11 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	agentID, err := createAgentWithBranch(req.Task, req.WorkDir, req.Branch, req.SelectedMCPs)
+	opts := codeagent.AgentOptions{Model: req.Model, Labels: map[string]string{"kind": "code"}}
+	if req.Branch != "" {
+		opts.Labels["kind"] = "branch"
+	}
+	agentID, err := createAgentWithBranch(req.Task, req.WorkDir, req.Branch, req.SelectedMCPs, opts)
 	if err != nil {
 		// Check if this is a form submission
 		if r.Header.Get("Content-Type") != "application/json" {
@@ -327,7 +416,9 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 	// Check if this is a form submission (redirect) or API call (JSON)
 	if r.Header.Get("Content-Type") != "application/json" {
 		// Form submission - redirect to agents page
-		if strings.HasPrefix(agentID, "queued-") {
+		if codeagent.IsWaitingForSlot(agentID) {
+			SetWarningFlash(w, "Agent queued - the maximum number of agents is already running")
+		} else if strings.HasPrefix(agentID, "queued-") {
 			SetWarningFlash(w, "Agent queued - another agent is currently running")
 		} else if strings.HasPrefix(agentID, "preparing-") {
 			SetSuccessFlash(w, "Agent is being prepared with git branch setup. You'll be notified when it's ready.")
@@ -366,6 +457,10 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 		}
+		queueStatus := fmt.Sprintf("Position %s", queuePos)
+		if codeagent.IsWaitingForSlot(agentID) {
+			queueStatus = fmt.Sprintf("Position %s waiting for a free agent slot", queuePos)
+		}
 
 		// Return queued agent info
 		w.Header().Set("Content-Type", "application/json")
@@ -377,7 +472,7 @@ func handleCreateAgent(w http.ResponseWriter, r *http.Request) {
 			"StartTime":     time.Now(),
 			"LastActive":    time.Now(),
 			"MessagesSent":  0,
-			"QueueStatus":   fmt.Sprintf("Position %s", queuePos),
+			"QueueStatus":   queueStatus,
 			"IsStale":       false,
 		})
 		return
@@ -694,6 +789,7 @@ func handlePRReview(w http.ResponseWriter, r *http.Request) {
 		Folder string `json:"folder"`
 		PRURL  string `json:"pr_url"`
 		Action string `json:"action"`
+		Rubric string `json:"rubric"`
 	}
 
 	if r.Header.Get("Content-Type") == "application/json" {
@@ -705,12 +801,20 @@ func handlePRReview(w http.ResponseWriter, r *http.Request) {
 		req.Folder = r.FormValue("folder")
 		req.PRURL = r.FormValue("pr_url")
 		req.Action = r.FormValue("action")
+		req.Rubric = r.FormValue("rubric")
 	}
 
 	if req.Folder == "" {
 		req.Folder = "."
 	}
 
+	rubric, err := core.GetReviewRubric(req.Rubric)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
 	// Resolve the directory path
 	absDir, err := ResolvePath(req.Folder)
 	if err != nil {
@@ -721,7 +825,7 @@ func handlePRReview(w http.ResponseWriter, r *http.Request) {
 
 	// Launch PR review agent
 	ctx := context.Background()
-	launchPRReviewAgent(ctx, absDir, req.PRURL, req.Action)
+	launchPRReviewAgent(ctx, absDir, req.PRURL, req.Action, rubric)
 
 	// Check if this is a form submission (redirect) or API call (JSON)
 	if r.Header.Get("Content-Type") != "application/json" {
@@ -787,9 +891,9 @@ func handleCheckDirectory(w http.ResponseWriter, r *http.Request) {
 
 	if isRepo {
 		// Get list of branches
-		branches, err := listGitBranches(absDir)
+		branches, err := core.ListGitBranches(absDir)
 		if err == nil {
-			response["branches"] = branches
+			response["branches"] = branches.Names()
 		}
 	}
 
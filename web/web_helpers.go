@@ -6,12 +6,14 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +30,7 @@ var (
 type AgentStatusInfo struct {
 	ID           string
 	Task         string
+	Folder       string
 	Status       string
 	StartTime    time.Time
 	LastActive   time.Time
@@ -39,14 +42,118 @@ type AgentStatusInfo struct {
 	Error        string
 	PlanContent  string
 	Command      string
+	Labels       map[string]string
 }
 
 // GetAllAgentsStatusJSON returns status of all active agents for web interface
 func GetAllAgentsStatusJSON() []AgentStatusInfo {
-	result := []AgentStatusInfo{}
+	result := agentStatusInfos(agentManager.ListAgents())
+	result = append(result, queuedStatusInfos()...)
 
-	// Get active agents from manager
-	agents := agentManager.ListAgents()
+	// Note: Preparing agents are temporary and not tracked in agentManager
+	// They will be replaced by actual agents once preparation is complete
+
+	return result
+}
+
+// Agent list paging defaults for the web interface
+const (
+	defaultAgentPageLimit = 50
+	maxAgentPageLimit     = 500
+)
+
+// AgentPage describes one page of the agents list
+type AgentPage struct {
+	Offset int
+	Limit  int
+	Status string // Status filter; empty shows all
+	Query  string // Search text matched against prompts and output; empty shows all
+	Folder string // Only agents working in this folder or below it; empty shows all
+	Total  int    // Number of agents and queued tasks matching the filters
+}
+
+// filtered reports whether the page is narrowed by a search or folder
+func (p AgentPage) filtered() bool {
+	return p.Query != "" || p.Folder != ""
+}
+
+// parseAgentPage reads the offset, limit, status, q and folder query parameters
+func parseAgentPage(r *http.Request) AgentPage {
+	query := r.URL.Query()
+	page := AgentPage{
+		Limit:  defaultAgentPageLimit,
+		Status: query.Get("status"),
+		Query:  strings.TrimSpace(query.Get("q")),
+		Folder: strings.TrimSpace(query.Get("folder")),
+	}
+	if offset, err := strconv.Atoi(query.Get("offset")); err == nil && offset > 0 {
+		page.Offset = offset
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		page.Limit = min(limit, maxAgentPageLimit)
+	}
+	return page
+}
+
+// GetAgentsStatusPage returns one page of agents for the web interface.
+// Running, pending and queued entries are always included; the rest are paged.
+// Search and folder results are not paged.
+func GetAgentsStatusPage(page AgentPage) ([]AgentStatusInfo, AgentPage) {
+	if page.filtered() {
+		return searchAgentsStatus(page)
+	}
+
+	agents, total := agentManager.ListAgentsPaged(page.Offset, page.Limit, page.Status)
+
+	active := 0
+	for active < len(agents) && (agents[active].Status == codeagent.StatusRunning || agents[active].Status == codeagent.StatusPending) {
+		active++
+	}
+
+	result := agentStatusInfos(agents[:active])
+	if page.Status == "" || page.Status == "queued" {
+		queued := queuedStatusInfos()
+		result = append(result, queued...)
+		total += len(queued)
+	}
+	result = append(result, agentStatusInfos(agents[active:])...)
+
+	page.Total = total
+	return result, page
+}
+
+// searchAgentsStatus returns the agents and queued tasks matching page.Query
+// and page.Folder
+func searchAgentsStatus(page AgentPage) ([]AgentStatusInfo, AgentPage) {
+	folder := page.Folder
+	if folder != "" {
+		if resolved, err := ResolvePath(folder); err == nil {
+			folder = resolved
+		}
+	}
+
+	opts := codeagent.SearchOptions{Status: codeagent.AgentStatus(page.Status), Folder: folder}
+	result := agentStatusInfos(agentManager.SearchAgents(page.Query, opts))
+	if page.Status == "" || page.Status == "queued" {
+		query := strings.ToLower(page.Query)
+		for _, task := range queuedStatusInfos() {
+			if folder != "" && !codeagent.InFolder(task.Folder, folder) {
+				continue
+			}
+			if strings.Contains(strings.ToLower(task.Task), query) {
+				result = append(result, task)
+			}
+		}
+	}
+
+	page.Offset = 0
+	page.Total = len(result)
+	return result, page
+}
+
+// agentStatusInfos converts manager agents to web status entries
+func agentStatusInfos(agents []codeagent.AgentInfo) []AgentStatusInfo {
+	result := []AgentStatusInfo{}
 	for _, agent := range agents {
 		status := "active"
 		if agent.Status != "active" {
@@ -59,43 +166,66 @@ func GetAllAgentsStatusJSON() []AgentStatusInfo {
 			command = fullAgent.GetCommandString()
 		}
 
+		lastActive := agent.StartTime
+		if !agent.LastActivity.IsZero() {
+			lastActive = agent.LastActivity
+		}
+
 		result = append(result, AgentStatusInfo{
 			ID:           agent.ID,
 			Task:         agent.Prompt,
+			Folder:       agent.Folder,
 			Status:       status,
 			StartTime:    agent.StartTime,
-			LastActive:   agent.StartTime, // Using StartTime as LastActive for now
-			MessagesSent: 0,               // Not tracked in current implementation
+			LastActive:   lastActive,
+			MessagesSent: 0, // Not tracked in current implementation
 			QueueStatus:  "running",
-			IsStale:      false,
+			IsStale:      agent.IsStale,
 			Output:       agent.Output,
 			Duration:     agent.Duration,
 			Error:        agent.Error,
 			PlanContent:  agent.PlanContent,
 			Command:      command,
+			Labels:       agent.Labels,
 		})
 	}
+	return result
+}
 
-	// Add queued tasks
-	queueStatus := agentManager.GetDetailedQueueStatus()
-	for folder, tasks := range queueStatus {
-		for i, task := range tasks {
+// queuedStatusInfos returns status entries for all queued tasks
+func queuedStatusInfos() []AgentStatusInfo {
+	result := []AgentStatusInfo{}
+	queueStatus := agentManager.GetFolderQueues()
+	for folder, queue := range queueStatus {
+		for i, task := range queue.Tasks {
 			result = append(result, AgentStatusInfo{
 				ID:           task.QueueID,
 				Task:         task.Prompt,
+				Folder:       folder,
 				Status:       "queued",
 				StartTime:    time.Now(), // Use current time as placeholder
 				LastActive:   time.Now(),
 				MessagesSent: 0,
 				QueueStatus:  fmt.Sprintf("Position %d in %s", i+1, folder),
 				IsStale:      false,
+				Labels:       task.Options.Labels,
+			})
+		}
+		for _, task := range queue.WaitingForSlot {
+			result = append(result, AgentStatusInfo{
+				ID:           task.QueueID,
+				Task:         task.Prompt,
+				Folder:       folder,
+				Status:       "queued",
+				StartTime:    time.Now(), // Use current time as placeholder
+				LastActive:   time.Now(),
+				MessagesSent: 0,
+				QueueStatus:  "Waiting for a free agent slot",
+				IsStale:      false,
+				Labels:       task.Options.Labels,
 			})
 		}
 	}
-
-	// Note: Preparing agents are temporary and not tracked in agentManager
-	// They will be replaced by actual agents once preparation is complete
-
 	return result
 }
 
@@ -308,7 +438,7 @@ func stopAgent(agentID string) error {
 	return StopAgent(agentID)
 }
 
-func createCodeAgent(task, workDir string, selectedMCPs []string) (string, error) {
+func createCodeAgent(task, workDir string, selectedMCPs []string, opts codeagent.AgentOptions) (string, error) {
 	if workDir == "" {
 		workDir = "."
 	}
@@ -335,7 +465,7 @@ func createCodeAgent(task, workDir string, selectedMCPs []string) (string, error
 	}
 
 	// Use the agent manager to create the agent
-	agentID, err := agentManager.LaunchAgent(context.Background(), workDir, task)
+	agentID, err := agentManager.LaunchAgentWithOptions(context.Background(), workDir, task, opts)
 	if err != nil {
 		// Restore MCP config if agent launch failed
 		if backupFile != "" {
@@ -348,7 +478,7 @@ func createCodeAgent(task, workDir string, selectedMCPs []string) (string, error
 	// Always set callback if MCP config was created, even if no backup exists
 	if len(selectedMCPs) > 0 {
 		if agent, err := agentManager.GetAgent(agentID); err == nil && agent != nil {
-			agent.SetCompletionCallback(func(a *codeagent.Agent) {
+			agent.AddCompletionCallback(func(a *codeagent.Agent) {
 				// Always clean up MCP config, whether backup exists or not
 				RestoreMCPConfigFile(workDir, backupFile)
 			})
@@ -412,19 +542,31 @@ func handleWebDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Security check - ensure path doesn't escape
-	cleanPath := filepath.Clean(path)
-	if strings.Contains(cleanPath, "..") {
+	if strings.Contains(filepath.Clean(path), "..") {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
 
-	http.ServeFile(w, r, cleanPath)
+	// Only serve files inside the allowed directories
+	absPath, err := ResolvePath(path)
+	if errors.Is(err, core.ErrPathNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid path: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	http.ServeFile(w, r, absPath)
 }
 
-// handleWebAgents returns JSON list of agents
+// handleWebAgents returns JSON list of agents, paged by the offset, limit and
+// status query parameters
 func handleWebAgents(w http.ResponseWriter, r *http.Request) {
-	agents := GetAllAgentsStatusJSON()
+	agents, page := GetAgentsStatusPage(parseAgentPage(r))
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
 	json.NewEncoder(w).Encode(agents)
 }
 
@@ -450,9 +592,9 @@ func GetAllAgentsStatus() []map[string]interface{} {
 	}
 
 	// Add queued tasks
-	queueStatus := agentManager.GetDetailedQueueStatus()
-	for folder, tasks := range queueStatus {
-		for i, task := range tasks {
+	queueStatus := agentManager.GetFolderQueues()
+	for folder, queue := range queueStatus {
+		for i, task := range queue.Tasks {
 			result = append(result, map[string]interface{}{
 				"id":             task.QueueID,
 				"directory":      folder,
@@ -461,6 +603,15 @@ func GetAllAgentsStatus() []map[string]interface{} {
 				"queue_position": i + 1,
 			})
 		}
+		for _, task := range queue.WaitingForSlot {
+			result = append(result, map[string]interface{}{
+				"id":               task.QueueID,
+				"directory":        folder,
+				"task":             task.Prompt,
+				"status":           "queued",
+				"waiting_for_slot": true,
+			})
+		}
 	}
 
 	return result
@@ -566,26 +717,12 @@ func CreateCodeAgent(ctx context.Context, workDir, task string, images []string)
 			queueTracker.RegisterQueuedAgent(queueID, AdminUserID, workDir, task)
 		}
 
-		// SSE removed - using meta refresh instead
-		// BroadcastSSEEvent("queue_update", map[string]interface{}{
-		// 	"directory":      workDir,
-		// 	"queue_position": queuePos,
-		// 	"total_queued":   queuedTasks,
-		// })
-
 		return agentID, nil
 	}
 
 	// Register the agent for user (only for non-queued agents)
 	RegisterAgentForUser(agentID, AdminUserID)
 
-	// SSE removed - using meta refresh instead
-	// BroadcastSSEEvent("agent_started", map[string]interface{}{
-	// 	"agent_id":  agentID,
-	// 	"directory": workDir,
-	// 	"task":      task,
-	// })
-
 	// Send Telegram notification about the agent launch
 	if b != nil && AdminUserID != 0 {
 		message := fmt.Sprintf("🌐 Code agent launched from Web UI!\n🆔 ID: `%s`\n📝 Task: %s\n📁 Directory: %s\n\nUse `/status %s` to check status.",
@@ -726,9 +863,10 @@ func runCommand(workDir, command string, args ...string) (string, error) {
 
 // checkUsageLimits checks if we've hit API usage limits
 func checkUsageLimits() error {
-	// This is a placeholder - implement actual usage limit checking
-	// based on your requirements
-	return nil
+	if agentManager == nil {
+		return nil
+	}
+	return agentManager.CheckTokenBudget()
 }
 
 // isGitRepo checks if a directory is a git repository
@@ -781,7 +919,7 @@ First, ensure you're on the correct branch and push it to the remote if needed.
 	fmt.Printf("Launched PR create agent with ID: %s for folder: %s\n", agentID, folder)
 }
 
-func launchPRReviewAgent(ctx context.Context, folder, prURL, action string) {
+func launchPRReviewAgent(ctx context.Context, folder, prURL, action string, rubric core.ReviewRubric) {
 	// Create the task for the PR review agent based on action
 	var task string
 	switch action {
@@ -896,6 +1034,7 @@ PR URL: %s`, prURL, prURL, prURL, prURL, prURL, prURL)
 	default:
 		task = fmt.Sprintf("Please review the pull request at %s and provide feedback.", prURL)
 	}
+	task = rubric.Apply(task)
 
 	// Launch the agent using the agent manager
 	agentID, err := agentManager.LaunchAgent(ctx, folder, task)
@@ -914,35 +1053,7 @@ func RegisterAgentForUser(agentID string, userID int64) {
 	fmt.Printf("Agent %s started (single-user mode)\n", agentID)
 }
 
-// checkBranchExists checks if a branch exists locally or remotely
-func checkBranchExists(workDir, branch string) (bool, error) {
-	// Check if the branch exists locally
-	cmd := exec.Command("git", "branch", "--list", branch)
-	cmd.Dir = workDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return false, fmt.Errorf("failed to check local branches: %v", err)
-	}
-
-	// Check if branch exists locally
-	if len(strings.TrimSpace(string(output))) > 0 {
-		return true, nil
-	}
-
-	// Check remote branches
-	cmd = exec.Command("git", "branch", "-r", "--list", fmt.Sprintf("origin/%s", branch))
-	cmd.Dir = workDir
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		return false, fmt.Errorf("failed to check remote branches: %v", err)
-	}
-
-	// Return true if branch exists remotely
-	return len(strings.TrimSpace(string(output))) > 0, nil
-}
-
-// createAgentWithBranch creates an agent with appropriate git behavior based on branch parameter
-func createAgentWithBranch(task, workDir, branch string, selectedMCPs []string) (string, error) {
+func createAgentWithBranch(task, workDir, branch string, selectedMCPs []string, opts codeagent.AgentOptions) (string, error) {
 	if workDir == "" {
 		workDir = "."
 	}
@@ -956,12 +1067,12 @@ func createAgentWithBranch(task, workDir, branch string, selectedMCPs []string)
 
 	// If no branch specified, use default code behavior
 	if branch == "" {
-		return createCodeAgent(task, workDir, selectedMCPs)
+		return createCodeAgent(task, workDir, selectedMCPs, opts)
 	}
 
-	// Check if it's a git repository
-	if !isGitRepo(workDir) {
-		return "", fmt.Errorf("branch specified but directory is not a git repository: %s", workDir)
+	// Check that it's a git repository before handing off to the background
+	if _, err := core.ValidateGitRepo(workDir); err != nil {
+		return "", fmt.Errorf("branch specified but %v", err)
 	}
 
 	// Generate a temporary agent ID for tracking
@@ -969,10 +1080,10 @@ func createAgentWithBranch(task, workDir, branch string, selectedMCPs []string)
 
 	// Launch background goroutine to handle git operations
 	go func(selectedMCPs []string) {
-		// Create a temp directory for git operations
-		tempDir, err := os.MkdirTemp("", "git-agent-*")
+		// Check out a worktree, or copy the repository if it has uncommitted changes
+		workspace, err := core.PrepareGitAgentWorkspace(workDir, branch, task)
 		if err != nil {
-			log.Printf("Failed to create temp directory: %v", err)
+			log.Printf("Failed to prepare workspace: %v", err)
 			// Send error notification if possible
 			if b != nil && AdminUserID != 0 {
 				message := fmt.Sprintf("❌ Failed to prepare git agent:\n%v", err)
@@ -980,94 +1091,18 @@ func createAgentWithBranch(task, workDir, branch string, selectedMCPs []string)
 			}
 			return
 		}
-
-		// Copy the repository to temp directory
-		cmd := exec.Command("rsync", "-av", "--exclude=node_modules", "--exclude=.DS_Store", workDir+"/", tempDir+"/")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			os.RemoveAll(tempDir)
-			log.Printf("Failed to copy repository: %v\nOutput: %s", err, string(output))
-			// Send error notification if possible
-			if b != nil && AdminUserID != 0 {
-				message := fmt.Sprintf("❌ Failed to prepare git agent:\nFailed to copy repository: %v", err)
-				core.SendMessage(context.Background(), b, AdminUserID, message)
-			}
-			return
-		}
-
-		// Check if branch exists
-		branchExists, err := checkBranchExists(tempDir, branch)
-		if err != nil {
-			os.RemoveAll(tempDir)
-			log.Printf("Failed to check branch: %v", err)
-			// Send error notification if possible
-			if b != nil && AdminUserID != 0 {
-				message := fmt.Sprintf("❌ Failed to prepare git agent:\nFailed to check branch: %v", err)
-				core.SendMessage(context.Background(), b, AdminUserID, message)
-			}
-			return
-		}
-
-		var gitPrompt string
-		if branchExists {
-			// Use edit_branch behavior
-			gitPrompt = fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
-You are working on a git repository with an existing branch. You MUST follow these steps:
-
-1. First, fetch the latest changes: git fetch origin
-2. Checkout the existing branch '%s' using: git checkout %s
-3. If the branch exists only on remote, use: git checkout -b %s origin/%s
-4. Pull the latest changes from the remote branch: git pull origin %s
-5. Make all the necessary changes to complete the task: %s
-6. Stage and commit your changes with a descriptive commit message
-   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
-   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
-   - Or stage files individually, explicitly excluding *_PLAN_*.md files
-7. Try to push the changes to the remote repository using: git push origin %s
-8. If the push fails due to authentication or permissions, that's okay - just report the status
-
-Remember:
-- You are working on the existing branch '%s'
-- Make atomic, well-described commits
-- Include a clear commit message explaining what was changed and why
-- Ensure you're up to date with the remote branch before making changes
-- NEVER commit *_PLAN_*.md files - they're for your planning only
-
-Task: %s`, branch, branch, branch, branch, branch, task, branch, branch, task)
-		} else {
-			// Use new_branch behavior - create feature branch
-			branchName := branch
-			if !strings.HasPrefix(branch, "feature/") {
-				branchName = fmt.Sprintf("feature/%s", branch)
-			}
-
-			gitPrompt = fmt.Sprintf(`IMPORTANT GIT WORKFLOW INSTRUCTIONS:
-You are working on a git repository. You MUST follow these steps:
-
-1. First, create a new branch for your changes using: git checkout -b %s
-2. Make all the necessary changes to complete the task: %s
-3. Stage and commit your changes with a descriptive commit message
-   IMPORTANT: When staging files, NEVER include *_PLAN_*.md files. Use commands like:
-   - git add . && git reset *_PLAN_*.md  (to add all except plan files)
-   - Or stage files individually, explicitly excluding *_PLAN_*.md files
-4. Try to push the branch to the remote repository using: git push -u origin %s
-5. If the push fails due to authentication or permissions, that's okay - just report the status
-
-Remember:
-- Always work on the new branch '%s', never directly on main/master
-- Make atomic, well-described commits
-- Include a clear commit message explaining what was changed and why
-- NEVER commit *_PLAN_*.md files - they're for your planning only
-
-Task: %s`, branchName, task, branchName, branchName, task)
+		tempDir := workspace.Dir
+		if workspace.Warning != "" && b != nil && AdminUserID != 0 {
+			core.SendMessage(context.Background(), b, AdminUserID, "⚠️ "+workspace.Warning)
 		}
+		branchExists := workspace.ExistingBranch
 
 		// Create MCP config file if MCPs are selected
 		var backupFile string
 		if len(selectedMCPs) > 0 {
 			// First verify MCP servers are available
 			if err := VerifyMCPServers(selectedMCPs, mcpStore, tempDir); err != nil {
-				os.RemoveAll(tempDir)
+				workspace.Cleanup()
 				log.Printf("MCP server verification failed: %v", err)
 				// Send error notification if possible
 				if b != nil && AdminUserID != 0 {
@@ -1079,7 +1114,7 @@ Task: %s`, branchName, task, branchName, branchName, task)
 
 			backupFile, err = CreateMCPConfigFile(tempDir, selectedMCPs, mcpStore)
 			if err != nil {
-				os.RemoveAll(tempDir)
+				workspace.Cleanup()
 				log.Printf("Failed to create MCP config: %v", err)
 				// Send error notification if possible
 				if b != nil && AdminUserID != 0 {
@@ -1090,14 +1125,30 @@ Task: %s`, branchName, task, branchName, branchName, task)
 			}
 		}
 
+		// Set up cleanup for when the agent finishes, registered once the
+		// agent is created so launches that wait in a queue are covered too.
+		// The MCP config is restored before the workspace is removed.
+		opts.OnCreate = func(agent *codeagent.Agent) {
+			agent.AddCompletionCallback(func(a *codeagent.Agent) {
+				// Always clean up MCP config, whether backup exists or not
+				if len(selectedMCPs) > 0 {
+					RestoreMCPConfigFile(tempDir, backupFile)
+				}
+				// Commits made in a worktree stay in the repository
+				if err := workspace.Cleanup(); err != nil {
+					log.Printf("[Worktree] Failed to clean up workspace for agent %s: %v", a.ID, err)
+				}
+			})
+		}
+
 		// Launch the agent with the git-specific prompt
-		agentID, err := agentManager.LaunchAgent(context.Background(), tempDir, gitPrompt)
+		agentID, err := agentManager.LaunchAgentWithOptions(context.Background(), tempDir, workspace.Prompt, opts)
 		if err != nil {
 			// Restore MCP config if needed
 			if backupFile != "" {
 				RestoreMCPConfigFile(tempDir, backupFile)
 			}
-			os.RemoveAll(tempDir)
+			workspace.Cleanup()
 			log.Printf("Failed to launch agent: %v", err)
 			// Send error notification if possible
 			if b != nil && AdminUserID != 0 {
@@ -1107,15 +1158,17 @@ Task: %s`, branchName, task, branchName, branchName, task)
 			return
 		}
 
-		// Set up cleanup callback for when agent finishes
-		if agent, err := agentManager.GetAgent(agentID); err == nil && agent != nil {
-			agent.SetCompletionCallback(func(a *codeagent.Agent) {
-				// Always clean up MCP config, whether backup exists or not
-				if len(selectedMCPs) > 0 {
-					RestoreMCPConfigFile(tempDir, backupFile)
-				}
-				// Note: tempDir cleanup is handled elsewhere
-			})
+		// A queued agent is announced by the start callback once it starts
+		if strings.HasPrefix(agentID, "queued-") {
+			if _, queueID, found := strings.Cut(agentID, "-qid-"); found {
+				queueTracker.RegisterQueuedAgent(queueID, AdminUserID, tempDir, task)
+			}
+			if b != nil && AdminUserID != 0 {
+				message := fmt.Sprintf("⏳ Git-aware code agent queued!\n📝 Task: %s\n🌿 Branch: %s\n📁 Original: %s\n📁 Workspace: %s\n\nThe agent will start automatically when a running agent completes.",
+					task, branch, workDir, tempDir)
+				core.SendMessage(context.Background(), b, AdminUserID, message)
+			}
+			return
 		}
 
 		// Send success notification
@@ -1134,53 +1187,3 @@ Task: %s`, branchName, task, branchName, branchName, task)
 	return tempAgentID, nil
 }
 
-// listGitBranches returns a list of all branches (local and remote) in a git repository
-func listGitBranches(workDir string) ([]string, error) {
-	branches := []string{}
-
-	// Get local branches
-	cmd := exec.Command("git", "branch", "--format=%(refname:short)")
-	cmd.Dir = workDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list local branches: %v", err)
-	}
-
-	// Parse local branches
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		branch := strings.TrimSpace(line)
-		if branch != "" {
-			branches = append(branches, branch)
-		}
-	}
-
-	// Get remote branches
-	cmd = exec.Command("git", "branch", "-r", "--format=%(refname:short)")
-	cmd.Dir = workDir
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		// If remote fetch fails, just return local branches
-		return branches, nil
-	}
-
-	// Parse remote branches and add unique ones
-	branchMap := make(map[string]bool)
-	for _, branch := range branches {
-		branchMap[branch] = true
-	}
-
-	lines = strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		branch := strings.TrimSpace(line)
-		// Remove "origin/" prefix if present
-		branch = strings.TrimPrefix(branch, "origin/")
-		// Skip HEAD reference
-		if branch != "" && branch != "HEAD" && !branchMap[branch] {
-			branches = append(branches, branch)
-			branchMap[branch] = true
-		}
-	}
-
-	return branches, nil
-}
@@ -4,8 +4,15 @@
 package web
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"mavis/core"
 )
 
 // Commenting out test that uses undefined variables and types
@@ -459,3 +466,39 @@ func TestAgentStatusMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleWebDownloadAllowedDirs(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(allowed, "report.txt"), []byte("report"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("top secret contents"), 0644)
+
+	if err := core.SetAllowedDirs([]string{allowed}); err != nil {
+		t.Fatalf("Failed to set allowed dirs: %v", err)
+	}
+	t.Cleanup(func() { core.SetAllowedDirs(nil) })
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{filepath.Join(allowed, "report.txt"), http.StatusOK, "report"},
+		{filepath.Join(outside, "secret.txt"), http.StatusForbidden, ""},
+		{"/etc/passwd", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/files/download?path="+url.QueryEscape(tt.path), nil)
+		rec := httptest.NewRecorder()
+		handleWebDownload(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, rec.Code)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "top secret contents") {
+			t.Errorf("%s: expected the file outside the allowed dirs not to be served", tt.path)
+		}
+	}
+}
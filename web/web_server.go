@@ -13,18 +13,9 @@ import (
 
 var (
 	webServer *http.Server
-	// SSE removed - using meta refresh instead
-	// sseClients   = make(map[chan SSEEvent]bool)
-	// sseBroadcast = make(chan SSEEvent)
 	// Authentication removed - running on local network only
 )
 
-// SSE removed - using meta refresh instead
-// type SSEEvent struct {
-// 	Type string      `json:"type"`
-// 	Data interface{} `json:"data"`
-// }
-
 func StartWebServer(port string) error {
 	mux := http.NewServeMux()
 
@@ -50,21 +41,28 @@ func StartWebServer(port string) error {
 	mux.HandleFunc("/api/git/pr/review", handlePRReview)
 	mux.HandleFunc("/api/git/check-directory", handleCheckDirectory)
 	mux.HandleFunc("/api/files/download", handleWebDownload)
+	mux.HandleFunc("/upload", handleFileUpload)
 	mux.HandleFunc("/api/command/run", handleWebRunCommand)
 	mux.HandleFunc("/api/images", handleImageUpload)
 	mux.HandleFunc("/api/system/restart", handleWebRestart)
 
 	// JSON API endpoints
 	mux.HandleFunc("/api/agents", handleWebAgents)
+	mux.HandleFunc("/api/agents/", handleAgentOutput)
+	mux.HandleFunc("/api/concurrency", handleConcurrency)
 	mux.HandleFunc("/api/mcps", handleMCPRoutes)
+
+	// Prometheus metrics
+	RegisterMetrics(mux)
 	
 	// Interactive agent endpoints
 	mux.HandleFunc("/api/interactive", handleInteractiveRoutes)
 	mux.HandleFunc("/api/interactive/", handleInteractiveAgentAction)
 	mux.HandleFunc("/stream/interactive/", handleInteractiveStream)
+	mux.HandleFunc("/interactive/", handleInteractiveSocketRoute)
 
-	// SSE removed - using meta refresh instead
-	// mux.HandleFunc("/events", handleSSE)
+	// Server-sent events for live agent updates
+	mux.HandleFunc("/events", handleEvents)
 
 	// Authentication removed - running on local network only
 
@@ -77,10 +75,6 @@ func StartWebServer(port string) error {
 		IdleTimeout:  5 * time.Minute,
 	}
 
-	// SSE removed - using meta refresh instead
-	// go sseEventBroadcaster()
-	// go agentStatusBroadcaster()
-
 	log.Printf("Starting web server on port %s", port)
 	return webServer.ListenAndServe()
 }
@@ -287,106 +281,4 @@ func handleMCPRoutes(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SSE removed - using meta refresh instead
-// func sseEventBroadcaster() {
-// 	for {
-// 		event := <-sseBroadcast
-// 		for client := range sseClients {
-// 			select {
-// 			case client <- event:
-// 			default:
-// 				// Client's channel is full, close it
-// 				close(client)
-// 				delete(sseClients, client)
-// 			}
-// 		}
-// 	}
-// }
-
-// SSE removed - using meta refresh instead
-// func BroadcastSSEEvent(eventType string, data interface{}) {
-// 	select {
-// 	case sseBroadcast <- SSEEvent{Type: eventType, Data: data}:
-// 	default:
-// 		// Broadcast channel is full, skip
-// 	}
-// }
-
-// SSE removed - using meta refresh instead
-// func agentStatusBroadcaster() {
-// 	ticker := time.NewTicker(2 * time.Second)
-// 	defer ticker.Stop()
-//
-// 	for range ticker.C {
-// 		agents := GetAllAgentsStatusJSON()
-// 		// Broadcast agent status updates
-// 		BroadcastSSEEvent("agents-update", agents)
-// 	}
-// }
-
-// SSE removed - using meta refresh instead
-// func handleSSE(w http.ResponseWriter, r *http.Request) {
-// 	// Set headers for SSE
-// 	w.Header().Set("Content-Type", "text/event-stream")
-// 	w.Header().Set("Cache-Control", "no-cache")
-// 	w.Header().Set("Connection", "keep-alive")
-// 	w.Header().Set("X-Accel-Buffering", "no") // Disable Nginx buffering
-//
-// 	// Create client channel
-// 	clientChan := make(chan SSEEvent, 10)
-// 	sseClients[clientChan] = true
-//
-// 	// Remove client on disconnect
-// 	defer func() {
-// 		delete(sseClients, clientChan)
-// 		close(clientChan)
-// 	}()
-//
-// 	// Get flusher
-// 	flusher, ok := w.(http.Flusher)
-// 	if !ok {
-// 		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-// 		return
-// 	}
-//
-// 	// Send initial connection event
-// 	fmt.Fprintf(w, "event: connected\ndata: {\"message\":\"Connected to Mavis\"}\n\n")
-// 	flusher.Flush()
-//
-// 	// Send initial agents data
-// 	agents := GetAllAgentsStatusJSON()
-// 	data, _ := json.Marshal(agents)
-// 	fmt.Fprintf(w, "event: agents-update\ndata: %s\n\n", data)
-// 	flusher.Flush()
-//
-// 	// Create a ticker for heartbeat
-// 	heartbeat := time.NewTicker(30 * time.Second)
-// 	defer heartbeat.Stop()
-//
-// 	// Listen for events
-// 	for {
-// 		select {
-// 		case event := <-clientChan:
-// 			data, _ := json.Marshal(event.Data)
-// 			_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
-// 			if err != nil {
-// 				log.Printf("SSE write error: %v", err)
-// 				return
-// 			}
-// 			flusher.Flush()
-// 		case <-heartbeat.C:
-// 			// Send heartbeat to keep connection alive
-// 			_, err := fmt.Fprintf(w, ": heartbeat\n\n")
-// 			if err != nil {
-// 				log.Printf("SSE heartbeat error: %v", err)
-// 				return
-// 			}
-// 			flusher.Flush()
-// 		case <-r.Context().Done():
-// 			log.Println("SSE client disconnected")
-// 			return
-// 		}
-// 	}
-// }
-
 // Authentication functions removed - running on local network only